@@ -24,4 +24,18 @@ env "prod" {
       diff = "{{ sql . \"  \" }}"
     }
   }
-}
\ No newline at end of file
+}
+
+env "sqlite" {
+  src = "file://internal/store/sqlite/schema.sql"
+  url = env("DATABASE_URL")
+  dev = "sqlite://dev?mode=memory"
+  migration {
+    dir = "file://migrations/sqlite"
+  }
+  format {
+    migrate {
+      diff = "{{ sql . \"  \" }}"
+    }
+  }
+}
@@ -0,0 +1,162 @@
+// Package main imports users in bulk from a CSV or NDJSON file, the command
+// line equivalent of the admin imports page. It runs the import in process
+// rather than queueing it, so files of any size finish without the job
+// timeout. Invitations are queued for the web server's job workers.
+//
+// Usage:
+//
+//	import-users [-format csv|ndjson] [-mode atomic|best_effort] [-dry-run] [-actor email] file
+//
+// Pass "-" as the file to read standard input. Row errors are printed to
+// standard output and progress to standard error; the exit status is 1 when
+// any row failed.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/dunamismax/go-web-server/internal/config"
+	"github.com/dunamismax/go-web-server/internal/handler"
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/store/sqlite"
+	"github.com/dunamismax/go-web-server/internal/userimport"
+)
+
+// progressInterval is how often progress is printed.
+const progressInterval = time.Second
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "import-users:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	format := flag.String("format", "", "file format, csv or ndjson (default: from the file name)")
+	mode := flag.String("mode", string(userimport.ModeAtomic), "atomic creates every user or none; best_effort creates every valid row")
+	dryRun := flag.Bool("dry-run", false, "validate every row without creating users")
+	actor := flag.String("actor", "", "email of the admin the import is audited against")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return errors.New("expected one file")
+	}
+	path := flag.Arg(0)
+
+	switch userimport.Mode(*mode) {
+	case userimport.ModeAtomic, userimport.ModeBestEffort:
+	default:
+		return fmt.Errorf("unknown mode %q", *mode)
+	}
+
+	fileFormat := userimport.Format(*format)
+	if fileFormat == "" {
+		var ok bool
+		if fileFormat, ok = userimport.FormatForFilename(path); !ok {
+			return errors.New("cannot tell the format from the file name; pass -format")
+		}
+	}
+
+	input := os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	rows, err := userimport.Decode(input, fileFormat)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg := config.New()
+	db, err := openStore(ctx, cfg.Database.URL)
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	defer db.Close()
+
+	var actorID *int64
+	if *actor != "" {
+		user, err := db.GetUserByEmail(ctx, *actor)
+		if err != nil {
+			return fmt.Errorf("look up actor %s: %w", *actor, err)
+		}
+		actorID = &user.ID
+	}
+
+	var lastProgress time.Time
+	importer := userimport.New(db, middleware.NewSessionAuthService(scs.New()), handler.RecordImportedUser)
+	result, err := importer.Run(ctx, rows, userimport.Options{
+		Mode:    userimport.Mode(*mode),
+		DryRun:  *dryRun,
+		ActorID: actorID,
+		Progress: func(result userimport.Result) {
+			if time.Since(lastProgress) < progressInterval && result.Processed < result.Total {
+				return
+			}
+			lastProgress = time.Now()
+			fmt.Fprintf(os.Stderr, "%d/%d rows processed, %d created, %d failed\n",
+				result.Processed, result.Total, result.Created, result.Failed)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, rowErr := range result.Errors {
+		fmt.Println(rowErr.Error())
+	}
+
+	switch {
+	case *dryRun:
+		fmt.Fprintf(os.Stderr, "Dry run: %d of %d rows are valid\n", result.Total-result.Failed, result.Total)
+	case userimport.Mode(*mode) == userimport.ModeAtomic && result.Failed > 0:
+		fmt.Fprintf(os.Stderr, "%d rows have errors; no users were created\n", result.Failed)
+	default:
+		fmt.Fprintf(os.Stderr, "Created %d of %d users\n", result.Created, result.Total)
+	}
+
+	if result.Failed > 0 {
+		return fmt.Errorf("%d rows failed", result.Failed)
+	}
+
+	return nil
+}
+
+// importStore is what the import needs from either storage backend.
+type importStore interface {
+	store.TxQuerier
+	Close()
+}
+
+// openStore connects to the backend named by the database URL's scheme.
+func openStore(ctx context.Context, databaseURL string) (importStore, error) {
+	backend, err := store.BackendForURL(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if backend == store.BackendSQLite {
+		return sqlite.Open(ctx, databaseURL, store.PoolConfig{})
+	}
+
+	return store.NewStore(ctx, databaseURL)
+}
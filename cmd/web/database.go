@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/alexedwards/scs/pgxstore"
+	"github.com/alexedwards/scs/sqlite3store"
+	"github.com/alexedwards/scs/v2"
+	"github.com/dunamismax/go-web-server/internal/events"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/store/sqlite"
+	"github.com/dunamismax/go-web-server/migrations"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// database is the storage backend selected by DATABASE_URL. Handlers and
+// workers only see its store.TxQuerier side, so they run unchanged on
+// either backend.
+type database interface {
+	store.TxQuerier
+	store.Locker
+
+	ConsumeRateLimitToken(ctx context.Context, key string, capacity, refillPerSecond float64, ttl time.Duration) (float64, bool, error)
+	Ping(ctx context.Context) error
+	PoolSaturation() float64
+	PoolStats() store.PoolStats
+	QueryStats() *store.QueryStats
+	AppliedMigrationVersion(ctx context.Context) (string, error)
+	InitSchema(ctx context.Context) error
+	Close()
+}
+
+var (
+	_ database = (*store.Store)(nil)
+	_ database = (*sqlite.Store)(nil)
+)
+
+// openDatabase connects to the backend named by the database URL's scheme.
+func openDatabase(ctx context.Context, databaseURL string, poolConfig store.PoolConfig) (database, error) {
+	backend, err := store.BackendForURL(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch backend {
+	case store.BackendSQLite:
+		return sqlite.Open(ctx, databaseURL, poolConfig)
+	default:
+		return store.NewStoreWithConfig(ctx, databaseURL, poolConfig)
+	}
+}
+
+// newSessionStore keeps sessions in the same database as everything else.
+// Expired sessions are purged by the scheduler, so the stores' own cleanup
+// loops, which would run on every replica, stay off.
+func newSessionStore(db database) scs.Store {
+	switch db := db.(type) {
+	case *sqlite.Store:
+		return sqlite3store.NewWithCleanupInterval(db.DB(), 0)
+	case *store.Store:
+		return pgxstore.NewWithCleanupInterval(db.DB(), 0)
+	default:
+		panic(fmt.Sprintf("no session store for %T", db))
+	}
+}
+
+// newPoolCollector exports the backend's connection pool statistics.
+func newPoolCollector(db database) prometheus.Collector {
+	if db, ok := db.(*sqlite.Store); ok {
+		return collectors.NewDBStatsCollector(db.DB(), "sqlite")
+	}
+
+	return store.NewPoolStatsCollector(db.(*store.Store).DB())
+}
+
+// latestMigrationVersion returns the newest migration embedded for db's backend.
+func latestMigrationVersion(db database) (string, error) {
+	if _, ok := db.(*sqlite.Store); ok {
+		return migrations.LatestSQLiteVersion()
+	}
+
+	return migrations.LatestVersion()
+}
+
+// databaseTarget describes the database for logs without its credentials.
+func databaseTarget(databaseURL string) string {
+	if backend, err := store.BackendForURL(databaseURL); err == nil && backend == store.BackendSQLite {
+		u, err := url.Parse(databaseURL)
+		if err != nil {
+			return "configured-database"
+		}
+		if u.Opaque != "" {
+			return u.Opaque
+		}
+		return u.Host + u.Path
+	}
+
+	cfg, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return "configured-database"
+	}
+
+	return fmt.Sprintf("%s:%d/%s", cfg.ConnConfig.Host, cfg.ConnConfig.Port, cfg.ConnConfig.Database)
+}
+
+// newEventBus publishes changes with NOTIFY on PostgreSQL so open pages on
+// every replica hear them. SQLite deployments run a single node, whose bus
+// delivers in process.
+func newEventBus(db database) *events.Bus {
+	if db, ok := db.(*store.Store); ok {
+		return events.NewPostgresBus(db)
+	}
+
+	return events.NewBus()
+}
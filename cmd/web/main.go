@@ -10,18 +10,30 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
-	"github.com/alexedwards/scs/pgxstore"
 	"github.com/alexedwards/scs/v2"
+	"github.com/dunamismax/go-web-server/internal/avatar"
 	"github.com/dunamismax/go-web-server/internal/config"
 	"github.com/dunamismax/go-web-server/internal/handler"
+	"github.com/dunamismax/go-web-server/internal/health"
+	"github.com/dunamismax/go-web-server/internal/jobs"
 	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/privacy"
+	"github.com/dunamismax/go-web-server/internal/scheduler"
+	"github.com/dunamismax/go-web-server/internal/server"
+	"github.com/dunamismax/go-web-server/internal/storage"
 	"github.com/dunamismax/go-web-server/internal/store"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/dunamismax/go-web-server/internal/telemetry"
+	"github.com/dunamismax/go-web-server/internal/userimport"
+	"github.com/dunamismax/go-web-server/internal/webhooks"
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 //go:generate go install github.com/a-h/templ/cmd/templ@v0.3.1001
@@ -34,18 +46,19 @@ func main() {
 	cfg := config.New()
 
 	// Setup structured logging
-	var logger *slog.Logger
+	var logHandler slog.Handler
 	if cfg.App.LogFormat == "json" {
-		logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		logHandler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 			Level: cfg.GetLogLevel(),
-		}))
+		})
 	} else {
-		logger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		logHandler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 			Level: cfg.GetLogLevel(),
-		}))
+		})
 	}
 
-	slog.SetDefault(logger)
+	// Records logged with a request context carry trace_id and span_id.
+	slog.SetDefault(slog.New(telemetry.NewLogHandler(logHandler)))
 
 	slog.Info("Starting Go Web Server",
 		"version", "1.0.0",
@@ -57,15 +70,43 @@ func main() {
 	// Create context for database operations
 	ctx := context.Background()
 
+	if cfg.Tracing.Enabled {
+		shutdownTracing, err := telemetry.Setup(ctx, telemetry.Config{
+			ServiceName:    "go-web-server",
+			ServiceVersion: "1.0.0",
+			Environment:    cfg.App.Environment,
+			Exporter:       cfg.Tracing.Exporter,
+			Endpoint:       cfg.Tracing.Endpoint,
+			FilePath:       cfg.Tracing.FilePath,
+			SampleRatio:    cfg.Tracing.SampleRatio,
+		})
+		if err != nil {
+			slog.Error("failed to configure tracing", "error", err)
+			return
+		}
+		defer func() {
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(flushCtx); err != nil {
+				slog.Warn("failed to flush traces", "error", err)
+			}
+		}()
+
+		slog.Info("Tracing enabled", "exporter", cfg.Tracing.Exporter, "sample_ratio", cfg.Tracing.SampleRatio)
+	}
+
 	// Initialize database store with configurable pool settings
 	poolConfig := store.PoolConfig{
-		MaxConns:        cfg.Database.MaxConnections,
-		MinConns:        cfg.Database.MinConnections,
-		MaxConnLifetime: cfg.Database.MaxConnLifetime,
-		MaxConnIdleTime: cfg.Database.MaxConnIdleTime,
+		MaxConns:           cfg.Database.MaxConnections,
+		MinConns:           cfg.Database.MinConnections,
+		MaxConnLifetime:    cfg.Database.MaxConnLifetime,
+		MaxConnIdleTime:    cfg.Database.MaxConnIdleTime,
+		QueryTimeout:       cfg.Database.Timeout,
+		SlowQueryThreshold: cfg.Database.SlowQueryThreshold,
 	}
 
-	store, err := store.NewStoreWithConfig(ctx, cfg.Database.URL, poolConfig)
+	// The backend is chosen by the URL scheme: postgres:// or sqlite://.
+	store, err := openDatabase(ctx, cfg.Database.URL, poolConfig)
 	if err != nil {
 		slog.Error("failed to connect to database", "error", err, "database_target", databaseTarget(cfg.Database.URL))
 		return
@@ -89,6 +130,13 @@ func main() {
 		slog.Info("Skipping schema bootstrap", "database_target", databaseTarget(cfg.Database.URL))
 	}
 
+	// Readiness checks shared by the public and admin probes
+	healthRegistry, err := newHealthRegistry(cfg, store)
+	if err != nil {
+		slog.Error("failed to configure health checks", "error", err)
+		return
+	}
+
 	// Create Echo instance
 	e := echo.New()
 	e.HideBanner = true
@@ -104,6 +152,9 @@ func main() {
 	// Configure custom error handler
 	e.HTTPErrorHandler = middleware.ErrorHandler
 
+	// Binder refuses unknown JSON fields when server.json asks for it
+	e.Binder = &middleware.Binder{}
+
 	// Set custom 404 and 405 handlers
 	e.RouteNotFound("/*", middleware.NotFoundHandler)
 	e.Add("*", "/*", middleware.MethodNotAllowedHandler)
@@ -111,20 +162,91 @@ func main() {
 	// Configure timeouts
 	e.Server.ReadTimeout = cfg.Server.ReadTimeout
 	e.Server.WriteTimeout = cfg.Server.WriteTimeout
+	e.TLSServer.ReadTimeout = cfg.Server.ReadTimeout
+	e.TLSServer.WriteTimeout = cfg.Server.WriteTimeout
+
+	// Native TLS with certificates reloaded from disk on renewal
+	var certReloader *server.CertReloader
+	if cfg.Server.TLS.Enabled {
+		certReloader, err = server.NewCertReloader(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+		if err != nil {
+			slog.Error("failed to load TLS certificate", "error", err)
+			return
+		}
+
+		e.TLSServer.TLSConfig, err = server.NewTLSConfig(server.TLSConfig{
+			CertFile:     cfg.Server.TLS.CertFile,
+			KeyFile:      cfg.Server.TLS.KeyFile,
+			MinVersion:   cfg.Server.TLS.MinVersion,
+			CipherSuites: cfg.Server.TLS.CipherSuites,
+			ClientCAFile: cfg.Server.TLS.ClientCAFile,
+		}, certReloader)
+		if err != nil {
+			slog.Error("failed to configure TLS", "error", err)
+			return
+		}
+	}
 
 	// Middleware stack (order matters)
 
 	// Custom recovery middleware (should be first)
 	e.Use(middleware.RecoveryMiddleware())
 
-	// Security headers middleware
-	e.Use(middleware.SecurityHeadersMiddleware())
+	// Server spans wrap everything below so metrics, logs and queries share the trace
+	e.Use(middleware.Tracing())
 
-	// Input sanitization middleware
-	e.Use(middleware.Sanitize())
+	// Request metrics, exposed only on the admin/ops listener
+	var metricsRegistry *prometheus.Registry
+	if cfg.Server.Admin.Enabled && cfg.Features.EnableMetrics {
+		metricsRegistry = newMetricsRegistry(store)
 
-	// CSRF protection middleware
-	e.Use(middleware.CSRF())
+		httpMetrics, err := middleware.NewHTTPMetrics(metricsRegistry)
+		if err != nil {
+			slog.Error("failed to register HTTP metrics", "error", err)
+			return
+		}
+		e.Use(httpMetrics.Middleware())
+	}
+
+	// Security headers and per-request CSP nonce
+	e.Use(middleware.SecurityHeadersWithConfig(middleware.SecurityHeadersConfig{
+		ContentSecurityPolicy:     cfg.Security.ContentSecurityPolicy,
+		CSPReportOnly:             cfg.Security.CSPReportOnly,
+		CSPReportURI:              cfg.Security.CSPReportURI,
+		HSTSMaxAge:                cfg.Security.HSTSMaxAge,
+		HSTSIncludeSubdomains:     cfg.Security.HSTSIncludeSubdomains,
+		HSTSPreload:               cfg.Security.HSTSPreload,
+		XSSProtection:             cfg.Security.XSSProtection,
+		ContentTypeNosniff:        cfg.Security.ContentTypeNosniff,
+		XFrameOptions:             cfg.Security.XFrameOptions,
+		ReferrerPolicy:            cfg.Security.ReferrerPolicy,
+		PermissionsPolicy:         cfg.Security.PermissionsPolicy,
+		CrossOriginOpenerPolicy:   cfg.Security.CrossOriginOpenerPolicy,
+		CrossOriginEmbedderPolicy: cfg.Security.CrossOriginEmbedderPolicy,
+		TrustedProxies:            cfg.Security.TrustedProxies,
+	}))
+
+	// Admin routes require a verified client certificate when mTLS is configured
+	if cfg.Server.TLS.Enabled && cfg.Server.TLS.ClientCAFile != "" {
+		e.Use(middleware.RequireClientCert(cfg.Server.TLS.ClientAuthPaths))
+	}
+
+	// Input sanitization and body size limits
+	sanitizeConfig := middleware.DefaultSanitizeConfig
+	sanitizeConfig.MaxBodyBytes = cfg.Server.BodyLimit
+	sanitizeConfig.BodyLimits = cfg.Server.BodyLimits
+	sanitizeConfig.RejectDuplicateKeys = cfg.Server.JSON.RejectDuplicateKeys
+	sanitizeConfig.RejectUnknownFields = cfg.Server.JSON.RejectUnknownFields
+	e.Use(middleware.SanitizeWithConfig(sanitizeConfig))
+
+	// CSRF protection middleware. Browsers post CSP violation reports without
+	// page involvement, so the report collector cannot carry a token.
+	csrfConfig := middleware.DefaultCSRFConfig
+	csrfConfig.CookieSecure = cfg.Auth.CookieSecure
+	csrfConfig.Skipper = func(c echo.Context) bool {
+		return c.Request().URL.Path == handler.RouteCSPReport
+	}
+	e.Use(middleware.CSRFWithConfig(csrfConfig))
 
 	// Validation error middleware
 	e.Use(middleware.ValidationErrorMiddleware())
@@ -144,9 +266,9 @@ func main() {
 		LogLatency:   true,
 		LogRemoteIP:  true,
 		LogUserAgent: cfg.App.Debug,
-		LogValuesFunc: func(_ echo.Context, v echomiddleware.RequestLoggerValues) error {
+		LogValuesFunc: func(c echo.Context, v echomiddleware.RequestLoggerValues) error {
 			if v.Error == nil {
-				slog.Info("request",
+				slog.InfoContext(c.Request().Context(), "request",
 					"method", v.Method,
 					"uri", v.URI,
 					"status", v.Status,
@@ -154,7 +276,7 @@ func main() {
 					"remote_ip", v.RemoteIP,
 					"request_id", v.RequestID)
 			} else {
-				slog.Error("request error",
+				slog.ErrorContext(c.Request().Context(), "request error",
 					"method", v.Method,
 					"uri", v.URI,
 					"status", v.Status,
@@ -168,15 +290,6 @@ func main() {
 		},
 	}))
 
-	// Security middleware
-	e.Use(echomiddleware.SecureWithConfig(echomiddleware.SecureConfig{
-		XSSProtection:         "1; mode=block",
-		ContentTypeNosniff:    "nosniff",
-		XFrameOptions:         "DENY",
-		HSTSMaxAge:            31536000,
-		ContentSecurityPolicy: "default-src 'self'; style-src 'self' 'unsafe-inline' https://fonts.googleapis.com https://fonts.gstatic.com; script-src 'self' 'unsafe-inline' 'unsafe-eval'; img-src 'self' data:; connect-src 'self'; font-src 'self' https://fonts.googleapis.com https://fonts.gstatic.com;",
-	}))
-
 	// CORS middleware
 	if cfg.Security.EnableCORS {
 		e.Use(echomiddleware.CORSWithConfig(echomiddleware.CORSConfig{
@@ -187,21 +300,14 @@ func main() {
 		}))
 	}
 
-	// Rate limiting
-	e.Use(echomiddleware.RateLimiterWithConfig(echomiddleware.RateLimiterConfig{
-		Store: echomiddleware.NewRateLimiterMemoryStore(20),
-		IdentifierExtractor: func(c echo.Context) (string, error) {
-			return c.RealIP(), nil
-		},
-		ErrorHandler: func(_ echo.Context, err error) error {
-			return middleware.ErrTooManyRequests.WithInternal(err)
-		},
-	}))
-
 	// Request deadline middleware.
 	// We avoid Echo's Timeout middleware here because it swaps the response writer
-	// and breaks templ rendering for full-page HTML responses.
-	e.Use(middleware.RequestTimeout(cfg.Server.ReadTimeout))
+	// and breaks templ rendering for full-page HTML responses. Event streams
+	// stay open for as long as the page does, and exports until they finish.
+	e.Use(middleware.RequestTimeoutWithConfig(middleware.RequestTimeoutConfig{
+		Timeout: cfg.Server.ReadTimeout,
+		Skipper: handler.IsStreamingRequest,
+	}))
 
 	// Add environment to context for error handling
 	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -214,8 +320,8 @@ func main() {
 
 	// Initialize session manager
 	sessionManager := scs.New()
-	sessionManager.Store = pgxstore.New(store.DB())
-	sessionManager.Lifetime = 24 * time.Hour
+	sessionManager.Store = newSessionStore(store)
+	sessionManager.Lifetime = cfg.Session.Lifetime
 	sessionManager.Cookie.Name = cfg.Auth.CookieName
 	sessionManager.Cookie.HttpOnly = true
 	sessionManager.Cookie.Secure = cfg.Auth.CookieSecure
@@ -227,8 +333,37 @@ func main() {
 	// Add session middleware to Echo
 	e.Use(authService.SessionMiddleware())
 
+	// Rate limiting runs after sessions so "user" policies can key on the session.
+	if cfg.RateLimit.Enabled {
+		rateLimiter, err := newRateLimiter(cfg, store, authService)
+		if err != nil {
+			slog.Error("failed to configure rate limiting", "error", err)
+			return
+		}
+		e.Use(rateLimiter.Middleware())
+	}
+
+	eventBus := newEventBus(store)
+
+	media, err := newMediaStorage(cfg)
+	if err != nil {
+		slog.Error("failed to configure media storage", "error", err)
+		return
+	}
+
 	// Initialize handlers and register routes
-	handlers := handler.NewHandlers(store, authService)
+	privacyConfig := privacy.Config{
+		CoolingOff:     cfg.Privacy.DeletionCoolingOff,
+		ExportLifetime: cfg.Privacy.ExportLifetime,
+	}
+	handlers := handler.NewHandlersWithConfig(store, authService, healthRegistry, eventBus, handler.Config{
+		Privacy: privacyConfig,
+		Avatar: avatar.Config{
+			MaxBytes:  cfg.Media.MaxAvatarSize,
+			MaxPixels: cfg.Media.MaxAvatarPixels,
+		},
+		Media: media,
+	})
 	if err := handler.RegisterRoutes(e, handlers); err != nil {
 		slog.Error("failed to register routes", "error", err)
 		return
@@ -238,19 +373,105 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	eventBus.Start(ctx)
+
+	// Maintenance tasks run on every replica's schedule, but only one replica
+	// runs each tick.
+	var maintenance *scheduler.Scheduler
+	if cfg.Scheduler.Enabled {
+		maintenance, err = newScheduler(cfg, store, authService, media)
+		if err != nil {
+			slog.Error("failed to configure scheduler", "error", err)
+			return
+		}
+		maintenance.Start(ctx)
+	}
+
+	if certReloader != nil && cfg.Server.TLS.ReloadInterval > 0 {
+		go certReloader.Watch(ctx, cfg.Server.TLS.ReloadInterval)
+	}
+
+	// Background job handlers are registered before the workers start so
+	// every kind this binary knows is claimed from the first poll.
+	jobRegistry := jobs.NewRegistry()
+	webhooks.NewSenderWithConfig(store, webhooks.Config{
+		Timeout:              cfg.Webhooks.Timeout,
+		DisableAfter:         cfg.Webhooks.DisableAfter,
+		AllowPrivateNetworks: cfg.Webhooks.AllowPrivateNetworks,
+	}).Register(jobRegistry)
+	userimport.NewRunner(store, userimport.New(store, authService, handler.RecordImportedUser)).Register(jobRegistry)
+	privacy.NewRunner(store, authService, privacyConfig).Register(jobRegistry)
+
+	var jobPool *jobs.Pool
+	if cfg.Jobs.Enabled {
+		jobPool = jobs.NewPoolWithConfig(store, jobRegistry, jobs.Config{
+			Workers:  cfg.Jobs.Workers,
+			Interval: cfg.Jobs.Interval,
+			Timeout:  cfg.Jobs.Timeout,
+		})
+		jobPool.Start(ctx)
+	}
+
 	// Start server in goroutine
 	go func() {
-		address := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
-		slog.Info("Server starting", "address", address)
+		address := net.JoinHostPort(cfg.Server.Host, cfg.Server.Port)
+
+		var err error
+		if cfg.Server.TLS.Enabled {
+			slog.Info("Server starting", "address", address, "tls", true, "min_tls_version", cfg.Server.TLS.MinVersion)
+			e.TLSServer.Addr = address
+			err = e.StartServer(e.TLSServer)
+		} else {
+			slog.Info("Server starting", "address", address)
+			err = e.Start(address)
+		}
 
-		if err := e.Start(address); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("failed to start server", "error", err)
 			return
 		}
 	}()
 
+	// Admin/ops listener for health, metrics and debug endpoints
+	var adminServer *echo.Echo
+	if cfg.Server.Admin.Enabled {
+		adminServer, err = startAdminServer(cfg, store, metricsRegistry, healthRegistry)
+		if err != nil {
+			slog.Error("failed to start admin listener", "error", err)
+			return
+		}
+	}
+
+	// Plain HTTP listener that only redirects to HTTPS
+	var redirectServer *http.Server
+	if cfg.Server.TLS.Enabled && cfg.Server.TLS.RedirectPort != "" {
+		redirectServer = &http.Server{
+			Addr:              net.JoinHostPort(cfg.Server.Host, cfg.Server.TLS.RedirectPort),
+			Handler:           server.RedirectHandler(cfg.Server.Port),
+			ReadHeaderTimeout: cfg.Server.ReadTimeout,
+			WriteTimeout:      cfg.Server.WriteTimeout,
+		}
+
+		go func() {
+			slog.Info("HTTPS redirect listener starting", "address", redirectServer.Addr)
+
+			if err := redirectServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("failed to start HTTPS redirect listener", "error", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal
 	<-ctx.Done()
+	// A second signal terminates immediately instead of waiting for the drain.
+	stop()
+
+	// Fail readiness first so load balancers stop routing before connections close.
+	healthRegistry.SetDraining(true)
+	if cfg.Health.DrainPeriod > 0 {
+		slog.Info("Draining before shutdown", "drain_period", cfg.Health.DrainPeriod)
+		time.Sleep(cfg.Health.DrainPeriod)
+	}
 
 	slog.Info("Shutting down server...")
 
@@ -258,11 +479,42 @@ func main() {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("failed to shutdown HTTPS redirect listener", "error", err)
+		}
+	}
+
+	// Event streams never finish on their own; closing the bus ends them so
+	// the server can.
+	eventBus.Close()
+
 	if err := e.Shutdown(shutdownCtx); err != nil {
 		slog.Error("failed to shutdown server gracefully", "error", err)
 		return
 	}
 
+	// Running jobs get what is left of the shutdown timeout; unfinished ones
+	// are released for another instance to pick up.
+	if jobPool != nil {
+		if err := jobPool.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("background jobs did not finish before shutdown", "error", err)
+		}
+	}
+
+	if maintenance != nil {
+		if err := maintenance.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("scheduled tasks did not finish before shutdown", "error", err)
+		}
+	}
+
+	// The admin listener stops last so probes and metrics cover the whole drain.
+	if adminServer != nil {
+		if err := adminServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("failed to shutdown admin listener", "error", err)
+		}
+	}
+
 	slog.Info("Server shutdown complete")
 }
 
@@ -312,11 +564,144 @@ func configureIPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
 	}, nil
 }
 
-func databaseTarget(databaseURL string) string {
-	cfg, err := pgxpool.ParseConfig(databaseURL)
+const rateLimitBackendPostgres = "postgres"
+
+func newMediaStorage(cfg *config.Config) (storage.Storage, error) {
+	switch cfg.Media.Backend {
+	case "", "local":
+		return storage.NewLocal(cfg.Media.Dir), nil
+	case "s3":
+		return storage.NewS3(storage.S3Config{
+			Endpoint:        cfg.Media.S3.Endpoint,
+			Region:          cfg.Media.S3.Region,
+			Bucket:          cfg.Media.S3.Bucket,
+			AccessKeyID:     cfg.Media.S3.AccessKeyID,
+			SecretAccessKey: cfg.Media.S3.SecretAccessKey,
+			PathStyle:       cfg.Media.S3.PathStyle,
+			Timeout:         cfg.Media.S3.Timeout,
+		})
+	default:
+		return nil, fmt.Errorf("unknown media backend %q", cfg.Media.Backend)
+	}
+}
+
+func newRateLimiter(cfg *config.Config, db database, authService *middleware.SessionAuthService) (*middleware.RateLimiter, error) {
+	var limitStore middleware.RateLimitStore
+	switch cfg.RateLimit.Backend {
+	case "", "memory":
+		limitStore = middleware.NewMemoryRateLimitStore()
+	case rateLimitBackendPostgres:
+		limitStore = middleware.NewSharedRateLimitStore(db.ConsumeRateLimitToken)
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", cfg.RateLimit.Backend)
+	}
+
+	policies := make(map[string]middleware.RateLimitPolicy, len(cfg.RateLimit.Policies))
+	for name, policy := range cfg.RateLimit.Policies {
+		policies[name] = middleware.RateLimitPolicy{
+			Rate:   policy.Rate,
+			Burst:  policy.Burst,
+			Window: policy.Window,
+			KeyBy:  policy.KeyBy,
+		}
+	}
+
+	return middleware.NewRateLimiter(middleware.RateLimitConfig{
+		Store:           limitStore,
+		Policies:        policies,
+		Routes:          cfg.RateLimit.Routes,
+		ExemptPaths:     cfg.RateLimit.ExemptPaths,
+		TrustedNetworks: cfg.RateLimit.TrustedNetworks,
+		UserIdentifier: func(c echo.Context) (string, bool) {
+			user, ok := authService.GetCurrentUser(c)
+			if !ok {
+				return "", false
+			}
+			return strconv.FormatInt(user.ID, 10), true
+		},
+	})
+}
+
+func newHealthRegistry(cfg *config.Config, db database) (*health.Registry, error) {
+	registry := health.NewRegistry(cfg.Health.CheckTimeout)
+	registry.Register("database", db.Ping)
+
+	if threshold := cfg.Health.PoolSaturationThreshold; threshold > 0 {
+		registry.Register("database_pool", func(context.Context) error {
+			if saturation := db.PoolSaturation(); saturation >= threshold {
+				return fmt.Errorf("%.0f%% of connections in use (threshold %.0f%%)", saturation*100, threshold*100)
+			}
+			return nil
+		})
+	}
+
+	// The startup bootstrap has no Atlas history, so only Atlas-managed schemas
+	// are checked against the migrations compiled into this binary.
+	if !cfg.Database.RunMigrations {
+		expected, err := latestMigrationVersion(db)
+		if err != nil {
+			return nil, fmt.Errorf("read embedded migrations: %w", err)
+		}
+
+		registry.Register("migrations", func(ctx context.Context) error {
+			applied, err := db.AppliedMigrationVersion(ctx)
+			if err != nil {
+				return err
+			}
+			if applied < expected {
+				return fmt.Errorf("schema at version %s, expected %s", applied, expected)
+			}
+			return nil
+		})
+	}
+
+	return registry, nil
+}
+
+func newMetricsRegistry(db database) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		newPoolCollector(db),
+		store.NewQueryStatsCollector(db.QueryStats()),
+	)
+
+	return registry
+}
+
+func startAdminServer(cfg *config.Config, db database, registry *prometheus.Registry, healthRegistry *health.Registry) (*echo.Echo, error) {
+	listener, err := server.Listen(cfg.Server.Admin.Address)
 	if err != nil {
-		return "configured-database"
+		return nil, fmt.Errorf("listen on %s: %w", cfg.Server.Admin.Address, err)
 	}
 
-	return fmt.Sprintf("%s:%d/%s", cfg.ConnConfig.Host, cfg.ConnConfig.Port, cfg.ConnConfig.Database)
+	admin := echo.New()
+	admin.HideBanner = true
+	admin.HidePort = true
+	admin.Listener = listener
+	admin.HTTPErrorHandler = middleware.ErrorHandler
+	admin.Use(middleware.RecoveryMiddleware())
+
+	// No write timeout: CPU profiles and traces stream for their full duration.
+	admin.Server.ReadHeaderTimeout = cfg.Server.ReadTimeout
+
+	options := handler.OpsOptions{EnablePprof: cfg.Features.EnablePprof}
+	if registry != nil {
+		options.Metrics = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	}
+	handler.RegisterOpsRoutes(admin, handler.NewOpsHandler(db), handler.NewHealthHandler(healthRegistry, db), options)
+
+	go func() {
+		slog.Info("Admin listener starting",
+			"address", cfg.Server.Admin.Address,
+			"metrics", registry != nil,
+			"pprof", cfg.Features.EnablePprof)
+
+		if err := admin.Start(""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("admin listener stopped", "error", err)
+		}
+	}()
+
+	return admin, nil
 }
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/avatar"
+	"github.com/dunamismax/go-web-server/internal/config"
+	"github.com/dunamismax/go-web-server/internal/handler"
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/privacy"
+	"github.com/dunamismax/go-web-server/internal/scheduler"
+	"github.com/dunamismax/go-web-server/internal/storage"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// newScheduler registers the maintenance tasks enabled in cfg.
+func newScheduler(cfg *config.Config, db database, authService *middleware.SessionAuthService, media storage.Storage) (*scheduler.Scheduler, error) {
+	sched := scheduler.New(db)
+
+	var tasks []scheduler.Task
+	if cfg.Scheduler.Sessions != "" {
+		tasks = append(tasks, scheduler.Task{
+			Name:     "sessions.purge",
+			Schedule: cfg.Scheduler.Sessions,
+			Run: func(ctx context.Context) error {
+				return purgeExpiredSessions(ctx, db)
+			},
+		})
+	}
+
+	// The memory rate limit backend evicts its own buckets.
+	if cfg.Scheduler.RateLimit != "" && cfg.RateLimit.Enabled && cfg.RateLimit.Backend == rateLimitBackendPostgres {
+		tasks = append(tasks, scheduler.Task{
+			Name:     "ratelimit.purge",
+			Schedule: cfg.Scheduler.RateLimit,
+			Run: func(ctx context.Context) error {
+				return purgeRateLimitBuckets(ctx, db)
+			},
+		})
+	}
+
+	if cfg.Scheduler.Retention != "" {
+		tasks = append(tasks, scheduler.Task{
+			Name:     "retention.events",
+			Schedule: cfg.Scheduler.Retention,
+			Run: func(ctx context.Context) error {
+				return purgeEventPayloads(ctx, db)
+			},
+		})
+		eraser := privacy.NewEraser(db, authService, func(ctx context.Context, q store.Querier, user store.User) error {
+			if err := handler.RecordErasedUser(ctx, q, user); err != nil {
+				return err
+			}
+			// Deleted before the erasure commits; if it rolls back, the next
+			// run erases the account without the avatar.
+			removeAvatar(ctx, media, user.ID, user.AvatarUrl)
+			return nil
+		})
+		tasks = append(tasks, scheduler.Task{
+			Name:     "privacy.erase",
+			Schedule: cfg.Scheduler.Retention,
+			Run: func(ctx context.Context) error {
+				return eraseAccounts(ctx, eraser)
+			},
+		})
+		tasks = append(tasks, scheduler.Task{
+			Name:     "privacy.exports",
+			Schedule: cfg.Scheduler.Retention,
+			Run: func(ctx context.Context) error {
+				return purgeDataExports(ctx, db)
+			},
+		})
+		if cfg.Retention.Users > 0 {
+			tasks = append(tasks, scheduler.Task{
+				Name:     "retention.users",
+				Schedule: cfg.Scheduler.Retention,
+				Run: func(ctx context.Context) error {
+					return purgeDeletedUsers(ctx, db, authService, media, cfg.Retention.Users)
+				},
+			})
+		}
+		if cfg.Retention.Audit > 0 {
+			tasks = append(tasks, scheduler.Task{
+				Name:     "retention.audit",
+				Schedule: cfg.Scheduler.Retention,
+				Run: func(ctx context.Context) error {
+					return purgeAuditEvents(ctx, db, cfg.Retention.Audit)
+				},
+			})
+		}
+		if cfg.Retention.Webhooks > 0 {
+			tasks = append(tasks, scheduler.Task{
+				Name:     "retention.webhooks",
+				Schedule: cfg.Scheduler.Retention,
+				Run: func(ctx context.Context) error {
+					return purgeWebhookDeliveries(ctx, db, cfg.Retention.Webhooks)
+				},
+			})
+		}
+		if cfg.Retention.Jobs > 0 {
+			tasks = append(tasks, scheduler.Task{
+				Name:     "retention.jobs",
+				Schedule: cfg.Scheduler.Retention,
+				Run: func(ctx context.Context) error {
+					return purgeFinishedJobs(ctx, db, cfg.Retention.Jobs)
+				},
+			})
+		}
+	}
+
+	for _, task := range tasks {
+		if err := sched.Register(task); err != nil {
+			return nil, err
+		}
+	}
+
+	return sched, nil
+}
+
+func purgeExpiredSessions(ctx context.Context, db database) error {
+	deleted, err := db.DeleteExpiredSessions(ctx)
+	if err != nil {
+		return fmt.Errorf("delete expired sessions: %w", err)
+	}
+	if deleted > 0 {
+		slog.Info("purged expired sessions", "sessions", deleted)
+	}
+
+	return nil
+}
+
+func purgeRateLimitBuckets(ctx context.Context, db database) error {
+	if _, err := db.DeleteExpiredRateLimitBuckets(ctx); err != nil {
+		return fmt.Errorf("delete expired rate limit buckets: %w", err)
+	}
+
+	return nil
+}
+
+func purgeDeletedUsers(ctx context.Context, db database, authService *middleware.SessionAuthService, media storage.Storage, retention time.Duration) error {
+	purged, err := db.PurgeDeletedUsers(ctx, retentionCutoff(retention))
+	if err != nil {
+		return fmt.Errorf("purge deleted users: %w", err)
+	}
+	if len(purged) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(purged))
+	for i, user := range purged {
+		ids[i] = user.ID
+		removeAvatar(ctx, media, user.ID, user.AvatarUrl)
+	}
+
+	// Sessions carry the user ID only inside their encoded data, so they
+	// cannot cascade in SQL.
+	sessions, err := authService.DestroyUserSessions(ctx, ids...)
+	if err != nil {
+		return fmt.Errorf("destroy sessions of %d purged users: %w", len(ids), err)
+	}
+	slog.Info("purged deleted users", "users", len(ids), "sessions", sessions)
+
+	return nil
+}
+
+// removeAvatar deletes the files of a removed user's avatar. A failure only
+// leaves unreferenced files behind, so it is logged.
+func removeAvatar(ctx context.Context, media storage.Storage, userID int64, url *string) {
+	if url == nil {
+		return
+	}
+	if err := avatar.Remove(ctx, media, *url); err != nil {
+		slog.Warn("failed to delete avatar files", "user_id", userID, "error", err)
+	}
+}
+
+// eraseAccounts erases accounts whose deletion cooling-off has passed.
+func eraseAccounts(ctx context.Context, eraser *privacy.Eraser) error {
+	erased, err := eraser.Run(ctx)
+	if erased > 0 {
+		slog.Info("erased accounts", "users", erased)
+	}
+
+	return err
+}
+
+// purgeDataExports deletes personal data archives that can no longer be
+// downloaded.
+func purgeDataExports(ctx context.Context, db database) error {
+	deleted, err := db.PurgeDataExports(ctx, pgtype.Timestamptz{Time: time.Now(), Valid: true})
+	if err != nil {
+		return fmt.Errorf("purge data exports: %w", err)
+	}
+	if deleted > 0 {
+		slog.Info("purged expired data exports", "exports", deleted)
+	}
+
+	return nil
+}
+
+func purgeAuditEvents(ctx context.Context, db database, retention time.Duration) error {
+	var deleted int64
+	err := db.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		// The append-only trigger only lets deletes through for the rest of
+		// this transaction.
+		if err := q.AllowAuditPurge(ctx); err != nil {
+			return err
+		}
+
+		var err error
+		deleted, err = q.PurgeAuditEvents(ctx, retentionCutoff(retention))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("purge audit events: %w", err)
+	}
+	if deleted > 0 {
+		slog.Info("purged old audit events", "events", deleted)
+	}
+
+	return nil
+}
+
+func purgeFinishedJobs(ctx context.Context, db database, retention time.Duration) error {
+	deleted, err := db.PurgeFinishedJobs(ctx, retentionCutoff(retention))
+	if err != nil {
+		return fmt.Errorf("purge finished jobs: %w", err)
+	}
+	if deleted > 0 {
+		slog.Info("purged finished jobs", "jobs", deleted)
+	}
+
+	return nil
+}
+
+func purgeWebhookDeliveries(ctx context.Context, db database, retention time.Duration) error {
+	deleted, err := db.PurgeWebhookDeliveries(ctx, retentionCutoff(retention))
+	if err != nil {
+		return fmt.Errorf("purge webhook deliveries: %w", err)
+	}
+	if deleted > 0 {
+		slog.Info("purged old webhook deliveries", "deliveries", deleted)
+	}
+
+	return nil
+}
+
+// eventPayloadRetention is how long spilled event payloads are kept. Every
+// listener loads them within seconds of the NOTIFY.
+const eventPayloadRetention = time.Hour
+
+func purgeEventPayloads(ctx context.Context, db database) error {
+	deleted, err := db.PurgeEventPayloads(ctx, retentionCutoff(eventPayloadRetention))
+	if err != nil {
+		return fmt.Errorf("purge event payloads: %w", err)
+	}
+	if deleted > 0 {
+		slog.Info("purged spilled event payloads", "payloads", deleted)
+	}
+
+	return nil
+}
+
+func retentionCutoff(retention time.Duration) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: time.Now().Add(-retention), Valid: true}
+}
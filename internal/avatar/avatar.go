@@ -0,0 +1,284 @@
+// Package avatar turns uploaded pictures into the square images shown next
+// to users. Uploads are sniffed instead of trusted by name, limited in bytes
+// and pixels, cropped to a centred square, resized to each of Sizes, and
+// re-encoded, so EXIF and any other metadata never reach storage.
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // registers the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dunamismax/go-web-server/internal/storage"
+)
+
+// Sizes are the square edge lengths, in pixels, every avatar is stored at,
+// largest first.
+var Sizes = []int{256, 128, 48}
+
+// MediaPrefix is where stored files are served from.
+const MediaPrefix = "/media/"
+
+const jpegQuality = 85
+
+// Errors returned by Process for uploads that are refused.
+var (
+	ErrTooLarge    = errors.New("avatar: file is too large")
+	ErrUnsupported = errors.New("avatar: file is not a JPEG, PNG or GIF image")
+	ErrDimensions  = errors.New("avatar: image has too many pixels")
+	ErrInvalid     = errors.New("avatar: image is damaged")
+)
+
+// Config limits uploads.
+type Config struct {
+	// MaxBytes is the largest file accepted.
+	MaxBytes int64
+	// MaxPixels is the largest width times height accepted. Images are
+	// decoded in full, so this bounds the memory an upload can take.
+	MaxPixels int
+}
+
+// DefaultConfig accepts photos from most phones.
+var DefaultConfig = Config{
+	MaxBytes:  5 << 20,
+	MaxPixels: 16_000_000,
+}
+
+// Image is one size of a processed avatar.
+type Image struct {
+	Size        int
+	ContentType string
+	Data        []byte
+}
+
+// Ext returns the file extension for the image's format.
+func (i Image) Ext() string {
+	if i.ContentType == "image/jpeg" {
+		return "jpg"
+	}
+
+	return "png"
+}
+
+// Process reads an upload and returns it at each of Sizes. JPEGs are turned
+// upright using their EXIF orientation and stay JPEGs; PNGs and GIFs become
+// PNGs so transparency survives. Only the first frame of an animated GIF is
+// kept.
+func Process(r io.Reader, config Config) ([]Image, error) {
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = DefaultConfig.MaxBytes
+	}
+	if config.MaxPixels <= 0 {
+		config.MaxPixels = DefaultConfig.MaxPixels
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, config.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > config.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupported
+	}
+
+	// Check the header before decoding, so a small file claiming huge
+	// dimensions is refused without allocating for them.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > config.MaxPixels/cfg.Height {
+		return nil, ErrDimensions
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	outputType := "image/png"
+	orientation := 1
+	if contentType == "image/jpeg" {
+		outputType = "image/jpeg"
+		orientation = jpegOrientation(data)
+	}
+
+	square := cropSquare(src)
+	images := make([]Image, 0, len(Sizes))
+	for _, size := range Sizes {
+		// Each size is scaled down from the one before, which is much
+		// cheaper than going back to the full image and looks the same.
+		square = resize(square, size)
+		if size == Sizes[0] {
+			square = orient(square, orientation)
+		}
+
+		var buf bytes.Buffer
+		if outputType == "image/jpeg" {
+			err = jpeg.Encode(&buf, square, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			err = png.Encode(&buf, square)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("encode %dpx avatar: %w", size, err)
+		}
+
+		images = append(images, Image{Size: size, ContentType: outputType, Data: buf.Bytes()})
+	}
+
+	return images, nil
+}
+
+// Save stores images under a new upload ID for the user and returns the URL
+// of the largest. The URL changes with every upload, so it can be cached
+// forever. If storing any size fails, the sizes already stored are removed.
+func Save(ctx context.Context, s storage.Storage, userID int64, images []Image) (string, error) {
+	if len(images) == 0 {
+		return "", errors.New("no avatar images to save")
+	}
+
+	upload := make([]byte, 16)
+	if _, err := rand.Read(upload); err != nil {
+		return "", err
+	}
+	dir := "avatars/" + strconv.FormatInt(userID, 10) + "/" + hex.EncodeToString(upload) + "/"
+
+	for i, img := range images {
+		key := dir + strconv.Itoa(img.Size) + "." + img.Ext()
+		if err := s.Put(ctx, key, bytes.NewReader(img.Data), int64(len(img.Data)), img.ContentType); err != nil {
+			for _, stored := range images[:i] {
+				_ = s.Delete(ctx, dir+strconv.Itoa(stored.Size)+"."+stored.Ext())
+			}
+			return "", err
+		}
+	}
+
+	return MediaPrefix + dir + strconv.Itoa(images[0].Size) + "." + images[0].Ext(), nil
+}
+
+// Remove deletes every size of the uploaded avatar at url. Anything else,
+// including an empty URL, is left alone.
+func Remove(ctx context.Context, s storage.Storage, url string) error {
+	if !IsUploaded(url) {
+		return nil
+	}
+
+	var errs []error
+	for _, size := range Sizes {
+		key := strings.TrimPrefix(URL(url, size), MediaPrefix)
+		errs = append(errs, s.Delete(ctx, key))
+	}
+
+	return errors.Join(errs...)
+}
+
+// URL returns the URL of the given size of the uploaded avatar at url, or ""
+// if url is not one, such as an external link saved before uploads existed.
+func URL(url string, size int) string {
+	if !IsUploaded(url) {
+		return ""
+	}
+
+	dir, file := url[:strings.LastIndexByte(url, '/')+1], url[strings.LastIndexByte(url, '/')+1:]
+	_, ext, _ := strings.Cut(file, ".")
+
+	return dir + strconv.Itoa(size) + "." + ext
+}
+
+// IsUploaded reports whether url points at an avatar stored by Save.
+func IsUploaded(url string) bool {
+	key, ok := strings.CutPrefix(url, MediaPrefix)
+	if !ok || !storage.ValidKey(key) {
+		return false
+	}
+
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 || parts[0] != "avatars" {
+		return false
+	}
+	if _, err := strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return false
+	}
+	if _, err := hex.DecodeString(parts[2]); err != nil || len(parts[2]) != 32 {
+		return false
+	}
+
+	size, ext, ok := strings.Cut(parts[3], ".")
+	if _, err := strconv.Atoi(size); err != nil || !ok {
+		return false
+	}
+
+	return ext == "jpg" || ext == "png"
+}
+
+// cropSquare copies the centred square of src into an RGBA image.
+func cropSquare(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	origin := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), src, origin, draw.Src)
+
+	return dst
+}
+
+// resize scales a square image to size×size. Each output pixel averages
+// the source area it covers, weighted by overlap, which keeps detail when
+// shrinking; enlarging repeats pixels.
+func resize(src *image.RGBA, size int) *image.RGBA {
+	n := src.Bounds().Dx()
+	if n == size {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	scale := float64(n) / float64(size)
+
+	for dy := range size {
+		y0, y1 := float64(dy)*scale, float64(dy+1)*scale
+		for dx := range size {
+			x0, x1 := float64(dx)*scale, float64(dx+1)*scale
+
+			var r, g, b, a, total float64
+			for sy := int(y0); sy < n && float64(sy) < y1; sy++ {
+				wy := min(y1, float64(sy+1)) - max(y0, float64(sy))
+				for sx := int(x0); sx < n && float64(sx) < x1; sx++ {
+					w := wy * (min(x1, float64(sx+1)) - max(x0, float64(sx)))
+					i := src.PixOffset(sx, sy)
+					r += w * float64(src.Pix[i])
+					g += w * float64(src.Pix[i+1])
+					b += w * float64(src.Pix[i+2])
+					a += w * float64(src.Pix[i+3])
+					total += w
+				}
+			}
+
+			i := dst.PixOffset(dx, dy)
+			dst.Pix[i] = uint8(r/total + 0.5)
+			dst.Pix[i+1] = uint8(g/total + 0.5)
+			dst.Pix[i+2] = uint8(b/total + 0.5)
+			dst.Pix[i+3] = uint8(a/total + 0.5)
+		}
+	}
+
+	return dst
+}
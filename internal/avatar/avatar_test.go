@@ -0,0 +1,226 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/dunamismax/go-web-server/internal/storage"
+)
+
+var (
+	red  = color.RGBA{R: 255, A: 255}
+	blue = color.RGBA{B: 255, A: 255}
+)
+
+// twoTone draws an image whose top half is red and bottom half blue.
+func twoTone(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			if y < h/2 {
+				img.Set(x, y, red)
+			} else {
+				img.Set(x, y, blue)
+			}
+		}
+	}
+
+	return img
+}
+
+// withSegments inserts raw JPEG segments straight after the SOI marker.
+func withSegments(t *testing.T, img image.Image, segments ...[]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("jpeg.Encode() error = %v", err)
+	}
+	data := buf.Bytes()
+
+	out := append([]byte{}, data[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, data[2:]...)
+}
+
+func segment(marker byte, payload []byte) []byte {
+	n := len(payload) + 2
+	return append([]byte{0xFF, marker, byte(n >> 8), byte(n)}, payload...)
+}
+
+// exifOrientation builds a big-endian EXIF APP1 payload holding only an
+// orientation entry.
+func exifOrientation(orientation byte) []byte {
+	return []byte("Exif\x00\x00" +
+		"MM\x00\x2a\x00\x00\x00\x08" + // header, IFD0 at offset 8
+		"\x00\x01" + // one entry
+		"\x01\x12\x00\x03\x00\x00\x00\x01\x00" + string([]byte{orientation}) + "\x00\x00" +
+		"\x00\x00\x00\x00") // no next IFD
+}
+
+func isClose(c color.Color, want color.RGBA) bool {
+	r, g, b, _ := c.RGBA()
+	diff := func(got uint32, want uint8) bool {
+		d := int(got>>8) - int(want)
+		return d > -48 && d < 48
+	}
+	return diff(r, want.R) && diff(g, want.G) && diff(b, want.B)
+}
+
+func TestProcessOrientsAndStripsMetadata(t *testing.T) {
+	t.Parallel()
+
+	upload := withSegments(t, twoTone(90, 60),
+		segment(0xE1, exifOrientation(6)),
+		segment(0xFE, []byte("GPS 51.5007 N, 0.1246 W")),
+	)
+	if got := jpegOrientation(upload); got != 6 {
+		t.Fatalf("jpegOrientation() = %d, want 6", got)
+	}
+
+	images, err := Process(bytes.NewReader(upload), DefaultConfig)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if len(images) != len(Sizes) {
+		t.Fatalf("Process() returned %d images, want %d", len(images), len(Sizes))
+	}
+
+	for i, img := range images {
+		if img.Size != Sizes[i] || img.ContentType != "image/jpeg" || img.Ext() != "jpg" {
+			t.Fatalf("image %d = %dpx %s, want %dpx image/jpeg", i, img.Size, img.ContentType, Sizes[i])
+		}
+		if bytes.Contains(img.Data, []byte("Exif")) || bytes.Contains(img.Data, []byte("GPS")) {
+			t.Fatalf("%dpx image kept the upload's metadata", img.Size)
+		}
+
+		decoded, err := jpeg.Decode(bytes.NewReader(img.Data))
+		if err != nil {
+			t.Fatalf("decode %dpx image: %v", img.Size, err)
+		}
+		if b := decoded.Bounds(); b.Dx() != img.Size || b.Dy() != img.Size {
+			t.Fatalf("%dpx image is %v", img.Size, b)
+		}
+
+		// Turned right, the red top half of the stored picture is on the
+		// right.
+		quarter := img.Size / 4
+		if left, right := decoded.At(quarter, img.Size/2), decoded.At(img.Size-quarter, img.Size/2); !isClose(left, blue) || !isClose(right, red) {
+			t.Fatalf("%dpx image has %v on the left and %v on the right, want blue then red", img.Size, left, right)
+		}
+	}
+}
+
+func TestProcessKeepsTransparencyInPNG(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 300, 300))); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+
+	images, err := Process(&buf, DefaultConfig)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	decoded, err := png.Decode(bytes.NewReader(images[0].Data))
+	if err != nil || images[0].ContentType != "image/png" {
+		t.Fatalf("largest image is %s (%v), want a PNG", images[0].ContentType, err)
+	}
+	if _, _, _, a := decoded.At(10, 10).RGBA(); a != 0 {
+		t.Fatalf("alpha = %d, want the transparent background kept", a)
+	}
+}
+
+func TestProcessRejectsUnsafeUploads(t *testing.T) {
+	t.Parallel()
+
+	var large bytes.Buffer
+	if err := png.Encode(&large, image.NewGray(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		config Config
+		want   error
+	}{
+		{name: "too many bytes", data: large.Bytes(), config: Config{MaxBytes: 64}, want: ErrTooLarge},
+		{name: "too many pixels", data: large.Bytes(), config: Config{MaxPixels: 200*100 - 1}, want: ErrDimensions},
+		{name: "html named .png", data: []byte("<html><script>alert(1)</script></html>"), want: ErrUnsupported},
+		{name: "svg", data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), want: ErrUnsupported},
+		{name: "truncated", data: large.Bytes()[:60], want: ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		if _, err := Process(bytes.NewReader(tt.data), tt.config); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Process() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestSaveAndRemove(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := storage.NewLocal(t.TempDir())
+
+	images, err := Process(bytes.NewReader(withSegments(t, twoTone(64, 64))), DefaultConfig)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	url, err := Save(ctx, s, 7, images)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if !IsUploaded(url) || !strings.HasPrefix(url, "/media/avatars/7/") || !strings.HasSuffix(url, "/256.jpg") {
+		t.Fatalf("Save() = %q, want a 256px upload URL for user 7", url)
+	}
+
+	small := URL(url, 48)
+	obj, err := s.Get(ctx, strings.TrimPrefix(small, MediaPrefix))
+	if err != nil {
+		t.Fatalf("Get(%s) error = %v", small, err)
+	}
+	obj.Body.Close()
+
+	if err := Remove(ctx, s, url); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	for _, size := range Sizes {
+		if _, err := s.Get(ctx, strings.TrimPrefix(URL(url, size), MediaPrefix)); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("%dpx image after Remove(): %v, want ErrNotFound", size, err)
+		}
+	}
+}
+
+func TestURLIgnoresExternalLinks(t *testing.T) {
+	t.Parallel()
+
+	for _, url := range []string{
+		"",
+		"https://example.com/avatar.jpg",
+		"/media/avatars/1/../../secret/256.jpg",
+		"/media/avatars/1/not-hex/256.jpg",
+		"/media/other/1/00112233445566778899aabbccddeeff/256.jpg",
+	} {
+		if IsUploaded(url) || URL(url, 48) != "" {
+			t.Errorf("URL(%q) treated it as an uploaded avatar", url)
+		}
+		if err := Remove(context.Background(), nil, url); err != nil {
+			t.Errorf("Remove(%q) error = %v", url, err)
+		}
+	}
+}
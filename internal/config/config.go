@@ -26,18 +26,54 @@ type Config struct {
 		ReadTimeout     time.Duration `mapstructure:"read_timeout"`
 		WriteTimeout    time.Duration `mapstructure:"write_timeout"`
 		ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+
+		// BodyLimit is the largest request body in bytes, zero for no limit.
+		// BodyLimits overrides it for path prefixes such as upload routes.
+		BodyLimit  int64            `mapstructure:"body_limit"`
+		BodyLimits map[string]int64 `mapstructure:"body_limits"`
+
+		// JSON request bodies can be checked strictly for API clients.
+		JSON struct {
+			RejectDuplicateKeys bool `mapstructure:"reject_duplicate_keys"`
+			RejectUnknownFields bool `mapstructure:"reject_unknown_fields"`
+		} `mapstructure:"json"`
+
+		// Native TLS termination. When enabled the server listens for HTTPS on
+		// Port and, if RedirectPort is set, redirects plain HTTP from there.
+		TLS struct {
+			Enabled        bool          `mapstructure:"enabled"`
+			CertFile       string        `mapstructure:"cert_file"`
+			KeyFile        string        `mapstructure:"key_file"`
+			MinVersion     string        `mapstructure:"min_version"`
+			CipherSuites   []string      `mapstructure:"cipher_suites"`
+			ReloadInterval time.Duration `mapstructure:"reload_interval"`
+			RedirectPort   string        `mapstructure:"redirect_port"`
+			// ClientCAFile enables mTLS; ClientAuthPaths must present a verified client certificate.
+			ClientCAFile    string   `mapstructure:"client_ca_file"`
+			ClientAuthPaths []string `mapstructure:"client_auth_paths"`
+		} `mapstructure:"tls"`
+
+		// Optional admin/ops listener for health, metrics and debug endpoints.
+		// Address is "host:port" or "unix:/path/to.sock".
+		Admin struct {
+			Enabled bool   `mapstructure:"enabled"`
+			Address string `mapstructure:"address"`
+		} `mapstructure:"admin"`
 	} `mapstructure:"server"`
 
 	// Database configuration
 	Database struct {
-		URL             string        `mapstructure:"url"`
-		MaxConnections  int32         `mapstructure:"max_connections"`
-		MinConnections  int32         `mapstructure:"min_connections"`
-		Timeout         time.Duration `mapstructure:"timeout"`
-		MaxConnLifetime time.Duration `mapstructure:"max_conn_lifetime"`
-		MaxConnIdleTime time.Duration `mapstructure:"max_conn_idle_time"`
-		RunMigrations   bool          `mapstructure:"run_migrations"`
-		SSLMode         string        `mapstructure:"ssl_mode"`
+		URL            string `mapstructure:"url"`
+		MaxConnections int32  `mapstructure:"max_connections"`
+		MinConnections int32  `mapstructure:"min_connections"`
+		// Timeout is the default deadline for queries whose context has none.
+		Timeout time.Duration `mapstructure:"timeout"`
+		// SlowQueryThreshold logs queries that take at least this long; zero disables it.
+		SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold"`
+		MaxConnLifetime    time.Duration `mapstructure:"max_conn_lifetime"`
+		MaxConnIdleTime    time.Duration `mapstructure:"max_conn_idle_time"`
+		RunMigrations      bool          `mapstructure:"run_migrations"`
+		SSLMode            string        `mapstructure:"ssl_mode"`
 	} `mapstructure:"database"`
 
 	// Application configuration
@@ -53,8 +89,148 @@ type Config struct {
 		TrustedProxies []string `mapstructure:"trusted_proxies"`
 		EnableCORS     bool     `mapstructure:"enable_cors"`
 		AllowedOrigins []string `mapstructure:"allowed_origins"`
+
+		// Response headers. Empty values omit the header; "{nonce}" in the
+		// content security policy is replaced with the per-request nonce.
+		ContentSecurityPolicy     string `mapstructure:"content_security_policy"`
+		CSPReportOnly             bool   `mapstructure:"csp_report_only"`
+		CSPReportURI              string `mapstructure:"csp_report_uri"`
+		HSTSMaxAge                int    `mapstructure:"hsts_max_age"`
+		HSTSIncludeSubdomains     bool   `mapstructure:"hsts_include_subdomains"`
+		HSTSPreload               bool   `mapstructure:"hsts_preload"`
+		XSSProtection             string `mapstructure:"xss_protection"`
+		ContentTypeNosniff        string `mapstructure:"content_type_nosniff"`
+		XFrameOptions             string `mapstructure:"x_frame_options"`
+		ReferrerPolicy            string `mapstructure:"referrer_policy"`
+		PermissionsPolicy         string `mapstructure:"permissions_policy"`
+		CrossOriginOpenerPolicy   string `mapstructure:"cross_origin_opener_policy"`
+		CrossOriginEmbedderPolicy string `mapstructure:"cross_origin_embedder_policy"`
 	} `mapstructure:"security"`
 
+	// Health probe configuration
+	Health struct {
+		// CheckTimeout bounds each readiness check.
+		CheckTimeout time.Duration `mapstructure:"check_timeout"`
+		// DrainPeriod is how long /readyz fails after SIGTERM before shutdown begins.
+		DrainPeriod time.Duration `mapstructure:"drain_period"`
+		// PoolSaturationThreshold fails readiness when this fraction of the pool is in use.
+		PoolSaturationThreshold float64 `mapstructure:"pool_saturation_threshold"`
+	} `mapstructure:"health"`
+
+	// Tracing configuration
+	Tracing struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Exporter is one of "stdout", "file" or "otlp".
+		Exporter string `mapstructure:"exporter"`
+		// Endpoint is the OTLP/HTTP collector URL, e.g. http://localhost:4318.
+		Endpoint string `mapstructure:"endpoint"`
+		// FilePath receives JSON spans when Exporter is "file".
+		FilePath string `mapstructure:"file_path"`
+		// SampleRatio is the fraction of new traces that are recorded.
+		SampleRatio float64 `mapstructure:"sample_ratio"`
+	} `mapstructure:"tracing"`
+
+	// Data retention configuration
+	Retention struct {
+		// Users is how long deleted users stay in the trash before they are
+		// purged with their sessions; zero keeps them forever.
+		Users time.Duration `mapstructure:"users"`
+		// Audit is how long audit events are kept; zero keeps them forever.
+		Audit time.Duration `mapstructure:"audit"`
+		// Jobs is how long finished background jobs are kept; zero keeps
+		// them forever.
+		Jobs time.Duration `mapstructure:"jobs"`
+		// Webhooks is how long webhook delivery logs are kept; zero keeps
+		// them forever.
+		Webhooks time.Duration `mapstructure:"webhooks"`
+	} `mapstructure:"retention"`
+
+	// Maintenance scheduler configuration. Schedules are cron expressions;
+	// an empty schedule disables the task.
+	Scheduler struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Sessions purges expired sessions.
+		Sessions string `mapstructure:"sessions"`
+		// RateLimit purges expired rate limit buckets from the postgres backend.
+		RateLimit string `mapstructure:"ratelimit"`
+		// Retention purges rows older than their retention period.
+		Retention string `mapstructure:"retention"`
+	} `mapstructure:"scheduler"`
+
+	// Session configuration
+	Session struct {
+		// Lifetime is how long a session lasts after sign-in.
+		Lifetime time.Duration `mapstructure:"lifetime"`
+	} `mapstructure:"session"`
+
+	// Background job configuration
+	Jobs struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Workers is how many jobs this instance runs at once.
+		Workers int `mapstructure:"workers"`
+		// Interval is how often idle workers poll for due jobs.
+		Interval time.Duration `mapstructure:"interval"`
+		// Timeout bounds a single job attempt.
+		Timeout time.Duration `mapstructure:"timeout"`
+	} `mapstructure:"jobs"`
+
+	// Outbound webhook configuration. Deliveries run as background jobs.
+	Webhooks struct {
+		// Timeout bounds each delivery request.
+		Timeout time.Duration `mapstructure:"timeout"`
+		// DisableAfter disables an endpoint after this many failed attempts
+		// in a row; zero never disables it.
+		DisableAfter int32 `mapstructure:"disable_after"`
+		// AllowPrivateNetworks lets endpoints resolve to loopback, private
+		// and link-local addresses.
+		AllowPrivateNetworks bool `mapstructure:"allow_private_networks"`
+	} `mapstructure:"webhooks"`
+
+	// Personal data export and account deletion configuration
+	Privacy struct {
+		// DeletionCoolingOff is how long users can cancel an account
+		// deletion before the account is erased.
+		DeletionCoolingOff time.Duration `mapstructure:"deletion_cooling_off"`
+		// ExportLifetime is how long a personal data archive can be
+		// downloaded before it is purged.
+		ExportLifetime time.Duration `mapstructure:"export_lifetime"`
+	} `mapstructure:"privacy"`
+
+	// Uploaded media configuration
+	Media struct {
+		// Backend is local or s3.
+		Backend string `mapstructure:"backend"`
+		// Dir is where the local backend keeps files.
+		Dir string `mapstructure:"dir"`
+		// MaxAvatarSize is the largest avatar upload accepted, in bytes.
+		MaxAvatarSize int64 `mapstructure:"max_avatar_size"`
+		// MaxAvatarPixels is the largest avatar width times height
+		// accepted. Uploads are decoded in full, so it bounds their memory.
+		MaxAvatarPixels int `mapstructure:"max_avatar_pixels"`
+
+		// S3 configures the s3 backend, which works with any S3-compatible
+		// service such as MinIO.
+		S3 struct {
+			Endpoint        string        `mapstructure:"endpoint"`
+			Region          string        `mapstructure:"region"`
+			Bucket          string        `mapstructure:"bucket"`
+			AccessKeyID     string        `mapstructure:"access_key_id"`
+			SecretAccessKey string        `mapstructure:"secret_access_key"`
+			PathStyle       bool          `mapstructure:"path_style"`
+			Timeout         time.Duration `mapstructure:"timeout"`
+		} `mapstructure:"s3"`
+	} `mapstructure:"media"`
+
+	// Rate limiting configuration
+	RateLimit struct {
+		Enabled         bool                       `mapstructure:"enabled"`
+		Backend         string                     `mapstructure:"backend"`
+		TrustedNetworks []string                   `mapstructure:"trusted_networks"`
+		ExemptPaths     []string                   `mapstructure:"exempt_paths"`
+		Policies        map[string]RateLimitPolicy `mapstructure:"policies"`
+		Routes          map[string]string          `mapstructure:"routes"`
+	} `mapstructure:"ratelimit"`
+
 	// Feature flags
 	Features struct {
 		EnableMetrics bool `mapstructure:"enable_metrics"`
@@ -71,6 +247,14 @@ type Config struct {
 	} `mapstructure:"auth"`
 }
 
+// RateLimitPolicy describes a token bucket that can be assigned to route groups.
+type RateLimitPolicy struct {
+	Rate   int           `mapstructure:"rate"`
+	Burst  int           `mapstructure:"burst"`
+	Window time.Duration `mapstructure:"window"`
+	KeyBy  string        `mapstructure:"key_by"`
+}
+
 // New creates and returns a new configuration instance with defaults, file, and environment overrides.
 func New() *Config {
 	k := koanf.New(".")
@@ -174,16 +358,38 @@ func setDefaults(k *koanf.Koanf) error {
 		"server.read_timeout":     10 * time.Second,
 		"server.write_timeout":    10 * time.Second,
 		"server.shutdown_timeout": 30 * time.Second,
+		"server.body_limit":       1 << 20,
+
+		// Uploads need more room: the largest file plus the form around it.
+		"server.body_limits./profile/avatar": 6 << 20,
+		"server.body_limits./admin/imports":  11 << 20,
+
+		"server.json.reject_duplicate_keys": false,
+		"server.json.reject_unknown_fields": false,
+
+		"server.tls.enabled":           false,
+		"server.tls.cert_file":         "",
+		"server.tls.key_file":          "",
+		"server.tls.min_version":       "1.2",
+		"server.tls.cipher_suites":     []string{},
+		"server.tls.reload_interval":   time.Minute,
+		"server.tls.redirect_port":     "",
+		"server.tls.client_ca_file":    "",
+		"server.tls.client_auth_paths": []string{"/admin"},
+
+		"server.admin.enabled": false,
+		"server.admin.address": "127.0.0.1:9090",
 
 		// Database defaults - will be overridden by environment variables
-		"database.url":                "", // Will be constructed from individual vars if not set
-		"database.max_connections":    25,
-		"database.min_connections":    5,
-		"database.timeout":            30 * time.Second,
-		"database.max_conn_lifetime":  time.Hour,
-		"database.max_conn_idle_time": 30 * time.Minute,
-		"database.run_migrations":     true,
-		"database.ssl_mode":           "disable",
+		"database.url":                  "", // Will be constructed from individual vars if not set
+		"database.max_connections":      25,
+		"database.min_connections":      5,
+		"database.timeout":              30 * time.Second,
+		"database.slow_query_threshold": 200 * time.Millisecond,
+		"database.max_conn_lifetime":    time.Hour,
+		"database.max_conn_idle_time":   30 * time.Minute,
+		"database.run_migrations":       true,
+		"database.ssl_mode":             "disable",
 
 		// Application defaults
 		"app.environment": "development",
@@ -196,6 +402,107 @@ func setDefaults(k *koanf.Koanf) error {
 		"security.enable_cors":     true,
 		"security.allowed_origins": []string{"*"},
 
+		"security.content_security_policy": "default-src 'self'; script-src 'self' {nonce}; " +
+			"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; img-src 'self' data:; " +
+			"connect-src 'self'; font-src 'self' https://fonts.gstatic.com; object-src 'none'; " +
+			"base-uri 'self'; form-action 'self'; frame-ancestors 'none'",
+		"security.csp_report_only":              false,
+		"security.csp_report_uri":               "/csp-report",
+		"security.hsts_max_age":                 31536000,
+		"security.hsts_include_subdomains":      false,
+		"security.hsts_preload":                 false,
+		"security.xss_protection":               "1; mode=block",
+		"security.content_type_nosniff":         "nosniff",
+		"security.x_frame_options":              "DENY",
+		"security.referrer_policy":              "strict-origin-when-cross-origin",
+		"security.permissions_policy":           "geolocation=(), microphone=(), camera=()",
+		"security.cross_origin_opener_policy":   "same-origin",
+		"security.cross_origin_embedder_policy": "require-corp",
+
+		// Health probe defaults
+		"health.check_timeout":             2 * time.Second,
+		"health.pool_saturation_threshold": 0.9,
+
+		// Tracing defaults
+		"tracing.enabled":      false,
+		"tracing.exporter":     "stdout",
+		"tracing.endpoint":     "",
+		"tracing.file_path":    "traces.jsonl",
+		"tracing.sample_ratio": 1.0,
+
+		// Retention defaults
+		"retention.users":    30 * 24 * time.Hour,
+		"retention.audit":    365 * 24 * time.Hour,
+		"retention.jobs":     7 * 24 * time.Hour,
+		"retention.webhooks": 30 * 24 * time.Hour,
+
+		// Scheduler defaults
+		"scheduler.enabled":   true,
+		"scheduler.sessions":  "*/15 * * * *",
+		"scheduler.ratelimit": "* * * * *",
+		"scheduler.retention": "7 * * * *",
+
+		// Session defaults
+		"session.lifetime": 24 * time.Hour,
+
+		// Background job defaults
+		"jobs.enabled":  true,
+		"jobs.workers":  4,
+		"jobs.interval": time.Second,
+		"jobs.timeout":  5 * time.Minute,
+
+		// Webhook defaults
+		"webhooks.timeout":                10 * time.Second,
+		"webhooks.disable_after":          20,
+		"webhooks.allow_private_networks": false,
+
+		// Privacy defaults
+		"privacy.deletion_cooling_off": 14 * 24 * time.Hour,
+		"privacy.export_lifetime":      7 * 24 * time.Hour,
+
+		// Media defaults
+		"media.backend":           "local",
+		"media.dir":               "data/media",
+		"media.max_avatar_size":   5 << 20,
+		"media.max_avatar_pixels": 16_000_000,
+		"media.s3.region":         "us-east-1",
+		"media.s3.path_style":     true,
+		"media.s3.timeout":        30 * time.Second,
+
+		// Rate limiting defaults
+		"ratelimit.enabled":          true,
+		"ratelimit.backend":          "memory",
+		"ratelimit.trusted_networks": []string{},
+		"ratelimit.exempt_paths":     []string{"/health", "/livez", "/readyz"},
+
+		"ratelimit.policies.default.rate":   20,
+		"ratelimit.policies.default.burst":  40,
+		"ratelimit.policies.default.window": time.Second,
+		"ratelimit.policies.default.key_by": "ip",
+
+		"ratelimit.policies.auth.rate":   20,
+		"ratelimit.policies.auth.burst":  10,
+		"ratelimit.policies.auth.window": time.Minute,
+		"ratelimit.policies.auth.key_by": "ip",
+
+		"ratelimit.policies.api.rate":   120,
+		"ratelimit.policies.api.burst":  60,
+		"ratelimit.policies.api.window": time.Minute,
+		"ratelimit.policies.api.key_by": "user",
+
+		"ratelimit.policies.static.rate":   200,
+		"ratelimit.policies.static.burst":  400,
+		"ratelimit.policies.static.window": time.Second,
+		"ratelimit.policies.static.key_by": "ip",
+
+		"ratelimit.routes./auth":                  "auth",
+		"ratelimit.routes./api":                   "api",
+		"ratelimit.routes./static":                "static",
+		"ratelimit.routes./media":                 "static",
+		"ratelimit.routes./profile/data/deletion": "auth",
+		"ratelimit.routes./profile/edit":          "auth",
+		"ratelimit.routes./profile/password":      "auth",
+
 		// Feature flags defaults
 		"features.enable_metrics": false,
 		"features.enable_pprof":   false,
@@ -227,7 +534,12 @@ func (c *Config) GetLogLevel() slog.Level {
 
 func applyDerivedDefaults(k *koanf.Koanf, cfg *Config) {
 	if !k.Exists("auth.cookie_secure") {
-		cfg.Auth.CookieSecure = strings.EqualFold(cfg.App.Environment, "production")
+		cfg.Auth.CookieSecure = cfg.Server.TLS.Enabled || strings.EqualFold(cfg.App.Environment, "production")
+	}
+
+	// Draining only matters behind a load balancer; keep local restarts instant.
+	if !k.Exists("health.drain_period") && strings.EqualFold(cfg.App.Environment, "production") {
+		cfg.Health.DrainPeriod = 10 * time.Second
 	}
 }
 
@@ -2,6 +2,7 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/knadh/koanf/providers/confmap"
 	"github.com/knadh/koanf/v2"
@@ -13,6 +14,7 @@ func TestApplyDerivedDefaultsSetsCookieSecureByEnvironment(t *testing.T) {
 	tests := []struct {
 		name        string
 		environment string
+		tlsEnabled  bool
 		wantSecure  bool
 	}{
 		{
@@ -25,6 +27,12 @@ func TestApplyDerivedDefaultsSetsCookieSecureByEnvironment(t *testing.T) {
 			environment: "production",
 			wantSecure:  true,
 		},
+		{
+			name:        "TLS defaults to secure cookies",
+			environment: "development",
+			tlsEnabled:  true,
+			wantSecure:  true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -34,6 +42,7 @@ func TestApplyDerivedDefaultsSetsCookieSecureByEnvironment(t *testing.T) {
 			k := koanf.New(".")
 			cfg := Config{}
 			cfg.App.Environment = tt.environment
+			cfg.Server.TLS.Enabled = tt.tlsEnabled
 
 			applyDerivedDefaults(k, &cfg)
 
@@ -65,6 +74,24 @@ func TestApplyDerivedDefaultsPreservesExplicitCookieSecure(t *testing.T) {
 	}
 }
 
+func TestApplyDerivedDefaultsDrainsOnlyInProduction(t *testing.T) {
+	t.Parallel()
+
+	for environment, want := range map[string]time.Duration{
+		"development": 0,
+		"production":  10 * time.Second,
+	} {
+		cfg := Config{}
+		cfg.App.Environment = environment
+
+		applyDerivedDefaults(koanf.New("."), &cfg)
+
+		if cfg.Health.DrainPeriod != want {
+			t.Fatalf("%s DrainPeriod = %v, want %v", environment, cfg.Health.DrainPeriod, want)
+		}
+	}
+}
+
 func TestBuildDatabaseURLEscapesReservedCharacters(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,283 @@
+// Package events tells every instance when something changes. Events are
+// published with PostgreSQL NOTIFY; each instance LISTENs on one dedicated
+// connection and fans what it hears out to its local subscribers, including
+// the publisher's own. A bus without a database delivers in-process only.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// Channel is the NOTIFY channel every instance listens on.
+	Channel = "app_events"
+	// maxNotifyPayload is the largest payload NOTIFY accepts. Larger events
+	// are spilled to event_payloads and sent by reference.
+	maxNotifyPayload = 7999
+	// subscriptionBuffer is how many messages a subscriber may fall behind
+	// before further ones are dropped for it.
+	subscriptionBuffer = 64
+	// maxReconnectDelay caps the wait between attempts to re-establish LISTEN.
+	maxReconnectDelay = 30 * time.Second
+	// loadTimeout bounds loading a spilled payload.
+	loadTimeout = 5 * time.Second
+)
+
+// Event is one published change as it travels between instances.
+type Event struct {
+	Topic string          `json:"topic"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	// Ref is the event_payloads row holding Data when it was too large to
+	// send inline.
+	Ref int64 `json:"ref,omitempty"`
+}
+
+// subscriber is a Subscription of any data type.
+type subscriber interface {
+	wants(topic string) bool
+	offer(event Event)
+	missed()
+	close()
+}
+
+// Bus publishes events and delivers them to subscribers.
+type Bus struct {
+	// store and connConfig are nil for an in-process bus.
+	store      store.Querier
+	connConfig *pgx.ConnConfig
+
+	mu     sync.Mutex
+	subs   map[subscriber]struct{}
+	closed bool
+	stop   context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewBus creates a bus that delivers events within this process only.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[subscriber]struct{})}
+}
+
+// NewPostgresBus creates a bus that publishes through db and, once started,
+// delivers events published by any instance.
+func NewPostgresBus(db *store.Store) *Bus {
+	return newPostgresBus(db, db.DB().Config().ConnConfig)
+}
+
+func newPostgresBus(q store.Querier, connConfig *pgx.ConnConfig) *Bus {
+	bus := NewBus()
+	bus.store = q
+	bus.connConfig = connConfig
+
+	return bus
+}
+
+// Start listens for events from other instances in the background. It does
+// nothing for an in-process bus.
+func (b *Bus) Start(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.connConfig == nil || b.stop != nil || b.closed {
+		return
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	b.stop = stop
+
+	b.wg.Add(1)
+	go b.listen(ctx)
+}
+
+// Close stops listening and closes every subscription, which ends the
+// streams reading from them. Later subscriptions are closed immediately.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	b.closed = true
+	stop := b.stop
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		sub.close()
+	}
+	b.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+	b.wg.Wait()
+}
+
+func (b *Bus) subscribe(sub subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		sub.close()
+		return
+	}
+	b.subs[sub] = struct{}{}
+}
+
+func (b *Bus) unsubscribe(sub subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subs, sub)
+	sub.close()
+}
+
+// publish sends data on topic. With PostgreSQL it is delivered to every
+// listening instance, this one included, once NOTIFY is sent.
+func (b *Bus) publish(ctx context.Context, topic string, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", topic, err)
+	}
+	event := Event{Topic: topic, Data: encoded}
+
+	if b.store == nil {
+		b.deliver(event)
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", topic, err)
+	}
+	if len(payload) > maxNotifyPayload {
+		ref, err := b.store.CreateEventPayload(ctx, store.CreateEventPayloadParams{Topic: topic, Data: encoded})
+		if err != nil {
+			return fmt.Errorf("spill %s event: %w", topic, err)
+		}
+
+		payload, err = json.Marshal(Event{Topic: topic, Ref: ref})
+		if err != nil {
+			return fmt.Errorf("encode %s event: %w", topic, err)
+		}
+	}
+
+	if err := b.store.Notify(ctx, store.NotifyParams{Channel: Channel, Payload: string(payload)}); err != nil {
+		return fmt.Errorf("notify %s event: %w", topic, err)
+	}
+
+	return nil
+}
+
+// deliver hands event to every subscriber of its topic.
+func (b *Bus) deliver(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		if sub.wants(event.Topic) {
+			sub.offer(event)
+		}
+	}
+}
+
+// resync tells every subscriber that events may have been lost.
+func (b *Bus) resync() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		sub.missed()
+	}
+}
+
+// receive decodes a notification, loading spilled data, and delivers it.
+func (b *Bus) receive(ctx context.Context, payload string) {
+	var event Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		slog.Warn("ignored malformed event", "error", err)
+		return
+	}
+
+	if event.Ref != 0 {
+		loadCtx, cancel := context.WithTimeout(ctx, loadTimeout)
+		defer cancel()
+
+		data, err := b.store.GetEventPayload(loadCtx, event.Ref)
+		if err != nil {
+			slog.Warn("failed to load spilled event", "topic", event.Topic, "ref", event.Ref, "error", err)
+			return
+		}
+		event.Data, event.Ref = data, 0
+	}
+
+	b.deliver(event)
+}
+
+// listen relays notifications to local subscribers, reconnecting with
+// backoff whenever the connection is lost.
+func (b *Bus) listen(ctx context.Context) {
+	defer b.wg.Done()
+
+	delay := time.Second
+	reconnecting := false
+	for {
+		connected, err := b.listenOnce(ctx, reconnecting)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = time.Second
+			reconnecting = true
+		}
+
+		slog.Warn("event listener disconnected; reconnecting", "error", err, "retry_in", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// listenOnce holds one dedicated connection until it fails or ctx ends. It
+// reports whether LISTEN was established. Once a lost connection has been
+// replaced, subscribers are told they may have missed events.
+func (b *Bus) listenOnce(ctx context.Context, reconnecting bool) (bool, error) {
+	// A dedicated connection rather than a pooled one: LISTEN state must not
+	// leak to other queries, and a held pool connection would shrink the pool.
+	conn, err := pgx.ConnectConfig(ctx, b.connConfig.Copy())
+	if err != nil {
+		return false, fmt.Errorf("connect: %w", err)
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		_ = conn.Close(closeCtx)
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return false, fmt.Errorf("listen: %w", err)
+	}
+	slog.Info("event listener connected", "channel", Channel)
+
+	if reconnecting {
+		b.resync()
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+
+		b.receive(ctx, notification.Payload)
+	}
+}
@@ -0,0 +1,136 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	storemem "github.com/dunamismax/go-web-server/internal/store/memstore"
+)
+
+type change struct {
+	ID   int64  `json:"id"`
+	Note string `json:"note,omitempty"`
+}
+
+const (
+	changeCreated Topic[change] = "change.created"
+	changeUpdated Topic[change] = "change.updated"
+)
+
+func TestBusDeliversToTopicSubscribers(t *testing.T) {
+	t.Parallel()
+
+	bus := NewBus()
+	defer bus.Close()
+
+	changes := Subscribe(bus, changeCreated, changeUpdated)
+	counts := Subscribe(bus, Topic[int]("count.changed"))
+
+	if err := changeUpdated.Publish(context.Background(), bus, change{ID: 7}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	msg := <-changes.Messages()
+	if msg.Topic != changeUpdated || msg.Data.ID != 7 || msg.Missed {
+		t.Fatalf("message = %+v, want change.updated for 7", msg)
+	}
+	select {
+	case msg := <-counts.Messages():
+		t.Fatalf("count subscriber received %+v", msg)
+	default:
+	}
+
+	changes.Close()
+	if err := changeCreated.Publish(context.Background(), bus, change{ID: 8}); err != nil {
+		t.Fatalf("Publish() after unsubscribe error = %v", err)
+	}
+	if _, ok := <-changes.Messages(); ok {
+		t.Fatal("closed subscription still receives messages")
+	}
+}
+
+func TestBusCloseEndsSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	bus := NewBus()
+	sub := Subscribe(bus, changeUpdated)
+
+	bus.Close()
+	if _, ok := <-sub.Messages(); ok {
+		t.Fatal("subscription still open after Close")
+	}
+	if _, ok := <-Subscribe(bus, changeUpdated).Messages(); ok {
+		t.Fatal("subscription after Close is open")
+	}
+	sub.Close()
+}
+
+func TestBusPublishRejectsUnencodableData(t *testing.T) {
+	t.Parallel()
+
+	bus := NewBus()
+	defer bus.Close()
+
+	var typeErr *json.UnsupportedTypeError
+	if err := Topic[chan int]("chan.sent").Publish(context.Background(), bus, make(chan int)); !errors.As(err, &typeErr) {
+		t.Fatalf("Publish() error = %v, want an unsupported type error", err)
+	}
+}
+
+func TestBusSpillsLargePayloads(t *testing.T) {
+	t.Parallel()
+
+	db := storemem.New()
+	bus := newPostgresBus(db, nil)
+	defer bus.Close()
+
+	sub := Subscribe(bus, changeUpdated)
+	ctx := context.Background()
+
+	small := change{ID: 1}
+	large := change{ID: 2, Note: strings.Repeat("x", maxNotifyPayload)}
+	for _, data := range []change{small, large} {
+		if err := changeUpdated.Publish(ctx, bus, data); err != nil {
+			t.Fatalf("Publish(%d) error = %v", data.ID, err)
+		}
+	}
+
+	notifications := db.Notifications()
+	if len(notifications) != 2 {
+		t.Fatalf("notifications = %d, want 2", len(notifications))
+	}
+	for i, want := range []change{small, large} {
+		n := notifications[i]
+		if n.Channel != Channel || len(n.Payload) > maxNotifyPayload {
+			t.Fatalf("notification %d = %q on %s, want at most %d bytes on %s", i, n.Payload[:min(len(n.Payload), 40)], n.Channel, maxNotifyPayload, Channel)
+		}
+
+		bus.receive(ctx, n.Payload)
+		msg := <-sub.Messages()
+		if msg.Data != want {
+			t.Fatalf("delivered %d = %+v, want %d with a %d byte note", i, msg.Data.ID, want.ID, len(want.Note))
+		}
+	}
+
+	var event Event
+	if err := json.Unmarshal([]byte(notifications[1].Payload), &event); err != nil || event.Ref == 0 || event.Data != nil {
+		t.Fatalf("large notification = %+v (%v), want a reference only", event, err)
+	}
+}
+
+func TestBusResyncMarksSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	bus := NewBus()
+	defer bus.Close()
+
+	sub := Subscribe(bus, changeUpdated)
+	bus.resync()
+
+	if msg := <-sub.Messages(); !msg.Missed {
+		t.Fatalf("message after resync = %+v, want Missed", msg)
+	}
+}
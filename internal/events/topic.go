@@ -0,0 +1,94 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// Topic names a stream of events whose data is a T. Publishers and
+// subscribers share the Topic value, so the compiler checks they agree on
+// the data type.
+type Topic[T any] string
+
+// Publish sends data to every subscriber of the topic, on every instance.
+func (t Topic[T]) Publish(ctx context.Context, b *Bus, data T) error {
+	return b.publish(ctx, string(t), data)
+}
+
+// Message is one event received by a Subscription.
+type Message[T any] struct {
+	Topic Topic[T]
+	Data  T
+	// Missed reports that events may have been lost, e.g. while the
+	// listener was reconnecting. Topic and Data are unset; subscribers
+	// should reload whatever they derive from the events.
+	Missed bool
+}
+
+// Subscription receives the events of its topics until it is closed.
+type Subscription[T any] struct {
+	bus      *Bus
+	topics   []Topic[T]
+	messages chan Message[T]
+	once     sync.Once
+}
+
+// Subscribe returns a subscription to topics. Close it when done.
+func Subscribe[T any](b *Bus, topics ...Topic[T]) *Subscription[T] {
+	sub := &Subscription[T]{
+		bus:      b,
+		topics:   topics,
+		messages: make(chan Message[T], subscriptionBuffer),
+	}
+	b.subscribe(sub)
+
+	return sub
+}
+
+// Messages returns the channel messages arrive on. It is closed when the
+// subscription or the bus is closed.
+func (s *Subscription[T]) Messages() <-chan Message[T] {
+	return s.messages
+}
+
+// Close stops the subscription.
+func (s *Subscription[T]) Close() {
+	s.bus.unsubscribe(s)
+}
+
+func (s *Subscription[T]) wants(topic string) bool {
+	return slices.Contains(s.topics, Topic[T](topic))
+}
+
+// offer decodes event and queues it, dropping it if the subscriber has
+// fallen too far behind. It is called with the bus lock held, so it never
+// blocks.
+func (s *Subscription[T]) offer(event Event) {
+	var data T
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		slog.Warn("ignored malformed event", "topic", event.Topic, "error", err)
+		return
+	}
+
+	select {
+	case s.messages <- Message[T]{Topic: Topic[T](event.Topic), Data: data}:
+	default:
+		slog.Warn("dropped event for slow subscriber", "topic", event.Topic)
+	}
+}
+
+func (s *Subscription[T]) missed() {
+	select {
+	case s.messages <- Message[T]{Missed: true}:
+	default:
+		// A full buffer is drained before anything newer arrives; dropping
+		// the marker here is no worse than the events already queued.
+	}
+}
+
+func (s *Subscription[T]) close() {
+	s.once.Do(func() { close(s.messages) })
+}
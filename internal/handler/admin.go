@@ -0,0 +1,576 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/jobs"
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/view"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// auditPageSize is how many events the audit page loads at a time.
+	auditPageSize = 50
+	// auditExportBatchSize is how many events an export reads per query.
+	auditExportBatchSize = 500
+	// jobPageSize is how many jobs the jobs page loads at a time.
+	jobPageSize = 50
+)
+
+// AdminHandler serves the administrative pages under /admin.
+type AdminHandler struct {
+	store       store.TxQuerier
+	authService *middleware.SessionAuthService
+}
+
+// NewAdminHandler creates a new AdminHandler.
+func NewAdminHandler(s store.TxQuerier, authService *middleware.SessionAuthService) *AdminHandler {
+	return &AdminHandler{store: s, authService: authService}
+}
+
+// RequireAdmin lets only administrators through. It runs after RequireAuth
+// and reads users.is_admin on every request, so revoking the flag takes
+// effect immediately.
+func (h *AdminHandler) RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		userID, ok := middleware.GetCurrentUserID(c)
+		if !ok {
+			return authenticationError(c, "Authentication required")
+		}
+
+		admin, err := isAdmin(c.Request().Context(), h.store, userID)
+		if err != nil {
+			return internalError(c, "Failed to check administrator access", err)
+		}
+		if !admin {
+			return middleware.NewAppError(
+				middleware.ErrorTypeAuthorization,
+				http.StatusForbidden,
+				"Administrator access required",
+			).WithContext(c)
+		}
+
+		return next(c)
+	}
+}
+
+// isAdmin reports whether userID is an active administrator. Missing and
+// trashed users are not.
+func isAdmin(ctx context.Context, q store.Querier, userID int64) (bool, error) {
+	user, err := q.GetUser(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return user.IsAdmin && isActiveUser(user), nil
+}
+
+// auditFilter narrows the audit log; zero values match everything.
+type auditFilter struct {
+	Action   string
+	Actor    string
+	TargetID int64
+}
+
+func parseAuditFilter(c echo.Context) (auditFilter, error) {
+	filter := auditFilter{
+		Action: strings.TrimSpace(c.QueryParam("action")),
+		Actor:  strings.TrimSpace(c.QueryParam("actor")),
+	}
+
+	if filter.Action != "" && !slices.Contains(AuditActions, filter.Action) {
+		return filter, middleware.NewAppError(
+			middleware.ErrorTypeValidation,
+			http.StatusBadRequest,
+			"Unknown audit action",
+		).WithContext(c)
+	}
+
+	if raw := strings.TrimSpace(c.QueryParam("target_id")); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			return filter, middleware.NewAppError(
+				middleware.ErrorTypeValidation,
+				http.StatusBadRequest,
+				"Target ID must be a positive number",
+			).WithContext(c)
+		}
+		filter.TargetID = id
+	}
+
+	return filter, nil
+}
+
+// params builds the query for events older than beforeID, or the newest
+// events when beforeID is zero.
+func (f auditFilter) params(beforeID int64, limit int32) store.ListAuditEventsParams {
+	params := store.ListAuditEventsParams{
+		Action:     stringPtr(f.Action),
+		ActorEmail: stringPtr(f.Actor),
+		MaxRows:    limit,
+	}
+	if f.TargetID > 0 {
+		params.TargetID = &f.TargetID
+	}
+	if beforeID > 0 {
+		params.BeforeID = &beforeID
+	}
+
+	return params
+}
+
+func (f auditFilter) query() url.Values {
+	query := url.Values{}
+	if f.Action != "" {
+		query.Set("action", f.Action)
+	}
+	if f.Actor != "" {
+		query.Set("actor", f.Actor)
+	}
+	if f.TargetID > 0 {
+		query.Set("target_id", strconv.FormatInt(f.TargetID, 10))
+	}
+
+	return query
+}
+
+func (f auditFilter) view() view.AuditFilter {
+	filter := view.AuditFilter{Action: f.Action, Actor: f.Actor}
+	if f.TargetID > 0 {
+		filter.TargetID = strconv.FormatInt(f.TargetID, 10)
+	}
+
+	return filter
+}
+
+// auditPage loads one page of events and the link to the next one.
+func (h *AdminHandler) auditPage(c echo.Context, filter auditFilter, beforeID int64) (view.AuditPage, error) {
+	// Fetch one extra row to learn whether another page exists.
+	events, err := h.store.ListAuditEvents(c.Request().Context(), filter.params(beforeID, auditPageSize+1))
+	if err != nil {
+		return view.AuditPage{}, err
+	}
+
+	page := view.AuditPage{Query: filter.query().Encode()}
+	if len(events) > auditPageSize {
+		events = events[:auditPageSize]
+
+		next := filter.query()
+		next.Set("before", strconv.FormatInt(events[len(events)-1].ID, 10))
+		page.NextURL = RouteAdminAuditEvents + "?" + next.Encode()
+	}
+	page.Events = events
+
+	return page, nil
+}
+
+// AuditLog renders the audit log page.
+func (h *AdminHandler) AuditLog(c echo.Context) error {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return err
+	}
+
+	page, err := h.auditPage(c, filter, 0)
+	if err != nil {
+		return logAndReturnError(c, "fetch audit events", err, http.StatusInternalServerError, "Failed to fetch audit events")
+	}
+
+	token := setupCSRFHeaders(c)
+
+	return renderWithCSRF(c, "Audit",
+		view.AuditContent(filter.view(), AuditActions, page),         // HTMX component
+		view.AuditWithCSRF(filter.view(), AuditActions, page, token), // Full page component with CSRF
+		view.Audit(filter.view(), AuditActions, page),                // Basic component
+	)
+}
+
+// AuditEvents returns filtered audit events as HTML fragment. With a before
+// cursor it returns only the next page of rows for "Load more".
+func (h *AdminHandler) AuditEvents(c echo.Context) error {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return err
+	}
+
+	var beforeID int64
+	if raw := c.QueryParam("before"); raw != "" {
+		beforeID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || beforeID <= 0 {
+			return middleware.NewAppError(
+				middleware.ErrorTypeValidation,
+				http.StatusBadRequest,
+				"Invalid page cursor",
+			).WithContext(c)
+		}
+	}
+
+	page, err := h.auditPage(c, filter, beforeID)
+	if err != nil {
+		return logAndReturnError(c, "fetch audit events", err, http.StatusInternalServerError, "Failed to fetch audit events")
+	}
+
+	if beforeID > 0 {
+		return render(c, "AuditEventRows", view.AuditEventRows(page))
+	}
+
+	return render(c, "AuditEventTable", view.AuditEventTable(page))
+}
+
+// auditExportRecord is one exported event; before and after stay raw JSON.
+type auditExportRecord struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorID    *int64          `json:"actor_id"`
+	ActorEmail *string         `json:"actor_email"`
+	Action     string          `json:"action"`
+	TargetType *string         `json:"target_type"`
+	TargetID   *int64          `json:"target_id"`
+	IP         *string         `json:"ip"`
+	UserAgent  *string         `json:"user_agent"`
+	RequestID  *string         `json:"request_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+}
+
+var auditCSVHeader = []string{
+	"id", "occurred_at", "actor_id", "actor_email", "action", "target_type", "target_id",
+	"ip", "user_agent", "request_id", "before", "after",
+}
+
+// ExportAudit streams every event matching the filter as CSV or NDJSON,
+// newest first.
+func (h *AdminHandler) ExportAudit(c echo.Context) error {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return err
+	}
+
+	format := c.QueryParam("format")
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv; charset=utf-8"
+	case "ndjson":
+		contentType = "application/x-ndjson"
+	default:
+		return middleware.NewAppError(
+			middleware.ErrorTypeValidation,
+			http.StatusBadRequest,
+			"Export format must be csv or ndjson",
+		).WithContext(c)
+	}
+
+	// Large exports outlive the server's write timeout.
+	if err := clearWriteDeadline(c); err != nil {
+		return logAndReturnError(c, "start audit export", err, http.StatusInternalServerError, "Failed to export audit events")
+	}
+
+	ctx := c.Request().Context()
+	res := c.Response()
+
+	var (
+		csvWriter   *csv.Writer
+		jsonEncoder *json.Encoder
+		beforeID    int64
+		started     bool
+	)
+
+	for {
+		events, err := h.store.ListAuditEvents(ctx, filter.params(beforeID, auditExportBatchSize))
+		if err != nil {
+			if started {
+				// Headers are gone; the truncated body is all we can do.
+				return err
+			}
+			return logAndReturnError(c, "export audit events", err, http.StatusInternalServerError, "Failed to export audit events")
+		}
+
+		if !started {
+			res.Header().Set(echo.HeaderContentType, contentType)
+			res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="audit-events.`+format+`"`)
+			res.WriteHeader(http.StatusOK)
+			started = true
+
+			if format == "csv" {
+				csvWriter = csv.NewWriter(res)
+				if err := csvWriter.Write(auditCSVHeader); err != nil {
+					return err
+				}
+			} else {
+				jsonEncoder = json.NewEncoder(res)
+			}
+		}
+
+		for _, event := range events {
+			record := auditExportRecord{
+				ID:         event.ID,
+				OccurredAt: event.OccurredAt.Time.UTC(),
+				ActorID:    event.ActorID,
+				ActorEmail: event.ActorEmail,
+				Action:     event.Action,
+				TargetType: event.TargetType,
+				TargetID:   event.TargetID,
+				IP:         event.Ip,
+				UserAgent:  event.UserAgent,
+				RequestID:  event.RequestID,
+				Before:     event.Before,
+				After:      event.After,
+			}
+
+			if csvWriter != nil {
+				err = csvWriter.Write(record.csvRow())
+			} else {
+				err = jsonEncoder.Encode(record)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if csvWriter != nil {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				return err
+			}
+		}
+		res.Flush()
+
+		if len(events) < auditExportBatchSize {
+			return nil
+		}
+		beforeID = events[len(events)-1].ID
+	}
+}
+
+func (r auditExportRecord) csvRow() []string {
+	formatID := func(id *int64) string {
+		if id == nil {
+			return ""
+		}
+		return strconv.FormatInt(*id, 10)
+	}
+
+	return []string{
+		strconv.FormatInt(r.ID, 10),
+		r.OccurredAt.Format(time.RFC3339),
+		formatID(r.ActorID),
+		csvSafe(derefString(r.ActorEmail)),
+		csvSafe(r.Action),
+		csvSafe(derefString(r.TargetType)),
+		formatID(r.TargetID),
+		csvSafe(derefString(r.IP)),
+		csvSafe(derefString(r.UserAgent)),
+		csvSafe(derefString(r.RequestID)),
+		csvSafe(string(r.Before)),
+		csvSafe(string(r.After)),
+	}
+}
+
+// parseJobState reads the optional state filter from the query or form.
+func parseJobState(c echo.Context) (string, error) {
+	state := strings.TrimSpace(c.FormValue("state"))
+	if state != "" && !slices.Contains(jobs.States, state) {
+		return "", middleware.NewAppError(
+			middleware.ErrorTypeValidation,
+			http.StatusBadRequest,
+			"Unknown job state",
+		).WithContext(c)
+	}
+
+	return state, nil
+}
+
+// jobPage loads one page of jobs in state, the link to the next page and the
+// per-state counts.
+func (h *AdminHandler) jobPage(c echo.Context, state string, beforeID int64) (view.JobPage, error) {
+	ctx := c.Request().Context()
+
+	params := store.ListJobsParams{
+		State: stringPtr(state),
+		// Fetch one extra row to learn whether another page exists.
+		MaxRows: jobPageSize + 1,
+	}
+	if beforeID > 0 {
+		params.BeforeID = &beforeID
+	}
+
+	list, err := h.store.ListJobs(ctx, params)
+	if err != nil {
+		return view.JobPage{}, err
+	}
+
+	counts, err := h.store.CountJobsByState(ctx)
+	if err != nil {
+		return view.JobPage{}, err
+	}
+
+	page := view.JobPage{State: state, States: jobs.States, Counts: make(map[string]int64, len(counts))}
+	for _, row := range counts {
+		page.Counts[row.State] = row.Count
+	}
+
+	if len(list) > jobPageSize {
+		list = list[:jobPageSize]
+
+		next := url.Values{}
+		if state != "" {
+			next.Set("state", state)
+		}
+		next.Set("before", strconv.FormatInt(list[len(list)-1].ID, 10))
+		page.NextURL = RouteAdminJobList + "?" + next.Encode()
+	}
+	page.Jobs = list
+
+	return page, nil
+}
+
+// Jobs renders the background jobs page.
+func (h *AdminHandler) Jobs(c echo.Context) error {
+	state, err := parseJobState(c)
+	if err != nil {
+		return err
+	}
+
+	page, err := h.jobPage(c, state, 0)
+	if err != nil {
+		return logAndReturnError(c, "fetch jobs", err, http.StatusInternalServerError, "Failed to fetch jobs")
+	}
+
+	token := setupCSRFHeaders(c)
+
+	return renderWithCSRF(c, "Jobs",
+		view.JobsContent(page),         // HTMX component
+		view.JobsWithCSRF(page, token), // Full page component with CSRF
+		view.Jobs(page),                // Basic component
+	)
+}
+
+// JobList returns jobs as HTML fragment. With a before cursor it returns only
+// the next page of rows for "Load more".
+func (h *AdminHandler) JobList(c echo.Context) error {
+	state, err := parseJobState(c)
+	if err != nil {
+		return err
+	}
+
+	var beforeID int64
+	if raw := c.QueryParam("before"); raw != "" {
+		beforeID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || beforeID <= 0 {
+			return middleware.NewAppError(
+				middleware.ErrorTypeValidation,
+				http.StatusBadRequest,
+				"Invalid page cursor",
+			).WithContext(c)
+		}
+	}
+
+	page, err := h.jobPage(c, state, beforeID)
+	if err != nil {
+		return logAndReturnError(c, "fetch jobs", err, http.StatusInternalServerError, "Failed to fetch jobs")
+	}
+
+	if beforeID > 0 {
+		return render(c, "JobRows", view.JobRows(page))
+	}
+
+	return render(c, "JobTable", view.JobTable(page))
+}
+
+// RetryJob requeues a dead or cancelled job with a fresh set of attempts.
+func (h *AdminHandler) RetryJob(c echo.Context) error {
+	return h.changeJob(c, AuditJobRetry, jobs.StatePending, store.Querier.RetryJob)
+}
+
+// CancelJob cancels a job that has not started yet.
+func (h *AdminHandler) CancelJob(c echo.Context) error {
+	return h.changeJob(c, AuditJobCancel, jobs.StateCancelled, store.Querier.CancelJob)
+}
+
+// changeJob applies change to the job in the URL and audits it, then returns
+// the refreshed job table for the submitted state filter. Jobs that do not
+// exist or are not in a state change accepts are reported as not found.
+func (h *AdminHandler) changeJob(c echo.Context, action, newState string, change func(store.Querier, context.Context, int64) (int64, error)) error {
+	ctx := c.Request().Context()
+
+	id, err := parseIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	state, err := parseJobState(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		changed, err := change(q, ctx, id)
+		if err != nil {
+			return err
+		}
+		if changed == 0 {
+			return store.ErrNotFound
+		}
+
+		return recordAudit(c, q, auditRecord{
+			Action:     action,
+			ActorID:    currentActorID(c, h.authService),
+			TargetType: auditTargetJob,
+			TargetID:   &id,
+			After:      map[string]string{"state": newState},
+		})
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
+	if constraintErr, ok := store.AsConstraintError(err); ok && constraintErr.Kind == store.ConstraintUnique {
+		return conflictError(c, "Another job with the same unique key is already queued", nil)
+	}
+	if err != nil {
+		return logAndReturnError(c, action, err, http.StatusInternalServerError, "Failed to update job")
+	}
+
+	slog.InfoContext(ctx, "Job state changed",
+		"id", id,
+		"action", action,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	page, err := h.jobPage(c, state, 0)
+	if err != nil {
+		return logAndReturnError(c, "fetch jobs", err, http.StatusInternalServerError, "Failed to fetch jobs")
+	}
+
+	return render(c, "JobTable", view.JobTable(page))
+}
+
+// Tasks renders the scheduled maintenance tasks and their last runs.
+func (h *AdminHandler) Tasks(c echo.Context) error {
+	tasks, err := h.store.ListScheduledTasks(c.Request().Context())
+	if err != nil {
+		return logAndReturnError(c, "fetch scheduled tasks", err, http.StatusInternalServerError, "Failed to fetch scheduled tasks")
+	}
+
+	token := setupCSRFHeaders(c)
+
+	return renderWithCSRF(c, "Tasks",
+		view.TasksContent(tasks),         // HTMX component
+		view.TasksWithCSRF(tasks, token), // Full page component with CSRF
+		view.Tasks(tasks),                // Basic component
+	)
+}
@@ -0,0 +1,717 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/dunamismax/go-web-server/internal/health"
+	"github.com/dunamismax/go-web-server/internal/jobs"
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/privacy"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/userimport"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	ada := ts.register(t, "ada@example.com")
+	admin := ts.registerAdmin(t, "admin@example.com")
+
+	for _, target := range []string{RouteAdminAudit, RouteAdminAuditEvents, RouteAdminAuditExport + "?format=csv", RouteAdminJobs, RouteAdminJobList, RouteAdminTasks, RouteAdminWebhooks, RouteAdminImports, RouteAdminDeletions} {
+		if rec := ts.do(t, http.MethodGet, target, nil, ada); rec.Code != http.StatusForbidden {
+			t.Fatalf("GET %s as a user = %d, want %d", target, rec.Code, http.StatusForbidden)
+		}
+		if rec := ts.do(t, http.MethodGet, target, nil, admin); rec.Code != http.StatusOK {
+			t.Fatalf("GET %s as an admin = %d, want %d", target, rec.Code, http.StatusOK)
+		}
+	}
+
+	// Editing the profile cannot grant access; only the stored flag does.
+	rec := ts.do(t, http.MethodPut, "/users/1", url.Values{
+		"email":   {"root@example.com"},
+		"name":    {"Ada"},
+		"version": {"1"},
+	}, ada)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if rec := ts.do(t, http.MethodGet, RouteAdminAudit, nil, ada); rec.Code != http.StatusForbidden {
+		t.Fatalf("GET %s after editing the profile = %d, want %d", RouteAdminAudit, rec.Code, http.StatusForbidden)
+	}
+
+	// Deactivating an administrator locks them out on the next request.
+	rec = ts.do(t, http.MethodPatch, "/users/2/deactivate", nil, ada)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("deactivate status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := ts.do(t, http.MethodGet, RouteAdminAudit, nil, admin); rec.Code == http.StatusOK {
+		t.Fatalf("GET %s as a deactivated admin = %d, want an error", RouteAdminAudit, rec.Code)
+	}
+}
+
+func TestActionsAreAudited(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	admin := ts.register(t, "admin@example.com")
+	ts.register(t, "ada@example.com")
+
+	rec := ts.do(t, http.MethodPost, RouteLogin, url.Values{
+		"email":    {"ada@example.com"},
+		"password": {"wrong-password"},
+	}, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("login with wrong password status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec = ts.do(t, http.MethodPut, "/users/2", url.Values{
+		"email":   {"ada@example.com"},
+		"name":    {"Ada Lovelace"},
+		"version": {"1"},
+	}, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodDelete, "/users/2", nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	events, err := ts.store.ListAuditEvents(context.Background(), store.ListAuditEventsParams{MaxRows: 10})
+	if err != nil {
+		t.Fatalf("ListAuditEvents() error = %v", err)
+	}
+
+	var actions []string
+	for _, event := range events {
+		actions = append(actions, event.Action)
+	}
+	want := []string{AuditUserDelete, AuditUserUpdate, AuditLoginFailed, AuditRegister, AuditRegister}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Fatalf("audited actions = %v, want %v", actions, want)
+	}
+
+	update := events[1]
+	if update.ActorEmail == nil || *update.ActorEmail != "admin@example.com" || update.TargetID == nil || *update.TargetID != 2 {
+		t.Fatalf("update event = %+v, want admin acting on user 2", update)
+	}
+	if !strings.Contains(string(update.Before), `"name":"Test User"`) || !strings.Contains(string(update.After), `"name":"Ada Lovelace"`) {
+		t.Fatalf("update event before/after = %s / %s", update.Before, update.After)
+	}
+	if strings.Contains(string(update.After), "argon2") {
+		t.Fatal("audit event leaks the password hash")
+	}
+	if update.Ip == nil || *update.Ip != "192.0.2.1" {
+		t.Fatalf("update event IP = %v, want 192.0.2.1", update.Ip)
+	}
+
+	failed := events[2]
+	if failed.ActorID != nil || !strings.Contains(string(failed.After), `"reason":"wrong_password"`) {
+		t.Fatalf("failed login event = %+v, want anonymous wrong_password", failed)
+	}
+}
+
+func TestAuditLogPageFiltersAndPaginates(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	admin := ts.registerAdmin(t, "admin@example.com")
+
+	for range auditPageSize + 5 {
+		ts.do(t, http.MethodPost, RouteLogin, url.Values{
+			"email":    {"nobody@example.com"},
+			"password": {"irrelevant"},
+		}, nil)
+	}
+
+	rec := ts.do(t, http.MethodGet, RouteAdminAudit+"?action="+AuditRegister, nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d, want %d", RouteAdminAudit, rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), AuditRegister) || strings.Contains(rec.Body.String(), "<code>"+AuditLoginFailed) {
+		t.Fatal("audit page does not apply the action filter")
+	}
+
+	rec = ts.do(t, http.MethodGet, RouteAdminAuditEvents+"?action="+AuditLoginFailed, nil, admin)
+	body := rec.Body.String()
+	if strings.Count(body, "<code>"+AuditLoginFailed) != auditPageSize || !strings.Contains(body, "Load more") {
+		t.Fatalf("first page has %d events, want %d and a Load more button", strings.Count(body, "<code>"+AuditLoginFailed), auditPageSize)
+	}
+
+	rec = ts.do(t, http.MethodGet, RouteAdminAuditEvents+"?action="+AuditLoginFailed+"&before=7", nil, admin)
+	body = rec.Body.String()
+	if strings.Count(body, "<code>"+AuditLoginFailed) != 5 || strings.Contains(body, "Load more") || strings.Contains(body, "<table") {
+		t.Fatalf("second page = %s, want the 5 remaining rows only", body)
+	}
+
+	rec = ts.do(t, http.MethodGet, RouteAdminAuditEvents+"?action=user.teleport", nil, admin)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown action filter status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = ts.do(t, http.MethodGet, RouteAdminAudit, nil, nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("anonymous GET %s status = %d, want redirect to login", RouteAdminAudit, rec.Code)
+	}
+}
+
+func TestExportAudit(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	admin := ts.registerAdmin(t, "admin@example.com")
+	ts.register(t, "ada@example.com")
+
+	rec := ts.do(t, http.MethodGet, RouteAdminAuditExport+"?format=ndjson&actor=admin@example.com", nil, admin)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("NDJSON export = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	var records []auditExportRecord
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var record auditExportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 1 || records[0].Action != AuditRegister || records[0].ActorEmail == nil || *records[0].ActorEmail != "admin@example.com" {
+		t.Fatalf("NDJSON export = %+v, want the admin's registration only", records)
+	}
+
+	rec = ts.do(t, http.MethodGet, RouteAdminAuditExport+"?format=csv", nil, admin)
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 || lines[0] != strings.Join(auditCSVHeader, ",") {
+		t.Fatalf("CSV export = %q, want a header and two events", rec.Body.String())
+	}
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "audit-events.csv") {
+		t.Fatalf("CSV Content-Disposition = %q", rec.Header().Get("Content-Disposition"))
+	}
+
+	rec = ts.do(t, http.MethodGet, RouteAdminAuditExport+"?format=xml", nil, admin)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown export format status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestExportAuditNeutralizesFormulas(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	admin := ts.registerAdmin(t, "admin@example.com")
+
+	// The user agent is client-controlled and ends up in the login event.
+	const userAgent = `=HYPERLINK("https://evil.example","open")`
+	form := url.Values{"email": {"admin@example.com"}, "password": {testPassword}}
+	req := httptest.NewRequest(http.MethodPost, RouteLogin, strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	req.Header.Set("User-Agent", userAgent)
+	rec := httptest.NewRecorder()
+	ts.e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login status = %d, want %d: %s", rec.Code, http.StatusFound, rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodGet, RouteAdminAuditExport+"?format=csv&action="+AuditLogin, nil, admin)
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV export: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("CSV export = %q, want a header and the login", rows)
+	}
+
+	column := slices.Index(auditCSVHeader, "user_agent")
+	if got := rows[1][column]; got != "'"+userAgent {
+		t.Fatalf("user_agent cell = %q, want it prefixed with a quote", got)
+	}
+}
+
+func TestJobsPageRetriesAndCancelsJobs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ts := newTestServer(t)
+	admin := ts.registerAdmin(t, "admin@example.com")
+
+	enqueue := func(kind string) store.Job {
+		t.Helper()
+		job, err := ts.store.EnqueueJob(ctx, store.EnqueueJobParams{Kind: kind, MaxAttempts: 1})
+		if err != nil {
+			t.Fatalf("EnqueueJob() error = %v", err)
+		}
+		return job
+	}
+
+	dead := enqueue("mail.send")
+	pending := enqueue("report.build")
+
+	worker := "test"
+	if _, err := ts.store.ClaimJobs(ctx, store.ClaimJobsParams{Worker: &worker, Kinds: []string{"mail.send"}, MaxJobs: 1}); err != nil {
+		t.Fatalf("ClaimJobs() error = %v", err)
+	}
+	if err := ts.store.FailJob(ctx, store.FailJobParams{LastError: "smtp unavailable", ID: dead.ID}); err != nil {
+		t.Fatalf("FailJob() error = %v", err)
+	}
+
+	rec := ts.do(t, http.MethodGet, RouteAdminJobs+"?state=dead", nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d, want %d", RouteAdminJobs, rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "mail.send") || !strings.Contains(body, "smtp unavailable") || strings.Contains(body, "report.build") {
+		t.Fatalf("dead jobs page does not show only the dead job: %s", body)
+	}
+
+	rec = ts.do(t, http.MethodGet, RouteAdminJobList+"?state=bogus", nil, admin)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown state status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = ts.do(t, http.MethodPatch, "/admin/jobs/2/retry", url.Values{"state": {"dead"}}, admin)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("retry pending job status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = ts.do(t, http.MethodPatch, "/admin/jobs/1/retry", url.Values{"state": {"dead"}}, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("retry dead job status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "mail.send") {
+		t.Fatalf("retried job still listed as dead: %s", rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodPatch, "/admin/jobs/2/cancel", nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("cancel pending job status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	counts, err := ts.store.CountJobsByState(ctx)
+	if err != nil {
+		t.Fatalf("CountJobsByState() error = %v", err)
+	}
+	got := map[string]int64{}
+	for _, row := range counts {
+		got[row.State] = row.Count
+	}
+	if got["pending"] != 1 || got["cancelled"] != 1 {
+		t.Fatalf("job counts = %v, want one pending and one cancelled", got)
+	}
+
+	events, err := ts.store.ListAuditEvents(ctx, store.ListAuditEventsParams{MaxRows: 2})
+	if err != nil {
+		t.Fatalf("ListAuditEvents() error = %v", err)
+	}
+	if len(events) != 2 || events[0].Action != AuditJobCancel || events[1].Action != AuditJobRetry {
+		t.Fatalf("audit events = %+v, want job cancel then retry", events)
+	}
+	if events[0].TargetType == nil || *events[0].TargetType != "job" || *events[0].TargetID != pending.ID {
+		t.Fatalf("cancel event target = %v #%v, want job #%d", events[0].TargetType, events[0].TargetID, pending.ID)
+	}
+}
+
+func TestTasksPageAndHealthShowScheduledTasks(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ts := newTestServer(t)
+	admin := ts.registerAdmin(t, "admin@example.com")
+
+	next := pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}
+	for _, name := range []string{"sessions.purge", "retention.audit"} {
+		if err := ts.store.UpsertScheduledTask(ctx, store.UpsertScheduledTaskParams{Name: name, Schedule: "7 * * * *", NextRunAt: next}); err != nil {
+			t.Fatalf("UpsertScheduledTask() error = %v", err)
+		}
+	}
+	lastError := "database unavailable"
+	if err := ts.store.FinishScheduledTask(ctx, store.FinishScheduledTaskParams{
+		Status:    "failed",
+		LastError: &lastError,
+		NextRunAt: next,
+		Name:      "retention.audit",
+	}); err != nil {
+		t.Fatalf("FinishScheduledTask() error = %v", err)
+	}
+
+	rec := ts.do(t, http.MethodGet, RouteAdminTasks, nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d, want %d", RouteAdminTasks, rec.Code, http.StatusOK)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "sessions.purge") || !strings.Contains(body, "never run") || !strings.Contains(body, lastError) {
+		t.Fatalf("tasks page does not show both tasks and the failure: %s", body)
+	}
+
+	// The public probes answer with a status code and nothing else.
+	for _, target := range []string{RouteReadyz, RouteHealth} {
+		rec = ts.do(t, http.MethodGet, target, nil, nil)
+		if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+			t.Fatalf("public GET %s = %d %q, want %d with no body", target, rec.Code, rec.Body.String(), http.StatusOK)
+		}
+	}
+
+	ops := echo.New()
+	RegisterOpsRoutes(ops, NewOpsHandler(nil), NewHealthHandler(health.NewRegistry(0), ts.store), OpsOptions{})
+	rec = httptest.NewRecorder()
+	ops.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, RouteHealth, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ops GET %s status = %d, want %d despite a failed task", RouteHealth, rec.Code, http.StatusOK)
+	}
+	var report struct {
+		Tasks map[string]struct {
+			Status string `json:"status"`
+			Error  string `json:"error"`
+		} `json:"tasks"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode health report: %v", err)
+	}
+	if got := report.Tasks["retention.audit"]; got.Status != "failed" || got.Error != lastError {
+		t.Fatalf("health tasks = %+v, want retention.audit failed with its error", report.Tasks)
+	}
+	if got := report.Tasks["sessions.purge"]; got.Status != "pending" {
+		t.Fatalf("health tasks = %+v, want sessions.purge pending", report.Tasks)
+	}
+}
+
+func TestWebhooksPageRegistersEndpointsAndRedelivers(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ts := newTestServer(t)
+	admin := ts.registerAdmin(t, "admin@example.com")
+
+	// htmx runs with allowEval off, so hx-on handlers would never fire.
+	rec := ts.do(t, http.MethodGet, RouteAdminWebhooks, nil, admin)
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, "data-reset-on-success") || strings.Contains(body, "hx-on") {
+		t.Fatalf("webhooks page = %d, want a form reset through data-reset-on-success", rec.Code)
+	}
+
+	rec = ts.do(t, http.MethodPost, RouteAdminWebhooks, url.Values{
+		"url":    {"ftp://example.com/hooks"},
+		"events": {string(EventUserCreated)},
+	}, admin)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("create with ftp URL status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = ts.do(t, http.MethodPost, RouteAdminWebhooks, url.Values{
+		"url":    {"https://example.com/hooks"},
+		"events": {string(EventUserCreated)},
+	}, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "https://example.com/hooks") {
+		t.Fatalf("endpoint table does not list the new endpoint: %s", rec.Body.String())
+	}
+
+	ts.register(t, "ada@example.com")
+	rec = ts.do(t, http.MethodPatch, "/users/2/deactivate", nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("deactivate status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	deliveries, err := ts.store.ListWebhookDeliveries(ctx, store.ListWebhookDeliveriesParams{EndpointID: 1, MaxRows: 10})
+	if err != nil {
+		t.Fatalf("ListWebhookDeliveries() error = %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Event != string(EventUserCreated) {
+		t.Fatalf("deliveries = %+v, want one user.created delivery", deliveries)
+	}
+	if !strings.Contains(string(deliveries[0].Payload), `"email":"ada@example.com"`) || strings.Contains(string(deliveries[0].Payload), "argon2") {
+		t.Fatalf("delivery payload = %s, want the user without the password hash", deliveries[0].Payload)
+	}
+
+	rec = ts.do(t, http.MethodGet, "/admin/webhooks/1", nil, admin)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "whsec_") {
+		t.Fatalf("endpoint page status = %d, want %d with the signing secret", rec.Code, http.StatusOK)
+	}
+
+	rec = ts.do(t, http.MethodPost, "/admin/webhooks/1/deliveries/1/redeliver", nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("redeliver status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	redelivery, err := ts.store.GetWebhookDelivery(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetWebhookDelivery() error = %v", err)
+	}
+	if redelivery.RedeliveryOf == nil || *redelivery.RedeliveryOf != 1 || redelivery.EventID != deliveries[0].EventID {
+		t.Fatalf("redelivery = %+v, want a copy of delivery 1", redelivery)
+	}
+
+	jobs, err := ts.store.ListJobs(ctx, store.ListJobsParams{MaxRows: 10})
+	if err != nil {
+		t.Fatalf("ListJobs() error = %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("queued %d delivery jobs, want 2", len(jobs))
+	}
+
+	rec = ts.do(t, http.MethodPatch, "/admin/webhooks/1/disable", nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("disable status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	rec = ts.do(t, http.MethodPost, "/admin/webhooks/1/deliveries/1/redeliver", nil, admin)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("redeliver to disabled endpoint status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	rec = ts.do(t, http.MethodDelete, "/admin/webhooks/1", nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if _, err := ts.store.GetWebhookDelivery(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("GetWebhookDelivery() after endpoint delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestImportsPageQueuesUploadsAndAuditsImportedUsers(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ts := newTestServer(t)
+	admin := ts.registerAdmin(t, "admin@example.com")
+
+	upload := func(filename, data string, fields map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for name, value := range fields {
+			if err := writer.WriteField(name, value); err != nil {
+				t.Fatalf("WriteField() error = %v", err)
+			}
+		}
+		part, err := writer.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatalf("CreateFormFile() error = %v", err)
+		}
+		if _, err := io.WriteString(part, data); err != nil {
+			t.Fatalf("write form file: %v", err)
+		}
+		if err := writer.Close(); err != nil {
+			t.Fatalf("multipart Close() error = %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, RouteAdminImports, &body)
+		req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+		for _, cookie := range admin {
+			req.AddCookie(cookie)
+		}
+
+		rec := httptest.NewRecorder()
+		ts.e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := upload("users.csv", "email,role\n", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("upload without a name column status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec = upload("users.txt", "email,name\n", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("upload with unknown extension status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	data := "email,name,password\nada@example.com,Ada Lovelace," + testPassword + "\n"
+	rec = upload("users.csv", data, map[string]string{"mode": "best_effort", "dry_run": "true"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if got := rec.Header().Get(HtmxPushURL); got != "/admin/imports/1" {
+		t.Fatalf("%s = %q, want /admin/imports/1", HtmxPushURL, got)
+	}
+
+	imp, err := ts.store.GetUserImport(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetUserImport() error = %v", err)
+	}
+	if imp.State != userimport.StatePending || imp.Mode != string(userimport.ModeBestEffort) || !imp.DryRun || imp.CreatedBy == nil || *imp.CreatedBy != 1 {
+		t.Fatalf("import = %+v, want a pending best-effort dry run by the admin", imp)
+	}
+	if stored, err := ts.store.GetUserImportFile(ctx, imp.ID); err != nil || string(stored) != data {
+		t.Fatalf("GetUserImportFile() = %q, %v, want the uploaded file", stored, err)
+	}
+
+	queued, err := ts.store.ListJobs(ctx, store.ListJobsParams{MaxRows: 10})
+	if err != nil {
+		t.Fatalf("ListJobs() error = %v", err)
+	}
+	if len(queued) != 1 || queued[0].Kind != (userimport.ImportArgs{}).Kind() {
+		t.Fatalf("queued jobs = %+v, want one import job", queued)
+	}
+
+	rec = ts.do(t, http.MethodGet, "/admin/imports/1/progress", nil, admin)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `hx-trigger="every 1s"`) {
+		t.Fatalf("progress status = %d, want %d with polling while pending", rec.Code, http.StatusOK)
+	}
+	rec = ts.do(t, http.MethodGet, RouteAdminImports, nil, admin)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "users.csv") {
+		t.Fatalf("imports page status = %d, want %d listing the upload", rec.Code, http.StatusOK)
+	}
+
+	hasher := middleware.NewSessionAuthServiceWithParams(scs.New(), testArgon2Params)
+	rows, err := userimport.Decode(strings.NewReader(data), userimport.FormatCSV)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	result, err := userimport.New(ts.store, hasher, RecordImportedUser).Run(ctx, rows, userimport.Options{
+		Mode:    userimport.ModeAtomic,
+		ActorID: imp.CreatedBy,
+	})
+	if err != nil || result.Created != 1 {
+		t.Fatalf("Run() = %+v, %v, want one user created", result, err)
+	}
+
+	action := AuditUserImport
+	auditEvents, err := ts.store.ListAuditEvents(ctx, store.ListAuditEventsParams{Action: &action, MaxRows: 10})
+	if err != nil {
+		t.Fatalf("ListAuditEvents() error = %v", err)
+	}
+	if len(auditEvents) != 1 || auditEvents[0].TargetID == nil || *auditEvents[0].TargetID != 2 {
+		t.Fatalf("%s audit events = %+v, want one for user 2", AuditUserImport, auditEvents)
+	}
+	if strings.Contains(string(auditEvents[0].After), "argon2") {
+		t.Fatalf("audit state includes the password hash: %s", auditEvents[0].After)
+	}
+}
+
+func TestPersonalDataExportAndAccountDeletion(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ts := newTestServer(t)
+	ada := ts.register(t, "ada@example.com")
+	grace := ts.registerAdmin(t, "grace@example.com")
+
+	rec := ts.do(t, http.MethodGet, "/profile/data", nil, ada)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Request export") {
+		t.Fatalf("GET /profile/data status = %d, want %d with the export form", rec.Code, http.StatusOK)
+	}
+
+	rec = ts.do(t, http.MethodPost, "/profile/data/exports", url.Values{"format": {"xml"}}, ada)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("export with unknown format status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec = ts.do(t, http.MethodPost, "/profile/data/exports", url.Values{"format": {"json"}}, ada)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `hx-trigger="every 1s"`) {
+		t.Fatalf("export status = %d, want %d with polling while pending: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	registry := jobs.NewRegistry()
+	privacy.NewRunner(ts.store, ts.auth, privacy.DefaultConfig).Register(registry)
+	pool := jobs.NewPoolWithConfig(ts.store, registry, jobs.Config{Workers: 1, Interval: 10 * time.Millisecond})
+	pool.Start(ctx)
+	t.Cleanup(func() { _ = pool.Shutdown(ctx) })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		export, err := ts.store.GetDataExport(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetDataExport() error = %v", err)
+		}
+		if export.State == privacy.StateSucceeded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("export = %+v, want it built", export)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rec = ts.do(t, http.MethodGet, "/profile/data/exports/1", nil, grace)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("download of another user's export status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	rec = ts.do(t, http.MethodGet, "/profile/data/exports/1", nil, ada)
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != "application/json" {
+		t.Fatalf("download status = %d, content type %q, want %d JSON", rec.Code, rec.Header().Get(echo.HeaderContentType), http.StatusOK)
+	}
+	var archive privacy.Archive
+	if err := json.Unmarshal(rec.Body.Bytes(), &archive); err != nil || archive.Profile.Email != "ada@example.com" || len(archive.Sessions) != 1 {
+		t.Fatalf("archive = %+v (%v), want Ada's profile and session", archive, err)
+	}
+	if strings.Contains(rec.Body.String(), "argon2") {
+		t.Fatal("archive includes the password hash")
+	}
+
+	rec = ts.do(t, http.MethodPost, "/profile/data/deletion", url.Values{"password": {"wrong-password"}}, ada)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "password") {
+		t.Fatalf("deletion with wrong password status = %d, want %d naming the password", rec.Code, http.StatusBadRequest)
+	}
+	rec = ts.do(t, http.MethodPost, "/profile/data/deletion", url.Values{"password": {testPassword}}, ada)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Keep my account") {
+		t.Fatalf("deletion status = %d, want %d offering to cancel: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodGet, RouteAdminDeletions, nil, grace)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ada@example.com") {
+		t.Fatalf("GET %s status = %d, want %d listing Ada", RouteAdminDeletions, rec.Code, http.StatusOK)
+	}
+
+	rec = ts.do(t, http.MethodDelete, "/profile/data/deletion", nil, ada)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Delete my account") {
+		t.Fatalf("cancel status = %d, want %d with the deletion form back", rec.Code, http.StatusOK)
+	}
+	if _, err := ts.store.GetAccountDeletion(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("GetAccountDeletion() error = %v, want not found after cancelling", err)
+	}
+
+	// Skip the cooling-off period.
+	_, err := ts.store.RequestAccountDeletion(ctx, store.RequestAccountDeletionParams{
+		UserID:      1,
+		DeleteAfter: pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("RequestAccountDeletion() error = %v", err)
+	}
+	erased, err := privacy.NewEraser(ts.store, ts.auth, RecordErasedUser).Run(ctx)
+	if err != nil || erased != 1 {
+		t.Fatalf("Run() = %d, %v, want Ada erased", erased, err)
+	}
+
+	rec = ts.do(t, http.MethodGet, RouteProfile, nil, ada)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("GET %s after erasure status = %d, want %d to the login page", RouteProfile, rec.Code, http.StatusFound)
+	}
+	if _, err := ts.store.GetDataExport(ctx, 1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("GetDataExport() error = %v, want the archive erased too", err)
+	}
+
+	events, err := ts.store.ListAuditEvents(ctx, store.ListAuditEventsParams{MaxRows: 20})
+	if err != nil {
+		t.Fatalf("ListAuditEvents() error = %v", err)
+	}
+	var actions []string
+	for _, event := range events {
+		actions = append(actions, event.Action)
+	}
+	want := []string{AuditUserErase, AuditDeletionCancel, AuditDeletionRequest, AuditDataDownload, AuditDataExport}
+	if got := strings.Join(actions[:len(want)], ","); got != strings.Join(want, ",") {
+		t.Fatalf("audited actions = %v, want %v first", actions, want)
+	}
+	if events[0].After != nil || events[0].TargetID == nil || *events[0].TargetID != 1 {
+		t.Fatalf("erase event = %+v, want user 1 without their details", events[0])
+	}
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/webhooks"
+	"github.com/labstack/echo/v4"
+)
+
+// Audit actions recorded in audit_events.
+const (
+	AuditLogin            = "auth.login"
+	AuditLoginFailed      = "auth.login_failed"
+	AuditLogout           = "auth.logout"
+	AuditRegister         = "auth.register"
+	AuditUserCreate       = "user.create"
+	AuditUserUpdate       = "user.update"
+	AuditPasswordChange   = "user.password_change"
+	AuditUserDeactivate   = "user.deactivate"
+	AuditUserReactivate   = "user.reactivate"
+	AuditUserDelete       = "user.delete"
+	AuditUserRestore      = "user.restore"
+	AuditUserExport       = "user.export"
+	AuditJobRetry         = "job.retry"
+	AuditJobCancel        = "job.cancel"
+	AuditWebhookCreate    = "webhook.create"
+	AuditWebhookEnable    = "webhook.enable"
+	AuditWebhookDisable   = "webhook.disable"
+	AuditWebhookDelete    = "webhook.delete"
+	AuditWebhookRedeliver = "webhook.redeliver"
+	AuditImportUpload     = "import.upload"
+	AuditUserImport       = "user.import"
+	AuditDataExport       = "user.data_export"
+	AuditDataDownload     = "user.data_download"
+	AuditDeletionRequest  = "user.deletion_request"
+	AuditDeletionCancel   = "user.deletion_cancel"
+	AuditUserErase        = "user.erase"
+)
+
+// AuditActions lists every action, in the order the audit page offers them.
+var AuditActions = []string{
+	AuditLogin, AuditLoginFailed, AuditLogout, AuditRegister,
+	AuditUserCreate, AuditUserUpdate, AuditPasswordChange,
+	AuditUserDeactivate, AuditUserReactivate, AuditUserDelete, AuditUserRestore,
+	AuditUserExport,
+	AuditJobRetry, AuditJobCancel,
+	AuditWebhookCreate, AuditWebhookEnable, AuditWebhookDisable,
+	AuditWebhookDelete, AuditWebhookRedeliver,
+	AuditImportUpload, AuditUserImport,
+	AuditDataExport, AuditDataDownload,
+	AuditDeletionRequest, AuditDeletionCancel, AuditUserErase,
+}
+
+// Audit target types.
+const (
+	auditTargetUser    = "user"
+	auditTargetJob     = "job"
+	auditTargetWebhook = "webhook"
+	auditTargetImport  = "import"
+)
+
+// auditRecord describes one audit event. Before and After are stored as JSON;
+// nil leaves the column NULL. TargetType defaults to a user when TargetID is
+// set.
+type auditRecord struct {
+	Action     string
+	ActorID    *int64
+	TargetType string
+	TargetID   *int64
+	Before     any
+	After      any
+}
+
+// auditUser is the audited view of a user row; it never includes the
+// password hash.
+type auditUser struct {
+	ID        int64   `json:"id"`
+	Email     string  `json:"email"`
+	Name      string  `json:"name"`
+	Bio       *string `json:"bio,omitempty"`
+	AvatarURL *string `json:"avatar_url,omitempty"`
+	IsActive  bool    `json:"is_active"`
+	Version   int64   `json:"version"`
+}
+
+func auditUserSnapshot(user store.User) auditUser {
+	return auditUser{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Bio:       user.Bio,
+		AvatarURL: user.AvatarUrl,
+		IsActive:  isActiveUser(user),
+		Version:   user.Version,
+	}
+}
+
+func isActiveUser(user store.User) bool {
+	return user.IsActive != nil && *user.IsActive
+}
+
+// recordAudit writes record with the request's IP, user agent and request ID.
+// Pass the transaction's Querier so the event commits or rolls back with the
+// change it describes.
+func recordAudit(c echo.Context, q store.Querier, record auditRecord) error {
+	params, err := auditParams(record)
+	if err != nil {
+		return err
+	}
+
+	params.Ip = stringPtr(c.RealIP())
+	params.UserAgent = stringPtr(c.Request().UserAgent())
+	params.RequestID = stringPtr(c.Response().Header().Get(echo.HeaderXRequestID))
+
+	return q.CreateAuditEvent(c.Request().Context(), params)
+}
+
+// RecordImportedUser audits a user created by a bulk import and notifies
+// webhooks of it. It runs in the import's transaction, outside any request.
+func RecordImportedUser(ctx context.Context, q store.Querier, user store.User, actorID *int64) error {
+	params, err := auditParams(auditRecord{
+		Action:   AuditUserImport,
+		ActorID:  actorID,
+		TargetID: &user.ID,
+		After:    auditUserSnapshot(user),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := q.CreateAuditEvent(ctx, params); err != nil {
+		return err
+	}
+
+	_, err = webhooks.Dispatch(ctx, q, string(EventUserCreated), auditUserSnapshot(user))
+	return err
+}
+
+// RecordErasedUser audits an account erased at its owner's request and
+// notifies webhooks of it. The audit event keeps only the user's ID, since
+// the point of erasure is to stop holding their details; webhooks still get
+// the snapshot so other systems can find the user and erase them too. It
+// runs in the erasure's transaction, outside any request.
+func RecordErasedUser(ctx context.Context, q store.Querier, user store.User) error {
+	params, err := auditParams(auditRecord{
+		Action:   AuditUserErase,
+		TargetID: &user.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := q.CreateAuditEvent(ctx, params); err != nil {
+		return err
+	}
+
+	_, err = webhooks.Dispatch(ctx, q, string(EventUserDeleted), auditUserSnapshot(user))
+	return err
+}
+
+func auditParams(record auditRecord) (store.CreateAuditEventParams, error) {
+	before, err := auditJSON(record.Before)
+	if err != nil {
+		return store.CreateAuditEventParams{}, err
+	}
+	after, err := auditJSON(record.After)
+	if err != nil {
+		return store.CreateAuditEventParams{}, err
+	}
+
+	var targetType *string
+	if record.TargetID != nil {
+		targetType = stringPtr(cmp.Or(record.TargetType, auditTargetUser))
+	}
+
+	return store.CreateAuditEventParams{
+		ActorID:    record.ActorID,
+		Action:     record.Action,
+		TargetType: targetType,
+		TargetID:   record.TargetID,
+		Before:     before,
+		After:      after,
+	}, nil
+}
+
+func auditJSON(value any) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("encode audit state: %w", err)
+	}
+
+	return data, nil
+}
+
+// currentActorID returns the signed-in user's ID, or nil for anonymous requests.
+func currentActorID(c echo.Context, authService *middleware.SessionAuthService) *int64 {
+	user, ok := authService.GetCurrentUser(c)
+	if !ok {
+		return nil
+	}
+
+	return &user.ID
+}
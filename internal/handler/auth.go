@@ -5,24 +5,26 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/dunamismax/go-web-server/internal/events"
 	"github.com/dunamismax/go-web-server/internal/middleware"
 	"github.com/dunamismax/go-web-server/internal/store"
 	"github.com/dunamismax/go-web-server/internal/view"
-	"github.com/jackc/pgx/v5"
 	"github.com/labstack/echo/v4"
 )
 
 // AuthHandler handles authentication-related requests
 type AuthHandler struct {
-	store       *store.Store
+	store       store.TxQuerier
 	authService *middleware.SessionAuthService
+	events      *events.Bus
 }
 
 // NewAuthHandler creates a new AuthHandler
-func NewAuthHandler(s *store.Store, authService *middleware.SessionAuthService) *AuthHandler {
+func NewAuthHandler(s store.TxQuerier, authService *middleware.SessionAuthService, bus *events.Bus) *AuthHandler {
 	return &AuthHandler{
 		store:       s,
 		authService: authService,
+		events:      bus,
 	}
 }
 
@@ -35,11 +37,10 @@ type LoginRequest struct {
 // RegisterRequest represents a registration request
 type RegisterRequest struct {
 	Email           string `json:"email" form:"email" validate:"required,email"`
-	Name            string `json:"name" form:"name" validate:"required,min=2,max=100"`
+	Name            string `json:"name" form:"name" validate:"required,min=2,max=100" sanitize:"strict"`
 	Password        string `json:"password" form:"password" validate:"required,password"`
 	ConfirmPassword string `json:"confirm_password" form:"confirm_password" validate:"required"`
-	Bio             string `json:"bio,omitempty" form:"bio" validate:"max=500"`
-	AvatarURL       string `json:"avatar_url,omitempty" form:"avatar_url" validate:"omitempty,url"`
+	Bio             string `json:"bio,omitempty" form:"bio" validate:"max=500" sanitize:"ugc"`
 }
 
 // Validate implements custom validation for RegisterRequest
@@ -61,7 +62,7 @@ func (h *AuthHandler) LoginPage(c echo.Context) error {
 
 	token := middleware.GetCSRFToken(c)
 
-	return renderWithCSRF(c,
+	return renderWithCSRF(c, "Login",
 		view.LoginContent(),       // HTMX component
 		view.LoginWithCSRF(token), // Full page component with CSRF
 		view.Login(),              // Basic component
@@ -77,7 +78,7 @@ func (h *AuthHandler) RegisterPage(c echo.Context) error {
 
 	token := middleware.GetCSRFToken(c)
 
-	return renderWithCSRF(c,
+	return renderWithCSRF(c, "Register",
 		view.RegisterContent(),       // HTMX component
 		view.RegisterWithCSRF(token), // Full page component with CSRF
 		view.Register(),              // Basic component
@@ -101,47 +102,60 @@ func (h *AuthHandler) Login(c echo.Context) error {
 	// Find user by email
 	user, err := h.store.GetUserByEmail(ctx, req.Email)
 	if err != nil {
-		if !errors.Is(err, pgx.ErrNoRows) {
-			slog.Error("Failed to load user for login",
+		if !errors.Is(err, store.ErrNotFound) {
+			slog.ErrorContext(c.Request().Context(), "Failed to load user for login",
 				"email", req.Email,
 				"error", err,
 				"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
 			return internalError(c, "Authentication error", err)
 		}
 
-		slog.Warn("Login attempt with invalid email",
+		slog.WarnContext(c.Request().Context(), "Login attempt with invalid email",
 			"email", req.Email,
 			"error", err,
 			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+		h.auditLoginFailure(c, req.Email, nil, "unknown_email")
 
 		return authenticationError(c, "Invalid email or password")
 	}
 
 	// Reject accounts without a usable password hash.
 	if user.PasswordHash == "" {
-		slog.Warn("Login attempt for account without password hash",
+		slog.WarnContext(c.Request().Context(), "Login attempt for account without password hash",
 			"email", req.Email,
 			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+		h.auditLoginFailure(c, req.Email, &user.ID, "no_password")
 		return authenticationError(c, "Invalid email or password")
 	}
 
-	valid, err := h.authService.VerifyPasswordArgon2(req.Password, user.PasswordHash)
+	valid, err := matchPassword(ctx, h.authService, user, req.Password)
 	if err != nil {
-		slog.Warn("Password verification failed due to invalid hash",
+		slog.WarnContext(c.Request().Context(), "Password verification failed due to invalid hash",
 			"email", req.Email,
 			"error", err,
 			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+		h.auditLoginFailure(c, req.Email, &user.ID, "invalid_hash")
 		return authenticationError(c, "Invalid email or password")
 	}
 	if !valid {
+		h.auditLoginFailure(c, req.Email, &user.ID, "wrong_password")
 		return authenticationError(c, "Invalid email or password")
 	}
 
 	// Check if user is active
 	if user.IsActive == nil || !*user.IsActive {
+		h.auditLoginFailure(c, req.Email, &user.ID, "inactive")
 		return authenticationError(c, "Account is inactive")
 	}
 
+	if err := recordAudit(c, h.store, auditRecord{
+		Action:   AuditLogin,
+		ActorID:  &user.ID,
+		TargetID: &user.ID,
+	}); err != nil {
+		return internalError(c, "Authentication error", err)
+	}
+
 	// Create user session
 	authUser := middleware.User{
 		ID:       user.ID,
@@ -152,7 +166,7 @@ func (h *AuthHandler) Login(c echo.Context) error {
 
 	err = h.authService.LoginUser(c, authUser)
 	if err != nil {
-		slog.Error("Failed to create user session",
+		slog.ErrorContext(c.Request().Context(), "Failed to create user session",
 			"user_id", user.ID,
 			"error", err,
 			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
@@ -164,7 +178,7 @@ func (h *AuthHandler) Login(c echo.Context) error {
 		).WithContext(c).WithInternal(err)
 	}
 
-	slog.Info("User logged in successfully",
+	slog.InfoContext(c.Request().Context(), "User logged in successfully",
 		"user_id", user.ID,
 		"email", user.Email,
 		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
@@ -182,6 +196,9 @@ func (h *AuthHandler) Register(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return validationError(c, err)
 	}
+	if err := middleware.SanitizeStruct(&req); err != nil {
+		return internalError(c, "Failed to read request", err)
+	}
 
 	if validationErrors := middleware.ValidateStruct(req); len(validationErrors) > 0 {
 		return validationErrorWithDetails(c, validationErrors)
@@ -193,9 +210,9 @@ func (h *AuthHandler) Register(c echo.Context) error {
 	}
 
 	// Hash password using Argon2id
-	hashedPassword, err := h.authService.HashPasswordArgon2(req.Password)
+	hashedPassword, err := h.authService.HashPasswordArgon2(ctx, req.Password)
 	if err != nil {
-		slog.Error("Failed to hash password",
+		slog.ErrorContext(c.Request().Context(), "Failed to hash password",
 			"error", err,
 			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
 
@@ -212,22 +229,34 @@ func (h *AuthHandler) Register(c echo.Context) error {
 		bioPtr = &req.Bio
 	}
 
-	var avatarURLPtr *string
-	if req.AvatarURL != "" {
-		avatarURLPtr = &req.AvatarURL
-	}
-
 	params := store.CreateUserParams{
 		Email:        req.Email,
 		Name:         req.Name,
 		Bio:          bioPtr,
-		AvatarUrl:    avatarURLPtr,
 		PasswordHash: hashedPassword,
 	}
 
-	user, err := h.store.CreateUser(ctx, params)
+	var user store.User
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		var err error
+		user, err = q.CreateUser(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		if err := recordAudit(c, q, auditRecord{
+			Action:   AuditRegister,
+			ActorID:  &user.ID,
+			TargetID: &user.ID,
+			After:    auditUserSnapshot(user),
+		}); err != nil {
+			return err
+		}
+
+		return dispatchUserWebhook(c, q, EventUserCreated, user)
+	})
 	if err != nil {
-		slog.Error("Failed to create user",
+		slog.ErrorContext(c.Request().Context(), "Failed to create user",
 			"email", req.Email,
 			"name", req.Name,
 			"error", err,
@@ -235,6 +264,7 @@ func (h *AuthHandler) Register(c echo.Context) error {
 
 		return databaseWriteError(c, err, "Failed to create user account")
 	}
+	publishUserChange(c, h.events, EventUserCreated, user.ID)
 
 	// Create user session for automatic login
 	authUser := middleware.User{
@@ -246,7 +276,7 @@ func (h *AuthHandler) Register(c echo.Context) error {
 
 	err = h.authService.LoginUser(c, authUser)
 	if err != nil {
-		slog.Error("Failed to create user session after registration",
+		slog.ErrorContext(c.Request().Context(), "Failed to create user session after registration",
 			"user_id", user.ID,
 			"error", err,
 			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
@@ -258,7 +288,7 @@ func (h *AuthHandler) Register(c echo.Context) error {
 		).WithContext(c).WithInternal(err)
 	}
 
-	slog.Info("User registered and logged in successfully",
+	slog.InfoContext(c.Request().Context(), "User registered and logged in successfully",
 		"user_id", user.ID,
 		"email", user.Email,
 		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
@@ -271,16 +301,27 @@ func (h *AuthHandler) Register(c echo.Context) error {
 func (h *AuthHandler) Logout(c echo.Context) error {
 	// Log the logout
 	if user, exists := h.authService.GetCurrentUser(c); exists {
-		slog.Info("User logged out successfully",
+		slog.InfoContext(c.Request().Context(), "User logged out successfully",
 			"user_id", user.ID,
 			"email", user.Email,
 			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+		if err := recordAudit(c, h.store, auditRecord{
+			Action:   AuditLogout,
+			ActorID:  &user.ID,
+			TargetID: &user.ID,
+		}); err != nil {
+			slog.ErrorContext(c.Request().Context(), "Failed to audit logout",
+				"user_id", user.ID,
+				"error", err,
+				"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+		}
 	}
 
 	// Destroy user session
 	err := h.authService.LogoutUser(c)
 	if err != nil {
-		slog.Error("Failed to destroy session",
+		slog.ErrorContext(c.Request().Context(), "Failed to destroy session",
 			"error", err,
 			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
 	}
@@ -289,6 +330,22 @@ func (h *AuthHandler) Logout(c echo.Context) error {
 	return redirectOrHtmx(c, RouteLogin, MsgLogoutSuccess)
 }
 
+// auditLoginFailure records a rejected login. The response is the same 401
+// whether or not this succeeds, so errors are only logged.
+func (h *AuthHandler) auditLoginFailure(c echo.Context, email string, userID *int64, reason string) {
+	err := recordAudit(c, h.store, auditRecord{
+		Action:   AuditLoginFailed,
+		TargetID: userID,
+		After:    map[string]string{"email": email, "reason": reason},
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request().Context(), "Failed to audit login failure",
+			"email", email,
+			"error", err,
+			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+	}
+}
+
 // Profile handles user profile page
 func (h *AuthHandler) Profile(c echo.Context) error {
 	user, exists := h.authService.GetCurrentUser(c)
@@ -298,7 +355,7 @@ func (h *AuthHandler) Profile(c echo.Context) error {
 
 	token := middleware.GetCSRFToken(c)
 
-	return renderWithCSRF(c,
+	return renderWithCSRF(c, "Profile",
 		view.ProfileContent(*user),         // HTMX component
 		view.ProfileWithCSRF(*user, token), // Full page component with CSRF
 		view.Profile(*user),                // Basic component
@@ -8,8 +8,12 @@ const (
 	HtmxTrigger       = "HX-Trigger"
 	HtmxTarget        = "HX-Target"
 	HtmxSwap          = "HX-Swap"
+	HtmxPushURL       = "HX-Push-Url"
 
 	ContentTypeJSON = "application/json"
+
+	HeaderETag    = "ETag"
+	HeaderIfMatch = "If-Match"
 )
 
 // Route constants
@@ -19,6 +23,27 @@ const (
 	RouteRegister = "/auth/register"
 	RouteLogout   = "/auth/logout"
 	RouteProfile  = "/profile"
+
+	RouteProfileData = "/profile/data"
+
+	RouteUserEvents = "/users/events"
+	RouteUserExport = "/users/export"
+
+	RouteLivez  = "/livez"
+	RouteReadyz = "/readyz"
+	RouteHealth = "/health"
+
+	RouteCSPReport = "/csp-report"
+
+	RouteAdminAudit       = "/admin/audit"
+	RouteAdminAuditEvents = "/admin/audit/events"
+	RouteAdminAuditExport = "/admin/audit/export"
+	RouteAdminJobs        = "/admin/jobs"
+	RouteAdminJobList     = "/admin/jobs/list"
+	RouteAdminTasks       = "/admin/tasks"
+	RouteAdminWebhooks    = "/admin/webhooks"
+	RouteAdminImports     = "/admin/imports"
+	RouteAdminDeletions   = "/admin/deletions"
 )
 
 // Response messages
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/events"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/view"
+	"github.com/labstack/echo/v4"
+)
+
+// Event topics published when users change.
+const (
+	EventUserCreated     events.Topic[userChange] = "user.created"
+	EventUserUpdated     events.Topic[userChange] = "user.updated"
+	EventUserDeactivated events.Topic[userChange] = "user.deactivated"
+	EventUserReactivated events.Topic[userChange] = "user.reactivated"
+	EventUserDeleted     events.Topic[userChange] = "user.deleted"
+	EventUserRestored    events.Topic[userChange] = "user.restored"
+)
+
+// UserEvents lists every user event topic.
+var UserEvents = []events.Topic[userChange]{
+	EventUserCreated, EventUserUpdated, EventUserDeactivated,
+	EventUserReactivated, EventUserDeleted, EventUserRestored,
+}
+
+const (
+	// sseKeepalive is how often an idle stream sends a comment so proxies
+	// do not close it.
+	sseKeepalive = 25 * time.Second
+	// sseRetry is how long browsers wait before reconnecting a dropped stream.
+	sseRetry = 5 * time.Second
+)
+
+// Server-sent events the users page listens for besides the per-row
+// user-<id> events, by the topic that sends them.
+var userPageSignals = map[events.Topic[userChange]][]string{
+	EventUserCreated:     {"user-list", "user-count"},
+	EventUserDeactivated: {"user-count"},
+	EventUserReactivated: {"user-list", "user-count"},
+	EventUserDeleted:     {"user-count", "user-trash"},
+	EventUserRestored:    {"user-list", "user-count", "user-trash"},
+}
+
+// userPageResync reloads every part of the users page.
+var userPageResync = []string{"user-list", "user-count", "user-trash"}
+
+// userChange is the payload of every user event.
+type userChange struct {
+	ID int64 `json:"id"`
+}
+
+// publishUserChange tells every instance about a committed user change. A
+// failure only leaves other open pages stale, so it is logged, not returned.
+func publishUserChange(c echo.Context, bus *events.Bus, topic events.Topic[userChange], id int64) {
+	ctx := c.Request().Context()
+
+	if err := topic.Publish(ctx, bus, userChange{ID: id}); err != nil {
+		slog.WarnContext(ctx, "Failed to publish user event",
+			"topic", topic,
+			"id", id,
+			"error", err,
+			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+	}
+}
+
+// StreamUserEvents streams user changes to the users page as server-sent
+// events. Every change re-renders the user's row as a user-<id> event, empty
+// when the user has left the list; the signals in userPageSignals tell the
+// page to reload its other parts, and all of them are reloaded when events
+// may have been missed. The stream ends when the client goes away
+// or the event bus closes for shutdown.
+func (h *UserHandler) StreamUserEvents(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	sub := events.Subscribe(h.events, UserEvents...)
+	defer sub.Close()
+
+	res := c.Response()
+
+	// The stream outlives the server's write timeout.
+	if err := clearWriteDeadline(c); err != nil {
+		return logAndReturnError(c, "start event stream", err, http.StatusInternalServerError, "Failed to start event stream")
+	}
+
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-store")
+	// Stop reverse proxies such as nginx from buffering the stream.
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(res, "retry: %d\n\n", sseRetry.Milliseconds()); err != nil {
+		return nil
+	}
+	res.Flush()
+
+	keepalive := time.NewTicker(sseKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keepalive.C:
+			_, err = io.WriteString(res, ": keepalive\n\n")
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				return nil
+			}
+			err = h.writeUserEvent(ctx, res, msg)
+		}
+		if err != nil {
+			// The client is gone; its request context ends with it.
+			return nil
+		}
+
+		res.Flush()
+	}
+}
+
+// writeUserEvent writes the server-sent events for one user change.
+func (h *UserHandler) writeUserEvent(ctx context.Context, w io.Writer, msg events.Message[userChange]) error {
+	if msg.Missed {
+		return writeSignals(w, userPageResync)
+	}
+
+	change := msg.Data
+	signals := userPageSignals[msg.Topic]
+
+	var row bytes.Buffer
+	user, err := h.store.GetUser(ctx, change.ID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		// Deleted: an empty row removes it.
+	case err != nil:
+		// The row cannot be rendered, so have the page reload the list.
+		slog.WarnContext(ctx, "Failed to load user for event", "id", change.ID, "error", err)
+		signals = append(slices.Clone(signals), "user-list")
+	case user.IsActive != nil && *user.IsActive:
+		if err := view.UserRow(user).Render(ctx, &row); err != nil {
+			return err
+		}
+	}
+
+	if err := writeSSE(w, "user-"+strconv.FormatInt(change.ID, 10), row.String()); err != nil {
+		return err
+	}
+
+	return writeSignals(w, signals)
+}
+
+// writeSignals writes an empty server-sent event for each signal.
+func writeSignals(w io.Writer, signals []string) error {
+	for _, signal := range signals {
+		if err := writeSSE(w, signal, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeSSE writes one server-sent event, splitting data across data lines.
+func writeSSE(w io.Writer, name, data string) error {
+	var b strings.Builder
+	b.WriteString("event: ")
+	b.WriteString(name)
+	b.WriteString("\n")
+	for line := range strings.SplitSeq(data, "\n") {
+		b.WriteString("data: ")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
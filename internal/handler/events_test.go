@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type sseEvent struct {
+	Name string
+	Data string
+}
+
+func TestUserEventsStreamRowsAndSignals(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	admin := ts.register(t, "admin@example.com")
+	ts.register(t, "ada@example.com")
+
+	srv := httptest.NewServer(ts.e)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+RouteUserEvents, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	for _, cookie := range admin {
+		req.AddCookie(cookie)
+	}
+
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", RouteUserEvents, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET %s = %d %q, want an event stream", RouteUserEvents, res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	stream := bufio.NewReader(res.Body)
+
+	if rec := ts.do(t, http.MethodPatch, "/users/2/deactivate", nil, admin); rec.Code != http.StatusOK {
+		t.Fatalf("deactivate status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := readSSE(t, stream); got != (sseEvent{Name: "user-2"}) {
+		t.Fatalf("deactivation event = %+v, want an empty row for user 2", got)
+	}
+	if got := readSSE(t, stream); got.Name != "user-count" {
+		t.Fatalf("deactivation signal = %+v, want user-count", got)
+	}
+
+	if rec := ts.do(t, http.MethodPatch, "/users/2/reactivate", nil, admin); rec.Code != http.StatusOK {
+		t.Fatalf("reactivate status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	got := readSSE(t, stream)
+	if got.Name != "user-2" || !strings.Contains(got.Data, "ada@example.com") || !strings.Contains(got.Data, `sse-swap="user-2"`) {
+		t.Fatalf("reactivation event = %+v, want the re-rendered row", got)
+	}
+	for _, want := range []string{"user-list", "user-count"} {
+		if got := readSSE(t, stream); got.Name != want {
+			t.Fatalf("reactivation signal = %+v, want %s", got, want)
+		}
+	}
+
+	// Closing the bus for shutdown ends the stream.
+	ts.bus.Close()
+	if _, err := io.ReadAll(stream); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("stream after bus close error = %v, want it to end", err)
+	}
+}
+
+// readSSE returns the next event, skipping comments and retry hints.
+func readSSE(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+
+	var event sseEvent
+	var data []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "":
+			if event.Name != "" {
+				event.Data = strings.Join(data, "\n")
+				return event
+			}
+		case strings.HasPrefix(line, "event: "):
+			event.Name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = append(data, strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
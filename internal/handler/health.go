@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/health"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/view"
+	"github.com/labstack/echo/v4"
+)
+
+// HealthHandler serves liveness, readiness and detailed health probes. The
+// detailed probes belong on the admin/ops listener; the public listener gets
+// ReadyzStatus and HealthStatus, which leave out check names and errors.
+type HealthHandler struct {
+	registry *health.Registry
+	store    store.Querier
+}
+
+// NewHealthHandler creates a new HealthHandler backed by registry. The
+// detailed report also lists the scheduled tasks recorded in s.
+func NewHealthHandler(registry *health.Registry, s store.Querier) *HealthHandler {
+	return &HealthHandler{registry: registry, store: s}
+}
+
+// taskStatus is a scheduled task's last outcome in the health report.
+type taskStatus struct {
+	Status  string     `json:"status"`
+	LastRun *time.Time `json:"last_run,omitempty"`
+	NextRun *time.Time `json:"next_run,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// Livez reports that the process is up and serving requests. It never checks
+// dependencies, so a database outage does not get the process restarted.
+func (h *HealthHandler) Livez(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	return c.JSON(http.StatusOK, map[string]string{"status": health.StatusOK})
+}
+
+// Readyz reports whether the instance should receive traffic. It fails
+// immediately while draining and otherwise runs every registered check.
+func (h *HealthHandler) Readyz(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	if h.registry.Draining() {
+		return c.JSON(http.StatusServiceUnavailable, health.Report{
+			Status:   health.StatusFailing,
+			Draining: true,
+			Checks:   []health.CheckResult{},
+		})
+	}
+
+	report := h.registry.Run(c.Request().Context())
+
+	return c.JSON(probeStatusCode(report), report)
+}
+
+// ReadyzStatus is Readyz with the status code alone, for the public listener.
+func (h *HealthHandler) ReadyzStatus(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	if h.registry.Draining() {
+		return c.NoContent(http.StatusServiceUnavailable)
+	}
+
+	return c.NoContent(probeStatusCode(h.registry.Run(c.Request().Context())))
+}
+
+// HealthStatus is Health for the public listener: the status code alone, or
+// for HTMX a fragment with just the overall status.
+func (h *HealthHandler) HealthStatus(c echo.Context) error {
+	report := h.registry.Run(c.Request().Context())
+
+	if isHtmxRequest(c) {
+		component := view.HealthStatus(report.Status, time.Now().UTC().Format(time.RFC3339))
+
+		return render(c, "HealthStatus", component)
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	return c.NoContent(probeStatusCode(report))
+}
+
+// Health returns a detailed health report, rendered as a fragment for HTMX.
+func (h *HealthHandler) Health(c echo.Context) error {
+	report := h.registry.Run(c.Request().Context())
+
+	checks := make(map[string]string, len(report.Checks))
+	for _, check := range report.Checks {
+		checks[check.Name] = check.Status
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339)
+	uptime := time.Since(startTime).String()
+
+	if isHtmxRequest(c) {
+		component := view.HealthCheck(report.Status, serviceName, serviceVersion, uptime, timestamp, checks)
+
+		return render(c, "HealthCheck", component)
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+	body := map[string]interface{}{
+		"status":    report.Status,
+		"timestamp": timestamp,
+		"service":   serviceName,
+		"version":   serviceVersion,
+		"uptime":    uptime,
+		"draining":  report.Draining,
+		"checks":    checks,
+	}
+	if tasks := h.taskStatuses(c); tasks != nil {
+		body["tasks"] = tasks
+	}
+
+	return c.JSON(probeStatusCode(report), body)
+}
+
+// taskStatuses reports each scheduled task's last run. A failed task does not
+// fail the health check, and tasks are left out if they cannot be listed.
+func (h *HealthHandler) taskStatuses(c echo.Context) map[string]taskStatus {
+	if h.store == nil {
+		return nil
+	}
+
+	tasks, err := h.store.ListScheduledTasks(c.Request().Context())
+	if err != nil {
+		slog.WarnContext(c.Request().Context(), "Failed to list scheduled tasks", "error", err)
+		return nil
+	}
+
+	statuses := make(map[string]taskStatus, len(tasks))
+	for _, task := range tasks {
+		status := taskStatus{Status: "pending"}
+		if task.LastStatus != nil {
+			status.Status = *task.LastStatus
+		}
+		if task.LastStartedAt.Valid {
+			status.LastRun = &task.LastStartedAt.Time
+		}
+		if task.NextRunAt.Valid {
+			status.NextRun = &task.NextRunAt.Time
+		}
+		if task.LastError != nil {
+			status.Error = *task.LastError
+		}
+		statuses[task.Name] = status
+	}
+
+	return statuses
+}
+
+func probeStatusCode(report health.Report) int {
+	if report.Healthy() {
+		return http.StatusOK
+	}
+
+	return http.StatusServiceUnavailable
+}
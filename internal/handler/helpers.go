@@ -7,11 +7,16 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/a-h/templ"
 	"github.com/dunamismax/go-web-server/internal/middleware"
-	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/telemetry"
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // isHtmxRequest checks if the request is an HTMX request
@@ -45,26 +50,49 @@ func setupCSRFHeaders(c echo.Context) string {
 }
 
 // renderWithCSRF renders content with CSRF handling for both HTMX and regular requests
-func renderWithCSRF(c echo.Context, htmxComponent, fullPageComponent, basicComponent templ.Component) error {
+func renderWithCSRF(c echo.Context, name string, htmxComponent, fullPageComponent, basicComponent templ.Component) error {
 	setupCSRFHeaders(c)
 
 	if isHtmxRequest(c) {
-		return htmxComponent.Render(c.Request().Context(), c.Response().Writer)
+		return render(c, name+"Content", htmxComponent)
 	}
 
 	// Try to use the full page component with CSRF first
 	if fullPageComponent != nil {
-		return fullPageComponent.Render(c.Request().Context(), c.Response().Writer)
+		return render(c, name+"WithCSRF", fullPageComponent)
 	}
 
 	// Fallback to basic component
-	return basicComponent.Render(c.Request().Context(), c.Response().Writer)
+	return render(c, name, basicComponent)
+}
+
+// render writes a templ component to the response inside a tracing span
+// named after the component.
+func render(c echo.Context, name string, component templ.Component) error {
+	ctx, span := telemetry.Tracer().Start(c.Request().Context(), "templ.render "+name,
+		trace.WithAttributes(attribute.String("templ.component", name)))
+	defer span.End()
+
+	if err := component.Render(ctx, c.Response().Writer); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	return nil
 }
 
 // Error helpers for common error patterns
 
-// validationError creates a validation error with context
+// validationError creates a validation error with context. Binding errors
+// that already explain themselves, such as an unknown JSON field, are
+// returned as they are.
 func validationError(c echo.Context, err error) error {
+	var appErr *middleware.AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
 	return middleware.NewAppError(
 		middleware.ErrorTypeValidation,
 		http.StatusBadRequest,
@@ -112,11 +140,11 @@ func internalError(c echo.Context, message string, err error) error {
 
 // databaseWriteError maps database constraint violations to useful client errors.
 func databaseWriteError(c echo.Context, err error, fallbackMessage string) error {
-	var pgErr *pgconn.PgError
-	if errors.As(err, &pgErr) {
-		switch pgErr.Code {
-		case "23505":
-			field := databaseConflictField(pgErr)
+	if constraintErr, ok := store.AsConstraintError(err); ok {
+		field := databaseFieldName(constraintErr.Column)
+
+		switch constraintErr.Kind {
+		case store.ConstraintUnique:
 			message := "Resource already exists"
 			details := map[string]string{}
 			if field != "" {
@@ -124,8 +152,7 @@ func databaseWriteError(c echo.Context, err error, fallbackMessage string) error
 				details[field] = message
 			}
 			return conflictError(c, message, details)
-		case "23502":
-			field := databaseFieldName(pgErr.ColumnName)
+		case store.ConstraintNotNull:
 			if field == "" {
 				field = "field"
 			}
@@ -138,15 +165,6 @@ func databaseWriteError(c echo.Context, err error, fallbackMessage string) error
 	return internalError(c, fallbackMessage, err)
 }
 
-func databaseConflictField(pgErr *pgconn.PgError) string {
-	switch {
-	case strings.Contains(pgErr.ConstraintName, "email"), pgErr.ColumnName == "email":
-		return "email"
-	default:
-		return databaseFieldName(pgErr.ColumnName)
-	}
-}
-
 func databaseFieldName(column string) string {
 	return strings.TrimSpace(strings.ToLower(column))
 }
@@ -175,7 +193,7 @@ func parseIDParam(c echo.Context) (int64, error) {
 
 // logAndReturnError logs an error and returns an app error
 func logAndReturnError(c echo.Context, operation string, err error, statusCode int, userMessage string) error {
-	slog.Error("Operation failed",
+	slog.ErrorContext(c.Request().Context(), "Operation failed",
 		"operation", operation,
 		"error", err,
 		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
@@ -194,3 +212,33 @@ func stringPtr(s string) *string {
 	}
 	return &s
 }
+
+// derefString returns the string a pointer refers to, or "" for nil
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// csvSafe stops spreadsheet applications from running a cell that starts
+// like a formula. Every user-supplied cell in a CSV export goes through it.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// clearWriteDeadline lifts the server's write timeout for a response that
+// streams for longer than it allows. Writers that cannot take deadlines,
+// such as test recorders, have none to lift.
+func clearWriteDeadline(c echo.Context) error {
+	err := http.NewResponseController(c.Response()).SetWriteDeadline(time.Time{})
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+
+	return err
+}
@@ -12,17 +12,17 @@ import (
 )
 
 const (
-	statusError   = "error"
-	statusWarning = "warning"
+	serviceName    = "go-web-server"
+	serviceVersion = "1.0.0"
 )
 
-// HomeHandler handles requests for the home page and health checks.
+// HomeHandler handles requests for the home page and demo content.
 type HomeHandler struct {
-	store *store.Store
+	store store.Querier
 }
 
 // NewHomeHandler creates a new HomeHandler instance.
-func NewHomeHandler(s *store.Store) *HomeHandler {
+func NewHomeHandler(s store.Querier) *HomeHandler {
 	return &HomeHandler{store: s}
 }
 
@@ -30,7 +30,7 @@ func NewHomeHandler(s *store.Store) *HomeHandler {
 func (h *HomeHandler) Home(c echo.Context) error {
 	token := middleware.GetCSRFToken(c)
 
-	return renderWithCSRF(c,
+	return renderWithCSRF(c, "Home",
 		view.HomeContent(),       // HTMX component
 		view.HomeWithCSRF(token), // Full page component with CSRF
 		view.Home(),              // Basic component
@@ -57,7 +57,7 @@ func (h *HomeHandler) Demo(c echo.Context) error {
 
 		component := view.DemoContent(demoData.Message, demoData.Features, demoData.ServerTime, demoData.RequestID)
 
-		return component.Render(c.Request().Context(), c.Response().Writer)
+		return render(c, "DemoContent", component)
 	}
 
 	// Set response headers for JSON response
@@ -66,74 +66,4 @@ func (h *HomeHandler) Demo(c echo.Context) error {
 	return c.JSON(http.StatusOK, demoData)
 }
 
-// Health provides a comprehensive health check endpoint.
-func (h *HomeHandler) Health(c echo.Context) error {
-	ctx := c.Request().Context()
-	checks := make(map[string]string)
-	overallStatus := "ok"
-
-	// Database connectivity check
-	if h.store != nil {
-		if _, err := h.store.CountUsers(ctx); err != nil {
-			checks["database"] = statusError
-			overallStatus = statusError
-		} else {
-			checks["database"] = "ok"
-		}
-
-		// Database connection stats
-		if db := h.store.DB(); db != nil {
-			_ = db.Stat()
-			checks["database_connections"] = "ok"
-		}
-	} else {
-		checks["database"] = statusError
-		overallStatus = statusError
-	}
-
-	// Memory check (basic)
-	checks["memory"] = "ok"
-
-	health := map[string]interface{}{
-		"status":    overallStatus,
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"service":   "go-web-server",
-		"version":   "1.0.0",
-		"uptime":    time.Since(startTime).String(),
-		"checks":    checks,
-	}
-
-	// Check if this is an HTMX request for formatted HTML display
-	if isHtmxRequest(c) {
-		component := view.HealthCheck(
-			health["status"].(string),
-			health["service"].(string),
-			health["version"].(string),
-			health["uptime"].(string),
-			health["timestamp"].(string),
-			health["checks"].(map[string]string),
-		)
-
-		return component.Render(c.Request().Context(), c.Response().Writer)
-	}
-
-	// Set response headers for JSON response
-	c.Response().Header().Set("Content-Type", "application/json")
-	c.Response().Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-
-	// Set appropriate HTTP status based on health
-	var statusCode int
-
-	switch overallStatus {
-	case statusError:
-		statusCode = http.StatusServiceUnavailable
-	case "degraded", statusWarning:
-		statusCode = http.StatusPartialContent
-	default:
-		statusCode = http.StatusOK
-	}
-
-	return c.JSON(statusCode, health)
-}
-
 var startTime = time.Now()
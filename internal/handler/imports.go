@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/userimport"
+	"github.com/dunamismax/go-web-server/internal/view"
+	"github.com/labstack/echo/v4"
+)
+
+// importListSize is how many recent imports the imports page shows.
+const importListSize = 50
+
+// Imports renders the bulk import page with recent imports.
+func (h *AdminHandler) Imports(c echo.Context) error {
+	imports, err := h.store.ListUserImports(c.Request().Context(), importListSize)
+	if err != nil {
+		return logAndReturnError(c, "fetch user imports", err, http.StatusInternalServerError, "Failed to fetch imports")
+	}
+
+	token := setupCSRFHeaders(c)
+
+	return renderWithCSRF(c, "Imports",
+		view.ImportsContent(imports),         // HTMX component
+		view.ImportsWithCSRF(imports, token), // Full page component with CSRF
+		view.Imports(imports),                // Basic component
+	)
+}
+
+// CreateImport stores an uploaded file and queues the job that imports it,
+// then shows the import's progress. Problems with the file as a whole are
+// reported straight away; problems with rows are reported on the import.
+func (h *AdminHandler) CreateImport(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return validationErrorWithDetails(c, middleware.ValidationErrors{{Field: "file", Message: "choose a file to import"}})
+	}
+	if file.Size > userimport.MaxFileSize {
+		return middleware.NewAppError(
+			middleware.ErrorTypeValidation,
+			http.StatusRequestEntityTooLarge,
+			"Import files are limited to "+strconv.Itoa(userimport.MaxFileSize>>20)+" MB",
+		).WithContext(c)
+	}
+
+	format := userimport.Format(c.FormValue("format"))
+	switch format {
+	case "":
+		var ok bool
+		if format, ok = userimport.FormatForFilename(file.Filename); !ok {
+			return validationErrorWithDetails(c, middleware.ValidationErrors{{Field: "format", Message: "choose a format for this file"}})
+		}
+	case userimport.FormatCSV, userimport.FormatNDJSON:
+	default:
+		return validationErrorWithDetails(c, middleware.ValidationErrors{{Field: "format", Message: "format must be csv or ndjson"}})
+	}
+
+	mode := userimport.Mode(c.FormValue("mode"))
+	switch mode {
+	case "":
+		mode = userimport.ModeAtomic
+	case userimport.ModeAtomic, userimport.ModeBestEffort:
+	default:
+		return validationErrorWithDetails(c, middleware.ValidationErrors{{Field: "mode", Message: "mode must be atomic or best_effort"}})
+	}
+
+	dryRun, _ := strconv.ParseBool(c.FormValue("dry_run"))
+
+	src, err := file.Open()
+	if err != nil {
+		return validationError(c, err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, userimport.MaxFileSize))
+	if err != nil {
+		return validationError(c, err)
+	}
+
+	// Decode now so a malformed file is rejected while the uploader waits.
+	if _, err := userimport.Decode(bytes.NewReader(data), format); err != nil {
+		return validationErrorWithDetails(c, middleware.ValidationErrors{{Field: "file", Message: err.Error()}})
+	}
+
+	actorID := currentActorID(c, h.authService)
+	params := userimport.QueueParams{
+		CreatedBy: actorID,
+		Filename:  file.Filename,
+		Format:    format,
+		Mode:      mode,
+		DryRun:    dryRun,
+	}
+
+	var imp store.UserImport
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		var err error
+		imp, err = userimport.Queue(ctx, q, params, data)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(c, q, auditRecord{
+			Action:     AuditImportUpload,
+			ActorID:    actorID,
+			TargetType: auditTargetImport,
+			TargetID:   &imp.ID,
+			After: map[string]any{
+				"filename": imp.Filename,
+				"format":   imp.Format,
+				"mode":     imp.Mode,
+				"dry_run":  imp.DryRun,
+				"bytes":    len(data),
+			},
+		})
+	})
+	if err != nil {
+		return logAndReturnError(c, "queue user import", err, http.StatusInternalServerError, "Failed to queue import")
+	}
+
+	slog.InfoContext(ctx, "User import queued",
+		"id", imp.ID,
+		"filename", imp.Filename,
+		"mode", imp.Mode,
+		"dry_run", imp.DryRun,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	c.Response().Header().Set(HtmxPushURL, importRoute(imp.ID))
+
+	return render(c, "ImportContent", view.ImportContent(imp))
+}
+
+// Import renders one import with its progress and row errors.
+func (h *AdminHandler) Import(c echo.Context) error {
+	imp, err := h.userImport(c)
+	if err != nil {
+		return err
+	}
+
+	token := setupCSRFHeaders(c)
+
+	return renderWithCSRF(c, "Import",
+		view.ImportContent(imp),         // HTMX component
+		view.ImportWithCSRF(imp, token), // Full page component with CSRF
+		view.Import(imp),                // Basic component
+	)
+}
+
+// ImportProgress returns an import's status as HTML fragment; the fragment
+// keeps polling until the import finishes.
+func (h *AdminHandler) ImportProgress(c echo.Context) error {
+	imp, err := h.userImport(c)
+	if err != nil {
+		return err
+	}
+
+	return render(c, "ImportStatus", view.ImportStatus(imp))
+}
+
+// userImport loads the import in the URL.
+func (h *AdminHandler) userImport(c echo.Context) (store.UserImport, error) {
+	id, err := parseIDParam(c)
+	if err != nil {
+		return store.UserImport{}, err
+	}
+
+	imp, err := h.store.GetUserImport(c.Request().Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return store.UserImport{}, middleware.ErrNotFound.WithContext(c)
+	}
+	if err != nil {
+		return store.UserImport{}, logAndReturnError(c, "fetch user import", err, http.StatusInternalServerError, "Failed to fetch import")
+	}
+
+	return imp, nil
+}
+
+func importRoute(id int64) string {
+	return RouteAdminImports + "/" + strconv.FormatInt(id, 10)
+}
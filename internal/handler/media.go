@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"cmp"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dunamismax/go-web-server/internal/avatar"
+	"github.com/dunamismax/go-web-server/internal/events"
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/storage"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/view"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// DefaultMediaDir is where uploads are kept when no storage is
+	// configured.
+	DefaultMediaDir = "data/media"
+	// mediaCacheControl lets browsers and proxies keep media for a year.
+	// Every upload gets a new key, so a stored object never changes.
+	mediaCacheControl = "public, max-age=31536000, immutable"
+	// multipartOverhead allows for the form encoding around an upload.
+	multipartOverhead = 64 << 10
+)
+
+// MediaHandler handles avatar uploads and serves stored media.
+type MediaHandler struct {
+	store       store.TxQuerier
+	authService *middleware.SessionAuthService
+	events      *events.Bus
+	storage     storage.Storage
+	config      avatar.Config
+}
+
+// NewMediaHandler creates a new MediaHandler. Zero limits in config fall
+// back to avatar.DefaultConfig.
+func NewMediaHandler(s store.TxQuerier, authService *middleware.SessionAuthService, bus *events.Bus, media storage.Storage, config avatar.Config) *MediaHandler {
+	config.MaxBytes = cmp.Or(config.MaxBytes, avatar.DefaultConfig.MaxBytes)
+	config.MaxPixels = cmp.Or(config.MaxPixels, avatar.DefaultConfig.MaxPixels)
+
+	return &MediaHandler{
+		store:       s,
+		authService: authService,
+		events:      bus,
+		storage:     media,
+		config:      config,
+	}
+}
+
+// UploadAvatar replaces the signed-in user's avatar with an uploaded image
+// and returns the refreshed avatar section of the profile form.
+func (h *MediaHandler) UploadAvatar(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, ok := h.authService.GetCurrentUser(c)
+	if !ok {
+		return authenticationError(c, "Authentication required")
+	}
+
+	// Stop reading oversized bodies instead of spooling them to disk.
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, h.config.MaxBytes+multipartOverhead)
+
+	file, err := c.FormFile("avatar")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return h.tooLargeError(c)
+	}
+	if err != nil {
+		return validationErrorWithDetails(c, middleware.ValidationErrors{{Field: "avatar", Message: "choose an image to upload"}})
+	}
+	if file.Size > h.config.MaxBytes {
+		return h.tooLargeError(c)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return validationError(c, err)
+	}
+	defer src.Close()
+
+	images, err := avatar.Process(src, h.config)
+	switch {
+	case errors.Is(err, avatar.ErrTooLarge):
+		return h.tooLargeError(c)
+	case errors.Is(err, avatar.ErrUnsupported):
+		return validationErrorWithDetails(c, middleware.ValidationErrors{{Field: "avatar", Message: "upload a JPEG, PNG or GIF image"}})
+	case errors.Is(err, avatar.ErrDimensions):
+		return validationErrorWithDetails(c, middleware.ValidationErrors{{Field: "avatar", Message: "the image has too many pixels; try a smaller one"}})
+	case errors.Is(err, avatar.ErrInvalid):
+		return validationErrorWithDetails(c, middleware.ValidationErrors{{Field: "avatar", Message: "the image could not be read"}})
+	case err != nil:
+		return internalError(c, "Failed to process avatar", err)
+	}
+
+	url, err := avatar.Save(ctx, h.storage, user.ID, images)
+	if err != nil {
+		return logAndReturnError(c, "store avatar", err, http.StatusInternalServerError, "Failed to upload avatar")
+	}
+
+	updated, previous, err := h.setAvatar(c, user.ID, &url)
+	if err != nil {
+		h.removeAvatar(c, url)
+		return err
+	}
+	h.removeAvatar(c, previous)
+
+	slog.InfoContext(ctx, "Avatar uploaded",
+		"user_id", user.ID,
+		"bytes", file.Size,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	c.Response().Header().Set(HeaderETag, userETag(updated.Version))
+
+	return render(c, "AvatarSection", view.AvatarSection(updated))
+}
+
+// RemoveAvatar clears the signed-in user's avatar and deletes its files.
+func (h *MediaHandler) RemoveAvatar(c echo.Context) error {
+	user, ok := h.authService.GetCurrentUser(c)
+	if !ok {
+		return authenticationError(c, "Authentication required")
+	}
+
+	updated, previous, err := h.setAvatar(c, user.ID, nil)
+	if err != nil {
+		return err
+	}
+	h.removeAvatar(c, previous)
+
+	c.Response().Header().Set(HeaderETag, userETag(updated.Version))
+
+	return render(c, "AvatarSection", view.AvatarSection(updated))
+}
+
+// Media serves a stored file. Responses are cached for a year, and a
+// revalidation with the object's ETag is answered without reading storage.
+func (h *MediaHandler) Media(c echo.Context) error {
+	key := c.Param("*")
+	if !storage.ValidKey(key) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
+
+	etag := `"` + key + `"`
+	header := c.Response().Header()
+	header.Set("Cache-Control", mediaCacheControl)
+	header.Set(HeaderETag, etag)
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	obj, err := h.storage.Get(c.Request().Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		header.Del("Cache-Control")
+		header.Del(HeaderETag)
+		return middleware.ErrNotFound.WithContext(c)
+	}
+	if err != nil {
+		header.Del("Cache-Control")
+		header.Del(HeaderETag)
+		return logAndReturnError(c, "fetch media", err, http.StatusInternalServerError, "Failed to fetch media")
+	}
+	defer obj.Body.Close()
+
+	header.Set(echo.HeaderContentType, obj.ContentType)
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	if obj.Size >= 0 {
+		header.Set(echo.HeaderContentLength, strconv.FormatInt(obj.Size, 10))
+	}
+	if !obj.ModTime.IsZero() {
+		header.Set(echo.HeaderLastModified, obj.ModTime.UTC().Format(http.TimeFormat))
+	}
+
+	c.Response().WriteHeader(http.StatusOK)
+	_, err = io.Copy(c.Response(), obj.Body)
+
+	return err
+}
+
+// setAvatar saves url as the user's avatar, audits the change and notifies
+// webhooks, and returns the updated user and the avatar it replaced.
+func (h *MediaHandler) setAvatar(c echo.Context, userID int64, url *string) (store.User, string, error) {
+	ctx := c.Request().Context()
+
+	var (
+		updated  store.User
+		previous string
+	)
+	err := h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		before, err := q.GetUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		previous = derefString(before.AvatarUrl)
+
+		updated, err = q.UpdateUser(ctx, store.UpdateUserParams{
+			Email:     before.Email,
+			Name:      before.Name,
+			Bio:       before.Bio,
+			AvatarUrl: url,
+			ID:        before.ID,
+			Version:   before.Version,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := recordAudit(c, q, auditRecord{
+			Action:   AuditUserUpdate,
+			ActorID:  &userID,
+			TargetID: &userID,
+			Before:   auditUserSnapshot(before),
+			After:    auditUserSnapshot(updated),
+		}); err != nil {
+			return err
+		}
+
+		return dispatchUserWebhook(c, q, EventUserUpdated, updated)
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return store.User{}, "", conflictError(c, "Your account was changed while uploading. Try again.", nil)
+	}
+	if err != nil {
+		return store.User{}, "", logAndReturnError(c, "update avatar", err, http.StatusInternalServerError, "Failed to update avatar")
+	}
+
+	publishUserChange(c, h.events, EventUserUpdated, userID)
+
+	return updated, previous, nil
+}
+
+// removeAvatar deletes the files of an avatar that is no longer used. A
+// failure only leaves unreferenced files behind, so it is logged.
+func (h *MediaHandler) removeAvatar(c echo.Context, url string) {
+	if err := avatar.Remove(c.Request().Context(), h.storage, url); err != nil {
+		slog.WarnContext(c.Request().Context(), "Failed to delete avatar files",
+			"url", url,
+			"error", err,
+			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+	}
+}
+
+func (h *MediaHandler) tooLargeError(c echo.Context) error {
+	return middleware.NewAppError(
+		middleware.ErrorTypeValidation,
+		http.StatusRequestEntityTooLarge,
+		"Avatars are limited to "+strconv.FormatInt(h.config.MaxBytes>>20, 10)+" MB",
+	).WithContext(c)
+}
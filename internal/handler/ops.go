@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/labstack/echo/v4"
+)
+
+// Ops listener routes.
+const (
+	RouteOpsMetrics = "/metrics"
+	RouteOpsRuntime = "/debug/runtime"
+	RouteOpsQueries = "/debug/queries"
+	RouteOpsPprof   = "/debug/pprof"
+)
+
+// OpsStore is the database view the ops listener reports on; both storage
+// backends implement it.
+type OpsStore interface {
+	PoolStats() store.PoolStats
+	QueryStats() *store.QueryStats
+}
+
+// OpsHandler serves diagnostics on the admin/ops listener.
+type OpsHandler struct {
+	store OpsStore
+}
+
+// NewOpsHandler creates a new OpsHandler.
+func NewOpsHandler(s OpsStore) *OpsHandler {
+	return &OpsHandler{store: s}
+}
+
+// OpsOptions selects the optional endpoints mounted on the ops listener.
+type OpsOptions struct {
+	// Metrics serves /metrics when non-nil.
+	Metrics http.Handler
+	// EnablePprof mounts net/http/pprof under /debug/pprof.
+	EnablePprof bool
+}
+
+// RegisterOpsRoutes sets up routes on the admin/ops listener.
+func RegisterOpsRoutes(e *echo.Echo, ops *OpsHandler, probes *HealthHandler, options OpsOptions) {
+	e.GET(RouteLivez, probes.Livez)
+	e.GET(RouteReadyz, probes.Readyz)
+	e.GET(RouteHealth, probes.Health)
+	e.GET(RouteOpsRuntime, ops.RuntimeInfo)
+	e.GET(RouteOpsQueries, ops.QueryStats)
+
+	if options.Metrics != nil {
+		e.GET(RouteOpsMetrics, echo.WrapHandler(options.Metrics))
+	}
+
+	if options.EnablePprof {
+		e.GET(RouteOpsPprof+"/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+		e.GET(RouteOpsPprof+"/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+		e.GET(RouteOpsPprof+"/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+		e.POST(RouteOpsPprof+"/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+		e.GET(RouteOpsPprof+"/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+		// Index also serves named profiles such as /debug/pprof/heap.
+		e.GET(RouteOpsPprof+"/*", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+	}
+}
+
+// RuntimeInfo reports build, Go runtime and connection pool details.
+func (h *OpsHandler) RuntimeInfo(c echo.Context) error {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	hostname, _ := os.Hostname()
+
+	info := map[string]interface{}{
+		"service":    serviceName,
+		"hostname":   hostname,
+		"pid":        os.Getpid(),
+		"started_at": startTime.UTC().Format(time.RFC3339),
+		"uptime":     time.Since(startTime).String(),
+		"go": map[string]interface{}{
+			"version":    runtime.Version(),
+			"os":         runtime.GOOS,
+			"arch":       runtime.GOARCH,
+			"num_cpu":    runtime.NumCPU(),
+			"gomaxprocs": runtime.GOMAXPROCS(0),
+			"goroutines": runtime.NumGoroutine(),
+		},
+		"memory": map[string]interface{}{
+			"heap_alloc_bytes":  mem.HeapAlloc,
+			"heap_inuse_bytes":  mem.HeapInuse,
+			"heap_objects":      mem.HeapObjects,
+			"sys_bytes":         mem.Sys,
+			"num_gc":            mem.NumGC,
+			"gc_pause_total_ns": mem.PauseTotalNs,
+		},
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		buildInfo := map[string]string{
+			"path":    build.Main.Path,
+			"version": build.Main.Version,
+		}
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision", "vcs.time", "vcs.modified":
+				buildInfo[setting.Key] = setting.Value
+			}
+		}
+		info["build"] = buildInfo
+	}
+
+	if h.store != nil {
+		stat := h.store.PoolStats()
+		info["database_pool"] = map[string]interface{}{
+			"acquired_conns": stat.AcquiredConns,
+			"idle_conns":     stat.IdleConns,
+			"total_conns":    stat.TotalConns,
+			"max_conns":      stat.MaxConns,
+		}
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	return c.JSON(http.StatusOK, info)
+}
+
+// QueryStats reports per-query counts and latency percentiles, slowest
+// cumulative time first.
+func (h *OpsHandler) QueryStats(c echo.Context) error {
+	queries := []store.QueryStat{}
+	if h.store != nil && h.store.QueryStats() != nil {
+		queries = h.store.QueryStats().Snapshot()
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"queries": queries,
+	})
+}
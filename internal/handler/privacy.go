@@ -0,0 +1,319 @@
+package handler
+
+import (
+	"cmp"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/privacy"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/view"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+// dataExportListSize is how many recent exports the personal data page shows.
+const dataExportListSize = 20
+
+// PrivacyHandler lets users export their personal data and delete their
+// account.
+type PrivacyHandler struct {
+	store       store.TxQuerier
+	authService *middleware.SessionAuthService
+	config      privacy.Config
+}
+
+// NewPrivacyHandler creates a new PrivacyHandler. Zero durations in config
+// fall back to privacy.DefaultConfig.
+func NewPrivacyHandler(s store.TxQuerier, authService *middleware.SessionAuthService, config privacy.Config) *PrivacyHandler {
+	config.CoolingOff = cmp.Or(config.CoolingOff, privacy.DefaultConfig.CoolingOff)
+	config.ExportLifetime = cmp.Or(config.ExportLifetime, privacy.DefaultConfig.ExportLifetime)
+
+	return &PrivacyHandler{store: s, authService: authService, config: config}
+}
+
+// PersonalData renders the signed-in user's exports and deletion status.
+func (h *PrivacyHandler) PersonalData(c echo.Context) error {
+	data, err := h.personalData(c)
+	if err != nil {
+		return err
+	}
+
+	token := setupCSRFHeaders(c)
+
+	return renderWithCSRF(c, "Privacy",
+		view.PrivacyContent(data),         // HTMX component
+		view.PrivacyWithCSRF(data, token), // Full page component with CSRF
+		view.Privacy(data),                // Basic component
+	)
+}
+
+// CreateDataExport queues an archive of the signed-in user's data and
+// returns the refreshed export list, which polls until it is built.
+func (h *PrivacyHandler) CreateDataExport(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	format := privacy.Format(c.FormValue("format"))
+	switch format {
+	case "":
+		format = privacy.FormatZIP
+	case privacy.FormatZIP, privacy.FormatJSON:
+	default:
+		return validationErrorWithDetails(c, middleware.ValidationErrors{{Field: "format", Message: "format must be zip or json"}})
+	}
+
+	var export store.DataExport
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		var err error
+		export, err = privacy.QueueExport(ctx, q, user.ID, format)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(c, q, auditRecord{
+			Action:   AuditDataExport,
+			ActorID:  &user.ID,
+			TargetID: &user.ID,
+			After:    map[string]any{"export_id": export.ID, "format": export.Format},
+		})
+	})
+	if err != nil {
+		return logAndReturnError(c, "queue data export", err, http.StatusInternalServerError, "Failed to request export")
+	}
+
+	slog.InfoContext(ctx, "Data export queued",
+		"id", export.ID,
+		"user_id", user.ID,
+		"format", export.Format,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	return h.DataExportList(c)
+}
+
+// DataExportList returns the signed-in user's recent exports as HTML
+// fragment; the fragment keeps polling while any of them is being built.
+func (h *PrivacyHandler) DataExportList(c echo.Context) error {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	exports, err := h.store.ListDataExports(c.Request().Context(), store.ListDataExportsParams{
+		UserID:  user.ID,
+		MaxRows: dataExportListSize,
+	})
+	if err != nil {
+		return logAndReturnError(c, "fetch data exports", err, http.StatusInternalServerError, "Failed to fetch exports")
+	}
+
+	return render(c, "DataExportList", view.DataExportList(exports))
+}
+
+// DownloadDataExport sends a finished archive. Other users' archives, and
+// archives that are unfinished or expired, are not found.
+func (h *PrivacyHandler) DownloadDataExport(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	export, err := h.store.GetDataExport(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
+	if err != nil {
+		return logAndReturnError(c, "fetch data export", err, http.StatusInternalServerError, "Failed to fetch export")
+	}
+	if export.UserID != user.ID || export.State != privacy.StateSucceeded ||
+		!export.ExpiresAt.Valid || !time.Now().Before(export.ExpiresAt.Time) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
+
+	data, err := h.store.GetDataExportFile(ctx, export.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
+	if err != nil {
+		return logAndReturnError(c, "fetch data export file", err, http.StatusInternalServerError, "Failed to fetch export")
+	}
+
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		return recordAudit(c, q, auditRecord{
+			Action:   AuditDataDownload,
+			ActorID:  &user.ID,
+			TargetID: &user.ID,
+			After:    map[string]any{"export_id": export.ID},
+		})
+	})
+	if err != nil {
+		return logAndReturnError(c, "audit data export download", err, http.StatusInternalServerError, "Failed to download export")
+	}
+
+	filename := "personal-data-" + strconv.FormatInt(export.ID, 10) + "." + export.Format
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	return c.Blob(http.StatusOK, privacy.Format(export.Format).ContentType(), data)
+}
+
+// RequestAccountDeletion schedules the signed-in user's account for erasure
+// once the cooling-off period has passed. The user must enter their password
+// again. Asking twice keeps the first request.
+func (h *PrivacyHandler) RequestAccountDeletion(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	stored, err := h.store.GetUser(ctx, user.ID)
+	if err != nil {
+		return logAndReturnError(c, "fetch user", err, http.StatusInternalServerError, "Failed to request deletion")
+	}
+
+	if !verifyPassword(ctx, h.authService, stored, c.FormValue("password")) {
+		return validationErrorWithDetails(c, middleware.ValidationErrors{{Field: "password", Message: "password is incorrect"}})
+	}
+
+	deleteAfter := pgtype.Timestamptz{Time: time.Now().Add(h.config.CoolingOff), Valid: true}
+
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		deletion, err := q.RequestAccountDeletion(ctx, store.RequestAccountDeletionParams{
+			UserID:      user.ID,
+			DeleteAfter: deleteAfter,
+		})
+		if errors.Is(err, store.ErrNotFound) {
+			// Already pending; the first request stands.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(c, q, auditRecord{
+			Action:   AuditDeletionRequest,
+			ActorID:  &user.ID,
+			TargetID: &user.ID,
+			After:    map[string]any{"delete_after": deletion.DeleteAfter.Time.UTC()},
+		})
+	})
+	if err != nil {
+		return logAndReturnError(c, "request account deletion", err, http.StatusInternalServerError, "Failed to request deletion")
+	}
+
+	slog.InfoContext(ctx, "Account deletion requested",
+		"user_id", user.ID,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	return h.accountDeletion(c)
+}
+
+// CancelAccountDeletion withdraws the signed-in user's pending deletion.
+func (h *PrivacyHandler) CancelAccountDeletion(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		cancelled, err := q.CancelAccountDeletion(ctx, user.ID)
+		if err != nil || cancelled == 0 {
+			return err
+		}
+
+		return recordAudit(c, q, auditRecord{
+			Action:   AuditDeletionCancel,
+			ActorID:  &user.ID,
+			TargetID: &user.ID,
+		})
+	})
+	if err != nil {
+		return logAndReturnError(c, "cancel account deletion", err, http.StatusInternalServerError, "Failed to cancel deletion")
+	}
+
+	return h.accountDeletion(c)
+}
+
+// accountDeletion renders the deletion section of the personal data page.
+func (h *PrivacyHandler) accountDeletion(c echo.Context) error {
+	data, err := h.personalData(c)
+	if err != nil {
+		return err
+	}
+
+	return render(c, "AccountDeletionSection", view.AccountDeletionSection(data))
+}
+
+// personalData loads what the personal data page shows.
+func (h *PrivacyHandler) personalData(c echo.Context) (view.PersonalData, error) {
+	ctx := c.Request().Context()
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return view.PersonalData{}, err
+	}
+
+	data := view.PersonalData{CoolingOff: h.config.CoolingOff}
+
+	data.Exports, err = h.store.ListDataExports(ctx, store.ListDataExportsParams{
+		UserID:  user.ID,
+		MaxRows: dataExportListSize,
+	})
+	if err != nil {
+		return view.PersonalData{}, logAndReturnError(c, "fetch data exports", err, http.StatusInternalServerError, "Failed to fetch your data")
+	}
+
+	deletion, err := h.store.GetAccountDeletion(ctx, user.ID)
+	switch {
+	case err == nil:
+		data.Deletion = &deletion
+	case !errors.Is(err, store.ErrNotFound):
+		return view.PersonalData{}, logAndReturnError(c, "fetch account deletion", err, http.StatusInternalServerError, "Failed to fetch your data")
+	}
+
+	return data, nil
+}
+
+func (h *PrivacyHandler) currentUser(c echo.Context) (*middleware.User, error) {
+	user, ok := h.authService.GetCurrentUser(c)
+	if !ok {
+		return nil, authenticationError(c, "Authentication required")
+	}
+
+	return user, nil
+}
+
+// AccountDeletions lists accounts waiting to be erased.
+func (h *AdminHandler) AccountDeletions(c echo.Context) error {
+	deletions, err := h.store.ListAccountDeletions(c.Request().Context())
+	if err != nil {
+		return logAndReturnError(c, "fetch account deletions", err, http.StatusInternalServerError, "Failed to fetch account deletions")
+	}
+
+	token := setupCSRFHeaders(c)
+
+	return renderWithCSRF(c, "AccountDeletions",
+		view.AccountDeletionsContent(deletions),         // HTMX component
+		view.AccountDeletionsWithCSRF(deletions, token), // Full page component with CSRF
+		view.AccountDeletions(deletions),                // Basic component
+	)
+}
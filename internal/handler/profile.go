@@ -0,0 +1,353 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/view"
+	"github.com/labstack/echo/v4"
+)
+
+// ProfileUpdateRequest represents the signed-in user's own editable fields.
+// The avatar is uploaded separately.
+type ProfileUpdateRequest struct {
+	Email string `json:"email" form:"email" validate:"required,email"`
+	Name  string `json:"name" form:"name" validate:"required,min=2,max=100" sanitize:"strict"`
+	Bio   string `json:"bio,omitempty" form:"bio" validate:"max=500" sanitize:"ugc"`
+	// CurrentPassword is only required to change the email, since the email
+	// is what the user signs in with.
+	CurrentPassword string `json:"current_password,omitempty" form:"current_password"`
+	// Version is the row version the form was rendered from. An If-Match
+	// header takes precedence.
+	Version int64 `json:"version,omitempty" form:"version"`
+}
+
+// PasswordChangeRequest represents a signed-in user's password change.
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password" form:"current_password" validate:"required"`
+	Password        string `json:"password" form:"password" validate:"required,password"`
+	ConfirmPassword string `json:"confirm_password" form:"confirm_password" validate:"required"`
+}
+
+// Validate implements custom validation for PasswordChangeRequest.
+func (r PasswordChangeRequest) Validate() error {
+	if r.Password != r.ConfirmPassword {
+		return middleware.ValidationErrors{
+			{Field: "confirm_password", Message: "passwords do not match"},
+		}
+	}
+	if r.Password == r.CurrentPassword {
+		return middleware.ValidationErrors{
+			{Field: "password", Message: "new password must differ from the current one"},
+		}
+	}
+	return nil
+}
+
+// EditProfilePage renders the signed-in user's profile form.
+func (h *AuthHandler) EditProfilePage(c echo.Context) error {
+	user, err := h.profileUser(c)
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(HeaderETag, userETag(user.Version))
+	token := setupCSRFHeaders(c)
+
+	return renderWithCSRF(c, "ProfileEdit",
+		view.ProfileEditContent(user),         // HTMX component
+		view.ProfileEditWithCSRF(user, token), // Full page component with CSRF
+		view.ProfileEdit(user),                // Basic component
+	)
+}
+
+// UpdateProfile saves the signed-in user's name, email and bio, and
+// refreshes the identity cached in their session. Changing the email needs
+// the current password.
+func (h *AuthHandler) UpdateProfile(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req ProfileUpdateRequest
+	if err := c.Bind(&req); err != nil {
+		return validationError(c, err)
+	}
+	if err := middleware.SanitizeStruct(&req); err != nil {
+		return internalError(c, "Failed to read request", err)
+	}
+
+	if validationErrors := middleware.ValidateStruct(req); len(validationErrors) > 0 {
+		return validationErrorWithDetails(c, validationErrors)
+	}
+
+	version, err := expectedVersion(c, req.Version)
+	if err != nil {
+		return err
+	}
+
+	stored, err := h.profileUser(c)
+	if err != nil {
+		return err
+	}
+
+	if req.Email != stored.Email && !verifyPassword(ctx, h.authService, stored, req.CurrentPassword) {
+		return validationErrorWithDetails(c, middleware.ValidationErrors{
+			{Field: "current_password", Message: "enter your current password to change your email"},
+		})
+	}
+
+	var (
+		updated   store.User
+		current   *store.User
+		updateErr error
+	)
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		before, err := q.GetUser(ctx, stored.ID)
+		if err != nil {
+			return err
+		}
+		// Resolved per attempt, so a retried transaction matches the row it
+		// just read rather than the one a failed attempt saw.
+		expected := version
+		if expected == anyVersion {
+			expected = before.Version
+		}
+
+		updated, updateErr = q.UpdateUser(ctx, store.UpdateUserParams{
+			Email:     req.Email,
+			Name:      req.Name,
+			Bio:       stringPtr(req.Bio),
+			AvatarUrl: before.AvatarUrl,
+			ID:        stored.ID,
+			Version:   expected,
+		})
+		if errors.Is(updateErr, store.ErrNotFound) {
+			// Saved from another tab or by an admin since the form loaded.
+			current = &before
+			return updateErr
+		}
+		if updateErr != nil {
+			return updateErr
+		}
+
+		if err := recordAudit(c, q, auditRecord{
+			Action:   AuditUserUpdate,
+			ActorID:  &stored.ID,
+			TargetID: &stored.ID,
+			Before:   auditUserSnapshot(before),
+			After:    auditUserSnapshot(updated),
+		}); err != nil {
+			return err
+		}
+
+		return dispatchUserWebhook(c, q, EventUserUpdated, updated)
+	})
+	if current != nil {
+		return userVersionConflictError(c, ManagedUserUpdateRequest{
+			Email: req.Email,
+			Name:  req.Name,
+			Bio:   req.Bio,
+		}, *current)
+	}
+	if updateErr != nil {
+		slog.ErrorContext(ctx, "Failed to update profile",
+			"user_id", stored.ID,
+			"email", req.Email,
+			"error", updateErr,
+			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+		return databaseWriteError(c, updateErr, "Failed to update profile")
+	}
+	if err != nil {
+		return logAndReturnError(c, "update profile", err, http.StatusInternalServerError, "Failed to update profile")
+	}
+
+	authUser := middleware.User{
+		ID:       updated.ID,
+		Email:    updated.Email,
+		Name:     updated.Name,
+		IsActive: isActiveUser(updated),
+	}
+	h.authService.RefreshUser(c, authUser)
+
+	slog.InfoContext(ctx, "Profile updated",
+		"user_id", updated.ID,
+		"email_changed", updated.Email != stored.Email,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	publishUserChange(c, h.events, EventUserUpdated, updated.ID)
+
+	c.Response().Header().Set(HeaderETag, userETag(updated.Version))
+	c.Response().Header().Set(HtmxPushURL, RouteProfile)
+
+	return render(c, "ProfileUpdated", view.ProfileUpdated(authUser, "Your profile has been updated."))
+}
+
+// ChangePasswordPage renders the password change form.
+func (h *AuthHandler) ChangePasswordPage(c echo.Context) error {
+	token := setupCSRFHeaders(c)
+
+	return renderWithCSRF(c, "ProfilePassword",
+		view.ProfilePasswordContent(),       // HTMX component
+		view.ProfilePasswordWithCSRF(token), // Full page component with CSRF
+		view.ProfilePassword(),              // Basic component
+	)
+}
+
+// ChangePassword replaces the signed-in user's password after checking the
+// current one. The session gets a new token and every other session of the
+// user is signed out.
+func (h *AuthHandler) ChangePassword(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req PasswordChangeRequest
+	if err := c.Bind(&req); err != nil {
+		return validationError(c, err)
+	}
+
+	if validationErrors := middleware.ValidateStruct(req); len(validationErrors) > 0 {
+		return validationErrorWithDetails(c, validationErrors)
+	}
+
+	if err := req.Validate(); err != nil {
+		return validationErrorWithDetails(c, err)
+	}
+
+	stored, err := h.profileUser(c)
+	if err != nil {
+		return err
+	}
+
+	if !verifyPassword(ctx, h.authService, stored, req.CurrentPassword) {
+		return validationErrorWithDetails(c, middleware.ValidationErrors{
+			{Field: "current_password", Message: "password is incorrect"},
+		})
+	}
+
+	// Hash outside the transaction so retries do not repeat the expensive work.
+	hashedPassword, err := h.authService.HashPasswordArgon2(ctx, req.Password)
+	if err != nil {
+		return internalError(c, "Failed to process password", err)
+	}
+
+	var updated store.User
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		var err error
+		updated, err = q.UpdateUserPassword(ctx, store.UpdateUserPasswordParams{
+			Email:        stored.Email,
+			Name:         stored.Name,
+			Bio:          stored.Bio,
+			AvatarUrl:    stored.AvatarUrl,
+			PasswordHash: hashedPassword,
+			ID:           stored.ID,
+			Version:      stored.Version,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := recordAudit(c, q, auditRecord{
+			Action:   AuditPasswordChange,
+			ActorID:  &stored.ID,
+			TargetID: &stored.ID,
+			Before:   auditUserSnapshot(stored),
+			After:    auditUserSnapshot(updated),
+		}); err != nil {
+			return err
+		}
+
+		return dispatchUserWebhook(c, q, EventUserUpdated, updated)
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return conflictError(c, "Your account was changed while you were typing. Try again.", nil)
+	}
+	if err != nil {
+		return logAndReturnError(c, "change password", err, http.StatusInternalServerError, "Failed to change password")
+	}
+
+	if err := h.authService.RenewSession(c); err != nil {
+		return internalError(c, "Failed to renew session", err)
+	}
+	revoked, err := h.authService.DestroyOtherSessions(c, stored.ID)
+	if err != nil {
+		// The password is already changed; report the sessions left behind.
+		return logAndReturnError(c, "revoke other sessions", err, http.StatusInternalServerError, "Password changed, but other sessions could not be signed out")
+	}
+
+	slog.InfoContext(ctx, "Password changed",
+		"user_id", stored.ID,
+		"revoked_sessions", revoked,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	publishUserChange(c, h.events, EventUserUpdated, updated.ID)
+
+	authUser := middleware.User{
+		ID:       updated.ID,
+		Email:    updated.Email,
+		Name:     updated.Name,
+		IsActive: isActiveUser(updated),
+	}
+	c.Response().Header().Set(HtmxPushURL, RouteProfile)
+
+	return render(c, "ProfileUpdated", view.ProfileUpdated(authUser, "Your password has been changed and your other sessions were signed out."))
+}
+
+// profileUser loads the signed-in user's row.
+func (h *AuthHandler) profileUser(c echo.Context) (store.User, error) {
+	user, ok := h.authService.GetCurrentUser(c)
+	if !ok {
+		return store.User{}, authenticationError(c, "Authentication required")
+	}
+
+	stored, err := h.store.GetUser(c.Request().Context(), user.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		return store.User{}, authenticationError(c, "Authentication required")
+	}
+	if err != nil {
+		return store.User{}, logAndReturnError(c, "fetch user", err, http.StatusInternalServerError, "Failed to fetch profile")
+	}
+
+	return stored, nil
+}
+
+// verifyPassword re-authenticates a signed-in user before a sensitive
+// change. Accounts without a usable hash never match.
+func verifyPassword(ctx context.Context, authService *middleware.SessionAuthService, user store.User, password string) bool {
+	if password == "" || user.PasswordHash == "" {
+		return false
+	}
+
+	valid, err := matchPassword(ctx, authService, user, password)
+	if err != nil {
+		slog.WarnContext(ctx, "Password verification failed due to invalid hash",
+			"user_id", user.ID,
+			"error", err)
+		return false
+	}
+
+	return valid
+}
+
+// matchPassword reports whether password matches user's hash; the error is
+// for hashes that cannot be read.
+//
+// Form passwords used to be trimmed and stripped of NUL bytes before
+// hashing, like every other field. They are now hashed as sent, so a
+// password that only matches in its old form is still accepted. The hash is
+// left alone: rehashing it as sent would stop the trimmed form, and every
+// other spacing of it, from matching.
+func matchPassword(ctx context.Context, authService *middleware.SessionAuthService, user store.User, password string) (bool, error) {
+	valid, err := authService.VerifyPasswordArgon2(ctx, password, user.PasswordHash)
+	if err != nil || valid {
+		return valid, err
+	}
+
+	legacy := middleware.SanitizeString(password, middleware.SanitizeConfig{TrimSpace: true, StripNullBytes: true})
+	if legacy == password || legacy == "" {
+		return false, nil
+	}
+
+	return authService.VerifyPasswordArgon2(ctx, legacy, user.PasswordHash)
+}
@@ -6,7 +6,12 @@ import (
 
 	"log/slog"
 
+	"github.com/dunamismax/go-web-server/internal/avatar"
+	"github.com/dunamismax/go-web-server/internal/events"
+	"github.com/dunamismax/go-web-server/internal/health"
 	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/privacy"
+	"github.com/dunamismax/go-web-server/internal/storage"
 	"github.com/dunamismax/go-web-server/internal/store"
 	"github.com/dunamismax/go-web-server/internal/ui"
 	"github.com/labstack/echo/v4"
@@ -14,17 +19,64 @@ import (
 
 // Handlers holds all the application handlers.
 type Handlers struct {
-	Home *HomeHandler
-	User *UserHandler
-	Auth *AuthHandler
+	Home     *HomeHandler
+	User     *UserHandler
+	Auth     *AuthHandler
+	Security *SecurityHandler
+	Health   *HealthHandler
+	Admin    *AdminHandler
+	Privacy  *PrivacyHandler
+	Media    *MediaHandler
 }
 
-// NewHandlers creates a new handlers instance with the given store.
-func NewHandlers(s *store.Store, authService *middleware.SessionAuthService) *Handlers {
+// Config holds the settings handlers take from the application config.
+type Config struct {
+	Privacy privacy.Config
+	Avatar  avatar.Config
+	// Media stores uploads; nil keeps them on disk under DefaultMediaDir.
+	Media storage.Storage
+}
+
+// DefaultConfig provides the handler defaults.
+var DefaultConfig = Config{
+	Privacy: privacy.DefaultConfig,
+	Avatar:  avatar.DefaultConfig,
+}
+
+// NewHandlers creates a new handlers instance with the given store. User
+// changes are published on bus.
+func NewHandlers(s store.TxQuerier, authService *middleware.SessionAuthService, registry *health.Registry, bus *events.Bus) *Handlers {
+	return NewHandlersWithConfig(s, authService, registry, bus, DefaultConfig)
+}
+
+// NewHandlersWithConfig creates handlers with a custom configuration.
+func NewHandlersWithConfig(s store.TxQuerier, authService *middleware.SessionAuthService, registry *health.Registry, bus *events.Bus, config Config) *Handlers {
+	media := config.Media
+	if media == nil {
+		media = storage.NewLocal(DefaultMediaDir)
+	}
+
 	return &Handlers{
-		Home: NewHomeHandler(s),
-		User: NewUserHandler(s, authService),
-		Auth: NewAuthHandler(s, authService),
+		Home:     NewHomeHandler(s),
+		User:     NewUserHandler(s, authService, bus),
+		Auth:     NewAuthHandler(s, authService, bus),
+		Security: NewSecurityHandler(),
+		Health:   NewHealthHandler(registry, s),
+		Admin:    NewAdminHandler(s, authService),
+		Privacy:  NewPrivacyHandler(s, authService, config.Privacy),
+		Media:    NewMediaHandler(s, authService, bus, media, config.Avatar),
+	}
+}
+
+// IsStreamingRequest reports whether c is for a route whose response may
+// stream for longer than the request timeout: the user event stream and the
+// exports. Use it as the request timeout middleware's skipper.
+func IsStreamingRequest(c echo.Context) bool {
+	switch c.Request().URL.Path {
+	case RouteUserEvents, RouteUserExport, RouteAdminAuditExport:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -40,10 +92,20 @@ func RegisterRoutes(e *echo.Echo, handlers *Handlers) error {
 
 	e.GET("/static/*", echo.WrapHandler(http.StripPrefix("/static/", http.FileServer(http.FS(staticFS)))))
 
+	// Uploaded media such as avatars
+	e.GET("/media/*", handlers.Media.Media)
+
 	// Home routes
 	e.GET("/", handlers.Home.Home)
 	e.GET("/demo", handlers.Home.Demo)
-	e.GET("/health", handlers.Home.Health)
+
+	// Probes; the detailed reports are only on the admin/ops listener
+	e.GET(RouteLivez, handlers.Health.Livez)
+	e.GET(RouteReadyz, handlers.Health.ReadyzStatus)
+	e.GET(RouteHealth, handlers.Health.HealthStatus)
+
+	// Content-Security-Policy violation reports
+	e.POST(RouteCSPReport, handlers.Security.CSPReport)
 
 	// Authentication routes (no auth required)
 	auth := e.Group("/auth")
@@ -58,21 +120,63 @@ func RegisterRoutes(e *echo.Echo, handlers *Handlers) error {
 	// Protected routes (authentication required)
 	profile := e.Group("/profile", requireAuth)
 	profile.GET("", handlers.Auth.Profile)
+	profile.GET("/edit", handlers.Auth.EditProfilePage)
+	profile.PUT("/edit", handlers.Auth.UpdateProfile)
+	profile.GET("/password", handlers.Auth.ChangePasswordPage)
+	profile.PUT("/password", handlers.Auth.ChangePassword)
+	profile.POST("/avatar", handlers.Media.UploadAvatar)
+	profile.DELETE("/avatar", handlers.Media.RemoveAvatar)
+	profile.GET("/data", handlers.Privacy.PersonalData)
+	profile.POST("/data/exports", handlers.Privacy.CreateDataExport)
+	profile.GET("/data/exports/list", handlers.Privacy.DataExportList)
+	profile.GET("/data/exports/:id", handlers.Privacy.DownloadDataExport)
+	profile.POST("/data/deletion", handlers.Privacy.RequestAccountDeletion)
+	profile.DELETE("/data/deletion", handlers.Privacy.CancelAccountDeletion)
 
 	// User management routes
 	users := e.Group("/users", requireAuth)
 	users.GET("", handlers.User.Users)
 	users.GET("/list", handlers.User.UserList)
+	users.GET("/events", handlers.User.StreamUserEvents)
+	users.GET("/export", handlers.User.ExportUsers, handlers.Admin.RequireAdmin)
 	users.GET("/form", handlers.User.UserForm)
+	users.GET("/trash", handlers.User.DeletedUsers)
 	users.GET("/:id/edit", handlers.User.EditUserForm)
 	users.POST("", handlers.User.CreateUser)
 	users.PUT("/:id", handlers.User.UpdateUser)
 	users.PATCH("/:id/deactivate", handlers.User.DeactivateUser)
+	users.PATCH("/:id/reactivate", handlers.User.ReactivateUser)
+	users.PATCH("/:id/restore", handlers.User.RestoreUser)
 	users.DELETE("/:id", handlers.User.DeleteUser)
 
+	// Admin routes; server.tls.client_auth_paths can also require mTLS here
+	admin := e.Group("/admin", requireAuth, handlers.Admin.RequireAdmin)
+	admin.GET("/audit", handlers.Admin.AuditLog)
+	admin.GET("/audit/events", handlers.Admin.AuditEvents)
+	admin.GET("/audit/export", handlers.Admin.ExportAudit)
+	admin.GET("/jobs", handlers.Admin.Jobs)
+	admin.GET("/jobs/list", handlers.Admin.JobList)
+	admin.PATCH("/jobs/:id/retry", handlers.Admin.RetryJob)
+	admin.PATCH("/jobs/:id/cancel", handlers.Admin.CancelJob)
+	admin.GET("/tasks", handlers.Admin.Tasks)
+	admin.GET("/webhooks", handlers.Admin.Webhooks)
+	admin.POST("/webhooks", handlers.Admin.CreateWebhook)
+	admin.GET("/webhooks/:id", handlers.Admin.Webhook)
+	admin.GET("/webhooks/:id/deliveries", handlers.Admin.WebhookDeliveries)
+	admin.POST("/webhooks/:id/deliveries/:delivery/redeliver", handlers.Admin.RedeliverWebhook)
+	admin.PATCH("/webhooks/:id/enable", handlers.Admin.EnableWebhook)
+	admin.PATCH("/webhooks/:id/disable", handlers.Admin.DisableWebhook)
+	admin.DELETE("/webhooks/:id", handlers.Admin.DeleteWebhook)
+	admin.GET("/imports", handlers.Admin.Imports)
+	admin.POST("/imports", handlers.Admin.CreateImport)
+	admin.GET("/imports/:id", handlers.Admin.Import)
+	admin.GET("/imports/:id/progress", handlers.Admin.ImportProgress)
+	admin.GET("/deletions", handlers.Admin.AccountDeletions)
+
 	// API routes
 	api := e.Group("/api", requireAuth)
 	api.GET("/users/count", handlers.User.UserCount)
+	api.GET("/users/:id", handlers.User.GetUser)
 
 	return nil
 }
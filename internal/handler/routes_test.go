@@ -0,0 +1,1029 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/dunamismax/go-web-server/internal/avatar"
+	"github.com/dunamismax/go-web-server/internal/events"
+	"github.com/dunamismax/go-web-server/internal/health"
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/storage"
+	"github.com/dunamismax/go-web-server/internal/store"
+	storemem "github.com/dunamismax/go-web-server/internal/store/memstore"
+	"github.com/dunamismax/go-web-server/internal/xlsx"
+	"github.com/labstack/echo/v4"
+)
+
+// testArgon2Params keeps password hashing cheap so router tests stay fast.
+var testArgon2Params = middleware.Argon2Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+const testPassword = "Passw0rdExample"
+
+type testServer struct {
+	e     *echo.Echo
+	store *storemem.Store
+	bus   *events.Bus
+	auth  *middleware.SessionAuthService
+}
+
+// newTestServer wires the full router against in-memory users and sessions.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	sessionManager := scs.New()
+	sessionManager.Store = memstore.New()
+	authService := middleware.NewSessionAuthServiceWithParams(sessionManager, testArgon2Params)
+
+	s := storemem.New()
+	bus := events.NewBus()
+	t.Cleanup(bus.Close)
+
+	e := echo.New()
+	e.HTTPErrorHandler = middleware.ErrorHandler
+	e.Use(authService.SessionMiddleware())
+
+	config := DefaultConfig
+	config.Media = storage.NewLocal(t.TempDir())
+	if err := RegisterRoutes(e, NewHandlersWithConfig(s, authService, health.NewRegistry(0), bus, config)); err != nil {
+		t.Fatalf("RegisterRoutes() error = %v", err)
+	}
+
+	return &testServer{e: e, store: s, bus: bus, auth: authService}
+}
+
+func (ts *testServer) do(t *testing.T, method, target string, form url.Values, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body *strings.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+
+	req := httptest.NewRequest(method, target, body)
+	if form != nil {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	rec := httptest.NewRecorder()
+	ts.e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// register signs up a user and returns the session cookies.
+func (ts *testServer) register(t *testing.T, email string) []*http.Cookie {
+	t.Helper()
+
+	rec := ts.do(t, http.MethodPost, RouteRegister, url.Values{
+		"email":            {email},
+		"name":             {"Test User"},
+		"password":         {testPassword},
+		"confirm_password": {testPassword},
+	}, nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("register %s status = %d, want %d: %s", email, rec.Code, http.StatusFound, rec.Body.String())
+	}
+
+	return rec.Result().Cookies()
+}
+
+// registerAdmin signs up a user, grants them is_admin and returns the
+// session cookies.
+func (ts *testServer) registerAdmin(t *testing.T, email string) []*http.Cookie {
+	t.Helper()
+
+	cookies := ts.register(t, email)
+	user, err := ts.store.GetUserByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("GetUserByEmail(%s) error = %v", email, err)
+	}
+	ts.store.GrantAdmin(user.ID)
+
+	return cookies
+}
+
+func TestProtectedRoutesRequireSession(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+
+	rec := ts.do(t, http.MethodGet, "/users", nil, nil)
+	if rec.Code != http.StatusFound || rec.Header().Get(echo.HeaderLocation) != RouteLogin {
+		t.Fatalf("GET /users = %d %q, want redirect to %s", rec.Code, rec.Header().Get(echo.HeaderLocation), RouteLogin)
+	}
+
+	rec = ts.do(t, http.MethodGet, "/api/users/count", nil, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("GET /api/users/count status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRegisterLogsInAndListsUser(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	cookies := ts.register(t, "ada@example.com")
+
+	rec := ts.do(t, http.MethodGet, "/users/list", nil, cookies)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /users/list status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), "ada@example.com") {
+		t.Fatalf("user list does not include the new user: %s", rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodGet, "/api/users/count", nil, cookies)
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "1" {
+		t.Fatalf("GET /api/users/count = %d %q, want 200 \"1\"", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRegisterRejectsDuplicateEmail(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	ts.register(t, "ada@example.com")
+
+	rec := ts.do(t, http.MethodPost, RouteRegister, url.Values{
+		"email":            {"ada@example.com"},
+		"name":             {"Someone Else"},
+		"password":         {testPassword},
+		"confirm_password": {testPassword},
+	}, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate register status = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "Email already exists") {
+		t.Fatalf("duplicate register body = %s, want email conflict message", rec.Body.String())
+	}
+}
+
+func TestLogin(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	ts.register(t, "ada@example.com")
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		want     int
+	}{
+		{name: "valid credentials", email: "ada@example.com", password: testPassword, want: http.StatusFound},
+		{name: "wrong password", email: "ada@example.com", password: "Wr0ngPassword", want: http.StatusUnauthorized},
+		{name: "unknown email", email: "nobody@example.com", password: testPassword, want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := ts.do(t, http.MethodPost, RouteLogin, url.Values{
+				"email":    {tt.email},
+				"password": {tt.password},
+			}, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("login status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestLoginAcceptsPasswordsHashedTrimmed(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+
+	// Form passwords used to be normalized like every other field.
+	legacy := true
+	ts.e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			config := middleware.DefaultSanitizeConfig
+			if legacy {
+				config = middleware.SanitizeConfig{TrimSpace: true, StripNullBytes: true}
+			}
+			return middleware.SanitizeWithConfig(config)(next)(c)
+		}
+	})
+
+	const password = " " + testPassword + " "
+	rec := ts.do(t, http.MethodPost, RouteRegister, url.Values{
+		"email":            {"ada@example.com"},
+		"name":             {"Ada Lovelace"},
+		"password":         {password},
+		"confirm_password": {password},
+	}, nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("register status = %d, want %d: %s", rec.Code, http.StatusFound, rec.Body.String())
+	}
+	before, _ := ts.store.GetUser(context.Background(), 1)
+
+	legacy = false
+	login := func(password string) int {
+		return ts.do(t, http.MethodPost, RouteLogin, url.Values{
+			"email":    {"ada@example.com"},
+			"password": {password},
+		}, nil).Code
+	}
+
+	if code := login(" Wr0ngPassword "); code != http.StatusUnauthorized {
+		t.Fatalf("login with a wrong password = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := login(password); code != http.StatusFound {
+		t.Fatalf("login with the password as registered = %d, want %d", code, http.StatusFound)
+	}
+	if code := login(testPassword); code != http.StatusFound {
+		t.Fatalf("login with the trimmed password = %d, want %d", code, http.StatusFound)
+	}
+
+	after, _ := ts.store.GetUser(context.Background(), 1)
+	if after.PasswordHash != before.PasswordHash {
+		t.Fatal("signing in with the old form rewrote the password hash")
+	}
+}
+
+func TestDeactivatedUserDisappearsAndCannotLogIn(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	admin := ts.register(t, "admin@example.com")
+	ts.register(t, "ada@example.com")
+
+	ada, err := ts.store.GetUserByEmail(context.Background(), "ada@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail() error = %v", err)
+	}
+
+	rec := ts.do(t, http.MethodPatch, "/users/"+strconv.FormatInt(ada.ID, 10)+"/deactivate", nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("deactivate status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "ada@example.com") {
+		t.Fatal("deactivated user is still listed")
+	}
+
+	rec = ts.do(t, http.MethodGet, "/api/users/count", nil, admin)
+	if strings.TrimSpace(rec.Body.String()) != "1" {
+		t.Fatalf("active user count = %q, want 1", rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodPost, RouteLogin, url.Values{
+		"email":    {"ada@example.com"},
+		"password": {testPassword},
+	}, nil)
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "Account is inactive") {
+		t.Fatalf("inactive login = %d %s, want 401 inactive account", rec.Code, rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodPatch, "/users/"+strconv.FormatInt(ada.ID, 10)+"/reactivate", nil, admin)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "ada@example.com") {
+		t.Fatalf("reactivate = %d %s, want 200 listing the user again", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDeleteMovesUserToTrash(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	admin := ts.register(t, "admin@example.com")
+	adaSession := ts.register(t, "ada@example.com")
+
+	ada, err := ts.store.GetUserByEmail(context.Background(), "ada@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail() error = %v", err)
+	}
+	target := "/users/" + strconv.FormatInt(ada.ID, 10)
+
+	rec := ts.do(t, http.MethodDelete, target, nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodDelete, target, nil, admin)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("second delete status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	rec = ts.do(t, http.MethodGet, "/users/list", nil, admin)
+	if strings.Contains(rec.Body.String(), "ada@example.com") {
+		t.Fatal("trashed user is still listed")
+	}
+
+	rec = ts.do(t, http.MethodGet, "/users/trash", nil, admin)
+	if !strings.Contains(rec.Body.String(), "ada@example.com") {
+		t.Fatalf("trash does not list the deleted user: %s", rec.Body.String())
+	}
+
+	// Deleting signs the user out everywhere and blocks new logins.
+	rec = ts.do(t, http.MethodGet, "/users", nil, adaSession)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("GET /users with a deleted user's session = %d, want redirect to login", rec.Code)
+	}
+	rec = ts.do(t, http.MethodPost, RouteLogin, url.Values{
+		"email":    {"ada@example.com"},
+		"password": {testPassword},
+	}, nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("login as deleted user status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	rec = ts.do(t, http.MethodPatch, target+"/restore", nil, admin)
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "ada@example.com") {
+		t.Fatalf("restore = %d %s, want 200 and an empty trash", rec.Code, rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodGet, "/users/list", nil, admin)
+	if !strings.Contains(rec.Body.String(), "ada@example.com") {
+		t.Fatal("restored user is not listed")
+	}
+}
+
+func TestEditMissingUserReturnsNotFound(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	cookies := ts.register(t, "ada@example.com")
+
+	rec := ts.do(t, http.MethodGet, "/users/999/edit", nil, cookies)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GET /users/999/edit status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	cookies := ts.register(t, "ada@example.com")
+	ts.register(t, "grace@example.com")
+
+	ada, err := ts.store.GetUserByEmail(context.Background(), "ada@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail() error = %v", err)
+	}
+	target := "/users/" + strconv.FormatInt(ada.ID, 10)
+
+	rec := ts.do(t, http.MethodPut, target, url.Values{
+		"email":   {"ada.lovelace@example.com"},
+		"name":    {"Ada Lovelace"},
+		"version": {strconv.FormatInt(ada.Version, 10)},
+	}, cookies)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "ada.lovelace@example.com") {
+		t.Fatal("updated user list does not reflect the change")
+	}
+
+	if etag := rec.Header().Get(HeaderETag); etag != `"2"` {
+		t.Fatalf("update ETag = %q, want %q", etag, `"2"`)
+	}
+
+	rec = ts.do(t, http.MethodPut, target, url.Values{
+		"email":   {"grace@example.com"},
+		"name":    {"Ada Lovelace"},
+		"version": {"2"},
+	}, cookies)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("update to a taken email status = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+}
+
+func TestUpdateUserRejectsStaleVersion(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	cookies := ts.register(t, "ada@example.com")
+
+	rec := ts.do(t, http.MethodGet, "/users/1/edit", nil, cookies)
+	if etag := rec.Header().Get(HeaderETag); etag != `"1"` {
+		t.Fatalf("edit form ETag = %q, want %q", etag, `"1"`)
+	}
+	if !strings.Contains(rec.Body.String(), `name="version" value="1"`) {
+		t.Fatal("edit form does not carry the user's version")
+	}
+
+	// Another editor saves first.
+	rec = ts.do(t, http.MethodPut, "/users/1", url.Values{
+		"email":   {"ada@example.com"},
+		"name":    {"Ada Lovelace"},
+		"version": {"1"},
+	}, cookies)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("first update status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodPut, "/users/1", url.Values{
+		"email":   {"ada@example.com"},
+		"name":    {"Countess of Lovelace"},
+		"version": {"1"},
+	}, cookies)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("stale update status = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+	if etag := rec.Header().Get(HeaderETag); etag != `"2"` {
+		t.Fatalf("conflict ETag = %q, want current version %q", etag, `"2"`)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `"diff":{"name":{"submitted":"Countess of Lovelace","current":"Ada Lovelace"}}`) {
+		t.Fatalf("conflict body does not describe the changed field: %s", body)
+	}
+
+	user, _ := ts.store.GetUser(context.Background(), 1)
+	if user.Name != "Ada Lovelace" || user.Version != 2 {
+		t.Fatalf("stored user = %+v, want the first edit to win", user)
+	}
+}
+
+func TestUpdateUserPreconditions(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	cookies := ts.register(t, "ada@example.com")
+
+	form := url.Values{"email": {"ada@example.com"}, "name": {"Ada Lovelace"}}
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    int
+	}{
+		{name: "missing version", want: http.StatusPreconditionRequired},
+		{name: "malformed If-Match", ifMatch: `"abc"`, want: http.StatusPreconditionFailed},
+		{name: "stale If-Match", ifMatch: `"7"`, want: http.StatusConflict},
+		{name: "current weak If-Match", ifMatch: `W/"1"`, want: http.StatusOK},
+		{name: "any version", ifMatch: "*", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/users/1", strings.NewReader(form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		if tt.ifMatch != "" {
+			req.Header.Set(HeaderIfMatch, tt.ifMatch)
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		rec := httptest.NewRecorder()
+		ts.e.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Fatalf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
+
+// retryStore runs each transaction twice, like a serialization failure
+// retried by InTx. Between the attempts another edit to user 1 commits.
+type retryStore struct {
+	*storemem.Store
+}
+
+func (s retryStore) RunInTx(ctx context.Context, opts store.TxOptions, fn func(q store.Querier) error) error {
+	errRetry := errors.New("could not serialize access")
+	if err := s.Store.RunInTx(ctx, opts, func(q store.Querier) error {
+		if err := fn(q); err != nil {
+			return err
+		}
+		return errRetry
+	}); !errors.Is(err, errRetry) {
+		return err
+	}
+
+	user, err := s.GetUser(ctx, 1)
+	if err != nil {
+		return err
+	}
+	if _, err := s.UpdateUser(ctx, store.UpdateUserParams{
+		Email:   user.Email,
+		Name:    "Concurrent Edit",
+		ID:      user.ID,
+		Version: user.Version,
+	}); err != nil {
+		return err
+	}
+
+	return s.Store.RunInTx(ctx, opts, fn)
+}
+
+func TestAnyVersionUpdateSurvivesRetries(t *testing.T) {
+	t.Parallel()
+
+	for _, target := range []string{"/users/1", "/profile/edit"} {
+		ts := newTestServer(t)
+		cookies := ts.register(t, "ada@example.com")
+
+		e := echo.New()
+		e.HTTPErrorHandler = middleware.ErrorHandler
+		e.Use(ts.auth.SessionMiddleware())
+		config := DefaultConfig
+		config.Media = storage.NewLocal(t.TempDir())
+		if err := RegisterRoutes(e, NewHandlersWithConfig(retryStore{ts.store}, ts.auth, health.NewRegistry(0), ts.bus, config)); err != nil {
+			t.Fatalf("RegisterRoutes() error = %v", err)
+		}
+
+		form := url.Values{"email": {"ada@example.com"}, "name": {"Ada Lovelace"}}
+		req := httptest.NewRequest(http.MethodPut, target, strings.NewReader(form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		req.Header.Set(HeaderIfMatch, "*")
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("PUT %s with If-Match: * = %d, want %d: %s", target, rec.Code, http.StatusOK, rec.Body.String())
+		}
+		user, _ := ts.store.GetUser(context.Background(), 1)
+		if user.Name != "Ada Lovelace" || user.Version != 3 {
+			t.Fatalf("PUT %s stored %q at version %d, want the retried edit at version 3", target, user.Name, user.Version)
+		}
+	}
+}
+
+func TestGetUserJSON(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	cookies := ts.register(t, "ada@example.com")
+
+	rec := ts.do(t, http.MethodGet, "/api/users/1", nil, cookies)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/users/1 status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if etag := rec.Header().Get(HeaderETag); etag != `"1"` {
+		t.Fatalf("ETag = %q, want %q", etag, `"1"`)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `"email":"ada@example.com"`) || !strings.Contains(body, `"version":1`) {
+		t.Fatalf("body = %s, want the user with its version", body)
+	}
+	if strings.Contains(body, "password") {
+		t.Fatalf("body = %s, want no password hash", body)
+	}
+
+	if rec := ts.do(t, http.MethodGet, "/api/users/99", nil, cookies); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET /api/users/99 status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	cookies := ts.register(t, "ada@example.com")
+
+	rec := ts.do(t, http.MethodGet, "/profile/edit", nil, cookies)
+	if rec.Code != http.StatusOK || rec.Header().Get(HeaderETag) != `"1"` {
+		t.Fatalf("GET /profile/edit = %d, ETag %q; want 200 with the row version", rec.Code, rec.Header().Get(HeaderETag))
+	}
+
+	rec = ts.do(t, http.MethodPut, "/profile/edit", url.Values{
+		"email":   {"ada@example.com"},
+		"name":    {"Ada Lovelace"},
+		"bio":     {"Analyst"},
+		"version": {"1"},
+	}, cookies)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update profile status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	// The session's cached name follows the edit.
+	rec = ts.do(t, http.MethodGet, RouteProfile, nil, cookies)
+	if !strings.Contains(rec.Body.String(), "Welcome, Ada Lovelace!") {
+		t.Fatalf("profile does not show the new name: %s", rec.Body.String())
+	}
+
+	emailChange := url.Values{
+		"email":   {"ada.lovelace@example.com"},
+		"name":    {"Ada Lovelace"},
+		"version": {"2"},
+	}
+	rec = ts.do(t, http.MethodPut, "/profile/edit", emailChange, cookies)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "current_password") {
+		t.Fatalf("email change without password = %d, want 400 asking for it: %s", rec.Code, rec.Body.String())
+	}
+
+	emailChange.Set("current_password", testPassword)
+	rec = ts.do(t, http.MethodPut, "/profile/edit", emailChange, cookies)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("email change status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodPut, "/profile/edit", emailChange, cookies)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("stale profile edit status = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+
+	user, _ := ts.store.GetUser(context.Background(), 1)
+	if user.Email != "ada.lovelace@example.com" || user.Bio != nil || user.Version != 3 {
+		t.Fatalf("stored user = %+v, want the new email and no bio at version 3", user)
+	}
+}
+
+func TestBioIsSanitizedAndRenderedAsMarkdown(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	cookies := ts.register(t, "ada@example.com")
+
+	rec := ts.do(t, http.MethodPut, "/profile/edit", url.Values{
+		"email":   {"ada@example.com"},
+		"name":    {"<b>Ada</b> Lovelace"},
+		"bio":     {"**Analyst** of [engines](https://example.com)<script>alert(1)</script>\n<img src=x onerror=alert(1)>"},
+		"version": {"1"},
+	}, cookies)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update profile status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	user, _ := ts.store.GetUser(context.Background(), 1)
+	if want := "**Analyst** of [engines](https://example.com)\n"; user.Name != "Ada Lovelace" || derefString(user.Bio) != want {
+		t.Fatalf("stored name %q, bio %q; want %q and %q", user.Name, derefString(user.Bio), "Ada Lovelace", want)
+	}
+
+	rec = ts.do(t, http.MethodGet, "/users/list", nil, cookies)
+	body := rec.Body.String()
+	if !strings.Contains(body, `<strong>Analyst</strong> of <a href="https://example.com" rel="nofollow">engines</a>`) {
+		t.Fatalf("user list does not render the bio as Markdown: %s", body)
+	}
+	if strings.Contains(body, "alert(1)") {
+		t.Fatalf("user list contains the removed script: %s", body)
+	}
+}
+
+func TestChangePasswordSignsOutOtherSessions(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	cookies := ts.register(t, "ada@example.com")
+
+	login := func(password string) *httptest.ResponseRecorder {
+		return ts.do(t, http.MethodPost, RouteLogin, url.Values{
+			"email":    {"ada@example.com"},
+			"password": {password},
+		}, nil)
+	}
+	other := login(testPassword).Result().Cookies()
+
+	const newPassword = "N3wPassw0rdExample"
+	change := url.Values{
+		"current_password": {"Wr0ngPassword"},
+		"password":         {newPassword},
+		"confirm_password": {newPassword},
+	}
+	rec := ts.do(t, http.MethodPut, "/profile/password", change, cookies)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("wrong current password status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+
+	change.Set("current_password", testPassword)
+	rec = ts.do(t, http.MethodPut, "/profile/password", change, cookies)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("change password status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	renewed := rec.Result().Cookies()
+	if len(renewed) == 0 || renewed[0].Value == cookies[0].Value {
+		t.Fatal("password change did not renew the session token")
+	}
+	if rec := ts.do(t, http.MethodGet, RouteProfile, nil, renewed); rec.Code != http.StatusOK {
+		t.Fatalf("current session after password change = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := ts.do(t, http.MethodGet, RouteProfile, nil, other); rec.Code != http.StatusFound {
+		t.Fatalf("other session after password change = %d, want it signed out", rec.Code)
+	}
+
+	if rec := login(testPassword); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("login with the old password = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := login(newPassword); rec.Code != http.StatusFound {
+		t.Fatalf("login with the new password = %d, want %d", rec.Code, http.StatusFound)
+	}
+}
+
+func TestAvatarUploadIsServedFromMedia(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	cookies := ts.register(t, "ada@example.com")
+
+	upload := func(data []byte) *httptest.ResponseRecorder {
+		t.Helper()
+
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("avatar", "avatar.png")
+		if err != nil {
+			t.Fatalf("CreateFormFile() error = %v", err)
+		}
+		_, _ = part.Write(data)
+		if err := writer.Close(); err != nil {
+			t.Fatalf("multipart Close() error = %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/profile/avatar", &body)
+		req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		rec := httptest.NewRecorder()
+		ts.e.ServeHTTP(rec, req)
+		return rec
+	}
+	avatarURL := func() string {
+		t.Helper()
+
+		user, err := ts.store.GetUser(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("GetUser() error = %v", err)
+		}
+		return derefString(user.AvatarUrl)
+	}
+
+	if rec := upload([]byte("<svg onload=alert(1)></svg>")); rec.Code != http.StatusBadRequest {
+		t.Fatalf("upload of a non-image status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+
+	var picture bytes.Buffer
+	if err := png.Encode(&picture, image.NewGray(image.Rect(0, 0, 300, 200))); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	rec := upload(picture.Bytes())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("upload status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	first := avatarURL()
+	if !strings.Contains(rec.Body.String(), avatar.URL(first, 128)) {
+		t.Fatalf("avatar section does not show the upload %s: %s", first, rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodGet, first, nil, nil)
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != "image/png" ||
+		!strings.Contains(rec.Header().Get("Cache-Control"), "immutable") {
+		t.Fatalf("GET %s = %d %q %q, want a cacheable PNG", first, rec.Code, rec.Header().Get(echo.HeaderContentType), rec.Header().Get("Cache-Control"))
+	}
+	if decoded, err := png.Decode(rec.Body); err != nil || decoded.Bounds().Dx() != avatar.Sizes[0] {
+		t.Fatalf("served avatar does not decode as a %dpx square: %v", avatar.Sizes[0], err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, first, nil)
+	req.Header.Set("If-None-Match", rec.Header().Get(HeaderETag))
+	rec = httptest.NewRecorder()
+	ts.e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("revalidation status = %d, want %d", rec.Code, http.StatusNotModified)
+	}
+
+	// A new upload gets a new URL and deletes the old files.
+	if rec := upload(picture.Bytes()); rec.Code != http.StatusOK {
+		t.Fatalf("second upload status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	second := avatarURL()
+	if second == first {
+		t.Fatal("second upload reused the first URL")
+	}
+	if rec := ts.do(t, http.MethodGet, avatar.URL(first, 48), nil, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET replaced avatar = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	if rec := ts.do(t, http.MethodDelete, "/profile/avatar", nil, cookies); rec.Code != http.StatusOK {
+		t.Fatalf("remove avatar status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if url := avatarURL(); url != "" {
+		t.Fatalf("avatar after removal = %q, want none", url)
+	}
+	if rec := ts.do(t, http.MethodGet, second, nil, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET removed avatar = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	if rec := ts.do(t, http.MethodGet, "/media/avatars/%2e%2e/secret", nil, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET outside the media store = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+// slowStore delays every exported row and audit page, like a large export
+// over a slow database, and gives up when the request's context ends.
+type slowStore struct {
+	*storemem.Store
+	delay time.Duration
+}
+
+func (s slowStore) wait(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s slowStore) StreamUsers(ctx context.Context, arg store.SearchUsersParams, fn func(store.User) error) error {
+	return s.Store.StreamUsers(ctx, arg, func(user store.User) error {
+		if err := s.wait(ctx); err != nil {
+			return err
+		}
+		return fn(user)
+	})
+}
+
+func (s slowStore) ListAuditEvents(ctx context.Context, arg store.ListAuditEventsParams) ([]store.ListAuditEventsRow, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.Store.ListAuditEvents(ctx, arg)
+}
+
+func TestExportsOutliveTimeouts(t *testing.T) {
+	t.Parallel()
+
+	const timeout = 50 * time.Millisecond
+
+	ts := newTestServer(t)
+	slow := slowStore{Store: ts.store, delay: 2 * timeout}
+
+	e := echo.New()
+	e.HTTPErrorHandler = middleware.ErrorHandler
+	e.Use(middleware.RequestTimeoutWithConfig(middleware.RequestTimeoutConfig{
+		Timeout: timeout,
+		Skipper: IsStreamingRequest,
+	}))
+	e.Use(ts.auth.SessionMiddleware())
+	config := DefaultConfig
+	config.Media = storage.NewLocal(t.TempDir())
+	if err := RegisterRoutes(e, NewHandlersWithConfig(slow, ts.auth, health.NewRegistry(0), ts.bus, config)); err != nil {
+		t.Fatalf("RegisterRoutes() error = %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(e)
+	srv.Config.WriteTimeout = timeout
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	admin := ts.registerAdmin(t, "admin@example.com")
+	ts.register(t, "ada@example.com")
+
+	for _, tt := range []struct {
+		target string
+		lines  int
+	}{
+		{target: RouteUserExport + "?format=csv", lines: 3},
+		{target: RouteAdminAuditExport + "?format=csv", lines: 4},
+	} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+tt.target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, cookie := range admin {
+			req.AddCookie(cookie)
+		}
+
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", tt.target, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("GET %s body cut off: %v", tt.target, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s status = %d, want %d: %s", tt.target, resp.StatusCode, http.StatusOK, body)
+		}
+		if lines := strings.Count(string(body), "\n"); lines != tt.lines {
+			t.Fatalf("GET %s = %q, want %d lines", tt.target, body, tt.lines)
+		}
+	}
+}
+
+func TestUserListFiltersAndExport(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	ts := newTestServer(t)
+	admin := ts.registerAdmin(t, "admin@example.com")
+	ts.register(t, "ada@example.com")
+	ts.register(t, "grace@example.com")
+
+	rec := ts.do(t, http.MethodPatch, "/users/3/deactivate", nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("deactivate status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec = ts.do(t, http.MethodGet, "/users/list?status=inactive", nil, admin)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "grace@example.com") || strings.Contains(rec.Body.String(), "ada@example.com") {
+		t.Fatalf("inactive list = %d %s, want only grace", rec.Code, rec.Body.String())
+	}
+	rec = ts.do(t, http.MethodGet, "/users/list?status=all&q=ADA", nil, admin)
+	if !strings.Contains(rec.Body.String(), "ada@example.com") || strings.Contains(rec.Body.String(), "admin@example.com") {
+		t.Fatalf("search list = %s, want only ada", rec.Body.String())
+	}
+	rec = ts.do(t, http.MethodGet, "/users/list?status=banned", nil, admin)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("list with unknown status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = ts.do(t, http.MethodGet, "/users/export?format=csv&columns=password_hash", nil, admin)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("export with password_hash column = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	rec = ts.do(t, http.MethodGet, "/users/export?format=pdf", nil, admin)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("export as pdf = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = ts.do(t, http.MethodGet, "/users/export?format=csv&status=all&columns=name,email", nil, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("CSV export status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 4 || lines[0] != "email,name" || lines[1] != "grace@example.com,Test User" {
+		t.Fatalf("CSV export = %q, want email and name for three users, newest first", rec.Body.String())
+	}
+
+	rec = ts.do(t, http.MethodGet, "/users/export?format=ndjson", nil, admin)
+	if rec.Code != http.StatusOK || strings.Count(rec.Body.String(), "\n") != 2 {
+		t.Fatalf("NDJSON export = %d %q, want two active users", rec.Code, rec.Body.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(strings.SplitN(rec.Body.String(), "\n", 2)[0]), &record); err != nil {
+		t.Fatalf("decode NDJSON record: %v", err)
+	}
+	if _, ok := record["password_hash"]; ok || record["email"] != "ada@example.com" || len(record) != len(UserExportColumns) {
+		t.Fatalf("NDJSON record = %v, want every export column and no password hash", record)
+	}
+
+	rec = ts.do(t, http.MethodGet, "/users/export?format=xlsx&q=nobody", nil, admin)
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != xlsx.ContentType || !strings.HasPrefix(rec.Body.String(), "PK") {
+		t.Fatalf("XLSX export = %d %q, want a workbook", rec.Code, rec.Header().Get(echo.HeaderContentType))
+	}
+
+	action := AuditUserExport
+	exports, err := ts.store.ListAuditEvents(ctx, store.ListAuditEventsParams{Action: &action, MaxRows: 10})
+	if err != nil {
+		t.Fatalf("ListAuditEvents() error = %v", err)
+	}
+	if len(exports) != 3 {
+		t.Fatalf("%s audit events = %d, want 3", AuditUserExport, len(exports))
+	}
+	if !strings.Contains(string(exports[2].After), `"columns":["email","name"]`) {
+		t.Fatalf("first export audit state = %s, want the selected columns", exports[2].After)
+	}
+}
+
+func TestUserExportRequiresAdmin(t *testing.T) {
+	t.Parallel()
+
+	ts := newTestServer(t)
+	admin := ts.registerAdmin(t, "admin@example.com")
+	ada := ts.register(t, "ada@example.com")
+
+	rec := ts.do(t, http.MethodGet, RouteUserExport+"?format=csv", nil, ada)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("GET %s as a user = %d, want %d", RouteUserExport, rec.Code, http.StatusForbidden)
+	}
+	action := AuditUserExport
+	exports, err := ts.store.ListAuditEvents(context.Background(), store.ListAuditEventsParams{Action: &action, MaxRows: 10})
+	if err != nil || len(exports) != 0 {
+		t.Fatalf("%s audit events = %d, %v; want none for a refused export", AuditUserExport, len(exports), err)
+	}
+
+	const exportForm = "<summary>Export</summary>"
+	if rec := ts.do(t, http.MethodGet, "/users", nil, ada); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), exportForm) {
+		t.Fatalf("users page as a user = %d, want %d without the export form", rec.Code, http.StatusOK)
+	}
+	if rec := ts.do(t, http.MethodGet, "/users", nil, admin); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), exportForm) {
+		t.Fatalf("users page as an admin = %d, want %d with the export form", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// maxCSPReportSize bounds report bodies; browsers send a few KB at most.
+	maxCSPReportSize = 64 << 10
+	// maxCSPReportsLogged bounds how many violations one request can log.
+	maxCSPReportsLogged = 20
+)
+
+// SecurityHandler handles browser security reports.
+type SecurityHandler struct{}
+
+// NewSecurityHandler creates a new SecurityHandler.
+func NewSecurityHandler() *SecurityHandler {
+	return &SecurityHandler{}
+}
+
+// cspViolation is the normalized form of a CSP violation report.
+type cspViolation struct {
+	DocumentURL        string
+	BlockedURL         string
+	EffectiveDirective string
+	Disposition        string
+	SourceFile         string
+	LineNumber         int
+	Sample             string
+}
+
+// legacyCSPReport is the report-uri format (Content-Type: application/csp-report).
+type legacyCSPReport struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		BlockedURI         string `json:"blocked-uri"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		ScriptSample       string `json:"script-sample"`
+	} `json:"csp-report"`
+}
+
+// reportingAPIReport is the report-to format (Content-Type: application/reports+json).
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		BlockedURL         string `json:"blockedURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		Disposition        string `json:"disposition"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		Sample             string `json:"sample"`
+	} `json:"body"`
+}
+
+// CSPReport collects Content-Security-Policy violation reports and logs them.
+func (h *SecurityHandler) CSPReport(c echo.Context) error {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxCSPReportSize+1))
+	if err != nil {
+		return validationError(c, err)
+	}
+	if len(body) > maxCSPReportSize {
+		return middleware.NewAppError(
+			middleware.ErrorTypeValidation,
+			http.StatusRequestEntityTooLarge,
+			"Report too large",
+		).WithContext(c)
+	}
+
+	violations, err := parseCSPReports(c.Request().Header.Get(echo.HeaderContentType), body)
+	if err != nil {
+		return validationError(c, err)
+	}
+
+	for i, violation := range violations {
+		if i == maxCSPReportsLogged {
+			slog.WarnContext(c.Request().Context(), "CSP violation reports truncated",
+				"received", len(violations),
+				"logged", maxCSPReportsLogged,
+				"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+			break
+		}
+
+		slog.WarnContext(c.Request().Context(), "CSP violation",
+			"document_url", violation.DocumentURL,
+			"blocked_url", violation.BlockedURL,
+			"directive", violation.EffectiveDirective,
+			"disposition", violation.Disposition,
+			"source_file", violation.SourceFile,
+			"line_number", violation.LineNumber,
+			"sample", violation.Sample,
+			"remote_ip", c.RealIP(),
+			"user_agent", c.Request().UserAgent(),
+			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func parseCSPReports(contentType string, body []byte) ([]cspViolation, error) {
+	if strings.HasPrefix(contentType, "application/reports+json") {
+		var reports []reportingAPIReport
+		if err := json.Unmarshal(body, &reports); err != nil {
+			return nil, err
+		}
+
+		violations := make([]cspViolation, 0, len(reports))
+		for _, report := range reports {
+			if report.Type != "csp-violation" {
+				continue
+			}
+			violations = append(violations, cspViolation{
+				DocumentURL:        report.Body.DocumentURL,
+				BlockedURL:         report.Body.BlockedURL,
+				EffectiveDirective: report.Body.EffectiveDirective,
+				Disposition:        report.Body.Disposition,
+				SourceFile:         report.Body.SourceFile,
+				LineNumber:         report.Body.LineNumber,
+				Sample:             report.Body.Sample,
+			})
+		}
+
+		return violations, nil
+	}
+
+	var report legacyCSPReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, err
+	}
+
+	directive := report.Report.EffectiveDirective
+	if directive == "" {
+		directive = report.Report.ViolatedDirective
+	}
+
+	return []cspViolation{{
+		DocumentURL:        report.Report.DocumentURI,
+		BlockedURL:         report.Report.BlockedURI,
+		EffectiveDirective: directive,
+		Disposition:        report.Report.Disposition,
+		SourceFile:         report.Report.SourceFile,
+		LineNumber:         report.Report.LineNumber,
+		Sample:             report.Report.ScriptSample,
+	}}, nil
+}
@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 
+	"github.com/dunamismax/go-web-server/internal/events"
 	"github.com/dunamismax/go-web-server/internal/middleware"
 	"github.com/dunamismax/go-web-server/internal/store"
 	"github.com/dunamismax/go-web-server/internal/view"
@@ -12,26 +14,31 @@ import (
 
 // UserHandler handles all user-related HTTP requests including CRUD operations.
 type UserHandler struct {
-	store       *store.Store
+	store       store.TxQuerier
 	authService *middleware.SessionAuthService
+	events      *events.Bus
 }
 
-// NewUserHandler creates a new UserHandler with the given store.
-func NewUserHandler(s *store.Store, authService *middleware.SessionAuthService) *UserHandler {
+// NewUserHandler creates a new UserHandler with the given store. Changes are
+// published on bus so that every open users page can update.
+func NewUserHandler(s store.TxQuerier, authService *middleware.SessionAuthService, bus *events.Bus) *UserHandler {
 	return &UserHandler{
 		store:       s,
 		authService: authService,
+		events:      bus,
 	}
 }
 
 // ManagedUserUpdateRequest represents the editable user fields from the CRUD form.
 type ManagedUserUpdateRequest struct {
 	Email           string `json:"email" form:"email" validate:"required,email"`
-	Name            string `json:"name" form:"name" validate:"required,min=2,max=100"`
+	Name            string `json:"name" form:"name" validate:"required,min=2,max=100" sanitize:"strict"`
 	Password        string `json:"password,omitempty" form:"password" validate:"omitempty,password"`
 	ConfirmPassword string `json:"confirm_password,omitempty" form:"confirm_password"`
-	Bio             string `json:"bio,omitempty" form:"bio" validate:"max=500"`
-	AvatarURL       string `json:"avatar_url,omitempty" form:"avatar_url" validate:"omitempty,url"`
+	Bio             string `json:"bio,omitempty" form:"bio" validate:"max=500" sanitize:"ugc"`
+	// Version is the row version the editor started from. An If-Match header
+	// takes precedence.
+	Version int64 `json:"version,omitempty" form:"version"`
 }
 
 // Validate implements custom validation for ManagedUserUpdateRequest.
@@ -55,28 +62,46 @@ func (r ManagedUserUpdateRequest) Validate() error {
 	return nil
 }
 
-// Users renders the main user management page.
+// Users renders the main user management page. Only administrators get the
+// export form.
 func (h *UserHandler) Users(c echo.Context) error {
 	token := setupCSRFHeaders(c)
 
-	return renderWithCSRF(c,
-		view.UsersContent(),       // HTMX component
-		view.UsersWithCSRF(token), // Full page component with CSRF
-		view.Users(),              // Basic component
+	var exportColumns []string
+	if userID, ok := middleware.GetCurrentUserID(c); ok {
+		admin, err := isAdmin(c.Request().Context(), h.store, userID)
+		if err != nil {
+			return internalError(c, "Failed to check administrator access", err)
+		}
+		if admin {
+			exportColumns = UserExportColumns
+		}
+	}
+
+	return renderWithCSRF(c, "Users",
+		view.UsersContent(exportColumns),         // HTMX component
+		view.UsersWithCSRF(exportColumns, token), // Full page component with CSRF
+		view.Users(exportColumns),                // Basic component
 	)
 }
 
-// UserList returns the list of users as HTML fragment.
+// UserList returns the users matching the q and status filters as HTML
+// fragment. Without filters it lists active users.
 func (h *UserHandler) UserList(c echo.Context) error {
 	ctx := c.Request().Context()
 	setupCSRFHeaders(c)
 
-	users, err := h.store.ListUsers(ctx)
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		return err
+	}
+
+	users, err := h.store.SearchUsers(ctx, filter.params())
 	if err != nil {
 		return logAndReturnError(c, "fetch users", err, http.StatusInternalServerError, "Failed to fetch users")
 	}
 
-	return view.UserList(users).Render(ctx, c.Response().Writer)
+	return render(c, "UserList", view.UserList(users))
 }
 
 // UserCount returns the count of active users.
@@ -89,13 +114,13 @@ func (h *UserHandler) UserCount(c echo.Context) error {
 		return logAndReturnError(c, "count users", err, http.StatusInternalServerError, "Failed to count users")
 	}
 
-	return view.UserCount(count).Render(ctx, c.Response().Writer)
+	return render(c, "UserCount", view.UserCount(count))
 }
 
 // UserForm renders the user creation/edit form.
 func (h *UserHandler) UserForm(c echo.Context) error {
 	token := setupCSRFHeaders(c)
-	return view.UserForm(nil, token).Render(c.Request().Context(), c.Response().Writer)
+	return render(c, "UserForm", view.UserForm(nil, token))
 }
 
 // EditUserForm renders the user edit form with existing data.
@@ -112,8 +137,31 @@ func (h *UserHandler) EditUserForm(c echo.Context) error {
 		return logAndReturnError(c, "fetch user", err, http.StatusNotFound, "User not found")
 	}
 
+	c.Response().Header().Set(HeaderETag, userETag(user.Version))
+
 	token := setupCSRFHeaders(c)
-	return view.UserForm(&user, token).Render(ctx, c.Response().Writer)
+	return render(c, "UserForm", view.UserForm(&user, token))
+}
+
+// GetUser returns a user as JSON. The ETag is the user's version, for use
+// in If-Match when updating it.
+func (h *UserHandler) GetUser(c echo.Context) error {
+	id, err := parseIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.store.GetUser(c.Request().Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
+	if err != nil {
+		return logAndReturnError(c, "fetch user", err, http.StatusInternalServerError, "Failed to fetch user")
+	}
+
+	c.Response().Header().Set(HeaderETag, userETag(user.Version))
+
+	return c.JSON(http.StatusOK, auditUserSnapshot(user))
 }
 
 // CreateUser creates a new user.
@@ -124,6 +172,9 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return validationError(c, err)
 	}
+	if err := middleware.SanitizeStruct(&req); err != nil {
+		return internalError(c, "Failed to read request", err)
+	}
 
 	if validationErrors := middleware.ValidateStruct(req); len(validationErrors) > 0 {
 		return validationErrorWithDetails(c, validationErrors)
@@ -133,7 +184,7 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 		return validationErrorWithDetails(c, err)
 	}
 
-	hashedPassword, err := h.authService.HashPasswordArgon2(req.Password)
+	hashedPassword, err := h.authService.HashPasswordArgon2(ctx, req.Password)
 	if err != nil {
 		return internalError(c, "Failed to process password", err)
 	}
@@ -142,13 +193,30 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 		Email:        req.Email,
 		Name:         req.Name,
 		Bio:          stringPtr(req.Bio),
-		AvatarUrl:    stringPtr(req.AvatarURL),
 		PasswordHash: hashedPassword,
 	}
 
-	_, err = h.store.CreateUser(ctx, params)
+	var created store.User
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		var err error
+		created, err = q.CreateUser(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		if err := recordAudit(c, q, auditRecord{
+			Action:   AuditUserCreate,
+			ActorID:  currentActorID(c, h.authService),
+			TargetID: &created.ID,
+			After:    auditUserSnapshot(created),
+		}); err != nil {
+			return err
+		}
+
+		return dispatchUserWebhook(c, q, EventUserCreated, created)
+	})
 	if err != nil {
-		slog.Error("Failed to create user",
+		slog.ErrorContext(c.Request().Context(), "Failed to create user",
 			"email", req.Email,
 			"name", req.Name,
 			"error", err,
@@ -156,20 +224,21 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 		return databaseWriteError(c, err, "Failed to create user")
 	}
 
-	slog.Info("User created successfully",
+	slog.InfoContext(c.Request().Context(), "User created successfully",
 		"name", req.Name,
 		"email", req.Email,
 		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
 
 	// Trigger custom event for HTMX
 	c.Response().Header().Set("HX-Trigger", "userCreated")
+	publishUserChange(c, h.events, EventUserCreated, created.ID)
 
 	users, err := h.store.ListUsers(ctx)
 	if err != nil {
 		return logAndReturnError(c, "fetch updated users", err, http.StatusInternalServerError, "Failed to fetch updated users")
 	}
 
-	return view.UserList(users).Render(ctx, c.Response().Writer)
+	return render(c, "UserList", view.UserList(users))
 }
 
 // UpdateUser updates an existing user.
@@ -185,6 +254,9 @@ func (h *UserHandler) UpdateUser(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return validationError(c, err)
 	}
+	if err := middleware.SanitizeStruct(&req); err != nil {
+		return internalError(c, "Failed to read request", err)
+	}
 
 	if validationErrors := middleware.ValidateStruct(req); len(validationErrors) > 0 {
 		return validationErrorWithDetails(c, validationErrors)
@@ -194,49 +266,115 @@ func (h *UserHandler) UpdateUser(c echo.Context) error {
 		return validationErrorWithDetails(c, err)
 	}
 
-	params := store.UpdateUserParams{
-		Email:     req.Email,
-		Name:      req.Name,
-		Bio:       stringPtr(req.Bio),
-		AvatarUrl: stringPtr(req.AvatarURL),
-		ID:        id,
+	version, err := expectedVersion(c, req.Version)
+	if err != nil {
+		return err
 	}
 
+	// Hash outside the transaction so retries do not repeat the expensive work.
+	var hashedPassword string
 	if req.Password != "" {
-		hashedPassword, err := h.authService.HashPasswordArgon2(req.Password)
+		hashedPassword, err = h.authService.HashPasswordArgon2(ctx, req.Password)
 		if err != nil {
 			return internalError(c, "Failed to process password", err)
 		}
+	}
 
-		_, err = h.store.UpdateUserPassword(ctx, store.UpdateUserPasswordParams{
-			Email:        req.Email,
-			Name:         req.Name,
-			Bio:          stringPtr(req.Bio),
-			AvatarUrl:    stringPtr(req.AvatarURL),
-			PasswordHash: hashedPassword,
-			ID:           id,
-		})
-		if err != nil {
-			slog.Error("Failed to update user with password",
-				"id", id,
-				"email", req.Email,
-				"error", err,
-				"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
-			return databaseWriteError(c, err, "Failed to update user")
-		}
-	} else {
-		_, err = h.store.UpdateUser(ctx, params)
+	// Update and re-list in one transaction so the fragment reflects the write.
+	var (
+		users     []store.User
+		updated   store.User
+		current   *store.User
+		updateErr error
+	)
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		before, err := q.GetUser(ctx, id)
 		if err != nil {
-			slog.Error("Failed to update user",
-				"id", id,
-				"email", req.Email,
-				"error", err,
-				"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
-			return databaseWriteError(c, err, "Failed to update user")
+			return err
+		}
+		// Resolved per attempt, so a retried transaction matches the row it
+		// just read rather than the one a failed attempt saw.
+		expected := version
+		if expected == anyVersion {
+			expected = before.Version
+		}
+
+		if hashedPassword != "" {
+			updated, updateErr = q.UpdateUserPassword(ctx, store.UpdateUserPasswordParams{
+				Email:        req.Email,
+				Name:         req.Name,
+				Bio:          stringPtr(req.Bio),
+				AvatarUrl:    before.AvatarUrl,
+				PasswordHash: hashedPassword,
+				ID:           id,
+				Version:      expected,
+			})
+		} else {
+			updated, updateErr = q.UpdateUser(ctx, store.UpdateUserParams{
+				Email:     req.Email,
+				Name:      req.Name,
+				Bio:       stringPtr(req.Bio),
+				AvatarUrl: before.AvatarUrl,
+				ID:        id,
+				Version:   expected,
+			})
+		}
+		if errors.Is(updateErr, store.ErrNotFound) {
+			// Either the user is gone or someone else saved first.
+			row, err := q.GetUser(ctx, id)
+			if err != nil {
+				return err
+			}
+			current = &row
+			return updateErr
 		}
+		if updateErr != nil {
+			return updateErr
+		}
+
+		action := AuditUserUpdate
+		if hashedPassword != "" {
+			action = AuditPasswordChange
+		}
+		if err := recordAudit(c, q, auditRecord{
+			Action:   action,
+			ActorID:  currentActorID(c, h.authService),
+			TargetID: &id,
+			Before:   auditUserSnapshot(before),
+			After:    auditUserSnapshot(updated),
+		}); err != nil {
+			return err
+		}
+
+		if err := dispatchUserWebhook(c, q, EventUserUpdated, updated); err != nil {
+			return err
+		}
+
+		users, err = q.ListUsers(ctx)
+		return err
+	})
+	if current != nil {
+		return userVersionConflictError(c, req, *current)
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
+	if updateErr != nil {
+		slog.ErrorContext(c.Request().Context(), "Failed to update user",
+			"id", id,
+			"email", req.Email,
+			"password_changed", hashedPassword != "",
+			"error", updateErr,
+			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+		return databaseWriteError(c, updateErr, "Failed to update user")
 	}
+	if err != nil {
+		return logAndReturnError(c, "update user", err, http.StatusInternalServerError, "Failed to update user")
+	}
+
+	c.Response().Header().Set(HeaderETag, userETag(updated.Version))
 
-	slog.Info("User updated successfully",
+	slog.InfoContext(c.Request().Context(), "User updated successfully",
 		"id", id,
 		"name", req.Name,
 		"email", req.Email,
@@ -244,17 +382,72 @@ func (h *UserHandler) UpdateUser(c echo.Context) error {
 
 	// Trigger custom event for HTMX
 	c.Response().Header().Set("HX-Trigger", "userUpdated")
+	publishUserChange(c, h.events, EventUserUpdated, id)
+
+	return render(c, "UserList", view.UserList(users))
+}
+
+// DeactivateUser deactivates a user instead of deleting.
+func (h *UserHandler) DeactivateUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := parseIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		before, err := q.GetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := q.DeactivateUser(ctx, id); err != nil {
+			return err
+		}
+
+		if err := recordAudit(c, q, auditRecord{
+			Action:   AuditUserDeactivate,
+			ActorID:  currentActorID(c, h.authService),
+			TargetID: &id,
+			Before:   map[string]bool{"is_active": isActiveUser(before)},
+			After:    map[string]bool{"is_active": false},
+		}); err != nil {
+			return err
+		}
+
+		after, err := q.GetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return dispatchUserWebhook(c, q, EventUserDeactivated, after)
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
+	if err != nil {
+		return logAndReturnError(c, "deactivate user", err, http.StatusInternalServerError, "Failed to deactivate user")
+	}
+
+	slog.InfoContext(c.Request().Context(), "User deactivated successfully",
+		"id", id,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	// Trigger custom event for HTMX
+	c.Response().Header().Set("HX-Trigger", "userDeactivated")
+	publishUserChange(c, h.events, EventUserDeactivated, id)
 
 	users, err := h.store.ListUsers(ctx)
 	if err != nil {
 		return logAndReturnError(c, "fetch updated users", err, http.StatusInternalServerError, "Failed to fetch updated users")
 	}
 
-	return view.UserList(users).Render(ctx, c.Response().Writer)
+	return render(c, "UserList", view.UserList(users))
 }
 
-// DeactivateUser deactivates a user instead of deleting.
-func (h *UserHandler) DeactivateUser(c echo.Context) error {
+// ReactivateUser marks a deactivated user active again.
+func (h *UserHandler) ReactivateUser(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	id, err := parseIDParam(c)
@@ -262,27 +455,62 @@ func (h *UserHandler) DeactivateUser(c echo.Context) error {
 		return err
 	}
 
-	err = h.store.DeactivateUser(ctx, id)
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		before, err := q.GetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		reactivated, err := q.ReactivateUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		if reactivated == 0 {
+			return store.ErrNotFound
+		}
+
+		if err := recordAudit(c, q, auditRecord{
+			Action:   AuditUserReactivate,
+			ActorID:  currentActorID(c, h.authService),
+			TargetID: &id,
+			Before:   map[string]bool{"is_active": isActiveUser(before)},
+			After:    map[string]bool{"is_active": true},
+		}); err != nil {
+			return err
+		}
+
+		after, err := q.GetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return dispatchUserWebhook(c, q, EventUserReactivated, after)
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
 	if err != nil {
-		return logAndReturnError(c, "deactivate user", err, http.StatusInternalServerError, "Failed to deactivate user")
+		return logAndReturnError(c, "reactivate user", err, http.StatusInternalServerError, "Failed to reactivate user")
 	}
 
-	slog.Info("User deactivated successfully",
+	slog.InfoContext(c.Request().Context(), "User reactivated successfully",
 		"id", id,
 		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
 
 	// Trigger custom event for HTMX
-	c.Response().Header().Set("HX-Trigger", "userDeactivated")
+	c.Response().Header().Set("HX-Trigger", "userReactivated")
+	publishUserChange(c, h.events, EventUserReactivated, id)
 
 	users, err := h.store.ListUsers(ctx)
 	if err != nil {
 		return logAndReturnError(c, "fetch updated users", err, http.StatusInternalServerError, "Failed to fetch updated users")
 	}
 
-	return view.UserList(users).Render(ctx, c.Response().Writer)
+	return render(c, "UserList", view.UserList(users))
 }
 
-// DeleteUser permanently deletes a user.
+// DeleteUser moves a user to the trash and signs them out. The retention job
+// deletes it permanently once the retention period has passed.
 func (h *UserHandler) DeleteUser(c echo.Context) error {
 	ctx := c.Request().Context()
 
@@ -291,18 +519,123 @@ func (h *UserHandler) DeleteUser(c echo.Context) error {
 		return err
 	}
 
-	err = h.store.DeleteUser(ctx, id)
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		before, err := q.GetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := q.SoftDeleteUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return store.ErrNotFound
+		}
+
+		if err := recordAudit(c, q, auditRecord{
+			Action:   AuditUserDelete,
+			ActorID:  currentActorID(c, h.authService),
+			TargetID: &id,
+			Before:   auditUserSnapshot(before),
+		}); err != nil {
+			return err
+		}
+
+		return dispatchUserWebhook(c, q, EventUserDeleted, before)
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
 	if err != nil {
 		return logAndReturnError(c, "delete user", err, http.StatusInternalServerError, "Failed to delete user")
 	}
 
-	slog.Info("User deleted successfully",
+	if _, err := h.authService.DestroyUserSessions(ctx, id); err != nil {
+		slog.WarnContext(ctx, "Failed to sign out deleted user",
+			"id", id,
+			"error", err,
+			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+	}
+
+	slog.InfoContext(c.Request().Context(), "User moved to trash",
 		"id", id,
 		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
 
 	// Trigger custom event for HTMX
 	c.Response().Header().Set("HX-Trigger", "userDeleted")
+	publishUserChange(c, h.events, EventUserDeleted, id)
 
 	// Return empty response since the row should be removed
 	return c.NoContent(http.StatusOK)
 }
+
+// DeletedUsers returns the users in the trash as HTML fragment.
+func (h *UserHandler) DeletedUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+	setupCSRFHeaders(c)
+
+	users, err := h.store.ListDeletedUsers(ctx)
+	if err != nil {
+		return logAndReturnError(c, "fetch deleted users", err, http.StatusInternalServerError, "Failed to fetch deleted users")
+	}
+
+	return render(c, "DeletedUserList", view.DeletedUserList(users))
+}
+
+// RestoreUser takes a user out of the trash.
+func (h *UserHandler) RestoreUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := parseIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		restored, err := q.RestoreUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		if restored == 0 {
+			return store.ErrNotFound
+		}
+
+		after, err := q.GetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := recordAudit(c, q, auditRecord{
+			Action:   AuditUserRestore,
+			ActorID:  currentActorID(c, h.authService),
+			TargetID: &id,
+			After:    auditUserSnapshot(after),
+		}); err != nil {
+			return err
+		}
+
+		return dispatchUserWebhook(c, q, EventUserRestored, after)
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
+	if err != nil {
+		return logAndReturnError(c, "restore user", err, http.StatusInternalServerError, "Failed to restore user")
+	}
+
+	slog.InfoContext(c.Request().Context(), "User restored successfully",
+		"id", id,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	// Trigger custom event for HTMX
+	c.Response().Header().Set("HX-Trigger", "userRestored")
+	publishUserChange(c, h.events, EventUserRestored, id)
+
+	users, err := h.store.ListDeletedUsers(ctx)
+	if err != nil {
+		return logAndReturnError(c, "fetch deleted users", err, http.StatusInternalServerError, "Failed to fetch deleted users")
+	}
+
+	return render(c, "DeletedUserList", view.DeletedUserList(users))
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/labstack/echo/v4"
+)
+
+// userETag formats a user row version as an entity tag.
+func userETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// anyVersion is returned by expectedVersion for "If-Match: *", which
+// matches whatever version is stored.
+const anyVersion int64 = 0
+
+// expectedVersion returns the row version an edit was based on, read from
+// If-Match or, for HTML forms, the version field. It returns anyVersion for
+// "If-Match: *"; callers then use the version they read.
+func expectedVersion(c echo.Context, formVersion int64) (int64, error) {
+	if ifMatch := strings.TrimSpace(c.Request().Header.Get(HeaderIfMatch)); ifMatch != "" {
+		if ifMatch == "*" {
+			return anyVersion, nil
+		}
+		tag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+		version, err := strconv.ParseInt(tag, 10, 64)
+		if err != nil || version <= 0 {
+			return 0, middleware.NewAppError(
+				middleware.ErrorTypeValidation,
+				http.StatusPreconditionFailed,
+				"If-Match must be the ETag returned for this user",
+			).WithContext(c)
+		}
+		return version, nil
+	}
+
+	if formVersion <= 0 {
+		return 0, middleware.NewAppError(
+			middleware.ErrorTypeValidation,
+			http.StatusPreconditionRequired,
+			"Edits must include the user's version or an If-Match header",
+		).WithContext(c)
+	}
+
+	return formVersion, nil
+}
+
+// fieldChange shows a submitted value next to the value now stored.
+type fieldChange struct {
+	Submitted string `json:"submitted"`
+	Current   string `json:"current"`
+}
+
+// userVersionConflictError reports that current was saved by someone else
+// after the editor loaded it.
+func userVersionConflictError(c echo.Context, req ManagedUserUpdateRequest, current store.User) error {
+	currentValues := map[string]string{
+		"email": current.Email,
+		"name":  current.Name,
+		"bio":   derefString(current.Bio),
+	}
+	submitted := map[string]string{
+		"email": req.Email,
+		"name":  req.Name,
+		"bio":   req.Bio,
+	}
+
+	diff := map[string]fieldChange{}
+	for field, value := range submitted {
+		if value != currentValues[field] {
+			diff[field] = fieldChange{Submitted: value, Current: currentValues[field]}
+		}
+	}
+
+	c.Response().Header().Set(HeaderETag, userETag(current.Version))
+
+	return conflictError(c, "This user was changed by someone else. Review the current values and try again.", map[string]interface{}{
+		"current": currentValues,
+		"version": current.Version,
+		"diff":    diff,
+	})
+}
@@ -0,0 +1,433 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dunamismax/go-web-server/internal/events"
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/view"
+	"github.com/dunamismax/go-web-server/internal/webhooks"
+	"github.com/labstack/echo/v4"
+)
+
+// webhookDeliveryPageSize is how many deliveries an endpoint's page loads at
+// a time.
+const webhookDeliveryPageSize = 50
+
+// WebhookEvents lists the events webhook endpoints can subscribe to.
+var WebhookEvents = []string{
+	string(EventUserCreated), string(EventUserUpdated), string(EventUserDeactivated),
+	string(EventUserReactivated), string(EventUserDeleted), string(EventUserRestored),
+}
+
+// WebhookEndpointRequest registers a webhook endpoint. No events subscribes
+// it to every event.
+type WebhookEndpointRequest struct {
+	URL         string   `json:"url" form:"url" validate:"required,url,max=2048"`
+	Description string   `json:"description,omitempty" form:"description" validate:"max=200" sanitize:"strict"`
+	Events      []string `json:"events,omitempty" form:"events"`
+}
+
+// Validate implements custom validation for WebhookEndpointRequest.
+func (r WebhookEndpointRequest) Validate() error {
+	if err := webhooks.ValidateURL(r.URL); err != nil {
+		return middleware.ValidationErrors{{Field: "url", Message: err.Error()}}
+	}
+
+	for _, event := range r.Events {
+		if !slices.Contains(WebhookEvents, event) {
+			return middleware.ValidationErrors{{Field: "events", Message: "unknown event " + event}}
+		}
+	}
+
+	return nil
+}
+
+// dispatchUserWebhook queues webhook deliveries for a user change. Call it
+// with the change's transaction so nothing is sent if the change rolls back.
+// The data is the audited view of the user, which never includes the
+// password hash.
+func dispatchUserWebhook(c echo.Context, q store.Querier, topic events.Topic[userChange], user store.User) error {
+	_, err := webhooks.Dispatch(c.Request().Context(), q, string(topic), auditUserSnapshot(user))
+	return err
+}
+
+// Webhooks renders the webhook endpoints page.
+func (h *AdminHandler) Webhooks(c echo.Context) error {
+	endpoints, err := h.store.ListWebhookEndpoints(c.Request().Context())
+	if err != nil {
+		return logAndReturnError(c, "fetch webhook endpoints", err, http.StatusInternalServerError, "Failed to fetch webhook endpoints")
+	}
+
+	token := setupCSRFHeaders(c)
+
+	return renderWithCSRF(c, "Webhooks",
+		view.WebhooksContent(endpoints, WebhookEvents),         // HTMX component
+		view.WebhooksWithCSRF(endpoints, WebhookEvents, token), // Full page component with CSRF
+		view.Webhooks(endpoints, WebhookEvents),                // Basic component
+	)
+}
+
+// CreateWebhook registers an endpoint with a new signing secret and returns
+// the refreshed endpoint table.
+func (h *AdminHandler) CreateWebhook(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req WebhookEndpointRequest
+	if err := c.Bind(&req); err != nil {
+		return validationError(c, err)
+	}
+	if err := middleware.SanitizeStruct(&req); err != nil {
+		return internalError(c, "Failed to read request", err)
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	req.Description = strings.TrimSpace(req.Description)
+
+	if validationErrors := middleware.ValidateStruct(req); len(validationErrors) > 0 {
+		return validationErrorWithDetails(c, validationErrors)
+	}
+
+	if err := req.Validate(); err != nil {
+		return validationErrorWithDetails(c, err)
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		return internalError(c, "Failed to create webhook secret", err)
+	}
+
+	params := store.CreateWebhookEndpointParams{
+		Url:         req.URL,
+		Description: stringPtr(req.Description),
+		Secret:      secret,
+		Events:      slices.Compact(slices.Sorted(slices.Values(req.Events))),
+	}
+
+	var created store.WebhookEndpoint
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		var err error
+		created, err = q.CreateWebhookEndpoint(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(c, q, auditRecord{
+			Action:     AuditWebhookCreate,
+			ActorID:    currentActorID(c, h.authService),
+			TargetType: auditTargetWebhook,
+			TargetID:   &created.ID,
+			After:      auditWebhookSnapshot(created),
+		})
+	})
+	if err != nil {
+		return logAndReturnError(c, "create webhook endpoint", err, http.StatusInternalServerError, "Failed to create webhook endpoint")
+	}
+
+	slog.InfoContext(ctx, "Webhook endpoint created",
+		"id", created.ID,
+		"url", created.Url,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	return h.renderWebhookTable(c)
+}
+
+// EnableWebhook re-enables an endpoint and clears its failure count.
+func (h *AdminHandler) EnableWebhook(c echo.Context) error {
+	return h.setWebhookEnabled(c, true)
+}
+
+// DisableWebhook stops deliveries to an endpoint until it is enabled again.
+func (h *AdminHandler) DisableWebhook(c echo.Context) error {
+	return h.setWebhookEnabled(c, false)
+}
+
+func (h *AdminHandler) setWebhookEnabled(c echo.Context, enabled bool) error {
+	ctx := c.Request().Context()
+
+	id, err := parseIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	action := AuditWebhookEnable
+	params := store.SetWebhookEndpointEnabledParams{Enabled: enabled, ID: id}
+	if !enabled {
+		action = AuditWebhookDisable
+		params.DisabledReason = stringPtr("disabled by an admin")
+	}
+
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		changed, err := q.SetWebhookEndpointEnabled(ctx, params)
+		if err != nil {
+			return err
+		}
+		if changed == 0 {
+			return store.ErrNotFound
+		}
+
+		return recordAudit(c, q, auditRecord{
+			Action:     action,
+			ActorID:    currentActorID(c, h.authService),
+			TargetType: auditTargetWebhook,
+			TargetID:   &id,
+			After:      map[string]bool{"enabled": enabled},
+		})
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
+	if err != nil {
+		return logAndReturnError(c, action, err, http.StatusInternalServerError, "Failed to update webhook endpoint")
+	}
+
+	slog.InfoContext(ctx, "Webhook endpoint changed",
+		"id", id,
+		"enabled", enabled,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	return h.renderWebhookTable(c)
+}
+
+// DeleteWebhook deletes an endpoint with its delivery log.
+func (h *AdminHandler) DeleteWebhook(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := parseIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		before, err := q.GetWebhookEndpoint(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if _, err := q.DeleteWebhookEndpoint(ctx, id); err != nil {
+			return err
+		}
+
+		return recordAudit(c, q, auditRecord{
+			Action:     AuditWebhookDelete,
+			ActorID:    currentActorID(c, h.authService),
+			TargetType: auditTargetWebhook,
+			TargetID:   &id,
+			Before:     auditWebhookSnapshot(before),
+		})
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
+	if err != nil {
+		return logAndReturnError(c, "delete webhook endpoint", err, http.StatusInternalServerError, "Failed to delete webhook endpoint")
+	}
+
+	slog.InfoContext(ctx, "Webhook endpoint deleted",
+		"id", id,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	return h.renderWebhookTable(c)
+}
+
+func (h *AdminHandler) renderWebhookTable(c echo.Context) error {
+	endpoints, err := h.store.ListWebhookEndpoints(c.Request().Context())
+	if err != nil {
+		return logAndReturnError(c, "fetch webhook endpoints", err, http.StatusInternalServerError, "Failed to fetch webhook endpoints")
+	}
+
+	return render(c, "WebhookTable", view.WebhookTable(endpoints))
+}
+
+// webhookDeliveryPage loads one page of an endpoint's deliveries and the
+// link to the next one.
+func (h *AdminHandler) webhookDeliveryPage(c echo.Context, endpoint store.WebhookEndpoint, beforeID int64) (view.WebhookDeliveryPage, error) {
+	params := store.ListWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		// Fetch one extra row to learn whether another page exists.
+		MaxRows: webhookDeliveryPageSize + 1,
+	}
+	if beforeID > 0 {
+		params.BeforeID = &beforeID
+	}
+
+	deliveries, err := h.store.ListWebhookDeliveries(c.Request().Context(), params)
+	if err != nil {
+		return view.WebhookDeliveryPage{}, err
+	}
+
+	page := view.WebhookDeliveryPage{Endpoint: endpoint}
+	if len(deliveries) > webhookDeliveryPageSize {
+		deliveries = deliveries[:webhookDeliveryPageSize]
+		page.NextURL = webhookDeliveriesRoute(endpoint.ID) + "?before=" + strconv.FormatInt(deliveries[len(deliveries)-1].ID, 10)
+	}
+	page.Deliveries = deliveries
+
+	return page, nil
+}
+
+// Webhook renders one endpoint with its signing secret and delivery log.
+func (h *AdminHandler) Webhook(c echo.Context) error {
+	endpoint, err := h.webhookEndpoint(c)
+	if err != nil {
+		return err
+	}
+
+	page, err := h.webhookDeliveryPage(c, endpoint, 0)
+	if err != nil {
+		return logAndReturnError(c, "fetch webhook deliveries", err, http.StatusInternalServerError, "Failed to fetch webhook deliveries")
+	}
+
+	token := setupCSRFHeaders(c)
+
+	return renderWithCSRF(c, "Webhook",
+		view.WebhookContent(page),         // HTMX component
+		view.WebhookWithCSRF(page, token), // Full page component with CSRF
+		view.Webhook(page),                // Basic component
+	)
+}
+
+// WebhookDeliveries returns an endpoint's delivery log as HTML fragment. With
+// a before cursor it returns only the next page of rows for "Load more".
+func (h *AdminHandler) WebhookDeliveries(c echo.Context) error {
+	endpoint, err := h.webhookEndpoint(c)
+	if err != nil {
+		return err
+	}
+
+	var beforeID int64
+	if raw := c.QueryParam("before"); raw != "" {
+		beforeID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || beforeID <= 0 {
+			return middleware.NewAppError(
+				middleware.ErrorTypeValidation,
+				http.StatusBadRequest,
+				"Invalid page cursor",
+			).WithContext(c)
+		}
+	}
+
+	page, err := h.webhookDeliveryPage(c, endpoint, beforeID)
+	if err != nil {
+		return logAndReturnError(c, "fetch webhook deliveries", err, http.StatusInternalServerError, "Failed to fetch webhook deliveries")
+	}
+
+	if beforeID > 0 {
+		return render(c, "WebhookDeliveryRows", view.WebhookDeliveryRows(page))
+	}
+
+	return render(c, "WebhookDeliveryTable", view.WebhookDeliveryTable(page))
+}
+
+// RedeliverWebhook sends a delivery's event to its endpoint again and returns
+// the refreshed delivery log.
+func (h *AdminHandler) RedeliverWebhook(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	endpoint, err := h.webhookEndpoint(c)
+	if err != nil {
+		return err
+	}
+
+	deliveryID, err := strconv.ParseInt(c.Param("delivery"), 10, 64)
+	if err != nil {
+		return middleware.NewAppError(
+			middleware.ErrorTypeValidation,
+			http.StatusBadRequest,
+			"Invalid ID format",
+		).WithContext(c).WithInternal(err)
+	}
+
+	var redelivery store.WebhookDelivery
+	err = h.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		original, err := q.GetWebhookDelivery(ctx, deliveryID)
+		if err != nil {
+			return err
+		}
+		if original.EndpointID != endpoint.ID {
+			return store.ErrNotFound
+		}
+
+		redelivery, err = webhooks.Redeliver(ctx, q, deliveryID)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(c, q, auditRecord{
+			Action:     AuditWebhookRedeliver,
+			ActorID:    currentActorID(c, h.authService),
+			TargetType: auditTargetWebhook,
+			TargetID:   &endpoint.ID,
+			After:      map[string]int64{"delivery_id": redelivery.ID, "redelivery_of": deliveryID},
+		})
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		return middleware.ErrNotFound.WithContext(c)
+	}
+	if errors.Is(err, webhooks.ErrEndpointDisabled) {
+		return conflictError(c, "Enable the endpoint before redelivering", nil)
+	}
+	if err != nil {
+		return logAndReturnError(c, "redeliver webhook", err, http.StatusInternalServerError, "Failed to redeliver webhook")
+	}
+
+	slog.InfoContext(ctx, "Webhook redelivery queued",
+		"endpoint_id", endpoint.ID,
+		"delivery_id", redelivery.ID,
+		"redelivery_of", deliveryID,
+		"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+	page, err := h.webhookDeliveryPage(c, endpoint, 0)
+	if err != nil {
+		return logAndReturnError(c, "fetch webhook deliveries", err, http.StatusInternalServerError, "Failed to fetch webhook deliveries")
+	}
+
+	return render(c, "WebhookDeliveryTable", view.WebhookDeliveryTable(page))
+}
+
+// webhookEndpoint loads the endpoint in the URL.
+func (h *AdminHandler) webhookEndpoint(c echo.Context) (store.WebhookEndpoint, error) {
+	id, err := parseIDParam(c)
+	if err != nil {
+		return store.WebhookEndpoint{}, err
+	}
+
+	endpoint, err := h.store.GetWebhookEndpoint(c.Request().Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		return store.WebhookEndpoint{}, middleware.ErrNotFound.WithContext(c)
+	}
+	if err != nil {
+		return store.WebhookEndpoint{}, logAndReturnError(c, "fetch webhook endpoint", err, http.StatusInternalServerError, "Failed to fetch webhook endpoint")
+	}
+
+	return endpoint, nil
+}
+
+func webhookDeliveriesRoute(id int64) string {
+	return RouteAdminWebhooks + "/" + strconv.FormatInt(id, 10) + "/deliveries"
+}
+
+// auditWebhook is the audited view of an endpoint; it never includes the
+// signing secret.
+type auditWebhook struct {
+	ID          int64    `json:"id"`
+	URL         string   `json:"url"`
+	Description *string  `json:"description,omitempty"`
+	Events      []string `json:"events"`
+	Enabled     bool     `json:"enabled"`
+}
+
+func auditWebhookSnapshot(endpoint store.WebhookEndpoint) auditWebhook {
+	return auditWebhook{
+		ID:          endpoint.ID,
+		URL:         endpoint.Url,
+		Description: endpoint.Description,
+		Events:      endpoint.Events,
+		Enabled:     endpoint.Enabled,
+	}
+}
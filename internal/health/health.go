@@ -0,0 +1,149 @@
+// Package health provides a registry of named readiness checks and the drain
+// state used to take an instance out of rotation before shutdown.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check reports whether a dependency is usable. It should honor ctx.
+type Check func(ctx context.Context) error
+
+// Check statuses.
+const (
+	StatusOK      = "ok"
+	StatusFailing = "failing"
+)
+
+// CheckResult is the outcome of a single named check.
+type CheckResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the combined outcome of all registered checks.
+type Report struct {
+	Status   string        `json:"status"`
+	Draining bool          `json:"draining"`
+	Checks   []CheckResult `json:"checks"`
+}
+
+// Healthy reports whether every check passed and the instance is not draining.
+func (r Report) Healthy() bool {
+	return r.Status == StatusOK
+}
+
+// Registry holds named checks. Subsystems register their checks at startup;
+// probes run them all concurrently.
+type Registry struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks map[string]Check
+
+	draining atomic.Bool
+}
+
+// NewRegistry creates a registry that bounds each check run by timeout.
+func NewRegistry(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	return &Registry{
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Register adds a named check, replacing any existing check with that name.
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks[name] = check
+}
+
+// SetDraining marks the instance as draining. Readiness fails while draining
+// so load balancers stop routing new requests before the server shuts down.
+func (r *Registry) SetDraining(draining bool) {
+	r.draining.Store(draining)
+}
+
+// Draining reports whether the instance is draining.
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+// Run executes every registered check concurrently and returns the report.
+// Results are sorted by check name.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.checks))
+	checks := make([]Check, 0, len(r.checks))
+	for name, check := range r.checks {
+		names = append(names, name)
+		checks = append(checks, check)
+	}
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.runCheck(ctx, names[i], checks[i])
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	report := Report{
+		Status:   StatusOK,
+		Draining: r.Draining(),
+		Checks:   results,
+	}
+	if report.Draining {
+		report.Status = StatusFailing
+	}
+	for _, result := range results {
+		if result.Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+
+	return report
+}
+
+func (r *Registry) runCheck(ctx context.Context, name string, check Check) (result CheckResult) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	result = CheckResult{Name: name, Status: StatusOK}
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result.Status = StatusFailing
+			result.Error = fmt.Sprintf("check panicked: %v", recovered)
+		}
+		result.Duration = time.Since(start).Round(time.Microsecond).String()
+	}()
+
+	if err := check(ctx); err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+
+	return result
+}
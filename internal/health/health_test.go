@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistryRunReportsEachCheck(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry(time.Second)
+	registry.Register("database", func(context.Context) error { return nil })
+	registry.Register("cache", func(context.Context) error { return errors.New("connection refused") })
+
+	report := registry.Run(context.Background())
+
+	if report.Healthy() {
+		t.Fatal("expected report with a failing check to be unhealthy")
+	}
+	if len(report.Checks) != 2 || report.Checks[0].Name != "cache" || report.Checks[1].Name != "database" {
+		t.Fatalf("Checks = %+v, want cache and database sorted by name", report.Checks)
+	}
+	if got := report.Checks[0]; got.Status != StatusFailing || got.Error != "connection refused" {
+		t.Fatalf("cache result = %+v, want failing with error", got)
+	}
+	if got := report.Checks[1]; got.Status != StatusOK {
+		t.Fatalf("database result = %+v, want ok", got)
+	}
+}
+
+func TestRegistryRunBoundsChecksByTimeout(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry(10 * time.Millisecond)
+	registry.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	report := registry.Run(context.Background())
+
+	if report.Healthy() || report.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("report = %+v, want deadline exceeded", report)
+	}
+}
+
+func TestRegistryRunRecoversPanickingCheck(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry(time.Second)
+	registry.Register("broken", func(context.Context) error { panic("boom") })
+
+	report := registry.Run(context.Background())
+
+	if report.Healthy() || report.Checks[0].Status != StatusFailing {
+		t.Fatalf("report = %+v, want failing check", report)
+	}
+}
+
+func TestRegistryDrainingFailsReport(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry(time.Second)
+	registry.Register("database", func(context.Context) error { return nil })
+
+	if report := registry.Run(context.Background()); !report.Healthy() {
+		t.Fatalf("report = %+v, want healthy before draining", report)
+	}
+
+	registry.SetDraining(true)
+
+	report := registry.Run(context.Background())
+	if report.Healthy() || !report.Draining {
+		t.Fatalf("report = %+v, want failing and draining", report)
+	}
+}
@@ -0,0 +1,204 @@
+// Package jobs runs background work from a queue kept in the jobs table.
+// Handlers are registered by kind at startup; any instance running a Pool
+// claims due jobs with FOR UPDATE SKIP LOCKED, so replicas share the queue
+// without running a job twice. Failed attempts are retried with exponential
+// backoff until max_attempts, after which the job is dead until retried by
+// hand.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Job states, as stored in jobs.state.
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateDead      = "dead"
+	StateCancelled = "cancelled"
+)
+
+// States lists every state, in the order the admin page shows them.
+var States = []string{StatePending, StateRunning, StateSucceeded, StateDead, StateCancelled}
+
+// DefaultMaxAttempts is how many times a job runs before it is dead.
+const DefaultMaxAttempts = 5
+
+const (
+	backoffBase = 10 * time.Second
+	backoffMax  = time.Hour
+)
+
+// ErrDuplicate is returned by Enqueue when a pending or running job already
+// holds the unique key.
+var ErrDuplicate = errors.New("a job with this unique key is already queued")
+
+// Args is the payload of one kind of job. It is stored as JSON, so fields
+// must survive a round trip through encoding/json.
+type Args interface {
+	// Kind names the job type; it must be stable across deploys.
+	Kind() string
+}
+
+// handlerFunc runs a job whose payload has not been decoded yet.
+type handlerFunc func(ctx context.Context, job store.Job) error
+
+// Registry maps job kinds to their handlers.
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]handlerFunc
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]handlerFunc)}
+}
+
+// Register adds the handler for jobs of A's kind. Handlers must honor ctx: it
+// is cancelled when the attempt times out or shutdown gives up waiting.
+// Registering a kind twice panics, as it is a programming error.
+func Register[A Args](r *Registry, fn func(ctx context.Context, job store.Job, args A) error) {
+	var zero A
+	kind := zero.Kind()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.handlers[kind]; exists {
+		panic(fmt.Sprintf("jobs: handler for %q registered twice", kind))
+	}
+
+	r.handlers[kind] = func(ctx context.Context, job store.Job) error {
+		var args A
+		if err := json.Unmarshal(job.Payload, &args); err != nil {
+			return Permanent(fmt.Errorf("decode %s payload: %w", kind, err))
+		}
+
+		return fn(ctx, job, args)
+	}
+}
+
+// Kinds returns the registered kinds in sorted order.
+func (r *Registry) Kinds() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	kinds := make([]string, 0, len(r.handlers))
+	for kind := range r.handlers {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+
+	return kinds
+}
+
+func (r *Registry) handler(kind string) (handlerFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, ok := r.handlers[kind]
+	return fn, ok
+}
+
+// EnqueueOptions adjusts how a job is queued; zero values use the defaults.
+type EnqueueOptions struct {
+	// RunAt delays the job until this time; zero runs it as soon as possible.
+	RunAt time.Time
+	// UniqueKey rejects the job with ErrDuplicate while another pending or
+	// running job holds the same key.
+	UniqueKey string
+	// MaxAttempts defaults to DefaultMaxAttempts.
+	MaxAttempts int32
+}
+
+// Enqueue queues args as a new job. Pass a transaction's Querier to queue the
+// job only if the surrounding change commits.
+func Enqueue(ctx context.Context, q store.Querier, args Args, opts EnqueueOptions) (store.Job, error) {
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return store.Job{}, fmt.Errorf("encode %s payload: %w", args.Kind(), err)
+	}
+
+	params := store.EnqueueJobParams{
+		Kind:        args.Kind(),
+		Payload:     payload,
+		MaxAttempts: opts.MaxAttempts,
+	}
+	if params.MaxAttempts <= 0 {
+		params.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.UniqueKey != "" {
+		params.UniqueKey = &opts.UniqueKey
+	}
+	if !opts.RunAt.IsZero() {
+		params.RunAt = pgtype.Timestamptz{Time: opts.RunAt, Valid: true}
+	}
+
+	job, err := q.EnqueueJob(ctx, params)
+	if errors.Is(err, store.ErrNotFound) {
+		// ON CONFLICT DO NOTHING returns no row for a duplicate unique key.
+		return store.Job{}, ErrDuplicate
+	}
+	if err != nil {
+		return store.Job{}, fmt.Errorf("enqueue %s job: %w", args.Kind(), err)
+	}
+
+	return job, nil
+}
+
+// permanentError marks a failure that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps err so the job goes straight to the dead state instead of
+// being retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent.
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// Backoff returns the delay before retrying a job that has failed attempt
+// times: 10s doubling per attempt up to an hour, with up to 20% jitter so
+// jobs that failed together do not retry together.
+func Backoff(attempt int32) time.Duration {
+	delay := backoffMax
+	if attempt < 1 {
+		attempt = 1
+	}
+	if shift := attempt - 1; shift < 20 {
+		delay = min(backoffBase<<shift, backoffMax)
+	}
+
+	//nolint:gosec // Jitter does not need a cryptographic source.
+	jitter := time.Duration(rand.Int64N(int64(delay/5) + 1))
+	return delay - jitter
+}
@@ -0,0 +1,207 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/store/memstore"
+)
+
+type echoArgs struct {
+	Message string `json:"message"`
+}
+
+func (echoArgs) Kind() string { return "test.echo" }
+
+func TestEnqueueHonorsUniqueKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := memstore.New()
+
+	job, err := Enqueue(ctx, s, echoArgs{Message: "hi"}, EnqueueOptions{UniqueKey: "greeting"})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if job.Kind != "test.echo" || string(job.Payload) != `{"message":"hi"}` {
+		t.Fatalf("Enqueue() = %s %s, want test.echo with encoded payload", job.Kind, job.Payload)
+	}
+	if job.MaxAttempts != DefaultMaxAttempts || job.State != StatePending {
+		t.Fatalf("Enqueue() = max_attempts %d state %s, want defaults", job.MaxAttempts, job.State)
+	}
+
+	if _, err := Enqueue(ctx, s, echoArgs{}, EnqueueOptions{UniqueKey: "greeting"}); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("duplicate Enqueue() error = %v, want ErrDuplicate", err)
+	}
+
+	if _, err := s.CancelJob(ctx, job.ID); err != nil {
+		t.Fatalf("CancelJob() error = %v", err)
+	}
+	if _, err := Enqueue(ctx, s, echoArgs{}, EnqueueOptions{UniqueKey: "greeting"}); err != nil {
+		t.Fatalf("Enqueue() after cancelling the holder error = %v", err)
+	}
+}
+
+func TestPoolRecordsOutcomes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := memstore.New()
+
+	registry := NewRegistry()
+	Register(registry, func(_ context.Context, _ store.Job, args echoArgs) error {
+		switch args.Message {
+		case "retry":
+			return errors.New("temporary failure")
+		case "fatal":
+			return Permanent(errors.New("bad input"))
+		case "panic":
+			panic("boom")
+		}
+		return nil
+	})
+
+	enqueue := func(message string, opts EnqueueOptions) store.Job {
+		t.Helper()
+		job, err := Enqueue(ctx, s, echoArgs{Message: message}, opts)
+		if err != nil {
+			t.Fatalf("Enqueue(%q) error = %v", message, err)
+		}
+		return job
+	}
+
+	ok := enqueue("ok", EnqueueOptions{})
+	retry := enqueue("retry", EnqueueOptions{})
+	fatal := enqueue("fatal", EnqueueOptions{})
+	panicked := enqueue("panic", EnqueueOptions{MaxAttempts: 1})
+	later := enqueue("ok", EnqueueOptions{RunAt: time.Now().Add(time.Hour)})
+
+	pool := NewPoolWithConfig(s, registry, Config{Workers: 2, Interval: 10 * time.Millisecond})
+	pool.Start(ctx)
+
+	want := map[int64]string{
+		ok.ID:       StateSucceeded,
+		retry.ID:    StatePending,
+		fatal.ID:    StateDead,
+		panicked.ID: StateDead,
+		later.ID:    StatePending,
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		jobs := jobsByID(t, s)
+		if jobs[retry.ID].Attempts == 1 && jobs[ok.ID].State == StateSucceeded &&
+			jobs[fatal.ID].State == StateDead && jobs[panicked.ID].State == StateDead {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs did not finish: %+v", jobs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := pool.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	jobs := jobsByID(t, s)
+	for id, state := range want {
+		if jobs[id].State != state {
+			t.Fatalf("job %d state = %s, want %s", id, jobs[id].State, state)
+		}
+	}
+	if got := jobs[retry.ID]; got.LastError == nil || *got.LastError != "temporary failure" || !got.RunAt.Time.After(time.Now()) {
+		t.Fatalf("retried job = %+v, want last error and a future run_at", got)
+	}
+	if got := jobs[panicked.ID]; got.LastError == nil || *got.LastError != "job panicked: boom" {
+		t.Fatalf("panicked job last error = %v, want the panic", got.LastError)
+	}
+	if jobs[later.ID].Attempts != 0 {
+		t.Fatal("scheduled job ran before its run_at")
+	}
+}
+
+func TestShutdownReleasesInterruptedJobs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := memstore.New()
+
+	started := make(chan struct{})
+	registry := NewRegistry()
+	Register(registry, func(ctx context.Context, _ store.Job, _ echoArgs) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	job, err := Enqueue(ctx, s, echoArgs{}, EnqueueOptions{})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	pool := NewPoolWithConfig(s, registry, Config{Workers: 1, Interval: 10 * time.Millisecond})
+	pool.Start(ctx)
+	<-started
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(shutdownCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() error = %v, want deadline exceeded", err)
+	}
+
+	got := jobsByID(t, s)[job.ID]
+	if got.State != StatePending || got.Attempts != 0 || got.LockedBy != nil {
+		t.Fatalf("interrupted job = %+v, want released without using an attempt", got)
+	}
+}
+
+func TestRegisterPanicsOnDuplicateKind(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	handler := func(context.Context, store.Job, echoArgs) error { return nil }
+	Register(registry, handler)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering a kind twice to panic")
+		}
+	}()
+	Register(registry, handler)
+}
+
+func TestBackoffGrowsAndCaps(t *testing.T) {
+	t.Parallel()
+
+	for attempt, base := range map[int32]time.Duration{
+		1:   10 * time.Second,
+		2:   20 * time.Second,
+		4:   80 * time.Second,
+		20:  time.Hour,
+		100: time.Hour,
+	} {
+		got := Backoff(attempt)
+		if got > base || got < base-base/5 {
+			t.Fatalf("Backoff(%d) = %s, want within 20%% below %s", attempt, got, base)
+		}
+	}
+}
+
+func jobsByID(t *testing.T, s *memstore.Store) map[int64]store.Job {
+	t.Helper()
+
+	jobs, err := s.ListJobs(context.Background(), store.ListJobsParams{MaxRows: 100})
+	if err != nil {
+		t.Fatalf("ListJobs() error = %v", err)
+	}
+
+	byID := make(map[int64]store.Job, len(jobs))
+	for _, job := range jobs {
+		byID[job.ID] = job
+	}
+
+	return byID
+}
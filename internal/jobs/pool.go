@@ -0,0 +1,280 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/telemetry"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// finishTimeout bounds the query that records a job's outcome.
+	finishTimeout = 10 * time.Second
+	// rescueGrace is how long past Timeout a running job may stay locked
+	// before another instance assumes its worker died.
+	rescueGrace = time.Minute
+)
+
+// Config configures a Pool.
+type Config struct {
+	// Workers is how many jobs run at once.
+	Workers int
+	// Interval is how often idle workers poll for due jobs.
+	Interval time.Duration
+	// Timeout bounds a single attempt.
+	Timeout time.Duration
+	// ID identifies this instance in jobs.locked_by; it defaults to the
+	// hostname and process ID.
+	ID string
+}
+
+// DefaultConfig provides the pool defaults.
+var DefaultConfig = Config{
+	Workers:  4,
+	Interval: time.Second,
+	Timeout:  5 * time.Minute,
+}
+
+// Pool claims and runs jobs for every kind in its registry.
+type Pool struct {
+	store    store.Querier
+	registry *Registry
+	config   Config
+
+	mu     sync.Mutex
+	stop   context.CancelFunc
+	abort  context.CancelFunc
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// NewPool creates a pool with the default configuration.
+func NewPool(q store.Querier, registry *Registry) *Pool {
+	return NewPoolWithConfig(q, registry, DefaultConfig)
+}
+
+// NewPoolWithConfig creates a pool with a custom configuration.
+func NewPoolWithConfig(q store.Querier, registry *Registry, config Config) *Pool {
+	if config.Workers <= 0 {
+		config.Workers = DefaultConfig.Workers
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultConfig.Interval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultConfig.Timeout
+	}
+	if config.ID == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "worker"
+		}
+		config.ID = host + "-" + strconv.Itoa(os.Getpid())
+	}
+
+	return &Pool{store: q, registry: registry, config: config}
+}
+
+// Start launches the workers and the rescuer of jobs orphaned by crashed
+// instances. Cancelling ctx stops claiming new jobs but lets running jobs
+// finish; call Shutdown to wait for them.
+func (p *Pool) Start(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stop != nil || p.closed {
+		return
+	}
+
+	claimCtx, stop := context.WithCancel(ctx)
+	// Running jobs outlive ctx so that a shutdown signal does not interrupt
+	// them; only Shutdown giving up aborts them.
+	runCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	p.stop, p.abort = stop, abort
+
+	kinds := p.registry.Kinds()
+	for i := range p.config.Workers {
+		p.wg.Add(1)
+		go p.work(claimCtx, runCtx, fmt.Sprintf("%s/%d", p.config.ID, i), kinds)
+	}
+
+	p.wg.Add(1)
+	go p.rescue(claimCtx)
+
+	slog.Info("job workers started", "workers", p.config.Workers, "kinds", kinds)
+}
+
+// Shutdown stops claiming jobs and waits for running ones to finish. If ctx
+// ends first, running jobs are cancelled and released back to the queue
+// without using up an attempt, and ctx's error is returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	stop, abort := p.stop, p.abort
+	p.mu.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+	stop()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		abort()
+		return nil
+	case <-ctx.Done():
+		abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work(claimCtx, runCtx context.Context, workerID string, kinds []string) {
+	defer p.wg.Done()
+
+	if len(kinds) == 0 {
+		return
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-claimCtx.Done():
+			return
+		case <-timer.C:
+		}
+
+		// Claim on runCtx so a shutdown mid-query cannot leave a job locked
+		// by a worker that never sees it.
+		claimed, err := p.store.ClaimJobs(runCtx, store.ClaimJobsParams{
+			Worker:  &workerID,
+			Kinds:   kinds,
+			MaxJobs: 1,
+		})
+		if err != nil {
+			slog.Warn("failed to claim jobs", "worker", workerID, "error", err)
+		}
+
+		for _, job := range claimed {
+			p.run(runCtx, job)
+		}
+
+		if len(claimed) > 0 {
+			// More work may be waiting; poll again straight away.
+			timer.Reset(0)
+		} else {
+			timer.Reset(p.config.Interval)
+		}
+	}
+}
+
+// run executes one claimed job and records the outcome.
+func (p *Pool) run(ctx context.Context, job store.Job) {
+	ctx, span := telemetry.Tracer().Start(ctx, "job "+job.Kind, trace.WithAttributes(
+		attribute.String("job.kind", job.Kind),
+		attribute.Int64("job.id", job.ID),
+		attribute.Int("job.attempt", int(job.Attempts)),
+	))
+	defer span.End()
+
+	started := time.Now()
+	err := p.execute(ctx, job)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+
+	logger := slog.With("job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts, "duration", time.Since(started))
+
+	switch {
+	case err == nil:
+		err = p.store.CompleteJob(finishCtx, job.ID)
+	case ctx.Err() != nil:
+		// Shutdown gave up waiting; another instance will pick the job up.
+		logger.Warn("job interrupted by shutdown", "error", err)
+		err = p.store.ReleaseJob(finishCtx, job.ID)
+	case IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		logger.Error("job failed permanently", "error", err)
+		err = p.store.FailJob(finishCtx, store.FailJobParams{LastError: err.Error(), ID: job.ID})
+	default:
+		delay := Backoff(job.Attempts)
+		logger.Warn("job failed, will retry", "error", err, "retry_in", delay)
+		err = p.store.ScheduleJobRetry(finishCtx, store.ScheduleJobRetryParams{
+			RunAt:     pgtype.Timestamptz{Time: time.Now().Add(delay), Valid: true},
+			LastError: err.Error(),
+			ID:        job.ID,
+		})
+	}
+
+	if err != nil {
+		logger.Error("failed to record job outcome", "error", err)
+	}
+}
+
+// execute calls the job's handler within the attempt timeout, turning panics
+// into errors so one bad job cannot take the worker down.
+func (p *Pool) execute(ctx context.Context, job store.Job) (err error) {
+	handle, ok := p.registry.handler(job.Kind)
+	if !ok {
+		return Permanent(fmt.Errorf("no handler registered for %q", job.Kind))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+
+	return handle(ctx, job)
+}
+
+// rescue returns jobs whose worker died mid-attempt to the queue.
+func (p *Pool) rescue(ctx context.Context) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(rescueGrace)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cutoff := pgtype.Timestamptz{Time: time.Now().Add(-p.config.Timeout - rescueGrace), Valid: true}
+			rescued, err := p.store.RescueStaleJobs(ctx, cutoff)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Warn("failed to rescue stale jobs", "error", err)
+				}
+				continue
+			}
+			if rescued > 0 {
+				slog.Warn("rescued jobs from unresponsive workers", "jobs", rescued)
+			}
+		}
+	}
+}
@@ -1,15 +1,19 @@
 package middleware
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/dunamismax/go-web-server/internal/telemetry"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/argon2"
 )
@@ -40,6 +44,13 @@ var DefaultArgon2Params = Argon2Params{
 	KeyLength:   32,        // 32 bytes key
 }
 
+// Session describes one of a user's sessions. It never includes the token.
+type Session struct {
+	// SignedInAt is zero for sessions created before it was recorded.
+	SignedInAt time.Time
+	ExpiresAt  time.Time
+}
+
 // SessionAuthService provides session-based authentication
 type SessionAuthService struct {
 	sessionManager *scs.SessionManager
@@ -48,14 +59,23 @@ type SessionAuthService struct {
 
 // NewSessionAuthService creates a new session-based auth service
 func NewSessionAuthService(sessionManager *scs.SessionManager) *SessionAuthService {
+	return NewSessionAuthServiceWithParams(sessionManager, DefaultArgon2Params)
+}
+
+// NewSessionAuthServiceWithParams creates a session-based auth service with
+// custom Argon2id parameters
+func NewSessionAuthServiceWithParams(sessionManager *scs.SessionManager, params Argon2Params) *SessionAuthService {
 	return &SessionAuthService{
 		sessionManager: sessionManager,
-		argon2Params:   DefaultArgon2Params,
+		argon2Params:   params,
 	}
 }
 
 // HashPasswordArgon2 hashes a password using Argon2id
-func (s *SessionAuthService) HashPasswordArgon2(password string) (string, error) {
+func (s *SessionAuthService) HashPasswordArgon2(ctx context.Context, password string) (string, error) {
+	_, span := telemetry.Tracer().Start(ctx, "argon2id.hash")
+	defer span.End()
+
 	// Generate random salt
 	salt, err := generateRandomBytes(s.argon2Params.SaltLength)
 	if err != nil {
@@ -75,7 +95,10 @@ func (s *SessionAuthService) HashPasswordArgon2(password string) (string, error)
 }
 
 // VerifyPasswordArgon2 verifies a password against an Argon2id hash
-func (s *SessionAuthService) VerifyPasswordArgon2(password, encoded string) (bool, error) {
+func (s *SessionAuthService) VerifyPasswordArgon2(ctx context.Context, password, encoded string) (bool, error) {
+	_, span := telemetry.Tracer().Start(ctx, "argon2id.verify")
+	defer span.End()
+
 	// Parse the encoded hash
 	params, salt, hash, err := decodeArgon2Hash(encoded)
 	if err != nil {
@@ -105,17 +128,117 @@ func (s *SessionAuthService) LoginUser(c echo.Context, user User) error {
 	s.sessionManager.Put(ctx, "user_email", user.Email)
 	s.sessionManager.Put(ctx, "user_name", user.Name)
 	s.sessionManager.Put(ctx, "user_is_active", user.IsActive)
+	s.sessionManager.Put(ctx, "signed_in_at", time.Now().Unix())
 	s.sessionManager.Put(ctx, "authenticated", true)
 
 	return nil
 }
 
+// RefreshUser updates the identity cached in the current session, after the
+// signed-in user changes their own details.
+func (s *SessionAuthService) RefreshUser(c echo.Context, user User) {
+	ctx := c.Request().Context()
+
+	s.sessionManager.Put(ctx, "user_email", user.Email)
+	s.sessionManager.Put(ctx, "user_name", user.Name)
+	s.sessionManager.Put(ctx, "user_is_active", user.IsActive)
+}
+
+// RenewSession gives the current session a new token, keeping its data, so
+// a token captured before a password change stops working.
+func (s *SessionAuthService) RenewSession(c echo.Context) error {
+	return s.sessionManager.RenewToken(c.Request().Context())
+}
+
+// DestroyOtherSessions signs the user out everywhere except the current
+// session, and returns how many sessions were deleted. Like
+// DestroyUserSessions, it needs a session store that supports iteration.
+func (s *SessionAuthService) DestroyOtherSessions(c echo.Context, userID int64) (int, error) {
+	ctx := c.Request().Context()
+	current := s.sessionManager.Token(ctx)
+
+	destroyed := 0
+	err := s.sessionManager.Iterate(ctx, func(sessionCtx context.Context) error {
+		if s.sessionManager.GetInt64(sessionCtx, "user_id") != userID || s.sessionManager.Token(sessionCtx) == current {
+			return nil
+		}
+
+		if err := s.sessionManager.Destroy(sessionCtx); err != nil {
+			return err
+		}
+		destroyed++
+
+		return nil
+	})
+
+	return destroyed, err
+}
+
 // LogoutUser destroys the user session
 func (s *SessionAuthService) LogoutUser(c echo.Context) error {
 	ctx := c.Request().Context()
 	return s.sessionManager.Destroy(ctx)
 }
 
+// DestroyUserSessions signs users out everywhere by deleting each of their
+// sessions, and returns how many were deleted. The session store must support
+// iteration, as the PostgreSQL and in-memory stores do.
+func (s *SessionAuthService) DestroyUserSessions(ctx context.Context, userIDs ...int64) (int, error) {
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+
+	targets := make(map[int64]struct{}, len(userIDs))
+	for _, id := range userIDs {
+		targets[id] = struct{}{}
+	}
+
+	destroyed := 0
+	err := s.sessionManager.Iterate(ctx, func(sessionCtx context.Context) error {
+		if _, ok := targets[s.sessionManager.GetInt64(sessionCtx, "user_id")]; !ok {
+			return nil
+		}
+
+		if err := s.sessionManager.Destroy(sessionCtx); err != nil {
+			return err
+		}
+		destroyed++
+
+		return nil
+	})
+
+	return destroyed, err
+}
+
+// UserSessions returns the user's unexpired sessions, soonest to expire
+// first. Like DestroyUserSessions, it needs a session store that supports
+// iteration.
+func (s *SessionAuthService) UserSessions(ctx context.Context, userID int64) ([]Session, error) {
+	var sessions []Session
+	err := s.sessionManager.Iterate(ctx, func(sessionCtx context.Context) error {
+		if s.sessionManager.GetInt64(sessionCtx, "user_id") != userID {
+			return nil
+		}
+
+		session := Session{ExpiresAt: s.sessionManager.Deadline(sessionCtx)}
+		if signedIn := s.sessionManager.GetInt64(sessionCtx, "signed_in_at"); signedIn > 0 {
+			session.SignedInAt = time.Unix(signedIn, 0)
+		}
+		sessions = append(sessions, session)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(sessions, func(a, b Session) int {
+		return a.ExpiresAt.Compare(b.ExpiresAt)
+	})
+
+	return sessions, nil
+}
+
 // GetCurrentUser retrieves the current authenticated user from session
 func (s *SessionAuthService) GetCurrentUser(c echo.Context) (*User, bool) {
 	ctx := c.Request().Context()
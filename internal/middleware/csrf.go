@@ -42,6 +42,9 @@ type CSRFConfig struct {
 	ContextKey string
 	// ErrorHandler defines a function which is executed for an invalid CSRF token
 	ErrorHandler CSRFErrorHandler
+	// Skipper defines a function to skip CSRF checks, e.g. for endpoints that
+	// browsers post to without page involvement such as CSP reports
+	Skipper func(echo.Context) bool
 }
 
 // CSRFErrorHandler defines a function which is executed for an invalid CSRF token.
@@ -122,6 +125,10 @@ func CSRFWithConfig(config CSRFConfig) echo.MiddlewareFunc {
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			if config.Skipper != nil && config.Skipper(c) {
+				return next(c)
+			}
+
 			// Skip CSRF for safe methods
 			method := c.Request().Method
 			if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
@@ -104,3 +104,25 @@ func TestCSRFUnsafeRequestRotatesTokenAndReturnsHeader(t *testing.T) {
 		t.Fatalf("X-CSRF-Token = %q, want rotated token", got)
 	}
 }
+
+func TestCSRFSkipperBypassesValidation(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultCSRFConfig
+	config.Skipper = func(c echo.Context) bool {
+		return c.Request().URL.Path == "/csp-report"
+	}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader("{}"))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := CSRFWithConfig(config)(func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	if err := handler(c); err != nil {
+		t.Fatalf("handler() error = %v, want skipped CSRF validation", err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ErrorType represents different categories of errors.
@@ -47,6 +48,7 @@ type AppError struct {
 	Details   any       `json:"details,omitempty"`
 	Internal  error     `json:"-"` // Internal error (not exposed to client)
 	RequestID string    `json:"request_id,omitempty"`
+	TraceID   string    `json:"trace_id,omitempty"`
 	Timestamp string    `json:"timestamp,omitempty"`
 	Path      string    `json:"path,omitempty"`
 	Method    string    `json:"method,omitempty"`
@@ -83,6 +85,7 @@ func NewAppErrorWithDetails(errorType ErrorType, code int, message string, detai
 func (e *AppError) WithContext(c echo.Context) *AppError {
 	if c != nil {
 		e.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+		e.TraceID = traceID(c)
 		e.Path = c.Request().URL.Path
 		e.Method = c.Request().Method
 	}
@@ -121,9 +124,20 @@ type ErrorResponse struct {
 	Path      string    `json:"path,omitempty"`
 	Method    string    `json:"method,omitempty"`
 	RequestID string    `json:"request_id,omitempty"`
+	TraceID   string    `json:"trace_id,omitempty"`
 	Timestamp string    `json:"timestamp"`
 }
 
+// traceID returns the ID of the trace the request belongs to, if any.
+func traceID(c echo.Context) string {
+	spanContext := trace.SpanContextFromContext(c.Request().Context())
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+
+	return spanContext.TraceID().String()
+}
+
 // ErrorHandler is a custom Echo error handler with enhanced error tracking.
 func ErrorHandler(err error, c echo.Context) {
 	var (
@@ -149,7 +163,7 @@ func ErrorHandler(err error, c echo.Context) {
 
 		// Log internal error if present
 		if appErr.Internal != nil {
-			slog.Error("application error",
+			slog.ErrorContext(c.Request().Context(), "application error",
 				"type", appErr.Type,
 				"error", appErr.Internal,
 				"code", code,
@@ -172,7 +186,7 @@ func ErrorHandler(err error, c echo.Context) {
 
 		details = echoErr.Internal
 
-		slog.Warn("HTTP error",
+		slog.WarnContext(c.Request().Context(), "HTTP error",
 			"error", err,
 			"code", code,
 			"message", message,
@@ -181,7 +195,7 @@ func ErrorHandler(err error, c echo.Context) {
 			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
 	} else {
 		// Generic error
-		slog.Error("unhandled error",
+		slog.ErrorContext(c.Request().Context(), "unhandled error",
 			"error", err,
 			"path", c.Request().URL.Path,
 			"method", c.Request().Method,
@@ -213,6 +227,7 @@ func ErrorHandler(err error, c echo.Context) {
 		Path:      c.Request().URL.Path,
 		Method:    c.Request().Method,
 		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+		TraceID:   traceID(c),
 		Timestamp: timestamp,
 	}
 
@@ -226,7 +241,7 @@ func ErrorHandler(err error, c echo.Context) {
 
 	// Send JSON error response
 	if err := c.JSON(code, errorResp); err != nil {
-		slog.Error("failed to send error response", "error", err)
+		slog.ErrorContext(c.Request().Context(), "failed to send error response", "error", err)
 	}
 }
 
@@ -246,7 +261,7 @@ func RecoveryMiddleware() echo.MiddlewareFunc {
 						err = errors.New("unknown panic")
 					}
 
-					slog.Error("panic recovered",
+					slog.ErrorContext(c.Request().Context(), "panic recovered",
 						"error", err,
 						"panic", r,
 						"path", c.Request().URL.Path,
@@ -318,18 +333,3 @@ func TimeoutErrorHandler() echo.MiddlewareFunc {
 		}
 	}
 }
-
-// SecurityHeadersMiddleware adds additional security headers not covered by Echo's secure middleware.
-func SecurityHeadersMiddleware() echo.MiddlewareFunc {
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			// Additional security headers not covered by Echo's SecureMiddleware
-			c.Response().Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
-			c.Response().Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
-			c.Response().Header().Set("Cross-Origin-Opener-Policy", "same-origin")
-			c.Response().Header().Set("Cross-Origin-Embedder-Policy", "require-corp")
-
-			return next(c)
-		}
-	}
-}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// duplicateKeyError reports a JSON object key that appears twice.
+type duplicateKeyError struct {
+	key string
+}
+
+func (e *duplicateKeyError) Error() string {
+	return fmt.Sprintf("json: duplicate key %q", e.key)
+}
+
+// isJSON reports whether the request body is JSON.
+func isJSON(req *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+	return err == nil && mediaType == echo.MIMEApplicationJSON
+}
+
+// normalizeJSONBody replaces a JSON body with one whose strings have been
+// through SanitizeString. Keys, numbers and the order of fields are kept as
+// sent.
+func normalizeJSONBody(req *http.Request, config SanitizeConfig) error {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	if err := req.Body.Close(); err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if body, err = normalizeJSON(body, config); err != nil {
+			return err
+		}
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Set(echo.HeaderContentLength, strconv.Itoa(len(body)))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	return nil
+}
+
+func normalizeJSON(data []byte, config SanitizeConfig) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var out bytes.Buffer
+	if err := normalizeJSONValue(dec, &out, config, ""); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("json: unexpected data after the top-level value")
+	}
+
+	return out.Bytes(), nil
+}
+
+// normalizeJSONValue copies the next value from dec to out. field is the
+// object key the value belongs to, so RawFields also covers arrays and
+// objects under a raw key.
+func normalizeJSONValue(dec *json.Decoder, out *bytes.Buffer, config SanitizeConfig, field string) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '[' {
+			out.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					out.WriteByte(',')
+				}
+				if err := normalizeJSONValue(dec, out, config, field); err != nil {
+					return err
+				}
+			}
+			out.WriteByte(']')
+		} else {
+			out.WriteByte('{')
+			seen := make(map[string]bool)
+			for i := 0; dec.More(); i++ {
+				token, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key := token.(string)
+				if config.RejectDuplicateKeys && seen[key] {
+					return &duplicateKeyError{key: key}
+				}
+				seen[key] = true
+
+				if i > 0 {
+					out.WriteByte(',')
+				}
+				writeJSON(out, key)
+				out.WriteByte(':')
+				if err := normalizeJSONValue(dec, out, config, key); err != nil {
+					return err
+				}
+			}
+			out.WriteByte('}')
+		}
+		// The closing delimiter.
+		_, err = dec.Token()
+		return err
+
+	case string:
+		if !slices.Contains(config.RawFields, field) {
+			value = SanitizeString(value, config)
+		}
+		writeJSON(out, value)
+
+	case json.Number:
+		out.WriteString(value.String())
+
+	default: // bool or nil
+		writeJSON(out, value)
+	}
+
+	return nil
+}
+
+func writeJSON(out *bytes.Buffer, value any) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	// Strings, booleans and null always encode.
+	_ = enc.Encode(value)
+	// Encode ends with a newline.
+	out.Truncate(out.Len() - 1)
+}
+
+// Binder binds requests like echo.DefaultBinder, except that JSON fields
+// the target struct does not have are refused when the Sanitize middleware
+// runs with RejectUnknownFields. Install it with e.Binder = &Binder{}.
+type Binder struct {
+	echo.DefaultBinder
+}
+
+// Bind implements echo.Binder.
+func (b *Binder) Bind(i any, c echo.Context) error {
+	req := c.Request()
+	strict, _ := c.Get(rejectUnknownFieldsKey).(bool)
+	if !strict || req.ContentLength == 0 || !isJSON(req) {
+		return b.DefaultBinder.Bind(i, c)
+	}
+
+	if err := b.BindPathParams(c, i); err != nil {
+		return err
+	}
+	if req.Method == http.MethodGet || req.Method == http.MethodDelete || req.Method == http.MethodHead {
+		if err := b.BindQueryParams(c, i); err != nil {
+			return err
+		}
+	}
+
+	dec := json.NewDecoder(req.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(i); err != nil {
+		// encoding/json has no error type for this, only the message.
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			if unquoted, err := strconv.Unquote(field); err == nil {
+				field = unquoted
+			}
+			return NewAppErrorWithDetails(
+				ErrorTypeValidation,
+				http.StatusBadRequest,
+				"Invalid JSON payload",
+				ValidationErrors{{Field: field, Message: "is not a known field"}},
+			).WithContext(c).WithInternal(err)
+		}
+
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+
+	return nil
+}
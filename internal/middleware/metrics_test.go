@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHTTPMetricsRecordsRoutePatternAndFinalStatus(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	metrics, err := NewHTTPMetrics(registry)
+	if err != nil {
+		t.Fatalf("NewHTTPMetrics() error = %v", err)
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.Use(metrics.Middleware())
+	e.GET("/users/:id", func(c echo.Context) error {
+		if c.Param("id") == "missing" {
+			return ErrNotFound.WithContext(c)
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/missing"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(metrics.requests.WithLabelValues(http.MethodGet, "/users/:id", "200")); got != 2 {
+		t.Fatalf("200 requests = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.requests.WithLabelValues(http.MethodGet, "/users/:id", "404")); got != 1 {
+		t.Fatalf("404 requests = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.inFlight); got != 0 {
+		t.Fatalf("in-flight requests = %v, want 0", got)
+	}
+
+	if _, err := NewHTTPMetrics(registry); err == nil {
+		t.Fatal("expected duplicate registration to fail")
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequireClientCert rejects requests under pathPrefixes unless the TLS
+// handshake presented a client certificate that verified against the
+// configured client CAs. Requests to other paths pass through unchanged.
+func RequireClientCert(pathPrefixes []string) echo.MiddlewareFunc {
+	prefixes := make([]string, 0, len(pathPrefixes))
+	for _, prefix := range pathPrefixes {
+		if prefix = strings.TrimRight(strings.TrimSpace(prefix), "/"); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			path := c.Request().URL.Path
+			protected := false
+			for _, prefix := range prefixes {
+				if path == prefix || strings.HasPrefix(path, prefix+"/") {
+					protected = true
+					break
+				}
+			}
+
+			if !protected {
+				return next(c)
+			}
+
+			state := c.Request().TLS
+			if state == nil || len(state.VerifiedChains) == 0 {
+				slog.WarnContext(c.Request().Context(), "client certificate required",
+					"path", path,
+					"remote_ip", c.RealIP(),
+					"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+				return NewAppError(
+					ErrorTypeAuthorization,
+					http.StatusForbidden,
+					"A verified client certificate is required",
+				).WithContext(c)
+			}
+
+			return next(c)
+		}
+	}
+}
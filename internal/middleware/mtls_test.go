@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequireClientCert(t *testing.T) {
+	t.Parallel()
+
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+
+	tests := []struct {
+		name    string
+		path    string
+		state   *tls.ConnectionState
+		wantErr bool
+	}{
+		{name: "public path without certificate", path: "/users", state: &tls.ConnectionState{}},
+		{name: "admin path without TLS", path: "/admin", wantErr: true},
+		{name: "admin subtree without certificate", path: "/admin/metrics", state: &tls.ConnectionState{}, wantErr: true},
+		{name: "admin path with verified certificate", path: "/admin/metrics", state: verified},
+		{name: "sibling path is not protected", path: "/administrators", state: &tls.ConnectionState{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.TLS = tt.state
+			c := e.NewContext(req, httptest.NewRecorder())
+
+			err := RequireClientCert([]string{"/admin/"})(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})(c)
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("handler() error = %v", err)
+				}
+				return
+			}
+
+			var appErr *AppError
+			if !errors.As(err, &appErr) || appErr.Code != http.StatusForbidden {
+				t.Fatalf("handler() error = %v, want 403 AppError", err)
+			}
+		})
+	}
+}
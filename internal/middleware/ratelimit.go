@@ -0,0 +1,414 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Rate limit key strategies.
+const (
+	RateLimitKeyIP    = "ip"
+	RateLimitKeyUser  = "user"
+	RateLimitKeyToken = "token"
+)
+
+// DefaultRateLimitPolicy is the policy name used for routes without an explicit assignment.
+const DefaultRateLimitPolicy = "default"
+
+// RateLimitPolicy describes a token bucket: Rate tokens are replenished every
+// Window, up to Burst tokens, and each request consumes one token.
+type RateLimitPolicy struct {
+	Name   string
+	Rate   int
+	Burst  int
+	Window time.Duration
+	KeyBy  string
+}
+
+// capacity returns the bucket size, defaulting to the replenish rate.
+func (p RateLimitPolicy) capacity() float64 {
+	if p.Burst > 0 {
+		return float64(p.Burst)
+	}
+
+	return float64(p.Rate)
+}
+
+// refillPerSecond returns how many tokens are added to the bucket per second.
+func (p RateLimitPolicy) refillPerSecond() float64 {
+	return float64(p.Rate) / p.Window.Seconds()
+}
+
+// ttl returns how long an idle bucket must be kept before it is full again.
+func (p RateLimitPolicy) ttl() time.Duration {
+	seconds := p.capacity() / p.refillPerSecond()
+	return time.Duration(math.Ceil(seconds)) * time.Second
+}
+
+func (p RateLimitPolicy) validate() error {
+	if p.Rate <= 0 {
+		return fmt.Errorf("rate limit policy %q: rate must be positive", p.Name)
+	}
+	if p.Burst < 0 {
+		return fmt.Errorf("rate limit policy %q: burst must not be negative", p.Name)
+	}
+	if p.Window <= 0 {
+		return fmt.Errorf("rate limit policy %q: window must be positive", p.Name)
+	}
+
+	switch p.KeyBy {
+	case RateLimitKeyIP, RateLimitKeyUser, RateLimitKeyToken:
+		return nil
+	default:
+		return fmt.Errorf("rate limit policy %q: unknown key_by %q", p.Name, p.KeyBy)
+	}
+}
+
+// RateLimitResult reports the state of a bucket after a request consumed from it.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining float64
+}
+
+// RateLimitStore consumes tokens from named buckets.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error)
+}
+
+// MemoryRateLimitStore is a per-process token bucket store.
+type MemoryRateLimitStore struct {
+	mu          sync.Mutex
+	buckets     map[string]*memoryBucket
+	now         func() time.Time
+	lastCleanup time.Time
+}
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	expiresAt time.Time
+}
+
+// memoryCleanupInterval controls how often idle buckets are evicted.
+const memoryCleanupInterval = time.Minute
+
+// NewMemoryRateLimitStore creates an in-memory token bucket store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*memoryBucket),
+		now:     time.Now,
+	}
+}
+
+// Take consumes one token from the bucket identified by key.
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastCleanup) >= memoryCleanupInterval {
+		for bucketKey, bucket := range s.buckets {
+			if now.After(bucket.expiresAt) {
+				delete(s.buckets, bucketKey)
+			}
+		}
+		s.lastCleanup = now
+	}
+
+	capacity := policy.capacity()
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: capacity, updatedAt: now}
+		s.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	bucket.tokens = math.Min(capacity, bucket.tokens+elapsed*policy.refillPerSecond())
+	bucket.updatedAt = now
+	bucket.expiresAt = now.Add(policy.ttl())
+
+	if bucket.tokens < 1 {
+		return RateLimitResult{Allowed: false, Remaining: bucket.tokens}, nil
+	}
+
+	bucket.tokens--
+
+	return RateLimitResult{Allowed: true, Remaining: bucket.tokens}, nil
+}
+
+// RateLimitTakeFunc consumes one token from a shared bucket and returns the
+// tokens left plus whether the request was admitted.
+type RateLimitTakeFunc func(ctx context.Context, key string, capacity, refillPerSecond float64, ttl time.Duration) (float64, bool, error)
+
+// SharedRateLimitStore adapts a shared backend (for example PostgreSQL) so
+// that every replica draws from the same buckets.
+type SharedRateLimitStore struct {
+	take RateLimitTakeFunc
+}
+
+// NewSharedRateLimitStore creates a store backed by the given take function.
+func NewSharedRateLimitStore(take RateLimitTakeFunc) *SharedRateLimitStore {
+	return &SharedRateLimitStore{take: take}
+}
+
+// Take consumes one token from the shared bucket identified by key.
+func (s *SharedRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	remaining, allowed, err := s.take(ctx, key, policy.capacity(), policy.refillPerSecond(), policy.ttl())
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return RateLimitResult{Allowed: allowed, Remaining: remaining}, nil
+}
+
+// RateLimitConfig defines the configuration for the rate limiter.
+type RateLimitConfig struct {
+	// Store holds the token buckets. Defaults to a MemoryRateLimitStore.
+	Store RateLimitStore
+	// Policies maps policy names to their definitions. A "default" policy is required.
+	Policies map[string]RateLimitPolicy
+	// Routes maps path prefixes (route groups) to policy names.
+	Routes map[string]string
+	// ExemptPaths are never limited. A trailing "/*" matches a whole subtree.
+	ExemptPaths []string
+	// TrustedNetworks are CIDRs or IPs whose requests are never limited.
+	TrustedNetworks []string
+	// UserIdentifier resolves the authenticated user for "user" policies.
+	UserIdentifier func(echo.Context) (string, bool)
+}
+
+// RateLimiter applies token bucket policies per route group.
+type RateLimiter struct {
+	store          RateLimitStore
+	policies       map[string]RateLimitPolicy
+	routes         []rateLimitRoute
+	exemptPaths    []string
+	trusted        []*net.IPNet
+	userIdentifier func(echo.Context) (string, bool)
+}
+
+type rateLimitRoute struct {
+	prefix string
+	policy string
+}
+
+// NewRateLimiter validates the configuration and builds a rate limiter.
+func NewRateLimiter(config RateLimitConfig) (*RateLimiter, error) {
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore()
+	}
+
+	if _, ok := config.Policies[DefaultRateLimitPolicy]; !ok {
+		return nil, fmt.Errorf("rate limit policy %q is required", DefaultRateLimitPolicy)
+	}
+
+	policies := make(map[string]RateLimitPolicy, len(config.Policies))
+	for name, policy := range config.Policies {
+		policy.Name = name
+		if policy.KeyBy == "" {
+			policy.KeyBy = RateLimitKeyIP
+		}
+		if err := policy.validate(); err != nil {
+			return nil, err
+		}
+		policies[name] = policy
+	}
+
+	routes := make([]rateLimitRoute, 0, len(config.Routes))
+	for prefix, name := range config.Routes {
+		if _, ok := policies[name]; !ok {
+			return nil, fmt.Errorf("route %q references unknown rate limit policy %q", prefix, name)
+		}
+		routes = append(routes, rateLimitRoute{prefix: strings.TrimSuffix(prefix, "/"), policy: name})
+	}
+
+	// Longest prefix wins so nested groups can override their parents.
+	sort.Slice(routes, func(i, j int) bool {
+		return len(routes[i].prefix) > len(routes[j].prefix)
+	})
+
+	trusted, err := parseNetworks(config.TrustedNetworks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RateLimiter{
+		store:          config.Store,
+		policies:       policies,
+		routes:         routes,
+		exemptPaths:    config.ExemptPaths,
+		trusted:        trusted,
+		userIdentifier: config.UserIdentifier,
+	}, nil
+}
+
+// Middleware returns middleware that picks the policy assigned to the request's route group.
+func (l *RateLimiter) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			path := c.Request().URL.Path
+			if l.isExempt(c, path) {
+				return next(c)
+			}
+
+			return l.limit(c, l.policies[l.policyFor(path)], next)
+		}
+	}
+}
+
+func (l *RateLimiter) limit(c echo.Context, policy RateLimitPolicy, next echo.HandlerFunc) error {
+	key := policy.Name + ":" + l.identifier(c, policy)
+
+	result, err := l.store.Take(c.Request().Context(), key, policy)
+	if err != nil {
+		// Fail open: an unavailable backend must not take the site down.
+		slog.WarnContext(c.Request().Context(), "rate limit store unavailable",
+			"policy", policy.Name,
+			"error", err,
+			"request_id", c.Response().Header().Get(echo.HeaderXRequestID))
+
+		return next(c)
+	}
+
+	setRateLimitHeaders(c, policy, result)
+
+	if !result.Allowed {
+		retryAfter := math.Ceil((1 - result.Remaining) / policy.refillPerSecond())
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Max(retryAfter, 1))))
+
+		return NewAppError(
+			ErrorTypeRateLimit,
+			http.StatusTooManyRequests,
+			"Rate limit exceeded",
+		).WithContext(c)
+	}
+
+	return next(c)
+}
+
+func setRateLimitHeaders(c echo.Context, policy RateLimitPolicy, result RateLimitResult) {
+	capacity := policy.capacity()
+	remaining := math.Max(math.Floor(result.Remaining), 0)
+	reset := math.Ceil((capacity - result.Remaining) / policy.refillPerSecond())
+
+	header := c.Response().Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(int(capacity)))
+	header.Set("RateLimit-Remaining", strconv.Itoa(int(remaining)))
+	header.Set("RateLimit-Reset", strconv.Itoa(int(math.Max(reset, 0))))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d;name=%q",
+		policy.Rate, int(math.Ceil(policy.Window.Seconds())), int(capacity), policy.Name))
+}
+
+func (l *RateLimiter) policyFor(path string) string {
+	for _, route := range l.routes {
+		if route.prefix == "" || path == route.prefix || strings.HasPrefix(path, route.prefix+"/") {
+			return route.policy
+		}
+	}
+
+	return DefaultRateLimitPolicy
+}
+
+func (l *RateLimiter) isExempt(c echo.Context, path string) bool {
+	for _, exempt := range l.exemptPaths {
+		if prefix, ok := strings.CutSuffix(exempt, "/*"); ok {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if path == exempt {
+			return true
+		}
+	}
+
+	if len(l.trusted) == 0 {
+		return false
+	}
+
+	ip := net.ParseIP(c.RealIP())
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range l.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// identifier returns the bucket identity for the request, falling back to the
+// client IP when the policy's preferred identity is unavailable.
+func (l *RateLimiter) identifier(c echo.Context, policy RateLimitPolicy) string {
+	switch policy.KeyBy {
+	case RateLimitKeyUser:
+		if l.userIdentifier != nil {
+			if id, ok := l.userIdentifier(c); ok {
+				return "user:" + id
+			}
+		}
+	case RateLimitKeyToken:
+		if token := requestToken(c.Request()); token != "" {
+			sum := sha256.Sum256([]byte(token))
+			return "token:" + hex.EncodeToString(sum[:])
+		}
+	}
+
+	return "ip:" + c.RealIP()
+}
+
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get(echo.HeaderAuthorization); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	return r.Header.Get("X-API-Key")
+}
+
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if _, network, err := net.ParseCIDR(value); err == nil {
+			networks = append(networks, network)
+			continue
+		}
+
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, errors.New("invalid trusted network " + strconv.Quote(value))
+		}
+
+		maskBits := 32
+		if ip.To4() == nil {
+			maskBits = 128
+		}
+
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(maskBits, maskBits)})
+	}
+
+	return networks, nil
+}
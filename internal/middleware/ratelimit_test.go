@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMemoryRateLimitStoreRefillsOverTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+
+	policy := RateLimitPolicy{Name: "test", Rate: 1, Burst: 2, Window: time.Second, KeyBy: RateLimitKeyIP}
+
+	for i := range 2 {
+		result, err := store.Take(context.Background(), "k", policy)
+		if err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("request %d was denied within burst", i+1)
+		}
+	}
+
+	result, _ := store.Take(context.Background(), "k", policy)
+	if result.Allowed {
+		t.Fatal("expected request beyond burst to be denied")
+	}
+
+	now = now.Add(time.Second)
+
+	result, _ = store.Take(context.Background(), "k", policy)
+	if !result.Allowed {
+		t.Fatal("expected request to be admitted after refill")
+	}
+}
+
+func newTestRateLimiter(t *testing.T, config RateLimitConfig) *RateLimiter {
+	t.Helper()
+
+	if config.Policies == nil {
+		config.Policies = map[string]RateLimitPolicy{
+			DefaultRateLimitPolicy: {Rate: 1, Burst: 1, Window: time.Minute},
+			"auth":                 {Rate: 1, Burst: 2, Window: time.Minute},
+		}
+	}
+
+	limiter, err := NewRateLimiter(config)
+	if err != nil {
+		t.Fatalf("NewRateLimiter() error = %v", err)
+	}
+
+	return limiter
+}
+
+func serveRateLimited(limiter *RateLimiter, path, remoteAddr string) (*httptest.ResponseRecorder, error) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := limiter.Middleware()(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})(c)
+
+	return rec, err
+}
+
+func TestRateLimiterRejectsWithHeaders(t *testing.T) {
+	t.Parallel()
+
+	limiter := newTestRateLimiter(t, RateLimitConfig{})
+
+	rec, err := serveRateLimited(limiter, "/", "192.0.2.1:1234")
+	if err != nil {
+		t.Fatalf("first request error = %v", err)
+	}
+	if got := rec.Header().Get("RateLimit-Limit"); got != "1" {
+		t.Fatalf("RateLimit-Limit = %q, want %q", got, "1")
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Fatalf("RateLimit-Remaining = %q, want %q", got, "0")
+	}
+
+	rec, err = serveRateLimited(limiter, "/", "192.0.2.1:1234")
+
+	var appErr *AppError
+	if !errors.As(err, &appErr) || appErr.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request error = %v, want 429 AppError", err)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Fatalf("Retry-After = %q, want %q", got, "60")
+	}
+}
+
+func TestRateLimiterUsesRouteGroupPolicy(t *testing.T) {
+	t.Parallel()
+
+	limiter := newTestRateLimiter(t, RateLimitConfig{
+		Routes: map[string]string{"/auth": "auth"},
+	})
+
+	rec, err := serveRateLimited(limiter, "/auth/login", "192.0.2.1:1234")
+	if err != nil {
+		t.Fatalf("request error = %v", err)
+	}
+	if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Fatalf("RateLimit-Limit = %q, want auth policy burst %q", got, "2")
+	}
+
+	// A sibling path sharing the prefix text must not match the group.
+	rec, err = serveRateLimited(limiter, "/authors", "192.0.2.1:1234")
+	if err != nil {
+		t.Fatalf("request error = %v", err)
+	}
+	if got := rec.Header().Get("RateLimit-Limit"); got != "1" {
+		t.Fatalf("RateLimit-Limit = %q, want default policy burst %q", got, "1")
+	}
+}
+
+func TestRateLimiterSkipsExemptPathsAndTrustedNetworks(t *testing.T) {
+	t.Parallel()
+
+	limiter := newTestRateLimiter(t, RateLimitConfig{
+		ExemptPaths:     []string{"/health"},
+		TrustedNetworks: []string{"10.0.0.0/8"},
+	})
+
+	for range 3 {
+		if _, err := serveRateLimited(limiter, "/health", "192.0.2.1:1234"); err != nil {
+			t.Fatalf("exempt path was limited: %v", err)
+		}
+		if _, err := serveRateLimited(limiter, "/", "10.1.2.3:1234"); err != nil {
+			t.Fatalf("trusted network was limited: %v", err)
+		}
+	}
+}
+
+func TestNewRateLimiterRejectsUnknownPolicyReference(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewRateLimiter(RateLimitConfig{
+		Policies: map[string]RateLimitPolicy{
+			DefaultRateLimitPolicy: {Rate: 1, Window: time.Second},
+		},
+		Routes: map[string]string{"/api": "missing"},
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown policy reference")
+	}
+}
@@ -1,12 +1,20 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
+	"unicode"
 
+	"github.com/dunamismax/go-web-server/internal/sanitize"
 	"github.com/labstack/echo/v4"
+	"golang.org/x/text/unicode/norm"
 )
 
 const defaultMultipartMemory = 32 << 20
@@ -17,40 +25,145 @@ type SanitizeConfig struct {
 	TrimSpace bool
 	// StripNullBytes removes NUL bytes from input values.
 	StripNullBytes bool
+	// StripControlChars removes control characters other than tab, line
+	// feed and carriage return, which can hide text or break logs and
+	// exports.
+	StripControlChars bool
+	// NormalizeUnicode converts input to Unicode Normalization Form C, so
+	// text that looks the same is stored the same whichever way it was
+	// typed.
+	NormalizeUnicode bool
 	// CustomSanitizers allows additional caller-provided normalization.
 	CustomSanitizers []func(string) string
+	// RawFields are query, form and JSON fields passed on exactly as sent,
+	// so passwords are hashed and checked as the user typed them. Hashes made
+	// when form passwords were still trimmed are matched by the handlers'
+	// fallback.
+	RawFields []string
+	// MaxBodyBytes is the largest request body accepted; larger bodies get
+	// a 413. Zero means no limit.
+	MaxBodyBytes int64
+	// BodyLimits overrides MaxBodyBytes for path prefixes, such as upload
+	// routes. The longest matching prefix wins.
+	BodyLimits map[string]int64
+	// RejectDuplicateKeys refuses JSON objects that repeat a key. Parsers
+	// disagree about which value wins, so a proxy and the app could see
+	// different requests.
+	RejectDuplicateKeys bool
+	// RejectUnknownFields makes Binder refuse JSON fields the request
+	// struct does not have, so typos in API clients fail loudly.
+	RejectUnknownFields bool
 }
 
 // DefaultSanitizeConfig is the default request normalization config.
 var DefaultSanitizeConfig = SanitizeConfig{
-	TrimSpace:      true,
-	StripNullBytes: true,
+	TrimSpace:         true,
+	StripNullBytes:    true,
+	StripControlChars: true,
+	NormalizeUnicode:  true,
+	RawFields:         []string{"password", "confirm_password", "current_password"},
+	MaxBodyBytes:      1 << 20,
 }
 
+// rejectUnknownFieldsKey marks requests Binder checks strictly.
+const rejectUnknownFieldsKey = "sanitize_reject_unknown_fields"
+
 // Sanitize returns request normalization middleware.
 func Sanitize() echo.MiddlewareFunc {
 	return SanitizeWithConfig(DefaultSanitizeConfig)
 }
 
 // SanitizeWithConfig returns request normalization middleware with config.
+// Query strings, form and multipart values, and JSON bodies are normalized
+// with SanitizeString; bodies over the route's limit are refused with a 413.
 func SanitizeWithConfig(config SanitizeConfig) echo.MiddlewareFunc {
+	limits := make([]bodyLimit, 0, len(config.BodyLimits))
+	for prefix, limit := range config.BodyLimits {
+		limits = append(limits, bodyLimit{prefix: strings.TrimSuffix(prefix, "/"), limit: limit})
+	}
+	// Longest prefix wins so a route can override its group.
+	sort.Slice(limits, func(i, j int) bool {
+		return len(limits[i].prefix) > len(limits[j].prefix)
+	})
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			req := c.Request().Clone(c.Request().Context())
+
+			limit := bodyLimitFor(limits, req.URL.Path, config.MaxBodyBytes)
+			if limit > 0 {
+				if req.ContentLength > limit {
+					return bodyTooLargeError(c, limit, nil)
+				}
+				req.Body = http.MaxBytesReader(c.Response(), req.Body, limit)
+			}
+
 			if err := normalizeRequest(req, config); err != nil {
-				return NewAppError(
-					ErrorTypeValidation,
-					http.StatusBadRequest,
-					"Invalid form payload",
-				).WithContext(c).WithInternal(err)
+				return sanitizeError(c, req, limit, err)
 			}
 			c.SetRequest(req)
+			if config.RejectUnknownFields {
+				c.Set(rejectUnknownFieldsKey, true)
+			}
 
 			return next(c)
 		}
 	}
 }
 
+type bodyLimit struct {
+	prefix string
+	limit  int64
+}
+
+func bodyLimitFor(limits []bodyLimit, path string, fallback int64) int64 {
+	for _, l := range limits {
+		if path == l.prefix || strings.HasPrefix(path, l.prefix+"/") {
+			return l.limit
+		}
+	}
+
+	return fallback
+}
+
+func sanitizeError(c echo.Context, req *http.Request, limit int64, err error) error {
+	var (
+		maxBytesErr  *http.MaxBytesError
+		duplicateErr *duplicateKeyError
+	)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return bodyTooLargeError(c, limit, err)
+	case errors.As(err, &duplicateErr):
+		return NewAppErrorWithDetails(
+			ErrorTypeValidation,
+			http.StatusBadRequest,
+			"Invalid JSON payload",
+			ValidationErrors{{Field: duplicateErr.key, Message: "appears more than once"}},
+		).WithContext(c).WithInternal(err)
+	case isJSON(req):
+		return NewAppError(
+			ErrorTypeValidation,
+			http.StatusBadRequest,
+			"Invalid JSON payload",
+		).WithContext(c).WithInternal(err)
+	default:
+		return NewAppError(
+			ErrorTypeValidation,
+			http.StatusBadRequest,
+			"Invalid form payload",
+		).WithContext(c).WithInternal(err)
+	}
+}
+
+func bodyTooLargeError(c echo.Context, limit int64, err error) error {
+	return NewAppError(
+		ErrorTypeValidation,
+		http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("Request body is larger than the %d byte limit", limit),
+	).WithContext(c).WithInternal(err)
+}
+
 func normalizeRequest(req *http.Request, config SanitizeConfig) error {
 	if req == nil {
 		return nil
@@ -61,13 +174,24 @@ func normalizeRequest(req *http.Request, config SanitizeConfig) error {
 	req.URL.RawQuery = queryValues.Encode()
 
 	contentType := req.Header.Get(echo.HeaderContentType)
-	if strings.HasPrefix(contentType, echo.MIMEMultipartForm) {
+	switch {
+	case strings.HasPrefix(contentType, echo.MIMEMultipartForm):
 		if err := req.ParseMultipartForm(defaultMultipartMemory); err != nil {
 			return err
 		}
 		normalizeMultipartForm(req.MultipartForm, config)
-	} else if err := req.ParseForm(); err != nil {
-		return err
+	case isJSON(req):
+		if err := normalizeJSONBody(req, config); err != nil {
+			return err
+		}
+		// Parses only the query string for JSON requests.
+		if err := req.ParseForm(); err != nil {
+			return err
+		}
+	default:
+		if err := req.ParseForm(); err != nil {
+			return err
+		}
 	}
 
 	normalizeValues(req.Form, config)
@@ -90,6 +214,9 @@ func normalizeValues(values url.Values, config SanitizeConfig) {
 	}
 
 	for key, items := range values {
+		if slices.Contains(config.RawFields, key) {
+			continue
+		}
 		for idx, item := range items {
 			values[key][idx] = SanitizeString(item, config)
 		}
@@ -106,6 +233,17 @@ func SanitizeString(input string, config SanitizeConfig) string {
 	if config.StripNullBytes {
 		result = strings.ReplaceAll(result, "\x00", "")
 	}
+	if config.StripControlChars {
+		result = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+				return -1
+			}
+			return r
+		}, result)
+	}
+	if config.NormalizeUnicode {
+		result = norm.NFC.String(result)
+	}
 	if config.TrimSpace {
 		result = strings.TrimSpace(result)
 	}
@@ -116,6 +254,61 @@ func SanitizeString(input string, config SanitizeConfig) string {
 	return result
 }
 
+// SanitizeStruct applies HTML policies to the string fields of the struct v
+// points to, as named by their sanitize tags:
+//
+//	Name string `form:"name" sanitize:"strict"`
+//	Bio  string `form:"bio" sanitize:"ugc"`
+//
+// Handlers call it after binding a request. Policies are listed in the
+// sanitize package; an unknown name is an error.
+func SanitizeStruct(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("sanitize: want a pointer to a struct, got %T", v)
+	}
+
+	return sanitizeFields(rv.Elem())
+}
+
+func sanitizeFields(rv reflect.Value) error {
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		field, value := rt.Field(i), rv.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && value.Kind() == reflect.Struct {
+			if err := sanitizeFields(value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name, ok := field.Tag.Lookup("sanitize")
+		if !ok {
+			continue
+		}
+		policy, ok := sanitize.Lookup(name)
+		if !ok {
+			return fmt.Errorf("sanitize: unknown policy %q on %s.%s", name, rt.Name(), field.Name)
+		}
+
+		switch {
+		case value.Kind() == reflect.String:
+			value.SetString(policy.Sanitize(value.String()))
+		case value.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.String:
+			if !value.IsNil() {
+				value.Elem().SetString(policy.Sanitize(value.Elem().String()))
+			}
+		default:
+			return fmt.Errorf("sanitize: %s.%s is not a string", rt.Name(), field.Name)
+		}
+	}
+
+	return nil
+}
+
 // Common normalization presets.
 var (
 	// FormSanitizeConfig normalizes form inputs.
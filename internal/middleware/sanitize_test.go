@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -26,7 +28,8 @@ func TestSanitizeMiddlewareNormalizesFormAndQueryValues(t *testing.T) {
 
 	e := echo.New()
 	form := url.Values{
-		"name": {"  O'Reilly\x00  "},
+		"name":     {"  O'Reilly\x00  "},
+		"password": {" pass word "},
 	}
 
 	req := httptest.NewRequest(http.MethodPost, "/users?tab=%20active%20", strings.NewReader(form.Encode()))
@@ -44,6 +47,10 @@ func TestSanitizeMiddlewareNormalizesFormAndQueryValues(t *testing.T) {
 			t.Fatalf("c.QueryParam(tab) = %q, want %q", got, want)
 		}
 
+		if got, want := c.FormValue("password"), " pass word "; got != want {
+			t.Fatalf("c.FormValue(password) = %q, want it as sent", got)
+		}
+
 		return c.NoContent(http.StatusNoContent)
 	})
 
@@ -51,3 +58,195 @@ func TestSanitizeMiddlewareNormalizesFormAndQueryValues(t *testing.T) {
 		t.Fatalf("handler returned error: %v", err)
 	}
 }
+
+func TestSanitizeStringNormalizesUnicode(t *testing.T) {
+	t.Parallel()
+
+	// "José" typed with a combining accent, with a bell character in it.
+	got := SanitizeString("Jose\u0301\tDoe\a\n", DefaultSanitizeConfig)
+	if want := "Jos\u00e9\tDoe"; got != want {
+		t.Fatalf("SanitizeString() = %q, want %q", got, want)
+	}
+
+	config := DefaultSanitizeConfig
+	config.NormalizeUnicode = false
+	config.StripControlChars = false
+	if got, want := SanitizeString("e\u0301\a", config), "e\u0301\a"; got != want {
+		t.Fatalf("SanitizeString() with normalization off = %q, want %q", got, want)
+	}
+}
+
+func TestSanitizeStruct(t *testing.T) {
+	t.Parallel()
+
+	bio := `<a href="javascript:alert(1)">me</a> <script>alert(1)</script>**hi**`
+	req := struct {
+		Name     string  `sanitize:"strict"`
+		Bio      *string `sanitize:"ugc"`
+		Password string
+	}{
+		Name:     "<b>Ada</b>",
+		Bio:      &bio,
+		Password: "<b>secret</b>",
+	}
+
+	if err := SanitizeStruct(&req); err != nil {
+		t.Fatalf("SanitizeStruct() error = %v", err)
+	}
+	if req.Name != "Ada" || *req.Bio != "me **hi**" || req.Password != "<b>secret</b>" {
+		t.Fatalf("SanitizeStruct() = %+v (bio %q), want tags applied to tagged fields only", req, *req.Bio)
+	}
+
+	bad := struct {
+		Bio string `sanitize:"rich"`
+	}{}
+	if err := SanitizeStruct(&bad); err == nil {
+		t.Fatal("SanitizeStruct() with an unknown policy returned nil error")
+	}
+}
+
+// serveSanitized runs req through SanitizeWithConfig and Binder into
+// handler, returning the status the error handler would send.
+func serveSanitized(t *testing.T, config SanitizeConfig, req *http.Request, handler echo.HandlerFunc) int {
+	t.Helper()
+
+	e := echo.New()
+	e.Binder = &Binder{}
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	err := SanitizeWithConfig(config)(handler)(c)
+	if err == nil {
+		return rec.Code
+	}
+
+	var appErr *AppError
+	if !errors.As(err, &appErr) {
+		t.Fatalf("handler returned %T %v, want an *AppError", err, err)
+	}
+
+	return appErr.Code
+}
+
+func jsonRequest(target, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, "application/json; charset=utf-8")
+
+	return req
+}
+
+func TestSanitizeNormalizesJSONBodies(t *testing.T) {
+	t.Parallel()
+
+	type signup struct {
+		Name     string   `json:"name"`
+		Password string   `json:"password"`
+		Tags     []string `json:"tags"`
+		Age      int64    `json:"age"`
+		Profile  struct {
+			Bio string `json:"bio"`
+		} `json:"profile"`
+	}
+
+	body := `{"name":"  Ada\u0000 ","password":"  s3cret ","tags":[" a ","b\u0007"],"age":12345678901,"profile":{"bio":" <b>hi</b> "}}`
+	code := serveSanitized(t, DefaultSanitizeConfig, jsonRequest("/users", body), func(c echo.Context) error {
+		var got signup
+		if err := c.Bind(&got); err != nil {
+			t.Fatalf("Bind() error = %v", err)
+		}
+
+		if got.Name != "Ada" || got.Password != "  s3cret " || got.Age != 12345678901 || got.Profile.Bio != "<b>hi</b>" {
+			t.Fatalf("bound %+v, want trimmed strings, an untouched password and the exact number", got)
+		}
+		if len(got.Tags) != 2 || got.Tags[0] != "a" || got.Tags[1] != "b" {
+			t.Fatalf("bound tags %q, want normalized array items", got.Tags)
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+	if code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestSanitizeRejectsInvalidJSON(t *testing.T) {
+	t.Parallel()
+
+	strict := DefaultSanitizeConfig
+	strict.RejectDuplicateKeys = true
+	strict.RejectUnknownFields = true
+
+	bind := func(c echo.Context) error {
+		var req struct {
+			Email string `json:"email"`
+		}
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	tests := []struct {
+		name   string
+		config SanitizeConfig
+		body   string
+		want   int
+	}{
+		{name: "malformed", config: DefaultSanitizeConfig, body: `{"email":`, want: http.StatusBadRequest},
+		{name: "trailing data", config: DefaultSanitizeConfig, body: `{"email":"a"} {}`, want: http.StatusBadRequest},
+		{name: "duplicate key allowed", config: DefaultSanitizeConfig, body: `{"email":"a","email":"b"}`, want: http.StatusNoContent},
+		{name: "duplicate key rejected", config: strict, body: `{"email":"a","email":"b"}`, want: http.StatusBadRequest},
+		{name: "nested duplicate rejected", config: strict, body: `{"email":"a","x":[{"k":1,"k":2}]}`, want: http.StatusBadRequest},
+		{name: "unknown field allowed", config: DefaultSanitizeConfig, body: `{"email":"a","admin":true}`, want: http.StatusNoContent},
+		{name: "unknown field rejected", config: strict, body: `{"email":"a","admin":true}`, want: http.StatusBadRequest},
+		{name: "strict and valid", config: strict, body: `{"email":"a"}`, want: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		if got := serveSanitized(t, tt.config, jsonRequest("/api/users", tt.body), bind); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeLimitsBodySize(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultSanitizeConfig
+	config.MaxBodyBytes = 64
+	config.BodyLimits = map[string]int64{"/uploads": 1024, "/uploads/small/": 16}
+
+	ok := func(c echo.Context) error {
+		var req map[string]any
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+	big := `{"bio":"` + strings.Repeat("x", 100) + `"}`
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{name: "under the global limit", req: jsonRequest("/users", `{"bio":"x"}`), want: http.StatusNoContent},
+		{name: "over the global limit", req: jsonRequest("/users", big), want: http.StatusRequestEntityTooLarge},
+		{name: "route allows more", req: jsonRequest("/uploads/avatar", big), want: http.StatusNoContent},
+		{name: "longest prefix wins", req: jsonRequest("/uploads/small", big), want: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		if got := serveSanitized(t, config, tt.req, ok); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// Without a Content-Length the limit is enforced while reading.
+	form := httptest.NewRequest(http.MethodPost, "/users", io.MultiReader(strings.NewReader("bio="+strings.Repeat("x", 100))))
+	form.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	form.ContentLength = -1
+	if got := serveSanitized(t, config, form, ok); got != http.StatusRequestEntityTooLarge {
+		t.Fatalf("chunked form status = %d, want %d", got, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a-h/templ"
+	"github.com/labstack/echo/v4"
+)
+
+func serveSecurityHeaders(config SecurityHeadersConfig, req *http.Request) (*httptest.ResponseRecorder, string, string) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	var handlerNonce, templNonce string
+	_ = SecurityHeadersWithConfig(config)(func(c echo.Context) error {
+		handlerNonce = GetCSPNonce(c)
+		templNonce = templ.GetNonce(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	})(c)
+
+	return rec, handlerNonce, templNonce
+}
+
+func TestSecurityHeadersInjectsPerRequestNonce(t *testing.T) {
+	t.Parallel()
+
+	rec, handlerNonce, templNonce := serveSecurityHeaders(DefaultSecurityHeadersConfig, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if handlerNonce == "" || handlerNonce != templNonce {
+		t.Fatalf("GetCSPNonce() = %q, templ.GetNonce() = %q, want equal non-empty nonces", handlerNonce, templNonce)
+	}
+
+	policy := rec.Header().Get(echo.HeaderContentSecurityPolicy)
+	if !strings.Contains(policy, "script-src 'self' 'nonce-"+handlerNonce+"'") {
+		t.Fatalf("Content-Security-Policy = %q, want request nonce in script-src", policy)
+	}
+	if strings.Contains(policy, CSPNoncePlaceholder) || strings.Contains(policy, "'unsafe-eval'") {
+		t.Fatalf("Content-Security-Policy = %q, want placeholder replaced and no unsafe-eval", policy)
+	}
+	if !strings.HasSuffix(policy, "; report-uri /csp-report; report-to csp-endpoint") {
+		t.Fatalf("Content-Security-Policy = %q, want report directives", policy)
+	}
+	if got := rec.Header().Get("Reporting-Endpoints"); got != `csp-endpoint="/csp-report"` {
+		t.Fatalf("Reporting-Endpoints = %q", got)
+	}
+
+	_, nextNonce, _ := serveSecurityHeaders(DefaultSecurityHeadersConfig, httptest.NewRequest(http.MethodGet, "/", nil))
+	if nextNonce == handlerNonce {
+		t.Fatal("expected a fresh nonce for every request")
+	}
+}
+
+func TestSecurityHeadersReportOnly(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultSecurityHeadersConfig
+	config.CSPReportOnly = true
+
+	rec, _, _ := serveSecurityHeaders(config, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := rec.Header().Get(echo.HeaderContentSecurityPolicy); got != "" {
+		t.Fatalf("Content-Security-Policy = %q, want empty in report-only mode", got)
+	}
+	if got := rec.Header().Get(echo.HeaderContentSecurityPolicyReportOnly); got == "" {
+		t.Fatal("expected Content-Security-Policy-Report-Only header")
+	}
+}
+
+func TestSecurityHeadersHSTSOnlyOverHTTPS(t *testing.T) {
+	t.Parallel()
+
+	config := DefaultSecurityHeadersConfig
+	config.HSTSIncludeSubdomains = true
+	config.HSTSPreload = true
+
+	rec, _, _ := serveSecurityHeaders(config, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get(echo.HeaderStrictTransportSecurity); got != "" {
+		t.Fatalf("Strict-Transport-Security = %q over plain HTTP, want empty", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderXForwardedProto, "https")
+
+	rec, _, _ = serveSecurityHeaders(config, req)
+	if got := rec.Header().Get(echo.HeaderStrictTransportSecurity); got != "" {
+		t.Fatalf("Strict-Transport-Security = %q for X-Forwarded-Proto from an untrusted peer, want empty", got)
+	}
+
+	config.TrustedProxies = []string{"192.0.2.0/24"}
+
+	rec, _, _ = serveSecurityHeaders(config, req)
+	if got, want := rec.Header().Get(echo.HeaderStrictTransportSecurity), "max-age=31536000; includeSubDomains; preload"; got != want {
+		t.Fatalf("Strict-Transport-Security = %q, want %q", got, want)
+	}
+}
+
+func TestSecurityHeadersOmitsEmptyValues(t *testing.T) {
+	t.Parallel()
+
+	rec, nonce, _ := serveSecurityHeaders(SecurityHeadersConfig{XFrameOptions: "SAMEORIGIN"}, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := rec.Header().Get(echo.HeaderXFrameOptions); got != "SAMEORIGIN" {
+		t.Fatalf("X-Frame-Options = %q, want %q", got, "SAMEORIGIN")
+	}
+	for _, name := range []string{echo.HeaderContentSecurityPolicy, echo.HeaderXXSSProtection, "Cross-Origin-Embedder-Policy"} {
+		if got := rec.Header().Get(name); got != "" {
+			t.Fatalf("%s = %q, want omitted", name, got)
+		}
+	}
+	if nonce != "" {
+		t.Fatalf("GetCSPNonce() = %q, want empty without a policy", nonce)
+	}
+}
@@ -8,12 +8,28 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// RequestTimeoutConfig configures RequestTimeout.
+type RequestTimeoutConfig struct {
+	// Timeout bounds each request; zero or less disables the deadline.
+	Timeout time.Duration
+	// Skipper exempts requests from the deadline, e.g. long-lived event
+	// streams.
+	Skipper func(echo.Context) bool
+}
+
 // RequestTimeout adds a deadline to the request context without swapping the response writer.
 // This avoids the incompatibilities in Echo's Timeout middleware for templ-rendered responses.
 func RequestTimeout(timeout time.Duration) echo.MiddlewareFunc {
+	return RequestTimeoutWithConfig(RequestTimeoutConfig{Timeout: timeout})
+}
+
+// RequestTimeoutWithConfig returns RequestTimeout middleware with config.
+func RequestTimeoutWithConfig(config RequestTimeoutConfig) echo.MiddlewareFunc {
+	timeout := config.Timeout
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			if timeout <= 0 {
+			if timeout <= 0 || (config.Skipper != nil && config.Skipper(c)) {
 				return next(c)
 			}
 
@@ -66,3 +66,30 @@ func TestRequestTimeoutReturnsAppTimeoutErrorAfterDeadline(t *testing.T) {
 		t.Fatalf("internal error = %v, want deadline exceeded", appErr.Internal)
 	}
 }
+
+func TestRequestTimeoutSkipsExemptRequests(t *testing.T) {
+	t.Parallel()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mw := RequestTimeoutWithConfig(RequestTimeoutConfig{
+		Timeout: 10 * time.Millisecond,
+		Skipper: func(c echo.Context) bool { return c.Request().URL.Path == "/events" },
+	})
+
+	var hasDeadline bool
+	err := mw(func(c echo.Context) error {
+		_, hasDeadline = c.Request().Context().Deadline()
+		return c.NoContent(http.StatusOK)
+	})(c)
+	if err != nil {
+		t.Fatalf("RequestTimeoutWithConfig() error = %v", err)
+	}
+
+	if hasDeadline {
+		t.Fatal("expected skipped request context to have no deadline")
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// Not parallel: the tracer provider and propagator are process globals.
+func TestTracingContinuesTraceparentAndRecordsRoute(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	e.Use(Tracing())
+	e.GET("/users/:id", func(c echo.Context) error {
+		return ErrInternalServer.WithContext(c)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	var body ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	if body.TraceID != traceID {
+		t.Fatalf("response trace_id = %q, want %q", body.TraceID, traceID)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("ended spans = %d, want 1", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "GET /users/:id" {
+		t.Fatalf("span name = %q, want %q", span.Name(), "GET /users/:id")
+	}
+	if got := span.SpanContext().TraceID().String(); got != traceID {
+		t.Fatalf("span trace ID = %q, want %q", got, traceID)
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Fatalf("parent span ID = %q, want %q", got, "00f067aa0ba902b7")
+	}
+	if span.Status().Code != codes.Error {
+		t.Fatalf("span status = %v, want %v", span.Status().Code, codes.Error)
+	}
+
+	attributes := map[string]any{}
+	for _, attr := range span.Attributes() {
+		attributes[string(attr.Key)] = attr.Value.AsInterface()
+	}
+	if got := attributes[string(semconv.HTTPRouteKey)]; got != "/users/:id" {
+		t.Fatalf("http.route = %v, want %q", got, "/users/:id")
+	}
+	if got := attributes[string(semconv.HTTPResponseStatusCodeKey)]; got != int64(http.StatusInternalServerError) {
+		t.Fatalf("http.response.status_code = %v, want %d", got, http.StatusInternalServerError)
+	}
+}
@@ -167,7 +167,7 @@ func ValidateAndBind(target interface{}) echo.MiddlewareFunc {
 
 			// Bind request data
 			if err := c.Bind(instance); err != nil {
-				slog.Error("failed to bind request data", "error", err)
+				slog.ErrorContext(c.Request().Context(), "failed to bind request data", "error", err)
 
 				return NewAppError(
 					ErrorTypeValidation,
@@ -176,10 +176,19 @@ func ValidateAndBind(target interface{}) echo.MiddlewareFunc {
 				).WithContext(c).WithInternal(err)
 			}
 
+			// Apply the HTML policies named in sanitize tags
+			if err := SanitizeStruct(instance); err != nil {
+				return NewAppError(
+					ErrorTypeSanitization,
+					http.StatusInternalServerError,
+					"Failed to sanitize request",
+				).WithContext(c).WithInternal(err)
+			}
+
 			// Run custom validation if implemented
 			if customValidator, ok := instance.(CustomValidator); ok {
 				if err := customValidator.Validate(); err != nil {
-					slog.Warn("custom validation failed", "error", err)
+					slog.WarnContext(c.Request().Context(), "custom validation failed", "error", err)
 
 					return NewAppError(
 						ErrorTypeValidation,
@@ -191,7 +200,7 @@ func ValidateAndBind(target interface{}) echo.MiddlewareFunc {
 
 			// Run struct validation using go-playground/validator
 			if validationErrors := ValidateStruct(instance); len(validationErrors) > 0 {
-				slog.Warn("struct validation failed", "errors", validationErrors)
+				slog.WarnContext(c.Request().Context(), "struct validation failed", "errors", validationErrors)
 
 				return NewAppErrorWithDetails(
 					ErrorTypeValidation,
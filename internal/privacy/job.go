@@ -0,0 +1,199 @@
+package privacy
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/jobs"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Export states, as stored in data_exports.state.
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+)
+
+// maxAttempts is how many times a queued export is tried.
+const maxAttempts = 3
+
+// ExportArgs is the job that builds a requested archive.
+type ExportArgs struct {
+	ExportID int64 `json:"export_id"`
+}
+
+// Kind implements jobs.Args.
+func (ExportArgs) Kind() string { return "privacy.export" }
+
+// QueueExport records an export and queues the job that builds it. Pass the
+// transaction's Querier so the request's audit entry commits with it.
+func QueueExport(ctx context.Context, q store.Querier, userID int64, format Format) (store.DataExport, error) {
+	export, err := q.CreateDataExport(ctx, store.CreateDataExportParams{UserID: userID, Format: string(format)})
+	if err != nil {
+		return store.DataExport{}, fmt.Errorf("create data export: %w", err)
+	}
+
+	if _, err := jobs.Enqueue(ctx, q, ExportArgs{ExportID: export.ID}, jobs.EnqueueOptions{MaxAttempts: maxAttempts}); err != nil {
+		return store.DataExport{}, fmt.Errorf("queue data export: %w", err)
+	}
+
+	return export, nil
+}
+
+// Runner builds queued archives.
+type Runner struct {
+	store    store.TxQuerier
+	sessions Sessions
+	lifetime time.Duration
+}
+
+// NewRunner creates a runner whose archives can be downloaded for
+// cfg.ExportLifetime, or DefaultConfig's when that is zero.
+func NewRunner(s store.TxQuerier, sessions Sessions, cfg Config) *Runner {
+	return &Runner{
+		store:    s,
+		sessions: sessions,
+		lifetime: cmp.Or(cfg.ExportLifetime, DefaultConfig.ExportLifetime),
+	}
+}
+
+// Register adds the export job handler to registry.
+func (r *Runner) Register(registry *jobs.Registry) {
+	jobs.Register(registry, r.runExport)
+}
+
+// runExport builds and stores one archive. The last failed attempt marks the
+// export failed so the user can ask again.
+func (r *Runner) runExport(ctx context.Context, job store.Job, args ExportArgs) error {
+	export, err := r.store.GetDataExport(ctx, args.ExportID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load data export: %w", err)
+	}
+	if export.State != StatePending && export.State != StateRunning {
+		return nil
+	}
+
+	if _, err := r.store.StartDataExport(ctx, export.ID); err != nil {
+		return fmt.Errorf("start data export: %w", err)
+	}
+
+	archive, err := Build(ctx, r.store, r.sessions, export.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return r.fail(ctx, export.ID, "the account no longer exists")
+	}
+	var data []byte
+	if err == nil {
+		data, err = Encode(archive, Format(export.Format))
+	}
+	if err != nil {
+		if job.Attempts >= job.MaxAttempts {
+			if failErr := r.fail(ctx, export.ID, "the archive could not be built"); failErr != nil {
+				slog.WarnContext(ctx, "Failed to record data export failure", "export_id", export.ID, "error", failErr)
+			}
+		}
+		return fmt.Errorf("build data export: %w", err)
+	}
+
+	expiresAt := pgtype.Timestamptz{Time: time.Now().Add(r.lifetime), Valid: true}
+
+	return r.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		if err := q.CreateDataExportFile(ctx, store.CreateDataExportFileParams{ExportID: export.ID, Data: data}); err != nil {
+			return fmt.Errorf("store data export: %w", err)
+		}
+
+		err := q.FinishDataExport(ctx, store.FinishDataExportParams{
+			State:     StateSucceeded,
+			SizeBytes: int64(len(data)),
+			ExpiresAt: expiresAt,
+			ID:        export.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("finish data export: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (r *Runner) fail(ctx context.Context, id int64, reason string) error {
+	err := r.store.FinishDataExport(ctx, store.FinishDataExportParams{
+		State:     StateFailed,
+		LastError: &reason,
+		ID:        id,
+	})
+	if err != nil {
+		return fmt.Errorf("finish data export: %w", err)
+	}
+
+	return nil
+}
+
+// ErasedFunc records an erased account. It runs in the erasure's transaction
+// with the user as they were just before.
+type ErasedFunc func(ctx context.Context, q store.Querier, user store.User) error
+
+// Eraser erases accounts whose cooling-off period has passed.
+type Eraser struct {
+	store    store.TxQuerier
+	sessions Sessions
+	erased   ErasedFunc
+}
+
+// NewEraser creates an eraser that calls erased for each account it erases.
+func NewEraser(s store.TxQuerier, sessions Sessions, erased ErasedFunc) *Eraser {
+	return &Eraser{store: s, sessions: sessions, erased: erased}
+}
+
+// Run erases every account whose deletion is due and returns how many it
+// erased. Each account is erased in its own transaction, so one failure
+// does not hold back the others. Archives and the pending deletion go with
+// the user row; sessions are then deleted from the session store, since
+// they only carry the user ID inside their encoded data.
+func (e *Eraser) Run(ctx context.Context) (int, error) {
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+
+	due, err := e.store.ListDueAccountDeletions(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("list due account deletions: %w", err)
+	}
+
+	var (
+		erased []int64
+		errs   []error
+	)
+	for _, deletion := range due {
+		err := e.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+			user, err := q.EraseUser(ctx, store.EraseUserParams{ID: deletion.UserID, DueAt: now})
+			if err != nil {
+				return err
+			}
+
+			return e.erased(ctx, q, user)
+		})
+		if errors.Is(err, store.ErrNotFound) {
+			// Cancelled since it was listed.
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("erase user %d: %w", deletion.UserID, err))
+			continue
+		}
+		erased = append(erased, deletion.UserID)
+	}
+
+	if _, err := e.sessions.DestroyUserSessions(ctx, erased...); err != nil {
+		errs = append(errs, fmt.Errorf("destroy sessions of %d erased users: %w", len(erased), err))
+	}
+
+	return len(erased), errors.Join(errs...)
+}
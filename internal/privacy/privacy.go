@@ -0,0 +1,236 @@
+// Package privacy lets users take away and remove what the application
+// stores about them. An export builds an archive of a user's profile,
+// sessions and audit events in a background job; a deletion request erases
+// the account once a cooling-off period has passed, taking their sessions,
+// archives and pending deletion with it.
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/store"
+)
+
+// Format is the encoding of a personal data archive.
+type Format string
+
+// Supported formats.
+const (
+	// FormatZIP holds profile.json, sessions.json and audit_events.json.
+	FormatZIP Format = "zip"
+	// FormatJSON is one JSON document with the same sections.
+	FormatJSON Format = "json"
+)
+
+// ContentType returns the media type of archives in f.
+func (f Format) ContentType() string {
+	if f == FormatZIP {
+		return "application/zip"
+	}
+
+	return "application/json"
+}
+
+// Config controls exports and deletions.
+type Config struct {
+	// CoolingOff is how long a deletion request can be cancelled before the
+	// account is erased.
+	CoolingOff time.Duration
+	// ExportLifetime is how long a finished archive can be downloaded.
+	ExportLifetime time.Duration
+}
+
+// DefaultConfig gives users two weeks to change their mind and a week to
+// download their archive.
+var DefaultConfig = Config{
+	CoolingOff:     14 * 24 * time.Hour,
+	ExportLifetime: 7 * 24 * time.Hour,
+}
+
+// Sessions lists and signs out users' sessions.
+// *middleware.SessionAuthService implements it.
+type Sessions interface {
+	UserSessions(ctx context.Context, userID int64) ([]middleware.Session, error)
+	DestroyUserSessions(ctx context.Context, userIDs ...int64) (int, error)
+}
+
+// Archive is everything stored about one user.
+type Archive struct {
+	GeneratedAt time.Time    `json:"generated_at"`
+	Profile     Profile      `json:"profile"`
+	Sessions    []Session    `json:"sessions"`
+	AuditEvents []AuditEvent `json:"audit_events"`
+}
+
+// Profile is the user's account. It never includes the password hash.
+type Profile struct {
+	ID              int64            `json:"id"`
+	Email           string           `json:"email"`
+	Name            string           `json:"name"`
+	Bio             *string          `json:"bio"`
+	AvatarURL       *string          `json:"avatar_url"`
+	IsActive        bool             `json:"is_active"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	AccountDeletion *AccountDeletion `json:"account_deletion,omitempty"`
+}
+
+// AccountDeletion is a pending deletion request.
+type AccountDeletion struct {
+	RequestedAt time.Time `json:"requested_at"`
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+// Session is one signed-in browser. Session tokens are never exported.
+type Session struct {
+	SignedInAt *time.Time `json:"signed_in_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+// AuditEvent is an audit log entry the user performed or that was about
+// them. Before and After are only kept for events about the user, so an
+// archive never holds other users' data.
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Action     string          `json:"action"`
+	ActorID    *int64          `json:"actor_id"`
+	TargetType *string         `json:"target_type"`
+	TargetID   *int64          `json:"target_id"`
+	IP         *string         `json:"ip"`
+	UserAgent  *string         `json:"user_agent"`
+	RequestID  *string         `json:"request_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+}
+
+// Build collects everything stored about a user. It returns store.ErrNotFound
+// once the user has been deleted.
+func Build(ctx context.Context, q store.Querier, sessions Sessions, userID int64) (Archive, error) {
+	user, err := q.GetUser(ctx, userID)
+	if err != nil {
+		return Archive{}, fmt.Errorf("load user: %w", err)
+	}
+
+	archive := Archive{
+		GeneratedAt: time.Now().UTC(),
+		Profile: Profile{
+			ID:        user.ID,
+			Email:     user.Email,
+			Name:      user.Name,
+			Bio:       user.Bio,
+			AvatarURL: user.AvatarUrl,
+			IsActive:  user.IsActive != nil && *user.IsActive,
+			CreatedAt: user.CreatedAt.Time.UTC(),
+			UpdatedAt: user.UpdatedAt.Time.UTC(),
+		},
+		Sessions:    []Session{},
+		AuditEvents: []AuditEvent{},
+	}
+
+	deletion, err := q.GetAccountDeletion(ctx, userID)
+	switch {
+	case err == nil:
+		archive.Profile.AccountDeletion = &AccountDeletion{
+			RequestedAt: deletion.RequestedAt.Time.UTC(),
+			DeleteAfter: deletion.DeleteAfter.Time.UTC(),
+		}
+	case !errors.Is(err, store.ErrNotFound):
+		return Archive{}, fmt.Errorf("load account deletion: %w", err)
+	}
+
+	userSessions, err := sessions.UserSessions(ctx, userID)
+	if err != nil {
+		return Archive{}, fmt.Errorf("list sessions: %w", err)
+	}
+	for _, session := range userSessions {
+		exported := Session{ExpiresAt: session.ExpiresAt.UTC()}
+		if !session.SignedInAt.IsZero() {
+			signedIn := session.SignedInAt.UTC()
+			exported.SignedInAt = &signedIn
+		}
+		archive.Sessions = append(archive.Sessions, exported)
+	}
+
+	events, err := q.ListUserAuditEvents(ctx, userID)
+	if err != nil {
+		return Archive{}, fmt.Errorf("list audit events: %w", err)
+	}
+	for _, event := range events {
+		exported := AuditEvent{
+			ID:         event.ID,
+			OccurredAt: event.OccurredAt.Time.UTC(),
+			Action:     event.Action,
+			ActorID:    event.ActorID,
+			TargetType: event.TargetType,
+			TargetID:   event.TargetID,
+			IP:         event.Ip,
+			UserAgent:  event.UserAgent,
+			RequestID:  event.RequestID,
+		}
+		if aboutUser(event, userID) {
+			exported.Before = event.Before
+			exported.After = event.After
+		}
+		archive.AuditEvents = append(archive.AuditEvents, exported)
+	}
+
+	return archive, nil
+}
+
+// aboutUser reports whether event targeted the user, or targeted nothing,
+// as their sign-ins and exports do.
+func aboutUser(event store.AuditEvent, userID int64) bool {
+	if event.TargetID == nil {
+		return true
+	}
+
+	return event.TargetType != nil && *event.TargetType == "user" && *event.TargetID == userID
+}
+
+// Encode writes archive in format.
+func Encode(archive Archive, format Format) ([]byte, error) {
+	if format == FormatJSON {
+		return json.MarshalIndent(archive, "", "  ")
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, part := range []struct {
+		name  string
+		value any
+	}{
+		{"profile.json", archive.Profile},
+		{"sessions.json", archive.Sessions},
+		{"audit_events.json", archive.AuditEvents},
+	} {
+		data, err := json.MarshalIndent(part.value, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("encode %s: %w", part.name, err)
+		}
+
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     part.name,
+			Method:   zip.Deflate,
+			Modified: archive.GeneratedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,223 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/store/memstore"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// fakeSessions stands in for the session store.
+type fakeSessions struct {
+	sessions  map[int64][]middleware.Session
+	destroyed []int64
+}
+
+func (f *fakeSessions) UserSessions(_ context.Context, userID int64) ([]middleware.Session, error) {
+	return f.sessions[userID], nil
+}
+
+func (f *fakeSessions) DestroyUserSessions(_ context.Context, userIDs ...int64) (int, error) {
+	f.destroyed = append(f.destroyed, userIDs...)
+	return len(userIDs), nil
+}
+
+func createUser(t *testing.T, s store.Querier, email string) store.User {
+	t.Helper()
+
+	user, err := s.CreateUser(context.Background(), store.CreateUserParams{Email: email, Name: "Test User", PasswordHash: "secret-hash"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	return user
+}
+
+func TestRunnerBuildsQueuedArchive(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := memstore.New()
+	ada := createUser(t, s, "ada@example.com")
+	grace := createUser(t, s, "grace@example.com")
+
+	userTarget := "user"
+	for _, params := range []store.CreateAuditEventParams{
+		{ActorID: &ada.ID, Action: "auth.login", TargetType: &userTarget, TargetID: &ada.ID, After: []byte(`{"self":true}`)},
+		{ActorID: &ada.ID, Action: "user.update", TargetType: &userTarget, TargetID: &grace.ID, After: []byte(`{"email":"grace@example.com"}`)},
+		{ActorID: &grace.ID, Action: "auth.login", TargetType: &userTarget, TargetID: &grace.ID},
+	} {
+		if err := s.CreateAuditEvent(ctx, params); err != nil {
+			t.Fatalf("CreateAuditEvent() error = %v", err)
+		}
+	}
+
+	signedIn := time.Now().Add(-time.Hour)
+	sessions := &fakeSessions{sessions: map[int64][]middleware.Session{
+		ada.ID: {{SignedInAt: signedIn, ExpiresAt: signedIn.Add(24 * time.Hour)}},
+	}}
+
+	export, err := QueueExport(ctx, s, ada.ID, FormatZIP)
+	if err != nil {
+		t.Fatalf("QueueExport() error = %v", err)
+	}
+
+	runner := NewRunner(s, sessions, Config{})
+	if err := runner.runExport(ctx, store.Job{}, ExportArgs{ExportID: export.ID}); err != nil {
+		t.Fatalf("runExport() error = %v", err)
+	}
+
+	export, err = s.GetDataExport(ctx, export.ID)
+	if err != nil {
+		t.Fatalf("GetDataExport() error = %v", err)
+	}
+	if export.State != StateSucceeded || export.SizeBytes == 0 || !export.ExpiresAt.Valid {
+		t.Fatalf("export = %+v, want succeeded with a size and expiry", export)
+	}
+	if lifetime := time.Until(export.ExpiresAt.Time); lifetime < DefaultConfig.ExportLifetime-time.Minute {
+		t.Fatalf("export expires in %v, want about %v", lifetime, DefaultConfig.ExportLifetime)
+	}
+
+	data, err := s.GetDataExportFile(ctx, export.ID)
+	if err != nil {
+		t.Fatalf("GetDataExportFile() error = %v", err)
+	}
+	if strings.Contains(string(data), "secret-hash") {
+		t.Fatal("archive contains the password hash")
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	files := make(map[string][]byte)
+	for _, file := range zr.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		files[file.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+
+	var profile Profile
+	if err := json.Unmarshal(files["profile.json"], &profile); err != nil || profile.Email != ada.Email {
+		t.Fatalf("profile.json = %s (%v), want Ada's profile", files["profile.json"], err)
+	}
+
+	var exportedSessions []Session
+	if err := json.Unmarshal(files["sessions.json"], &exportedSessions); err != nil || len(exportedSessions) != 1 || exportedSessions[0].SignedInAt == nil {
+		t.Fatalf("sessions.json = %s (%v), want one session with its sign-in time", files["sessions.json"], err)
+	}
+
+	var events []AuditEvent
+	if err := json.Unmarshal(files["audit_events.json"], &events); err != nil {
+		t.Fatalf("decode audit_events.json: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("len(events) = %d, want Ada's 2", len(events))
+	}
+	if events[0].After == nil {
+		t.Fatal("event about Ada lost its state")
+	}
+	if events[1].After != nil {
+		t.Fatalf("event about Grace kept her state: %s", events[1].After)
+	}
+}
+
+func TestRunnerSkipsFinishedAndMissingExports(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := memstore.New()
+	ada := createUser(t, s, "ada@example.com")
+
+	export, err := QueueExport(ctx, s, ada.ID, FormatJSON)
+	if err != nil {
+		t.Fatalf("QueueExport() error = %v", err)
+	}
+
+	runner := NewRunner(s, &fakeSessions{}, Config{})
+	if err := runner.fail(ctx, export.ID, "the archive could not be built"); err != nil {
+		t.Fatalf("fail() error = %v", err)
+	}
+	if err := runner.runExport(ctx, store.Job{}, ExportArgs{ExportID: export.ID}); err != nil {
+		t.Fatalf("runExport(failed) error = %v", err)
+	}
+
+	export, err = s.GetDataExport(ctx, export.ID)
+	if err != nil {
+		t.Fatalf("GetDataExport() error = %v", err)
+	}
+	if export.State != StateFailed {
+		t.Fatalf("State = %q, want the failed export left alone", export.State)
+	}
+
+	// Deleting the user takes their exports with them.
+	if err := runner.runExport(ctx, store.Job{}, ExportArgs{ExportID: export.ID + 1}); err != nil {
+		t.Fatalf("runExport(missing) error = %v", err)
+	}
+}
+
+func TestEraserErasesOnlyDueAccounts(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := memstore.New()
+	due := createUser(t, s, "due@example.com")
+	waiting := createUser(t, s, "waiting@example.com")
+
+	for user, deleteAfter := range map[int64]time.Time{
+		due.ID:     time.Now().Add(-time.Minute),
+		waiting.ID: time.Now().Add(time.Hour),
+	} {
+		_, err := s.RequestAccountDeletion(ctx, store.RequestAccountDeletionParams{
+			UserID:      user,
+			DeleteAfter: pgtype.Timestamptz{Time: deleteAfter, Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("RequestAccountDeletion() error = %v", err)
+		}
+	}
+	if _, err := QueueExport(ctx, s, due.ID, FormatZIP); err != nil {
+		t.Fatalf("QueueExport() error = %v", err)
+	}
+
+	var recorded []int64
+	sessions := &fakeSessions{}
+	eraser := NewEraser(s, sessions, func(_ context.Context, _ store.Querier, user store.User) error {
+		recorded = append(recorded, user.ID)
+		return nil
+	})
+
+	erased, err := eraser.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if erased != 1 || !slices.Equal(recorded, []int64{due.ID}) || !slices.Equal(sessions.destroyed, []int64{due.ID}) {
+		t.Fatalf("Run() erased %d, recorded %v, destroyed sessions of %v; want only user %d", erased, recorded, sessions.destroyed, due.ID)
+	}
+
+	if _, err := s.GetUser(ctx, due.ID); err == nil {
+		t.Fatal("due user still exists")
+	}
+	if exports, _ := s.ListDataExports(ctx, store.ListDataExportsParams{UserID: due.ID, MaxRows: 10}); len(exports) != 0 {
+		t.Fatalf("erased user kept %d exports", len(exports))
+	}
+	if _, err := s.GetAccountDeletion(ctx, waiting.ID); err != nil {
+		t.Fatalf("waiting deletion is gone: %v", err)
+	}
+	if _, err := s.GetUser(ctx, waiting.ID); err != nil {
+		t.Fatalf("waiting user was erased early: %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package sanitize
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// orderedItem matches the marker of an ordered list item, such as "1. ".
+var orderedItem = regexp.MustCompile(`^\d{1,9}[.)] `)
+
+// Markdown renders the subset of Markdown a bio needs and returns HTML that
+// has been through UGCPolicy:
+//
+//   - paragraphs, separated by blank lines, with single line breaks kept
+//   - "> " quotes, "- " or "* " lists and "1. " numbered lists
+//   - fenced code blocks and `code`
+//   - **strong**, *emphasis* and [links](https://example.com)
+//
+// HTML written in the source is passed to the policy like everything else,
+// so it is held to the same allowlist as the rendered Markdown.
+func Markdown(source string) string {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	lines := strings.Split(strings.TrimSpace(source), "\n")
+
+	var b strings.Builder
+	renderBlocks(&b, lines)
+
+	return ugc.Sanitize(b.String())
+}
+
+// ugc renders Markdown.
+var ugc = UGCPolicy()
+
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		trimmed := strings.TrimSpace(lines[i])
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case strings.HasPrefix(trimmed, "```"):
+			end := i + 1
+			for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), "```") {
+				end++
+			}
+			b.WriteString("<pre><code>")
+			b.WriteString(html.EscapeString(strings.Join(lines[i+1:end], "\n")))
+			b.WriteString("</code></pre>\n")
+			i = end + 1
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quote := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(quote, " "))
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+
+		case listMarker(trimmed) != "":
+			i = renderList(b, lines, i)
+
+		default:
+			var paragraph []string
+			for ; i < len(lines); i++ {
+				trimmed := strings.TrimSpace(lines[i])
+				if trimmed == "" || strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, ">") || listMarker(trimmed) != "" {
+					break
+				}
+				paragraph = append(paragraph, renderInline(trimmed))
+			}
+			b.WriteString("<p>")
+			b.WriteString(strings.Join(paragraph, "<br>\n"))
+			b.WriteString("</p>\n")
+		}
+	}
+}
+
+// renderList renders the list starting at lines[start] and returns the
+// index of the first line after it. Lines that are not items continue the
+// item before them.
+func renderList(b *strings.Builder, lines []string, start int) int {
+	tag := "ul"
+	if orderedItem.MatchString(strings.TrimSpace(lines[start])) {
+		tag = "ol"
+	}
+
+	var items []string
+	i := start
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" {
+			break
+		}
+		marker := listMarker(trimmed)
+		if marker == "" && len(items) > 0 {
+			items[len(items)-1] += " " + trimmed
+			continue
+		}
+		if (tag == "ol") != orderedItem.MatchString(trimmed) {
+			break
+		}
+		items = append(items, strings.TrimPrefix(trimmed, marker))
+	}
+
+	b.WriteString("<" + tag + ">\n")
+	for _, item := range items {
+		b.WriteString("<li>" + renderInline(item) + "</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+
+	return i
+}
+
+// listMarker returns the list item marker line starts with, or "".
+func listMarker(line string) string {
+	for _, marker := range []string{"- ", "* ", "+ "} {
+		if strings.HasPrefix(line, marker) {
+			return marker
+		}
+	}
+
+	return orderedItem.FindString(line)
+}
+
+// renderInline renders code spans, emphasis and links in one line of text.
+func renderInline(text string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		rest := text[i:]
+
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte("\\`*_[]()<>#+-.!", text[i+1]) >= 0:
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				b.WriteString("<code>" + html.EscapeString(rest[1:end+1]) + "</code>")
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if inner, n := delimited(text, i, rest[:2]); n > 0 {
+				b.WriteString("<strong>" + renderInline(inner) + "</strong>")
+				i += n
+				continue
+			}
+
+		case c == '*' || c == '_':
+			if inner, n := delimited(text, i, rest[:1]); n > 0 {
+				b.WriteString("<em>" + renderInline(inner) + "</em>")
+				i += n
+				continue
+			}
+
+		case c == '[':
+			if label, href, n := link(rest); n > 0 {
+				b.WriteString(`<a href="` + html.EscapeString(href) + `">` + renderInline(label) + "</a>")
+				i += n
+				continue
+			}
+		}
+
+		b.WriteByte(c)
+		i++
+	}
+
+	return b.String()
+}
+
+// delimited finds the text between delim at text[start:] and its closing
+// match, returning it and the length of the whole span, or 0 if there is no
+// match. As in CommonMark, the text may not start or end with a space, and
+// underscores inside words do not count, so snake_case stays as written.
+func delimited(text string, start int, delim string) (string, int) {
+	open := start + len(delim)
+	if open >= len(text) || text[open] == ' ' {
+		return "", 0
+	}
+	if delim[0] == '_' && wordBefore(text, start) {
+		return "", 0
+	}
+
+	for end := open + 1; end+len(delim) <= len(text); end++ {
+		if text[end:end+len(delim)] != delim || text[end-1] == ' ' {
+			continue
+		}
+		after := end + len(delim)
+		if delim[0] == '_' && wordAt(text, after) {
+			continue
+		}
+		if len(delim) == 1 && after < len(text) && text[after] == delim[0] {
+			// Part of a strong delimiter; keep looking.
+			end++
+			continue
+		}
+
+		return text[open:end], after - start
+	}
+
+	return "", 0
+}
+
+// link parses "[label](href)" at the start of text and returns its parts
+// and length, or 0 if text does not start with a link.
+func link(text string) (string, string, int) {
+	labelEnd := strings.Index(text, "](")
+	if labelEnd < 1 {
+		return "", "", 0
+	}
+	hrefEnd := strings.IndexByte(text[labelEnd+2:], ')')
+	if hrefEnd < 1 {
+		return "", "", 0
+	}
+
+	href := strings.TrimSpace(text[labelEnd+2 : labelEnd+2+hrefEnd])
+	if strings.ContainsAny(href, " \t") {
+		return "", "", 0
+	}
+
+	return text[1:labelEnd], href, labelEnd + 3 + hrefEnd
+}
+
+// wordBefore reports whether the character before text[i] is part of a word.
+func wordBefore(text string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return isWord(r)
+}
+
+// wordAt reports whether the character at text[i] is part of a word.
+func wordAt(text string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return isWord(r)
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
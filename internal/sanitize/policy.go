@@ -0,0 +1,266 @@
+// Package sanitize cleans user-written HTML with allowlist policies and
+// renders Markdown through them, so text users write can be shown as markup
+// without letting them run script or restyle the page.
+package sanitize
+
+import (
+	"html"
+	"net/url"
+	"slices"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// Policy is an allowlist of elements and attributes. Anything it does not
+// list is removed: disallowed tags are dropped with their text kept, and
+// elements whose content is code or hidden, such as script and style, are
+// dropped with their content.
+type Policy struct {
+	// Elements maps each allowed element to its allowed attributes.
+	Elements map[string][]string
+	// URLAttrs are attributes holding URLs. Their values are kept only if
+	// they are relative or use one of URLSchemes.
+	URLAttrs []string
+	// URLSchemes are the schemes allowed in URLAttrs.
+	URLSchemes []string
+	// LinkRel is set as the rel of every link, replacing any rel written.
+	LinkRel string
+}
+
+// StrictPolicy allows no markup at all. Text is kept as written, so it
+// suits fields shown as plain text, such as names.
+func StrictPolicy() *Policy {
+	return &Policy{}
+}
+
+// UGCPolicy allows the inline formatting, lists, quotes and links that user
+// generated content such as bios needs. Links get rel="nofollow" so spam in
+// a bio earns no search ranking. Images are not allowed, since they would
+// load from other sites.
+func UGCPolicy() *Policy {
+	return &Policy{
+		Elements: map[string][]string{
+			"a":          {"href", "title"},
+			"b":          nil,
+			"blockquote": nil,
+			"br":         nil,
+			"code":       nil,
+			"del":        nil,
+			"em":         nil,
+			"i":          nil,
+			"li":         nil,
+			"ol":         nil,
+			"p":          nil,
+			"pre":        nil,
+			"s":          nil,
+			"strong":     nil,
+			"u":          nil,
+			"ul":         nil,
+		},
+		URLAttrs:   []string{"href"},
+		URLSchemes: []string{"http", "https", "mailto"},
+		LinkRel:    "nofollow",
+	}
+}
+
+// named holds the policies struct tags can refer to.
+var named = map[string]*Policy{
+	"strict": StrictPolicy(),
+	"ugc":    UGCPolicy(),
+}
+
+// Lookup returns the policy with the given name: "strict" or "ugc".
+func Lookup(name string) (*Policy, bool) {
+	p, ok := named[name]
+	return p, ok
+}
+
+// dropContent lists elements whose content is never shown as text.
+var dropContent = map[string]bool{
+	"iframe":    true,
+	"noembed":   true,
+	"noframes":  true,
+	"noscript":  true,
+	"object":    true,
+	"plaintext": true,
+	"script":    true,
+	"style":     true,
+	"template":  true,
+	"textarea":  true,
+	"title":     true,
+	"xmp":       true,
+}
+
+// voidElements have no content and no end tag.
+var voidElements = map[string]bool{
+	"br": true,
+	"hr": true,
+}
+
+// Sanitize returns input with everything the policy does not allow removed.
+// Open elements are closed and stray end tags dropped, so the result can be
+// embedded in a page without affecting markup around it.
+//
+// Text between tags is kept byte for byte, so sanitizing is idempotent and a
+// field that holds Markdown keeps its syntax. That is safe because the
+// tokenizer follows the HTML5 spec: a text token never holds anything a
+// browser would read as markup.
+func (p *Policy) Sanitize(input string) string {
+	if !strings.Contains(input, "<") {
+		return input
+	}
+
+	var (
+		b       strings.Builder
+		open    []string
+		skipped int
+	)
+	z := nethtml.NewTokenizer(strings.NewReader(input))
+
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		// Token unescapes the text in place, so take the raw text first.
+		raw := string(z.Raw())
+		token := z.Token()
+
+		switch tt {
+		case nethtml.TextToken:
+			if skipped == 0 {
+				b.WriteString(raw)
+			}
+
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			if dropContent[token.Data] {
+				if tt == nethtml.StartTagToken {
+					skipped++
+				}
+				continue
+			}
+			if skipped > 0 {
+				continue
+			}
+
+			attrs, ok := p.allowed(token)
+			if !ok {
+				continue
+			}
+			b.WriteString(startTag(token.Data, attrs))
+			if voidElements[token.Data] {
+				continue
+			}
+			if tt == nethtml.SelfClosingTagToken {
+				b.WriteString("</" + token.Data + ">")
+				continue
+			}
+			open = append(open, token.Data)
+
+		case nethtml.EndTagToken:
+			if dropContent[token.Data] {
+				skipped = max(skipped-1, 0)
+				continue
+			}
+			if skipped > 0 {
+				continue
+			}
+
+			// Close elements left open inside this one, as a browser would.
+			i := lastIndex(open, token.Data)
+			if i < 0 {
+				continue
+			}
+			for _, name := range slices.Backward(open[i:]) {
+				b.WriteString("</" + name + ">")
+			}
+			open = open[:i]
+		}
+	}
+
+	for _, name := range slices.Backward(open) {
+		b.WriteString("</" + name + ">")
+	}
+
+	return b.String()
+}
+
+// allowed returns the attributes of token the policy keeps, and whether the
+// element is kept at all. A link whose URL was removed is dropped, leaving
+// its text.
+func (p *Policy) allowed(token nethtml.Token) ([]nethtml.Attribute, bool) {
+	allowedAttrs, ok := p.Elements[token.Data]
+	if !ok {
+		return nil, false
+	}
+
+	var attrs []nethtml.Attribute
+	for _, attr := range token.Attr {
+		if attr.Namespace != "" || !slices.Contains(allowedAttrs, attr.Key) {
+			continue
+		}
+		if slices.Contains(p.URLAttrs, attr.Key) {
+			cleaned, ok := p.cleanURL(attr.Val)
+			if !ok {
+				continue
+			}
+			attr.Val = cleaned
+		}
+		attrs = append(attrs, attr)
+	}
+
+	if token.Data == "a" {
+		if !slices.ContainsFunc(attrs, func(a nethtml.Attribute) bool { return a.Key == "href" }) {
+			return nil, false
+		}
+		if p.LinkRel != "" {
+			attrs = append(attrs, nethtml.Attribute{Key: "rel", Val: p.LinkRel})
+		}
+	}
+
+	return attrs, true
+}
+
+// cleanURL returns raw if it is relative or uses an allowed scheme.
+// Browsers ignore whitespace and control characters inside URLs, so they
+// are removed first rather than letting "java\tscript:" through.
+func (p *Policy) cleanURL(raw string) (string, bool) {
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, raw)
+
+	u, err := url.Parse(cleaned)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme != "" && !slices.Contains(p.URLSchemes, strings.ToLower(u.Scheme)) {
+		return "", false
+	}
+
+	return cleaned, true
+}
+
+func lastIndex(open []string, name string) int {
+	for i := len(open) - 1; i >= 0; i-- {
+		if open[i] == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func startTag(name string, attrs []nethtml.Attribute) string {
+	var b strings.Builder
+	b.WriteString("<" + name)
+	for _, attr := range attrs {
+		b.WriteString(" " + attr.Key + `="` + html.EscapeString(attr.Val) + `"`)
+	}
+	b.WriteString(">")
+
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUGCPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "plain text", input: "Gopher & friends", want: "Gopher & friends"},
+		{name: "markdown kept", input: "> quoted\n- item <3", want: "> quoted\n- item <3"},
+		{name: "allowed formatting", input: "<p><strong>Hi</strong> <em>there</em></p>", want: "<p><strong>Hi</strong> <em>there</em></p>"},
+		{name: "script and content dropped", input: "a<script>alert(1)</script>b", want: "ab"},
+		{name: "style and content dropped", input: "<style>body{display:none}</style>ok", want: "ok"},
+		{name: "unknown tags unwrapped", input: `<div class="x"><span>text</span></div>`, want: "text"},
+		{name: "event handlers dropped", input: `<b onclick="alert(1)" style="color:red">bold</b>`, want: "<b>bold</b>"},
+		{name: "images dropped", input: `<img src="https://tracker.example/p.gif">hi`, want: "hi"},
+		{name: "link gets nofollow", input: `<a href="https://go.dev" rel="me" target="_blank">Go</a>`, want: `<a href="https://go.dev" rel="nofollow">Go</a>`},
+		{name: "relative link kept", input: `<a href="/users">users</a>`, want: `<a href="/users" rel="nofollow">users</a>`},
+		{name: "javascript link unwrapped", input: `<a href="javascript:alert(1)">x</a>`, want: "x"},
+		{name: "obfuscated scheme unwrapped", input: "<a href=\"java\tscript:alert(1)\">x</a>", want: "x"},
+		{name: "entity scheme unwrapped", input: `<a href="&#106;avascript:alert(1)">x</a>`, want: "x"},
+		{name: "data link unwrapped", input: `<a href="data:text/html,hi">x</a>`, want: "x"},
+		{name: "attribute escaped", input: `<a href='https://go.dev/?q="><script>'>x</a>`, want: `<a href="https://go.dev/?q=&#34;&gt;&lt;script&gt;" rel="nofollow">x</a>`},
+		{name: "unclosed elements closed", input: "<ul><li><b>one", want: "<ul><li><b>one</b></li></ul>"},
+		{name: "stray end tags dropped", input: "</p></div>text</b>", want: "text"},
+		{name: "comments dropped", input: "a<!-- <script>alert(1)</script> -->b", want: "ab"},
+	}
+
+	p := UGCPolicy()
+	for _, tt := range tests {
+		got := p.Sanitize(tt.input)
+		if got != tt.want {
+			t.Errorf("%s: Sanitize(%q) = %q, want %q", tt.name, tt.input, got, tt.want)
+		}
+		if again := p.Sanitize(got); again != got {
+			t.Errorf("%s: Sanitize is not idempotent: %q became %q", tt.name, got, again)
+		}
+	}
+}
+
+func TestStrictPolicy(t *testing.T) {
+	t.Parallel()
+
+	p, ok := Lookup("strict")
+	if !ok {
+		t.Fatal(`Lookup("strict") found no policy`)
+	}
+
+	if got, want := p.Sanitize(`<b>Ada</b> <i onmouseover="x">Lovelace</i> <3`), "Ada Lovelace <3"; got != want {
+		t.Fatalf("Sanitize() = %q, want %q", got, want)
+	}
+	if _, ok := Lookup("none"); ok {
+		t.Fatal(`Lookup("none") found a policy`)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "paragraphs and line breaks",
+			source: "Gopher at heart.\nLikes tea.\n\nSecond paragraph.",
+			want:   "<p>Gopher at heart.<br>\nLikes tea.</p>\n<p>Second paragraph.</p>\n",
+		},
+		{
+			name:   "emphasis",
+			source: "**bold**, *italic* and __also bold__ but snake_case_name",
+			want:   "<p><strong>bold</strong>, <em>italic</em> and <strong>also bold</strong> but snake_case_name</p>\n",
+		},
+		{
+			name:   "code",
+			source: "Run `go test <pkg>`\n```\nif a < b {\n}\n```",
+			want:   "<p>Run <code>go test &lt;pkg&gt;</code></p>\n<pre><code>if a &lt; b {\n}</code></pre>\n",
+		},
+		{
+			name:   "lists",
+			source: "- one\n- *two*\n\n1. first\n2. second",
+			want:   "<ul>\n<li>one</li>\n<li><em>two</em></li>\n</ul>\n<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n",
+		},
+		{
+			name:   "quote",
+			source: "> quoted\n> text",
+			want:   "<blockquote>\n<p>quoted<br>\ntext</p>\n</blockquote>\n",
+		},
+		{
+			name:   "link",
+			source: "See [my site](https://example.com/?a=1&b=2).",
+			want:   "<p>See <a href=\"https://example.com/?a=1&amp;b=2\" rel=\"nofollow\">my site</a>.</p>\n",
+		},
+		{
+			name:   "javascript link",
+			source: "[click](javascript:alert(1))",
+			want:   "<p>click)</p>\n",
+		},
+		{
+			name:   "inline html held to the policy",
+			source: "Hi <b>there</b><script>alert(1)</script> <img src=x onerror=alert(1)>",
+			want:   "<p>Hi <b>there</b> </p>\n",
+		},
+		{
+			name:   "escaped characters",
+			source: `\*not emphasis\* and \<b>`,
+			want:   "<p>*not emphasis* and &lt;b></p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := Markdown(tt.source); got != tt.want {
+			t.Errorf("%s: Markdown(%q) = %q, want %q", tt.name, tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownNeverEmitsScript(t *testing.T) {
+	t.Parallel()
+
+	for _, source := range []string{
+		"`</code><script>alert(1)</script>`",
+		"[x](https://a.example/\"><script>alert(1)</script>)",
+		"**<scr**ipt>alert(1)</script>",
+		"<a href=\"https://a.example\" onclick=\"alert(1)\">x</a>",
+		"```\n</pre><script>alert(1)</script>",
+	} {
+		got := strings.ToLower(Markdown(source))
+		if strings.Contains(got, "<script") || strings.Contains(got, "onclick") {
+			t.Errorf("Markdown(%q) = %q, want no script", source, got)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Package scheduler runs maintenance tasks on cron schedules. Every replica
+// runs the same schedules; a PostgreSQL advisory lock and the last tick
+// recorded in scheduled_tasks make sure only one of them runs each tick. The
+// outcome of every run is recorded there for the health and admin pages.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/telemetry"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Run statuses, as stored in scheduled_tasks.last_status.
+const (
+	StatusRunning = "running"
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+)
+
+const (
+	// DefaultTimeout bounds a run when the task sets no timeout.
+	DefaultTimeout = 10 * time.Minute
+	// finishTimeout bounds the query that records a run's outcome.
+	finishTimeout = 10 * time.Second
+)
+
+// Standard five-field expressions plus descriptors such as @hourly.
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Store is what the scheduler needs from the database.
+type Store interface {
+	store.Querier
+	store.Locker
+}
+
+// Task is a named piece of periodic work.
+type Task struct {
+	// Name identifies the task across replicas and restarts.
+	Name string
+	// Schedule is a five-field cron expression or a descriptor such as
+	// @hourly, evaluated in the server's local time zone.
+	Schedule string
+	// Timeout bounds one run; zero means DefaultTimeout.
+	Timeout time.Duration
+	// Run does the work. It must honor ctx.
+	Run func(ctx context.Context) error
+}
+
+type entry struct {
+	task     Task
+	schedule cron.Schedule
+	lockKey  int64
+}
+
+// Scheduler runs registered tasks on their schedules.
+type Scheduler struct {
+	store Store
+
+	mu      sync.Mutex
+	entries []*entry
+	stop    context.CancelFunc
+	abort   context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// New creates a scheduler with no tasks.
+func New(s Store) *Scheduler {
+	return &Scheduler{store: s}
+}
+
+// ParseSchedule validates a schedule expression. @every is rejected: its
+// ticks depend on when each replica started, so replicas could not agree on
+// which tick is which.
+func ParseSchedule(expr string) (cron.Schedule, error) {
+	schedule, err := parser.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("parse schedule %q: %w", expr, err)
+	}
+	if _, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		return nil, fmt.Errorf("parse schedule %q: @every is not supported; use a cron expression", expr)
+	}
+
+	return schedule, nil
+}
+
+// Register adds a task. It must be called before Start.
+func (s *Scheduler) Register(task Task) error {
+	if task.Name == "" || task.Run == nil {
+		return errors.New("scheduled task needs a name and a run function")
+	}
+
+	schedule, err := ParseSchedule(task.Schedule)
+	if err != nil {
+		return fmt.Errorf("task %s: %w", task.Name, err)
+	}
+	if task.Timeout <= 0 {
+		task.Timeout = DefaultTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.entries {
+		if existing.task.Name == task.Name {
+			return fmt.Errorf("task %s registered twice", task.Name)
+		}
+	}
+
+	s.entries = append(s.entries, &entry{
+		task:     task,
+		schedule: schedule,
+		lockKey:  store.AdvisoryLockKey("scheduler:" + task.Name),
+	})
+
+	return nil
+}
+
+// Start records each task's schedule and waits for its ticks in the
+// background. Cancelling ctx stops scheduling new runs but lets running ones
+// finish; call Shutdown to wait for them.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+
+	loopCtx, stop := context.WithCancel(ctx)
+	runCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	s.stop, s.abort = stop, abort
+
+	for _, e := range s.entries {
+		err := s.store.UpsertScheduledTask(ctx, store.UpsertScheduledTaskParams{
+			Name:      e.task.Name,
+			Schedule:  e.task.Schedule,
+			NextRunAt: timestamptz(e.schedule.Next(time.Now())),
+		})
+		if err != nil {
+			slog.Warn("failed to record scheduled task", "task", e.task.Name, "error", err)
+		}
+
+		s.wg.Add(1)
+		go s.loop(loopCtx, runCtx, e)
+	}
+
+	slog.Info("scheduler started", "tasks", len(s.entries))
+}
+
+// Shutdown stops scheduling runs and waits for running ones. If ctx ends
+// first, running tasks are cancelled and ctx's error is returned.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	stop, abort := s.stop, s.abort
+	s.mu.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+	stop()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		abort()
+		return nil
+	case <-ctx.Done():
+		abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop(loopCtx, runCtx context.Context, e *entry) {
+	defer s.wg.Done()
+
+	for {
+		tick := e.schedule.Next(time.Now())
+		timer := time.NewTimer(time.Until(tick))
+
+		select {
+		case <-loopCtx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.runTick(runCtx, e, tick)
+	}
+}
+
+// runTick runs e for tick unless another replica holds its lock or has
+// already run that tick.
+func (s *Scheduler) runTick(ctx context.Context, e *entry, tick time.Time) {
+	ran := false
+	_, err := s.store.WithAdvisoryLock(ctx, e.lockKey, func(ctx context.Context) error {
+		claimed, err := s.store.ClaimScheduledTick(ctx, store.ClaimScheduledTickParams{
+			Tick: timestamptz(tick),
+			Name: e.task.Name,
+		})
+		if err != nil || claimed == 0 {
+			return err
+		}
+
+		ran = true
+		s.execute(ctx, e)
+		return nil
+	})
+	if err != nil {
+		slog.Warn("failed to run scheduled task", "task", e.task.Name, "tick", tick, "error", err)
+		return
+	}
+	if !ran {
+		slog.Debug("scheduled task tick handled by another instance", "task", e.task.Name, "tick", tick)
+	}
+}
+
+// execute runs the task and records its outcome.
+func (s *Scheduler) execute(ctx context.Context, e *entry) {
+	ctx, span := telemetry.Tracer().Start(ctx, "scheduled "+e.task.Name,
+		trace.WithAttributes(attribute.String("task.name", e.task.Name)))
+	defer span.End()
+
+	started := time.Now()
+	err := runTask(ctx, e.task)
+	duration := time.Since(started)
+	durationMs := duration.Milliseconds()
+
+	params := store.FinishScheduledTaskParams{
+		Status:     StatusOK,
+		DurationMs: &durationMs,
+		NextRunAt:  timestamptz(e.schedule.Next(time.Now())),
+		Name:       e.task.Name,
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		params.Status = StatusFailed
+		lastError := err.Error()
+		params.LastError = &lastError
+		slog.Error("scheduled task failed", "task", e.task.Name, "duration", duration, "error", err)
+	} else {
+		slog.Info("scheduled task finished", "task", e.task.Name, "duration", duration)
+	}
+
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+	defer cancel()
+
+	if err := s.store.FinishScheduledTask(finishCtx, params); err != nil {
+		slog.Warn("failed to record scheduled task outcome", "task", e.task.Name, "error", err)
+	}
+}
+
+// runTask calls the task within its timeout, turning panics into errors.
+func runTask(ctx context.Context, task Task) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, task.Timeout)
+	defer cancel()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("task panicked: %v", recovered)
+		}
+	}()
+
+	return task.Run(ctx)
+}
+
+func timestamptz(t time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: t, Valid: true}
+}
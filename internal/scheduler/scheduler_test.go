@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/store/memstore"
+)
+
+func TestParseScheduleRejectsEvery(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{"*/5 * * * *", "@hourly", "0 3 * * 1"} {
+		if _, err := ParseSchedule(expr); err != nil {
+			t.Fatalf("ParseSchedule(%q) error = %v", expr, err)
+		}
+	}
+	for _, expr := range []string{"@every 5m", "* * *", "not a schedule"} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Fatalf("ParseSchedule(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestRunTickRunsEachTickOnce(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := memstore.New()
+	scheduler := New(s)
+
+	runs := 0
+	fail := false
+	if err := scheduler.Register(Task{
+		Name:     "cleanup",
+		Schedule: "@hourly",
+		Run: func(context.Context) error {
+			runs++
+			if fail {
+				return errors.New("database unavailable")
+			}
+			return nil
+		},
+	}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := scheduler.Register(Task{Name: "cleanup", Schedule: "@daily", Run: func(context.Context) error { return nil }}); err == nil {
+		t.Fatal("expected registering a task twice to fail")
+	}
+
+	scheduler.Start(ctx)
+	if err := scheduler.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	e := scheduler.entries[0]
+	tick := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	scheduler.runTick(ctx, e, tick)
+	// A second replica waking for the same tick finds it already claimed.
+	scheduler.runTick(ctx, e, tick)
+	if runs != 1 {
+		t.Fatalf("runs after two attempts at one tick = %d, want 1", runs)
+	}
+
+	// A replica holding the lock keeps everyone else out.
+	locked, err := s.WithAdvisoryLock(ctx, e.lockKey, func(context.Context) error {
+		scheduler.runTick(ctx, e, tick.Add(time.Hour))
+		return nil
+	})
+	if err != nil || !locked {
+		t.Fatalf("WithAdvisoryLock() = %v, %v; want lock taken", locked, err)
+	}
+	if runs != 1 {
+		t.Fatalf("runs while another holder had the lock = %d, want 1", runs)
+	}
+
+	fail = true
+	scheduler.runTick(ctx, e, tick.Add(2*time.Hour))
+
+	tasks, err := s.ListScheduledTasks(ctx)
+	if err != nil {
+		t.Fatalf("ListScheduledTasks() error = %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("ListScheduledTasks() = %d tasks, want 1", len(tasks))
+	}
+	got := tasks[0]
+	if got.Name != "cleanup" || got.Schedule != "@hourly" || got.Runs != 2 || got.Failures != 1 {
+		t.Fatalf("task record = %+v, want 2 runs with 1 failure", got)
+	}
+	if got.LastStatus == nil || *got.LastStatus != StatusFailed || got.LastError == nil || *got.LastError != "database unavailable" {
+		t.Fatalf("task outcome = %v %v, want failed with the error", got.LastStatus, got.LastError)
+	}
+	if !got.LastTick.Time.Equal(tick.Add(2*time.Hour)) || !got.NextRunAt.Valid {
+		t.Fatalf("task ticks = last %v next %v", got.LastTick, got.NextRunAt)
+	}
+}
+
+func TestAdvisoryLockKeyIsStable(t *testing.T) {
+	t.Parallel()
+
+	if store.AdvisoryLockKey("scheduler:a") != store.AdvisoryLockKey("scheduler:a") {
+		t.Fatal("AdvisoryLockKey() is not deterministic")
+	}
+	if store.AdvisoryLockKey("scheduler:a") == store.AdvisoryLockKey("scheduler:b") {
+		t.Fatal("AdvisoryLockKey() collides for different names")
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+// unixSocketPrefix marks a listen address as a Unix domain socket path.
+const unixSocketPrefix = "unix:"
+
+// Listen opens a TCP listener for "host:port" or a Unix socket for
+// "unix:/path/to.sock". A stale socket file left by a previous run is removed;
+// the socket is created group-accessible so local tooling can reach it.
+func Listen(address string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, unixSocketPrefix)
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+
+	if path == "" {
+		return nil, errors.New("unix socket address requires a path")
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, 0o660); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("set socket permissions: %w", err)
+	}
+
+	return listener, nil
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net"
+	"net/http"
+)
+
+// RedirectHandler redirects every plain HTTP request to the same host and
+// path over HTTPS on httpsPort.
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			http.Error(w, "missing Host header", http.StatusBadRequest)
+			return
+		}
+
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			host = "[" + host + "]"
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+
+		// 308 keeps the method and body for non-GET requests.
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
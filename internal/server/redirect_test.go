@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		httpsPort string
+		target    string
+		want      string
+	}{
+		{
+			name:      "default HTTPS port is omitted",
+			httpsPort: "443",
+			target:    "http://example.com:8080/users?page=2",
+			want:      "https://example.com/users?page=2",
+		},
+		{
+			name:      "custom HTTPS port is kept",
+			httpsPort: "8443",
+			target:    "http://example.com/auth/login",
+			want:      "https://example.com:8443/auth/login",
+		},
+		{
+			name:      "IPv6 hosts stay bracketed",
+			httpsPort: "443",
+			target:    "http://[::1]:80/",
+			want:      "https://[::1]/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			RedirectHandler(tt.httpsPort).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, nil))
+
+			if rec.Code != http.StatusPermanentRedirect {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusPermanentRedirect)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Fatalf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Package storage keeps uploaded files. Local writes them under a directory
+// on disk; S3 keeps them in a bucket on any S3-compatible service, such as
+// AWS S3 or MinIO. Keys are slash-separated paths like
+// "avatars/1/3f2a/256.jpg".
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by Get when no object has the key.
+var ErrNotFound = errors.New("storage: object not found")
+
+// errInvalidKey is returned for keys that could escape the store's root.
+var errInvalidKey = errors.New("storage: invalid key")
+
+// Object is a stored file opened for reading. Callers must close Body.
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// Storage puts, gets and deletes files by key.
+type Storage interface {
+	// Put stores size bytes from body under key, replacing any object
+	// already there.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key.
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete removes the object stored under key. Deleting a missing
+	// object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidKey reports whether key is a relative slash-separated path of
+// letters, digits, '.', '-' and '_' with no empty, "." or ".." segments.
+// Such keys need no escaping on disk or in URLs.
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 512 {
+		return false
+	}
+
+	for segment := range strings.SplitSeq(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+		for _, r := range segment {
+			switch {
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			case r == '.', r == '-', r == '_':
+			default:
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// cursorBatchSize is how many rows each FETCH from a cursor returns.
+const cursorBatchSize = 500
+
+// UserStreamer streams users without loading them all into memory.
+type UserStreamer interface {
+	// StreamUsers calls fn with each user SearchUsers would return, in the
+	// same order. It stops at fn's first error and returns it.
+	StreamUsers(ctx context.Context, arg SearchUsersParams, fn func(User) error) error
+}
+
+var _ UserStreamer = (*Store)(nil)
+
+// StreamUsers implements UserStreamer with a server-side cursor in a
+// read-only repeatable read transaction, so every row comes from one snapshot
+// and only one batch is held in memory at a time.
+func (s *Store) StreamUsers(ctx context.Context, arg SearchUsersParams, fn func(User) error) error {
+	// Rows already handed to fn cannot be taken back, so never retry.
+	opts := TxOptions{IsoLevel: pgx.RepeatableRead, ReadOnly: true, MaxAttempts: 1}
+
+	return s.InTx(ctx, opts, func(q *Queries) error {
+		return q.StreamUsers(ctx, arg, fn)
+	})
+}
+
+// StreamUsers runs SearchUsers through a cursor. Cursors only live inside a
+// transaction, so q must be bound to one.
+func (q *Queries) StreamUsers(ctx context.Context, arg SearchUsersParams, fn func(User) error) error {
+	if _, err := q.db.Exec(ctx, "DECLARE user_stream NO SCROLL CURSOR FOR "+searchUsers, arg.Status, arg.Query); err != nil {
+		return fmt.Errorf("declare user cursor: %w", err)
+	}
+
+	fetch := "FETCH " + strconv.Itoa(cursorBatchSize) + " FROM user_stream"
+	for {
+		rows, err := q.db.Query(ctx, fetch)
+		if err != nil {
+			return fmt.Errorf("fetch users: %w", err)
+		}
+		users, err := pgx.CollectRows(rows, pgx.RowToStructByName[User])
+		if err != nil {
+			return fmt.Errorf("fetch users: %w", err)
+		}
+
+		for _, user := range users {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+
+		if len(users) < cursorBatchSize {
+			break
+		}
+	}
+
+	if _, err := q.db.Exec(ctx, "CLOSE user_stream"); err != nil {
+		return fmt.Errorf("close user cursor: %w", err)
+	}
+
+	return nil
+}
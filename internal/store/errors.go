@@ -0,0 +1,111 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrNotFound is returned by :one queries that match no row.
+var ErrNotFound = pgx.ErrNoRows
+
+// ConstraintKind classifies integrity constraint violations.
+type ConstraintKind string
+
+const (
+	// ConstraintUnique is a duplicate value in a unique column.
+	ConstraintUnique ConstraintKind = "unique"
+	// ConstraintNotNull is a missing value in a required column.
+	ConstraintNotNull ConstraintKind = "not_null"
+)
+
+// ConstraintError describes an integrity constraint violation independently
+// of the database backend that reported it.
+type ConstraintError struct {
+	Kind ConstraintKind
+	// Column is the offending column when the backend reports it.
+	Column string
+	// Constraint is the constraint name when the backend reports it.
+	Constraint string
+	Err        error
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%s constraint violation on %q: %v", e.Kind, e.Column, e.Err)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// AsConstraintError translates a backend error into a ConstraintError. It
+// returns false for errors that are not constraint violations.
+func AsConstraintError(err error) (*ConstraintError, bool) {
+	var constraintErr *ConstraintError
+	if errors.As(err, &constraintErr) {
+		return constraintErr, true
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil, false
+	}
+
+	var kind ConstraintKind
+	switch pgErr.Code {
+	case "23505":
+		kind = ConstraintUnique
+	case "23502":
+		kind = ConstraintNotNull
+	default:
+		return nil, false
+	}
+
+	column := pgErr.ColumnName
+	// Unique violations name the constraint, not the column; Postgres names
+	// single-column unique constraints <table>_<column>_key.
+	if column == "" && pgErr.TableName != "" {
+		name := strings.TrimPrefix(pgErr.ConstraintName, pgErr.TableName+"_")
+		if trimmed, ok := strings.CutSuffix(name, "_key"); ok && name != pgErr.ConstraintName {
+			column = trimmed
+		}
+	}
+
+	return &ConstraintError{
+		Kind:       kind,
+		Column:     strings.ToLower(column),
+		Constraint: pgErr.ConstraintName,
+		Err:        err,
+	}, true
+}
+
+// Backend identifies a storage engine.
+type Backend string
+
+const (
+	// BackendPostgres is the PostgreSQL backend.
+	BackendPostgres Backend = "postgres"
+	// BackendSQLite is the SQLite backend.
+	BackendSQLite Backend = "sqlite"
+)
+
+// BackendForURL selects the storage backend from the database URL scheme.
+func BackendForURL(databaseURL string) (Backend, error) {
+	scheme, _, found := strings.Cut(databaseURL, ":")
+	if !found || strings.ContainsAny(scheme, "= ") {
+		// pgx also accepts keyword/value DSNs such as "host=localhost dbname=app".
+		return BackendPostgres, nil
+	}
+
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return BackendPostgres, nil
+	case "sqlite", "sqlite3", "file":
+		return BackendSQLite, nil
+	default:
+		return "", fmt.Errorf("unsupported database URL scheme %q", scheme)
+	}
+}
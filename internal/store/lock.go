@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+)
+
+// Locker runs work under a lock shared by every instance using the database.
+type Locker interface {
+	// WithAdvisoryLock runs fn while holding the lock named key. It reports
+	// false without running fn when another holder has the lock.
+	WithAdvisoryLock(ctx context.Context, key int64, fn func(ctx context.Context) error) (bool, error)
+}
+
+var _ Locker = (*Store)(nil)
+
+// AdvisoryLockKey derives a lock key from a name, so callers can lock on
+// readable names instead of coordinating numbers.
+func AdvisoryLockKey(name string) int64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(name))
+
+	//nolint:gosec // Wrapping into the signed range is intended; any 64 bits make a key.
+	return int64(hash.Sum64())
+}
+
+// WithAdvisoryLock implements Locker with a session-level PostgreSQL advisory
+// lock held on a dedicated connection for the duration of fn.
+func (s *Store) WithAdvisoryLock(ctx context.Context, key int64, fn func(ctx context.Context) error) (bool, error) {
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("acquire lock connection: %w", err)
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		return false, fmt.Errorf("take advisory lock: %w", err)
+	}
+	if !locked {
+		return false, nil
+	}
+
+	defer func() {
+		if _, err := conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", key); err != nil {
+			// A connection that still holds the lock must not go back to
+			// the pool; closing it ends the session and frees the lock.
+			_ = conn.Conn().Close(context.WithoutCancel(ctx))
+		}
+	}()
+
+	return true, fn(ctx)
+}
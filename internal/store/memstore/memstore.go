@@ -0,0 +1,1899 @@
+// Package memstore provides an in-memory store.Querier for tests. It mirrors
+// the PostgreSQL semantics handlers rely on: pgx.ErrNoRows for missing rows, a
+// unique-violation *pgconn.PgError for duplicate emails, active-only listing
+// and counting, trashed users hidden from everything but the trash, and job
+// state transitions that only apply from the states the queries expect.
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Store is an in-memory implementation of store.Querier. It is safe for
+// concurrent use.
+type Store struct {
+	// txMu serializes RunInTx calls; mu guards the data.
+	txMu      sync.Mutex
+	mu        sync.Mutex
+	nextID    int64
+	nextJobID int64
+	users     map[int64]store.User
+	buckets   map[string]store.RateLimitBucket
+	audit     []store.AuditEvent
+	jobs      map[int64]store.Job
+	tasks     map[string]store.ScheduledTask
+	locks     map[int64]bool
+	// payloads holds spilled event data; notifications records every
+	// committed Notify, since nothing listens.
+	nextPayloadID int64
+	payloads      map[int64]store.EventPayload
+	notifications []store.NotifyParams
+	// endpoints and deliveries hold outbound webhooks.
+	nextEndpointID int64
+	nextDeliveryID int64
+	endpoints      map[int64]store.WebhookEndpoint
+	deliveries     map[int64]store.WebhookDelivery
+	// imports holds bulk user imports; importFiles their pending uploads.
+	nextImportID int64
+	imports      map[int64]store.UserImport
+	importFiles  map[int64][]byte
+	// exports holds personal data exports; exportFiles their archives.
+	nextExportID int64
+	exports      map[int64]store.DataExport
+	exportFiles  map[int64][]byte
+	// deletions holds pending account deletions by user ID.
+	deletions map[int64]store.AccountDeletion
+	// auditPurge lets PurgeAuditEvents delete until the transaction ends.
+	auditPurge bool
+	now        func() time.Time
+}
+
+var (
+	_ store.TxQuerier = (*Store)(nil)
+	_ store.Locker    = (*Store)(nil)
+)
+
+// New creates an empty Store.
+func New() *Store {
+	return &Store{
+		users:       make(map[int64]store.User),
+		buckets:     make(map[string]store.RateLimitBucket),
+		jobs:        make(map[int64]store.Job),
+		tasks:       make(map[string]store.ScheduledTask),
+		locks:       make(map[int64]bool),
+		payloads:    make(map[int64]store.EventPayload),
+		endpoints:   make(map[int64]store.WebhookEndpoint),
+		deliveries:  make(map[int64]store.WebhookDelivery),
+		imports:     make(map[int64]store.UserImport),
+		importFiles: make(map[int64][]byte),
+		exports:     make(map[int64]store.DataExport),
+		exportFiles: make(map[int64][]byte),
+		deletions:   make(map[int64]store.AccountDeletion),
+		now:         time.Now,
+	}
+}
+
+// AllowAuditPurge lets PurgeAuditEvents delete until the surrounding RunInTx
+// ends.
+func (s *Store) AllowAuditPurge(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditPurge = true
+	return nil
+}
+
+// CancelAccountDeletion deletes a user's pending deletion and returns how
+// many it deleted.
+func (s *Store) CancelAccountDeletion(_ context.Context, userID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deletions[userID]; !ok {
+		return 0, nil
+	}
+	delete(s.deletions, userID)
+
+	return 1, nil
+}
+
+// CancelJob cancels a pending job and returns how many jobs it cancelled.
+func (s *Store) CancelJob(_ context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.State != "pending" {
+		return 0, nil
+	}
+
+	now := s.timestamp()
+	job.State = "cancelled"
+	job.FinishedAt = now
+	job.UpdatedAt = now
+	s.jobs[id] = job
+
+	return 1, nil
+}
+
+// ClaimJobs marks up to MaxJobs due pending jobs of the given kinds as
+// running, oldest run_at first.
+func (s *Store) ClaimJobs(_ context.Context, arg store.ClaimJobsParams) ([]store.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.timestamp()
+	due := make([]store.Job, 0)
+	for _, job := range s.jobs {
+		if job.State == "pending" && !job.RunAt.Time.After(now.Time) && slices.Contains(arg.Kinds, job.Kind) {
+			due = append(due, job)
+		}
+	}
+	slices.SortFunc(due, func(a, b store.Job) int {
+		if c := a.RunAt.Time.Compare(b.RunAt.Time); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	claimed := make([]store.Job, 0, min(len(due), int(arg.MaxJobs)))
+	for _, job := range due[:min(len(due), int(arg.MaxJobs))] {
+		job.State = "running"
+		job.Attempts++
+		job.LockedAt = now
+		job.LockedBy = cloneString(arg.Worker)
+		job.UpdatedAt = now
+		s.jobs[job.ID] = job
+		claimed = append(claimed, cloneJob(job))
+	}
+
+	return claimed, nil
+}
+
+// ClaimScheduledTick records tick as task's latest run and returns 1, or 0 if
+// the task already ran that tick or later.
+func (s *Store) ClaimScheduledTick(_ context.Context, arg store.ClaimScheduledTickParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[arg.Name]
+	if !ok || (task.LastTick.Valid && !task.LastTick.Time.Before(arg.Tick.Time)) {
+		return 0, nil
+	}
+
+	now := s.timestamp()
+	running := "running"
+	task.LastTick = arg.Tick
+	task.LastStartedAt = now
+	task.LastStatus = &running
+	task.UpdatedAt = now
+	s.tasks[arg.Name] = task
+
+	return 1, nil
+}
+
+// CompleteJob marks a running job as succeeded.
+func (s *Store) CompleteJob(_ context.Context, id int64) error {
+	s.updateRunningJob(id, func(job *store.Job, now pgtype.Timestamptz) {
+		job.State = "succeeded"
+		job.LastError = nil
+		job.FinishedAt = now
+	})
+
+	return nil
+}
+
+// CountJobsByState returns how many jobs are in each state, ordered by state.
+func (s *Store) CountJobsByState(_ context.Context) ([]store.CountJobsByStateRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int64)
+	for _, job := range s.jobs {
+		counts[job.State]++
+	}
+
+	rows := make([]store.CountJobsByStateRow, 0, len(counts))
+	for _, state := range slices.Sorted(maps.Keys(counts)) {
+		rows = append(rows, store.CountJobsByStateRow{State: state, Count: counts[state]})
+	}
+
+	return rows, nil
+}
+
+// CountUsers returns the number of active users.
+func (s *Store) CountUsers(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for _, user := range s.users {
+		if isActive(user) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// CreateAuditEvent appends an audit event.
+func (s *Store) CreateAuditEvent(_ context.Context, arg store.CreateAuditEventParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.audit = append(s.audit, store.AuditEvent{
+		ID:         int64(len(s.audit) + 1),
+		OccurredAt: s.timestamp(),
+		ActorID:    cloneInt64(arg.ActorID),
+		Action:     arg.Action,
+		TargetType: cloneString(arg.TargetType),
+		TargetID:   cloneInt64(arg.TargetID),
+		Ip:         cloneString(arg.Ip),
+		UserAgent:  cloneString(arg.UserAgent),
+		RequestID:  cloneString(arg.RequestID),
+		Before:     slices.Clone(arg.Before),
+		After:      slices.Clone(arg.After),
+	})
+
+	return nil
+}
+
+// CreateDataExport records a pending personal data export.
+func (s *Store) CreateDataExport(_ context.Context, arg store.CreateDataExportParams) (store.DataExport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextExportID++
+	export := store.DataExport{
+		ID:        s.nextExportID,
+		UserID:    arg.UserID,
+		Format:    arg.Format,
+		State:     "pending",
+		CreatedAt: s.timestamp(),
+	}
+	s.exports[export.ID] = export
+
+	return cloneExport(export), nil
+}
+
+// CreateDataExportFile stores an export's archive.
+func (s *Store) CreateDataExportFile(_ context.Context, arg store.CreateDataExportFileParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.exportFiles[arg.ExportID] = slices.Clone(arg.Data)
+
+	return nil
+}
+
+// CreateEventPayload stores spilled event data and returns its ID.
+func (s *Store) CreateEventPayload(_ context.Context, arg store.CreateEventPayloadParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextPayloadID++
+	s.payloads[s.nextPayloadID] = store.EventPayload{
+		ID:        s.nextPayloadID,
+		Topic:     arg.Topic,
+		Data:      slices.Clone(arg.Data),
+		CreatedAt: s.timestamp(),
+	}
+
+	return s.nextPayloadID, nil
+}
+
+// CreateUser inserts a new active user, enforcing unique emails.
+func (s *Store) CreateUser(_ context.Context, arg store.CreateUserParams) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkUniqueEmail(arg.Email, 0); err != nil {
+		return store.User{}, err
+	}
+
+	s.nextID++
+	now := s.timestamp()
+	active := true
+	user := store.User{
+		ID:           s.nextID,
+		Email:        arg.Email,
+		Name:         arg.Name,
+		AvatarUrl:    cloneString(arg.AvatarUrl),
+		Bio:          cloneString(arg.Bio),
+		PasswordHash: arg.PasswordHash,
+		IsActive:     &active,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Version:      1,
+	}
+	s.users[user.ID] = user
+
+	return cloneUser(user), nil
+}
+
+// CreateUserImport records a pending import.
+func (s *Store) CreateUserImport(_ context.Context, arg store.CreateUserImportParams) (store.UserImport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextImportID++
+	imp := store.UserImport{
+		ID:        s.nextImportID,
+		CreatedBy: cloneInt64(arg.CreatedBy),
+		Filename:  arg.Filename,
+		Format:    arg.Format,
+		Mode:      arg.Mode,
+		DryRun:    arg.DryRun,
+		State:     "pending",
+		RowErrors: []byte("[]"),
+		CreatedAt: s.timestamp(),
+	}
+	s.imports[imp.ID] = imp
+
+	return cloneImport(imp), nil
+}
+
+// CreateUserImportFile stores an import's uploaded file.
+func (s *Store) CreateUserImportFile(_ context.Context, arg store.CreateUserImportFileParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.importFiles[arg.ImportID] = slices.Clone(arg.Data)
+
+	return nil
+}
+
+// CreateWebhookDelivery records a pending delivery to an endpoint.
+func (s *Store) CreateWebhookDelivery(_ context.Context, arg store.CreateWebhookDeliveryParams) (store.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextDeliveryID++
+	delivery := store.WebhookDelivery{
+		ID:           s.nextDeliveryID,
+		EndpointID:   arg.EndpointID,
+		Event:        arg.Event,
+		EventID:      arg.EventID,
+		Payload:      slices.Clone(arg.Payload),
+		State:        "pending",
+		RedeliveryOf: cloneInt64(arg.RedeliveryOf),
+		CreatedAt:    s.timestamp(),
+	}
+	s.deliveries[delivery.ID] = delivery
+
+	return cloneDelivery(delivery), nil
+}
+
+// CreateWebhookEndpoint registers an enabled endpoint.
+func (s *Store) CreateWebhookEndpoint(_ context.Context, arg store.CreateWebhookEndpointParams) (store.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.timestamp()
+	s.nextEndpointID++
+	endpoint := store.WebhookEndpoint{
+		ID:          s.nextEndpointID,
+		Url:         arg.Url,
+		Description: cloneString(arg.Description),
+		Secret:      arg.Secret,
+		Events:      slices.Clone(arg.Events),
+		Enabled:     true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if endpoint.Events == nil {
+		endpoint.Events = []string{}
+	}
+	s.endpoints[endpoint.ID] = endpoint
+
+	return cloneEndpoint(endpoint), nil
+}
+
+// DeactivateUser marks a user inactive. Missing users are ignored, like the
+// UPDATE it replaces.
+func (s *Store) DeactivateUser(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok || isDeleted(user) {
+		return nil
+	}
+
+	active := false
+	user.IsActive = &active
+	user.UpdatedAt = s.timestamp()
+	s.users[id] = user
+
+	return nil
+}
+
+// DeleteExpiredRateLimitBuckets removes expired buckets and reports how many.
+func (s *Store) DeleteExpiredRateLimitBuckets(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var deleted int64
+	for key, bucket := range s.buckets {
+		if bucket.ExpiresAt.Time.Before(now) {
+			delete(s.buckets, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// DeleteExpiredSessions is a no-op: tests keep sessions in the SCS memory
+// store, which expires them itself.
+func (s *Store) DeleteExpiredSessions(_ context.Context) (int64, error) {
+	return 0, nil
+}
+
+// DeleteUser removes a user. Missing users are ignored.
+func (s *Store) DeleteUser(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeUser(id)
+
+	return nil
+}
+
+// DeleteUserImportFile deletes an import's uploaded file.
+func (s *Store) DeleteUserImportFile(_ context.Context, importID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.importFiles, importID)
+
+	return nil
+}
+
+// DeleteWebhookEndpoint deletes an endpoint with its deliveries and returns
+// how many endpoints it deleted.
+func (s *Store) DeleteWebhookEndpoint(_ context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.endpoints[id]; !ok {
+		return 0, nil
+	}
+
+	delete(s.endpoints, id)
+	for deliveryID, delivery := range s.deliveries {
+		if delivery.EndpointID == id {
+			s.deleteDelivery(deliveryID)
+		}
+	}
+
+	return 1, nil
+}
+
+// EnqueueJob inserts a pending job. Like the ON CONFLICT DO NOTHING in the
+// query, it returns pgx.ErrNoRows when a pending or running job already holds
+// the unique key.
+func (s *Store) EnqueueJob(_ context.Context, arg store.EnqueueJobParams) (store.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.uniqueJobKeyTaken(arg.UniqueKey, 0) {
+		return store.Job{}, pgx.ErrNoRows
+	}
+
+	now := s.timestamp()
+	runAt := arg.RunAt
+	if !runAt.Valid {
+		runAt = now
+	}
+	payload := slices.Clone(arg.Payload)
+	if payload == nil {
+		payload = []byte("{}")
+	}
+
+	s.nextJobID++
+	job := store.Job{
+		ID:          s.nextJobID,
+		Kind:        arg.Kind,
+		Payload:     payload,
+		State:       "pending",
+		MaxAttempts: arg.MaxAttempts,
+		UniqueKey:   cloneString(arg.UniqueKey),
+		RunAt:       runAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	s.jobs[job.ID] = job
+
+	return cloneJob(job), nil
+}
+
+// EraseUser permanently removes a user whose deletion is due and returns
+// them, or pgx.ErrNoRows if the deletion was cancelled or is not yet due.
+func (s *Store) EraseUser(_ context.Context, arg store.EraseUserParams) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deletion, ok := s.deletions[arg.ID]
+	user, exists := s.users[arg.ID]
+	if !ok || !exists || deletion.DeleteAfter.Time.After(arg.DueAt.Time) {
+		return store.User{}, pgx.ErrNoRows
+	}
+	s.removeUser(arg.ID)
+
+	return cloneUser(user), nil
+}
+
+// FailJob moves a running job to the dead state.
+func (s *Store) FailJob(_ context.Context, arg store.FailJobParams) error {
+	s.updateRunningJob(arg.ID, func(job *store.Job, now pgtype.Timestamptz) {
+		job.State = "dead"
+		job.LastError = &arg.LastError
+		job.FinishedAt = now
+	})
+
+	return nil
+}
+
+// FailWebhookDelivery marks a pending delivery failed without an attempt.
+func (s *Store) FailWebhookDelivery(_ context.Context, arg store.FailWebhookDeliveryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivery, ok := s.deliveries[arg.ID]
+	if !ok || delivery.State != "pending" {
+		return nil
+	}
+
+	delivery.State = "failed"
+	delivery.LastError = &arg.LastError
+	s.deliveries[arg.ID] = delivery
+
+	return nil
+}
+
+// FinishDataExport records the outcome of an export.
+func (s *Store) FinishDataExport(_ context.Context, arg store.FinishDataExportParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	export, ok := s.exports[arg.ID]
+	if !ok {
+		return nil
+	}
+
+	export.State = arg.State
+	export.SizeBytes = arg.SizeBytes
+	export.LastError = cloneString(arg.LastError)
+	export.FinishedAt = s.timestamp()
+	export.ExpiresAt = arg.ExpiresAt
+	s.exports[arg.ID] = export
+
+	return nil
+}
+
+// FinishScheduledTask records the outcome of a task's latest run.
+func (s *Store) FinishScheduledTask(_ context.Context, arg store.FinishScheduledTaskParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, ok := s.tasks[arg.Name]
+	if !ok {
+		return nil
+	}
+
+	now := s.timestamp()
+	status := arg.Status
+	task.LastStatus = &status
+	task.LastError = cloneString(arg.LastError)
+	task.LastFinishedAt = now
+	task.LastDurationMs = cloneInt64(arg.DurationMs)
+	task.NextRunAt = arg.NextRunAt
+	task.Runs++
+	if arg.Status == "failed" {
+		task.Failures++
+	}
+	task.UpdatedAt = now
+	s.tasks[arg.Name] = task
+
+	return nil
+}
+
+// FinishUserImport records the outcome of an import.
+func (s *Store) FinishUserImport(_ context.Context, arg store.FinishUserImportParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	imp, ok := s.imports[arg.ID]
+	if !ok {
+		return nil
+	}
+
+	imp.State = arg.State
+	imp.ProcessedRows = arg.ProcessedRows
+	imp.CreatedRows = arg.CreatedRows
+	imp.FailedRows = arg.FailedRows
+	imp.RowErrors = slices.Clone(arg.RowErrors)
+	imp.LastError = cloneString(arg.LastError)
+	imp.FinishedAt = s.timestamp()
+	s.imports[arg.ID] = imp
+
+	return nil
+}
+
+// FinishWebhookAttempt records the outcome of one delivery attempt.
+func (s *Store) FinishWebhookAttempt(_ context.Context, arg store.FinishWebhookAttemptParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivery, ok := s.deliveries[arg.ID]
+	if !ok {
+		return nil
+	}
+
+	now := s.timestamp()
+	delivery.State = arg.State
+	delivery.Attempts++
+	delivery.ResponseStatus = cloneInt32(arg.ResponseStatus)
+	delivery.ResponseBody = cloneString(arg.ResponseBody)
+	delivery.LastError = cloneString(arg.LastError)
+	delivery.DurationMs = cloneInt64(arg.DurationMs)
+	delivery.LastAttemptAt = now
+	delivery.DeliveredAt = pgtype.Timestamptz{}
+	if arg.State == "succeeded" {
+		delivery.DeliveredAt = now
+	}
+	s.deliveries[arg.ID] = delivery
+
+	return nil
+}
+
+// GetAccountDeletion returns a user's pending deletion.
+func (s *Store) GetAccountDeletion(_ context.Context, userID int64) (store.AccountDeletion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deletion, ok := s.deletions[userID]
+	if !ok {
+		return store.AccountDeletion{}, pgx.ErrNoRows
+	}
+
+	return deletion, nil
+}
+
+// GetDataExport returns an export by ID.
+func (s *Store) GetDataExport(_ context.Context, id int64) (store.DataExport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	export, ok := s.exports[id]
+	if !ok {
+		return store.DataExport{}, pgx.ErrNoRows
+	}
+
+	return cloneExport(export), nil
+}
+
+// GetDataExportFile returns an export's archive.
+func (s *Store) GetDataExportFile(_ context.Context, exportID int64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.exportFiles[exportID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+
+	return slices.Clone(data), nil
+}
+
+// GetEventPayload returns spilled event data by ID.
+func (s *Store) GetEventPayload(_ context.Context, id int64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payload, ok := s.payloads[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+
+	return slices.Clone(payload.Data), nil
+}
+
+// GetUser returns a user by ID, active or not, unless it is in the trash.
+func (s *Store) GetUser(_ context.Context, id int64) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok || isDeleted(user) {
+		return store.User{}, pgx.ErrNoRows
+	}
+
+	return cloneUser(user), nil
+}
+
+// GetUserByEmail returns a user by exact email, active or not, unless it is
+// in the trash.
+func (s *Store) GetUserByEmail(_ context.Context, email string) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Email == email && !isDeleted(user) {
+			return cloneUser(user), nil
+		}
+	}
+
+	return store.User{}, pgx.ErrNoRows
+}
+
+// GetUserImport returns an import by ID.
+func (s *Store) GetUserImport(_ context.Context, id int64) (store.UserImport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	imp, ok := s.imports[id]
+	if !ok {
+		return store.UserImport{}, pgx.ErrNoRows
+	}
+
+	return cloneImport(imp), nil
+}
+
+// GetUserImportFile returns an import's uploaded file.
+func (s *Store) GetUserImportFile(_ context.Context, importID int64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.importFiles[importID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+
+	return slices.Clone(data), nil
+}
+
+// GetWebhookDelivery returns a delivery by ID.
+func (s *Store) GetWebhookDelivery(_ context.Context, id int64) (store.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivery, ok := s.deliveries[id]
+	if !ok {
+		return store.WebhookDelivery{}, pgx.ErrNoRows
+	}
+
+	return cloneDelivery(delivery), nil
+}
+
+// GetWebhookEndpoint returns an endpoint by ID.
+func (s *Store) GetWebhookEndpoint(_ context.Context, id int64) (store.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint, ok := s.endpoints[id]
+	if !ok {
+		return store.WebhookEndpoint{}, pgx.ErrNoRows
+	}
+
+	return cloneEndpoint(endpoint), nil
+}
+
+// GrantAdmin sets is_admin on a user, which the app itself never does.
+// Missing users are ignored.
+func (s *Store) GrantAdmin(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[id]; ok {
+		user.IsAdmin = true
+		s.users[id] = user
+	}
+}
+
+// ListAccountDeletions returns pending deletions with their users, the
+// soonest due first.
+func (s *Store) ListAccountDeletions(_ context.Context) ([]store.ListAccountDeletionsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []store.ListAccountDeletionsRow
+	for _, deletion := range s.sortedDeletions() {
+		user, ok := s.users[deletion.UserID]
+		if !ok {
+			continue
+		}
+		rows = append(rows, store.ListAccountDeletionsRow{
+			UserID:      deletion.UserID,
+			Email:       user.Email,
+			Name:        user.Name,
+			RequestedAt: deletion.RequestedAt,
+			DeleteAfter: deletion.DeleteAfter,
+		})
+	}
+
+	return rows, nil
+}
+
+// ListAllUsers returns every user outside the trash, newest first.
+func (s *Store) ListAllUsers(_ context.Context) ([]store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listUsers(func(user store.User) bool { return !isDeleted(user) }), nil
+}
+
+// ListAuditEvents returns matching audit events, newest first, with the
+// actor's current email.
+func (s *Store) ListAuditEvents(_ context.Context, arg store.ListAuditEventsParams) ([]store.ListAuditEventsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []store.ListAuditEventsRow
+	for i := len(s.audit) - 1; i >= 0 && len(rows) < int(arg.MaxRows); i-- {
+		event := s.audit[i]
+
+		var actorEmail *string
+		if event.ActorID != nil {
+			if actor, ok := s.users[*event.ActorID]; ok {
+				actorEmail = &actor.Email
+			}
+		}
+
+		switch {
+		case arg.Action != nil && event.Action != *arg.Action,
+			arg.ActorEmail != nil && (actorEmail == nil || *actorEmail != *arg.ActorEmail),
+			arg.TargetID != nil && (event.TargetID == nil || *event.TargetID != *arg.TargetID),
+			arg.BeforeID != nil && event.ID >= *arg.BeforeID:
+			continue
+		}
+
+		rows = append(rows, store.ListAuditEventsRow{
+			ID:         event.ID,
+			OccurredAt: event.OccurredAt,
+			ActorID:    cloneInt64(event.ActorID),
+			ActorEmail: cloneString(actorEmail),
+			Action:     event.Action,
+			TargetType: cloneString(event.TargetType),
+			TargetID:   cloneInt64(event.TargetID),
+			Ip:         cloneString(event.Ip),
+			UserAgent:  cloneString(event.UserAgent),
+			RequestID:  cloneString(event.RequestID),
+			Before:     slices.Clone(event.Before),
+			After:      slices.Clone(event.After),
+		})
+	}
+
+	return rows, nil
+}
+
+// ListDataExports returns a user's newest exports first.
+func (s *Store) ListDataExports(_ context.Context, arg store.ListDataExportsParams) ([]store.DataExport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var exports []store.DataExport
+	for _, export := range s.exports {
+		if export.UserID == arg.UserID {
+			exports = append(exports, cloneExport(export))
+		}
+	}
+	slices.SortFunc(exports, func(a, b store.DataExport) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+	if len(exports) > int(arg.MaxRows) {
+		exports = exports[:arg.MaxRows]
+	}
+
+	return exports, nil
+}
+
+// ListDeletedUsers returns users in the trash, most recently deleted first.
+func (s *Store) ListDeletedUsers(_ context.Context) ([]store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := s.listUsers(isDeleted)
+	slices.SortStableFunc(users, func(a, b store.User) int {
+		return b.DeletedAt.Time.Compare(a.DeletedAt.Time)
+	})
+
+	return users, nil
+}
+
+// ListDueAccountDeletions returns the deletions due at dueAt, the soonest
+// due first.
+func (s *Store) ListDueAccountDeletions(_ context.Context, dueAt pgtype.Timestamptz) ([]store.AccountDeletion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []store.AccountDeletion
+	for _, deletion := range s.sortedDeletions() {
+		if !deletion.DeleteAfter.Time.After(dueAt.Time) {
+			due = append(due, deletion)
+		}
+	}
+
+	return due, nil
+}
+
+// ListJobs returns matching jobs, newest first.
+func (s *Store) ListJobs(_ context.Context, arg store.ListJobsParams) ([]store.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]store.Job, 0)
+	for _, job := range s.jobs {
+		if arg.State != nil && job.State != *arg.State {
+			continue
+		}
+		if arg.BeforeID != nil && job.ID >= *arg.BeforeID {
+			continue
+		}
+		jobs = append(jobs, cloneJob(job))
+	}
+	slices.SortFunc(jobs, func(a, b store.Job) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	return jobs[:min(len(jobs), int(arg.MaxRows))], nil
+}
+
+// ListScheduledTasks returns every scheduled task ordered by name.
+func (s *Store) ListScheduledTasks(_ context.Context) ([]store.ScheduledTask, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks := make([]store.ScheduledTask, 0, len(s.tasks))
+	for _, name := range slices.Sorted(maps.Keys(s.tasks)) {
+		task := s.tasks[name]
+		task.LastStatus = cloneString(task.LastStatus)
+		task.LastError = cloneString(task.LastError)
+		task.LastDurationMs = cloneInt64(task.LastDurationMs)
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// ListUserAuditEvents returns the events a user performed or that targeted
+// them, oldest first.
+func (s *Store) ListUserAuditEvents(_ context.Context, userID int64) ([]store.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []store.AuditEvent
+	for _, event := range s.audit {
+		actor := event.ActorID != nil && *event.ActorID == userID
+		target := event.TargetType != nil && *event.TargetType == "user" && event.TargetID != nil && *event.TargetID == userID
+		if actor || target {
+			events = append(events, cloneAuditEvent(event))
+		}
+	}
+
+	return events, nil
+}
+
+// ListUserImports returns up to maxRows imports, newest first.
+func (s *Store) ListUserImports(_ context.Context, maxRows int32) ([]store.UserImport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	imports := make([]store.UserImport, 0, len(s.imports))
+	for _, imp := range s.imports {
+		imports = append(imports, cloneImport(imp))
+	}
+	slices.SortFunc(imports, func(a, b store.UserImport) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	return imports[:min(len(imports), int(maxRows))], nil
+}
+
+// ListUsers returns active users, newest first.
+func (s *Store) ListUsers(_ context.Context) ([]store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listUsers(isActive), nil
+}
+
+// ListWebhookDeliveries returns an endpoint's deliveries, newest first.
+func (s *Store) ListWebhookDeliveries(_ context.Context, arg store.ListWebhookDeliveriesParams) ([]store.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries := make([]store.WebhookDelivery, 0)
+	for _, delivery := range s.deliveries {
+		if delivery.EndpointID != arg.EndpointID {
+			continue
+		}
+		if arg.BeforeID != nil && delivery.ID >= *arg.BeforeID {
+			continue
+		}
+		deliveries = append(deliveries, cloneDelivery(delivery))
+	}
+	slices.SortFunc(deliveries, func(a, b store.WebhookDelivery) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	return deliveries[:min(len(deliveries), int(arg.MaxRows))], nil
+}
+
+// ListWebhookEndpoints returns every endpoint ordered by ID.
+func (s *Store) ListWebhookEndpoints(_ context.Context) ([]store.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listEndpoints(func(store.WebhookEndpoint) bool { return true }), nil
+}
+
+// ListWebhookEndpointsForEvent returns the enabled endpoints subscribed to
+// event, ordered by ID.
+func (s *Store) ListWebhookEndpointsForEvent(_ context.Context, event string) ([]store.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listEndpoints(func(endpoint store.WebhookEndpoint) bool {
+		return endpoint.Enabled && (len(endpoint.Events) == 0 || slices.Contains(endpoint.Events, event))
+	}), nil
+}
+
+// Notify records a notification. Inside RunInTx it is kept only if the
+// transaction commits, as with NOTIFY.
+func (s *Store) Notify(_ context.Context, arg store.NotifyParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.notifications = append(s.notifications, arg)
+
+	return nil
+}
+
+// Notifications returns every notification sent so far, oldest first.
+func (s *Store) Notifications() []store.NotifyParams {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.notifications)
+}
+
+// PurgeAuditEvents deletes events that occurred before occurredBefore. Like
+// the append-only trigger, it fails unless AllowAuditPurge ran earlier in the
+// same transaction.
+func (s *Store) PurgeAuditEvents(_ context.Context, occurredBefore pgtype.Timestamptz) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.auditPurge {
+		return 0, &pgconn.PgError{Severity: "ERROR", Code: "P0001", Message: "audit_events is append-only"}
+	}
+
+	kept := s.audit[:0:0]
+	for _, event := range s.audit {
+		if !event.OccurredAt.Time.Before(occurredBefore.Time) {
+			kept = append(kept, event)
+		}
+	}
+	purged := int64(len(s.audit) - len(kept))
+	s.audit = kept
+
+	return purged, nil
+}
+
+// PurgeDataExports deletes exports, with their archives, that expired
+// before the cutoff.
+func (s *Store) PurgeDataExports(_ context.Context, expiresBefore pgtype.Timestamptz) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, export := range s.exports {
+		if export.ExpiresAt.Valid && export.ExpiresAt.Time.Before(expiresBefore.Time) {
+			delete(s.exports, id)
+			delete(s.exportFiles, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// PurgeDeletedUsers permanently removes users trashed before deletedBefore
+// and returns their IDs and avatars.
+func (s *Store) PurgeDeletedUsers(_ context.Context, deletedBefore pgtype.Timestamptz) ([]store.PurgeDeletedUsersRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged []store.PurgeDeletedUsersRow
+	for id, user := range s.users {
+		if isDeleted(user) && user.DeletedAt.Time.Before(deletedBefore.Time) {
+			s.removeUser(id)
+			purged = append(purged, store.PurgeDeletedUsersRow{ID: id, AvatarUrl: user.AvatarUrl})
+		}
+	}
+	slices.SortFunc(purged, func(a, b store.PurgeDeletedUsersRow) int { return cmp.Compare(a.ID, b.ID) })
+
+	return purged, nil
+}
+
+// PurgeEventPayloads deletes spilled event data created before the cutoff.
+func (s *Store) PurgeEventPayloads(_ context.Context, createdBefore pgtype.Timestamptz) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, payload := range s.payloads {
+		if payload.CreatedAt.Time.Before(createdBefore.Time) {
+			delete(s.payloads, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// PurgeFinishedJobs deletes succeeded, dead and cancelled jobs that finished
+// before finishedBefore.
+func (s *Store) PurgeFinishedJobs(_ context.Context, finishedBefore pgtype.Timestamptz) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, job := range s.jobs {
+		if job.FinishedAt.Valid && job.FinishedAt.Time.Before(finishedBefore.Time) {
+			delete(s.jobs, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// PurgeWebhookDeliveries deletes deliveries created before the cutoff.
+func (s *Store) PurgeWebhookDeliveries(_ context.Context, createdBefore pgtype.Timestamptz) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, delivery := range s.deliveries {
+		if delivery.CreatedAt.Time.Before(createdBefore.Time) {
+			s.deleteDelivery(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// ReactivateUser marks a user active again and reports whether it existed.
+func (s *Store) ReactivateUser(_ context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok || isDeleted(user) {
+		return 0, nil
+	}
+
+	active := true
+	user.IsActive = &active
+	user.UpdatedAt = s.timestamp()
+	s.users[id] = user
+
+	return 1, nil
+}
+
+// RecordWebhookEndpointFailure counts a failed attempt and disables the
+// endpoint once DisableAfter attempts in a row have failed.
+func (s *Store) RecordWebhookEndpointFailure(_ context.Context, arg store.RecordWebhookEndpointFailureParams) (store.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint, ok := s.endpoints[arg.ID]
+	if !ok {
+		return store.WebhookEndpoint{}, pgx.ErrNoRows
+	}
+
+	endpoint.ConsecutiveFailures++
+	if endpoint.Enabled && arg.DisableAfter > 0 && endpoint.ConsecutiveFailures >= arg.DisableAfter {
+		endpoint.Enabled = false
+		endpoint.DisabledReason = &arg.Reason
+	}
+	endpoint.UpdatedAt = s.timestamp()
+	s.endpoints[arg.ID] = endpoint
+
+	return cloneEndpoint(endpoint), nil
+}
+
+// RecordWebhookEndpointSuccess clears an endpoint's failure count.
+func (s *Store) RecordWebhookEndpointSuccess(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint, ok := s.endpoints[id]
+	if !ok || endpoint.ConsecutiveFailures == 0 {
+		return nil
+	}
+
+	endpoint.ConsecutiveFailures = 0
+	endpoint.UpdatedAt = s.timestamp()
+	s.endpoints[id] = endpoint
+
+	return nil
+}
+
+// ReleaseJob returns a running job to pending without using up an attempt.
+func (s *Store) ReleaseJob(_ context.Context, id int64) error {
+	s.updateRunningJob(id, func(job *store.Job, _ pgtype.Timestamptz) {
+		job.State = "pending"
+		job.Attempts--
+	})
+
+	return nil
+}
+
+// RequestAccountDeletion schedules a user's account for erasure, or returns
+// pgx.ErrNoRows if a deletion is already pending.
+func (s *Store) RequestAccountDeletion(_ context.Context, arg store.RequestAccountDeletionParams) (store.AccountDeletion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.deletions[arg.UserID]; ok {
+		return store.AccountDeletion{}, pgx.ErrNoRows
+	}
+
+	deletion := store.AccountDeletion{
+		UserID:      arg.UserID,
+		RequestedAt: s.timestamp(),
+		DeleteAfter: arg.DeleteAfter,
+	}
+	s.deletions[arg.UserID] = deletion
+
+	return deletion, nil
+}
+
+// RescueStaleJobs returns running jobs locked before lockedBefore to pending
+// and reports how many it rescued.
+func (s *Store) RescueStaleJobs(_ context.Context, lockedBefore pgtype.Timestamptz) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rescued int64
+	lastError := "worker stopped before finishing the job"
+	for id, job := range s.jobs {
+		if job.State != "running" || !job.LockedAt.Time.Before(lockedBefore.Time) {
+			continue
+		}
+
+		job.State = "pending"
+		job.LastError = &lastError
+		job.LockedAt = pgtype.Timestamptz{}
+		job.LockedBy = nil
+		job.UpdatedAt = s.timestamp()
+		s.jobs[id] = job
+		rescued++
+	}
+
+	return rescued, nil
+}
+
+// RestoreUser takes a user out of the trash and reports whether it was there.
+func (s *Store) RestoreUser(_ context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok || !isDeleted(user) {
+		return 0, nil
+	}
+
+	user.DeletedAt = pgtype.Timestamptz{}
+	user.UpdatedAt = s.timestamp()
+	s.users[id] = user
+
+	return 1, nil
+}
+
+// RetryJob requeues a dead or cancelled job with its attempts reset and
+// returns how many jobs it requeued. Requeuing a job whose unique key another
+// pending or running job holds is a unique violation, as in PostgreSQL.
+func (s *Store) RetryJob(_ context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || (job.State != "dead" && job.State != "cancelled") {
+		return 0, nil
+	}
+	if s.uniqueJobKeyTaken(job.UniqueKey, id) {
+		return 0, &pgconn.PgError{
+			Severity:       "ERROR",
+			Code:           "23505",
+			Message:        `duplicate key value violates unique constraint "jobs_unique_key"`,
+			TableName:      "jobs",
+			ConstraintName: "jobs_unique_key",
+		}
+	}
+
+	now := s.timestamp()
+	job.State = "pending"
+	job.Attempts = 0
+	job.RunAt = now
+	job.FinishedAt = pgtype.Timestamptz{}
+	job.UpdatedAt = now
+	s.jobs[id] = job
+
+	return 1, nil
+}
+
+// ScheduleJobRetry returns a running job to pending, due at RunAt.
+func (s *Store) ScheduleJobRetry(_ context.Context, arg store.ScheduleJobRetryParams) error {
+	s.updateRunningJob(arg.ID, func(job *store.Job, _ pgtype.Timestamptz) {
+		job.State = "pending"
+		job.RunAt = arg.RunAt
+		job.LastError = &arg.LastError
+	})
+
+	return nil
+}
+
+// SearchUsers returns users who are not trashed, newest first, filtered by
+// status and a case-insensitive match on name or email.
+func (s *Store) SearchUsers(_ context.Context, arg store.SearchUsersParams) ([]store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listUsers(func(user store.User) bool {
+		return !isDeleted(user) && matchesSearch(user, arg)
+	}), nil
+}
+
+// SetWebhookEndpointEnabled enables or disables an endpoint and returns how
+// many endpoints it changed. Enabling clears the failure count.
+func (s *Store) SetWebhookEndpointEnabled(_ context.Context, arg store.SetWebhookEndpointEnabledParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint, ok := s.endpoints[arg.ID]
+	if !ok {
+		return 0, nil
+	}
+
+	endpoint.Enabled = arg.Enabled
+	if arg.Enabled {
+		endpoint.ConsecutiveFailures = 0
+	}
+	endpoint.DisabledReason = cloneString(arg.DisabledReason)
+	endpoint.UpdatedAt = s.timestamp()
+	s.endpoints[arg.ID] = endpoint
+
+	return 1, nil
+}
+
+// SoftDeleteUser moves a user to the trash and reports whether it was live.
+func (s *Store) SoftDeleteUser(_ context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok || isDeleted(user) {
+		return 0, nil
+	}
+
+	user.DeletedAt = s.timestamp()
+	user.UpdatedAt = user.DeletedAt
+	s.users[id] = user
+
+	return 1, nil
+}
+
+// StartDataExport moves a pending or running export to running.
+func (s *Store) StartDataExport(_ context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	export, ok := s.exports[id]
+	if !ok || (export.State != "pending" && export.State != "running") {
+		return 0, nil
+	}
+
+	export.State = "running"
+	s.exports[id] = export
+
+	return 1, nil
+}
+
+// StartUserImport moves a pending or running import to running with its
+// counters reset.
+func (s *Store) StartUserImport(_ context.Context, arg store.StartUserImportParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	imp, ok := s.imports[arg.ID]
+	if !ok || (imp.State != "pending" && imp.State != "running") {
+		return 0, nil
+	}
+
+	imp.State = "running"
+	imp.TotalRows = arg.TotalRows
+	imp.ProcessedRows, imp.CreatedRows, imp.FailedRows = 0, 0, 0
+	imp.StartedAt = s.timestamp()
+	s.imports[arg.ID] = imp
+
+	return 1, nil
+}
+
+// TakeRateLimitToken applies the same token bucket arithmetic as the SQL query.
+func (s *Store) TakeRateLimitToken(_ context.Context, arg store.TakeRateLimitTokenParams) (store.TakeRateLimitTokenRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	expiresAt := now.Add(time.Duration(arg.TtlSeconds * float64(time.Second)))
+
+	bucket, ok := s.buckets[arg.Key]
+	if !ok {
+		bucket = store.RateLimitBucket{
+			Key:     arg.Key,
+			Tokens:  arg.Capacity - 1,
+			Allowed: true,
+		}
+	} else {
+		elapsed := now.Sub(bucket.UpdatedAt.Time).Seconds()
+		tokens := math.Min(arg.Capacity, bucket.Tokens+elapsed*arg.RefillPerSecond)
+
+		bucket.Allowed = tokens >= 1
+		if bucket.Allowed {
+			tokens--
+		}
+		bucket.Tokens = tokens
+	}
+
+	bucket.UpdatedAt = pgtype.Timestamptz{Time: now, Valid: true}
+	bucket.ExpiresAt = pgtype.Timestamptz{Time: expiresAt, Valid: true}
+	s.buckets[arg.Key] = bucket
+
+	return store.TakeRateLimitTokenRow{Tokens: bucket.Tokens, Allowed: bucket.Allowed}, nil
+}
+
+// UpdateUser updates profile fields, enforcing unique emails.
+func (s *Store) UpdateUser(_ context.Context, arg store.UpdateUserParams) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A stale version matches no row, exactly like the WHERE clause.
+	user, ok := s.users[arg.ID]
+	if !ok || isDeleted(user) || user.Version != arg.Version {
+		return store.User{}, pgx.ErrNoRows
+	}
+
+	if err := s.checkUniqueEmail(arg.Email, arg.ID); err != nil {
+		return store.User{}, err
+	}
+
+	user.Email = arg.Email
+	user.Name = arg.Name
+	user.Bio = cloneString(arg.Bio)
+	user.AvatarUrl = cloneString(arg.AvatarUrl)
+	user.Version++
+	user.UpdatedAt = s.timestamp()
+	s.users[arg.ID] = user
+
+	return cloneUser(user), nil
+}
+
+// UpdateUserImportProgress records how far an import has got.
+func (s *Store) UpdateUserImportProgress(_ context.Context, arg store.UpdateUserImportProgressParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	imp, ok := s.imports[arg.ID]
+	if !ok {
+		return nil
+	}
+
+	imp.ProcessedRows = arg.ProcessedRows
+	imp.CreatedRows = arg.CreatedRows
+	imp.FailedRows = arg.FailedRows
+	s.imports[arg.ID] = imp
+
+	return nil
+}
+
+// UpdateUserPassword updates profile fields and the password hash.
+func (s *Store) UpdateUserPassword(_ context.Context, arg store.UpdateUserPasswordParams) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A stale version matches no row, exactly like the WHERE clause.
+	user, ok := s.users[arg.ID]
+	if !ok || isDeleted(user) || user.Version != arg.Version {
+		return store.User{}, pgx.ErrNoRows
+	}
+
+	if err := s.checkUniqueEmail(arg.Email, arg.ID); err != nil {
+		return store.User{}, err
+	}
+
+	user.Email = arg.Email
+	user.Name = arg.Name
+	user.Bio = cloneString(arg.Bio)
+	user.AvatarUrl = cloneString(arg.AvatarUrl)
+	user.PasswordHash = arg.PasswordHash
+	user.Version++
+	user.UpdatedAt = s.timestamp()
+	s.users[arg.ID] = user
+
+	return cloneUser(user), nil
+}
+
+// UpsertScheduledTask creates a task record or updates its schedule.
+func (s *Store) UpsertScheduledTask(_ context.Context, arg store.UpsertScheduledTaskParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task := s.tasks[arg.Name]
+	task.Name = arg.Name
+	task.Schedule = arg.Schedule
+	task.NextRunAt = arg.NextRunAt
+	task.UpdatedAt = s.timestamp()
+	s.tasks[arg.Name] = task
+
+	return nil
+}
+
+// RunInTx runs fn against the store and restores the previous state if fn
+// returns an error or panics. Transactions run one at a time; writes made
+// outside RunInTx while one is running are lost if it rolls back.
+func (s *Store) RunInTx(_ context.Context, _ store.TxOptions, fn func(q store.Querier) error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.Lock()
+	nextID, nextJobID := s.nextID, s.nextJobID
+	users := maps.Clone(s.users)
+	buckets := maps.Clone(s.buckets)
+	audit := slices.Clone(s.audit)
+	jobs := maps.Clone(s.jobs)
+	tasks := maps.Clone(s.tasks)
+	nextPayloadID, payloads := s.nextPayloadID, maps.Clone(s.payloads)
+	notifications := slices.Clone(s.notifications)
+	nextEndpointID, endpoints := s.nextEndpointID, maps.Clone(s.endpoints)
+	nextDeliveryID, deliveries := s.nextDeliveryID, maps.Clone(s.deliveries)
+	nextImportID, imports, importFiles := s.nextImportID, maps.Clone(s.imports), maps.Clone(s.importFiles)
+	nextExportID, exports, exportFiles := s.nextExportID, maps.Clone(s.exports), maps.Clone(s.exportFiles)
+	deletions := maps.Clone(s.deletions)
+	s.mu.Unlock()
+
+	committed := false
+	defer func() {
+		s.mu.Lock()
+		s.auditPurge = false
+		s.mu.Unlock()
+
+		if committed {
+			return
+		}
+
+		s.mu.Lock()
+		s.nextID, s.nextJobID = nextID, nextJobID
+		s.users = users
+		s.buckets = buckets
+		s.audit = audit
+		s.jobs = jobs
+		s.tasks = tasks
+		s.nextPayloadID, s.payloads = nextPayloadID, payloads
+		s.notifications = notifications
+		s.nextEndpointID, s.endpoints = nextEndpointID, endpoints
+		s.nextDeliveryID, s.deliveries = nextDeliveryID, deliveries
+		s.nextImportID, s.imports, s.importFiles = nextImportID, imports, importFiles
+		s.nextExportID, s.exports, s.exportFiles = nextExportID, exports, exportFiles
+		s.deletions = deletions
+		s.mu.Unlock()
+	}()
+
+	if err := fn(s); err != nil {
+		return err
+	}
+
+	committed = true
+	return nil
+}
+
+// StreamUsers calls fn with each user SearchUsers returns. The store's lock
+// is not held while fn runs.
+func (s *Store) StreamUsers(ctx context.Context, arg store.SearchUsersParams, fn func(store.User) error) error {
+	users, err := s.SearchUsers(ctx, arg)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WithAdvisoryLock runs fn unless another caller holds key.
+func (s *Store) WithAdvisoryLock(ctx context.Context, key int64, fn func(ctx context.Context) error) (bool, error) {
+	s.mu.Lock()
+	if s.locks[key] {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.locks[key] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.locks, key)
+		s.mu.Unlock()
+	}()
+
+	return true, fn(ctx)
+}
+
+// checkUniqueEmail reports a users_email_key violation when another user
+// already has email.
+func (s *Store) checkUniqueEmail(email string, exceptID int64) error {
+	for _, user := range s.users {
+		if user.ID != exceptID && user.Email == email {
+			return &pgconn.PgError{
+				Severity:       "ERROR",
+				Code:           "23505",
+				Message:        `duplicate key value violates unique constraint "users_email_key"`,
+				TableName:      "users",
+				ConstraintName: "users_email_key",
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteDelivery deletes a delivery and clears references to it from its
+// redeliveries.
+func (s *Store) deleteDelivery(id int64) {
+	delete(s.deliveries, id)
+	for otherID, other := range s.deliveries {
+		if other.RedeliveryOf != nil && *other.RedeliveryOf == id {
+			other.RedeliveryOf = nil
+			s.deliveries[otherID] = other
+		}
+	}
+}
+
+// removeUser deletes a user and applies the foreign keys that reference
+// users: their imports lose created_by, and their exports and pending
+// deletion go with them.
+func (s *Store) removeUser(userID int64) {
+	delete(s.users, userID)
+	delete(s.deletions, userID)
+
+	for id, imp := range s.imports {
+		if imp.CreatedBy != nil && *imp.CreatedBy == userID {
+			imp.CreatedBy = nil
+			s.imports[id] = imp
+		}
+	}
+
+	for id, export := range s.exports {
+		if export.UserID == userID {
+			delete(s.exports, id)
+			delete(s.exportFiles, id)
+		}
+	}
+}
+
+// sortedDeletions returns pending deletions, the soonest due first.
+func (s *Store) sortedDeletions() []store.AccountDeletion {
+	deletions := slices.Collect(maps.Values(s.deletions))
+	slices.SortFunc(deletions, func(a, b store.AccountDeletion) int {
+		return cmp.Or(a.DeleteAfter.Time.Compare(b.DeleteAfter.Time), cmp.Compare(a.UserID, b.UserID))
+	})
+
+	return deletions
+}
+
+func (s *Store) listEndpoints(include func(store.WebhookEndpoint) bool) []store.WebhookEndpoint {
+	endpoints := make([]store.WebhookEndpoint, 0, len(s.endpoints))
+	for _, endpoint := range s.endpoints {
+		if include(endpoint) {
+			endpoints = append(endpoints, cloneEndpoint(endpoint))
+		}
+	}
+	slices.SortFunc(endpoints, func(a, b store.WebhookEndpoint) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return endpoints
+}
+
+func (s *Store) listUsers(include func(store.User) bool) []store.User {
+	users := make([]store.User, 0, len(s.users))
+	for _, user := range s.users {
+		if include(user) {
+			users = append(users, cloneUser(user))
+		}
+	}
+
+	// Newest first; IDs break ties between users created in the same instant.
+	slices.SortFunc(users, func(a, b store.User) int {
+		if c := b.CreatedAt.Time.Compare(a.CreatedAt.Time); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	return users
+}
+
+// uniqueJobKeyTaken reports whether a pending or running job other than
+// exceptID holds key.
+func (s *Store) uniqueJobKeyTaken(key *string, exceptID int64) bool {
+	if key == nil {
+		return false
+	}
+
+	for _, job := range s.jobs {
+		if job.ID != exceptID && job.UniqueKey != nil && *job.UniqueKey == *key &&
+			(job.State == "pending" || job.State == "running") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// updateRunningJob applies update to job id if it is running, then unlocks it.
+func (s *Store) updateRunningJob(id int64, update func(job *store.Job, now pgtype.Timestamptz)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || job.State != "running" {
+		return
+	}
+
+	now := s.timestamp()
+	update(&job, now)
+	job.LockedAt = pgtype.Timestamptz{}
+	job.LockedBy = nil
+	job.UpdatedAt = now
+	s.jobs[id] = job
+}
+
+func (s *Store) timestamp() pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: s.now(), Valid: true}
+}
+
+func isActive(user store.User) bool {
+	return user.IsActive != nil && *user.IsActive && !isDeleted(user)
+}
+
+func matchesSearch(user store.User, arg store.SearchUsersParams) bool {
+	if arg.Status != nil && (*arg.Status == "active") != (user.IsActive != nil && *user.IsActive) {
+		return false
+	}
+	if arg.Query == nil {
+		return true
+	}
+
+	query := strings.ToLower(*arg.Query)
+	return strings.Contains(strings.ToLower(user.Email), query) || strings.Contains(strings.ToLower(user.Name), query)
+}
+
+func isDeleted(user store.User) bool {
+	return user.DeletedAt.Valid
+}
+
+func cloneUser(user store.User) store.User {
+	user.AvatarUrl = cloneString(user.AvatarUrl)
+	user.Bio = cloneString(user.Bio)
+	if user.IsActive != nil {
+		active := *user.IsActive
+		user.IsActive = &active
+	}
+
+	return user
+}
+
+func cloneJob(job store.Job) store.Job {
+	job.Payload = slices.Clone(job.Payload)
+	job.UniqueKey = cloneString(job.UniqueKey)
+	job.LockedBy = cloneString(job.LockedBy)
+	job.LastError = cloneString(job.LastError)
+
+	return job
+}
+
+func cloneEndpoint(endpoint store.WebhookEndpoint) store.WebhookEndpoint {
+	endpoint.Description = cloneString(endpoint.Description)
+	endpoint.Events = slices.Clone(endpoint.Events)
+	endpoint.DisabledReason = cloneString(endpoint.DisabledReason)
+
+	return endpoint
+}
+
+func cloneDelivery(delivery store.WebhookDelivery) store.WebhookDelivery {
+	delivery.Payload = slices.Clone(delivery.Payload)
+	delivery.ResponseStatus = cloneInt32(delivery.ResponseStatus)
+	delivery.ResponseBody = cloneString(delivery.ResponseBody)
+	delivery.LastError = cloneString(delivery.LastError)
+	delivery.DurationMs = cloneInt64(delivery.DurationMs)
+	delivery.RedeliveryOf = cloneInt64(delivery.RedeliveryOf)
+
+	return delivery
+}
+
+func cloneImport(imp store.UserImport) store.UserImport {
+	imp.CreatedBy = cloneInt64(imp.CreatedBy)
+	imp.RowErrors = slices.Clone(imp.RowErrors)
+	imp.LastError = cloneString(imp.LastError)
+
+	return imp
+}
+
+func cloneExport(export store.DataExport) store.DataExport {
+	export.LastError = cloneString(export.LastError)
+
+	return export
+}
+
+func cloneAuditEvent(event store.AuditEvent) store.AuditEvent {
+	event.ActorID = cloneInt64(event.ActorID)
+	event.TargetType = cloneString(event.TargetType)
+	event.TargetID = cloneInt64(event.TargetID)
+	event.Ip = cloneString(event.Ip)
+	event.UserAgent = cloneString(event.UserAgent)
+	event.RequestID = cloneString(event.RequestID)
+	event.Before = slices.Clone(event.Before)
+	event.After = slices.Clone(event.After)
+
+	return event
+}
+
+func cloneString(value *string) *string {
+	if value == nil {
+		return nil
+	}
+
+	clone := *value
+	return &clone
+}
+
+func cloneInt64(value *int64) *int64 {
+	if value == nil {
+		return nil
+	}
+
+	clone := *value
+	return &clone
+}
+
+func cloneInt32(value *int32) *int32 {
+	if value == nil {
+		return nil
+	}
+
+	clone := *value
+	return &clone
+}
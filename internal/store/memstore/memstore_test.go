@@ -0,0 +1,349 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestCreateUserEnforcesUniqueEmail(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New()
+
+	first, err := s.CreateUser(ctx, store.CreateUserParams{Email: "ada@example.com", Name: "Ada"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if first.IsActive == nil || !*first.IsActive {
+		t.Fatal("expected new users to be active")
+	}
+
+	_, err = s.CreateUser(ctx, store.CreateUserParams{Email: "ada@example.com", Name: "Imposter"})
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" || pgErr.ConstraintName != "users_email_key" {
+		t.Fatalf("duplicate CreateUser() error = %v, want users_email_key unique violation", err)
+	}
+
+	second, err := s.CreateUser(ctx, store.CreateUserParams{Email: "grace@example.com", Name: "Grace"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	_, err = s.UpdateUser(ctx, store.UpdateUserParams{ID: second.ID, Email: first.Email, Name: second.Name, Version: second.Version})
+	if !errors.As(err, &pgErr) {
+		t.Fatalf("UpdateUser() to a taken email error = %v, want unique violation", err)
+	}
+
+	if _, err := s.UpdateUser(ctx, store.UpdateUserParams{ID: first.ID, Email: first.Email, Name: "Ada L.", Version: first.Version}); err != nil {
+		t.Fatalf("UpdateUser() keeping own email error = %v", err)
+	}
+}
+
+func TestDeactivatedUsersAreHiddenFromListings(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New()
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if _, err := s.CreateUser(ctx, store.CreateUserParams{Email: email, Name: email}); err != nil {
+			t.Fatalf("CreateUser(%q) error = %v", email, err)
+		}
+	}
+
+	if err := s.DeactivateUser(ctx, 2); err != nil {
+		t.Fatalf("DeactivateUser() error = %v", err)
+	}
+
+	count, err := s.CountUsers(ctx)
+	if err != nil || count != 2 {
+		t.Fatalf("CountUsers() = %d, %v; want 2", count, err)
+	}
+
+	active, _ := s.ListUsers(ctx)
+	if len(active) != 2 || active[0].ID != 3 || active[1].ID != 1 {
+		t.Fatalf("ListUsers() = %+v, want users 3 and 1 newest first", active)
+	}
+
+	all, _ := s.ListAllUsers(ctx)
+	if len(all) != 3 {
+		t.Fatalf("ListAllUsers() returned %d users, want 3", len(all))
+	}
+
+	// Inactive users can still be looked up, e.g. to reject their login.
+	user, err := s.GetUserByEmail(ctx, "b@example.com")
+	if err != nil || user.IsActive == nil || *user.IsActive {
+		t.Fatalf("GetUserByEmail() = %+v, %v; want inactive user", user, err)
+	}
+
+	if err := s.DeleteUser(ctx, 1); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, err := s.GetUser(ctx, 1); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("GetUser() after delete error = %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func TestRunInTxRollsBackOnError(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New()
+	errAbort := errors.New("abort")
+
+	err := s.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		if _, err := q.CreateUser(ctx, store.CreateUserParams{Email: "ada@example.com", Name: "Ada"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("RunInTx() error = %v, want %v", err, errAbort)
+	}
+
+	if count, _ := s.CountUsers(ctx); count != 0 {
+		t.Fatalf("CountUsers() after rollback = %d, want 0", count)
+	}
+
+	err = s.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		_, err := q.CreateUser(ctx, store.CreateUserParams{Email: "ada@example.com", Name: "Ada"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RunInTx() error = %v", err)
+	}
+	if count, _ := s.CountUsers(ctx); count != 1 {
+		t.Fatalf("CountUsers() after commit = %d, want 1", count)
+	}
+}
+
+func TestUpdateUserChecksVersion(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New()
+
+	user, err := s.CreateUser(ctx, store.CreateUserParams{Email: "ada@example.com", Name: "Ada"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if user.Version != 1 {
+		t.Fatalf("new user version = %d, want 1", user.Version)
+	}
+
+	updated, err := s.UpdateUser(ctx, store.UpdateUserParams{ID: user.ID, Email: user.Email, Name: "Ada L.", Version: 1})
+	if err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+	if updated.Version != 2 {
+		t.Fatalf("updated version = %d, want 2", updated.Version)
+	}
+
+	_, err = s.UpdateUserPassword(ctx, store.UpdateUserPasswordParams{ID: user.ID, Email: user.Email, Name: "Stale", Version: 1})
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("UpdateUserPassword() with stale version error = %v, want pgx.ErrNoRows", err)
+	}
+
+	current, _ := s.GetUser(ctx, user.ID)
+	if current.Name != "Ada L." || current.Version != 2 {
+		t.Fatalf("GetUser() = %+v, want stale write to be rejected", current)
+	}
+}
+
+func TestTrashHidesRestoresAndPurgesUsers(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	ada, _ := s.CreateUser(ctx, store.CreateUserParams{Email: "ada@example.com", Name: "Ada"})
+	grace, _ := s.CreateUser(ctx, store.CreateUserParams{Email: "grace@example.com", Name: "Grace"})
+
+	if n, err := s.SoftDeleteUser(ctx, ada.ID); err != nil || n != 1 {
+		t.Fatalf("SoftDeleteUser() = %d, %v; want 1", n, err)
+	}
+	if n, _ := s.SoftDeleteUser(ctx, ada.ID); n != 0 {
+		t.Fatalf("second SoftDeleteUser() = %d, want 0", n)
+	}
+
+	if _, err := s.GetUserByEmail(ctx, ada.Email); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("GetUserByEmail() on trashed user error = %v, want pgx.ErrNoRows", err)
+	}
+	if all, _ := s.ListAllUsers(ctx); len(all) != 1 || all[0].ID != grace.ID {
+		t.Fatalf("ListAllUsers() = %+v, want only Grace", all)
+	}
+	if count, _ := s.CountUsers(ctx); count != 1 {
+		t.Fatalf("CountUsers() = %d, want 1", count)
+	}
+	// The email stays reserved while the user can still be restored.
+	if _, err := s.CreateUser(ctx, store.CreateUserParams{Email: ada.Email, Name: "Ada again"}); err == nil {
+		t.Fatal("CreateUser() reused a trashed user's email")
+	}
+
+	if n, _ := s.RestoreUser(ctx, ada.ID); n != 1 {
+		t.Fatalf("RestoreUser() = %d, want 1", n)
+	}
+	if _, err := s.GetUser(ctx, ada.ID); err != nil {
+		t.Fatalf("GetUser() after restore error = %v", err)
+	}
+
+	s.SoftDeleteUser(ctx, ada.ID)
+	now = now.Add(time.Hour)
+	s.SoftDeleteUser(ctx, grace.ID)
+
+	trash, _ := s.ListDeletedUsers(ctx)
+	if len(trash) != 2 || trash[0].ID != grace.ID {
+		t.Fatalf("ListDeletedUsers() = %+v, want Grace then Ada", trash)
+	}
+
+	purged, err := s.PurgeDeletedUsers(ctx, pgtype.Timestamptz{Time: now, Valid: true})
+	if err != nil || len(purged) != 1 || purged[0].ID != ada.ID {
+		t.Fatalf("PurgeDeletedUsers() = %v, %v; want only Ada", purged, err)
+	}
+	if trash, _ := s.ListDeletedUsers(ctx); len(trash) != 1 {
+		t.Fatalf("ListDeletedUsers() after purge returned %d users, want 1", len(trash))
+	}
+}
+
+func TestAuditEventsFilterAndRollBack(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New()
+
+	admin, _ := s.CreateUser(ctx, store.CreateUserParams{Email: "admin@example.com", Name: "Admin"})
+	target := int64(42)
+	for _, action := range []string{"auth.login", "user.update", "user.delete"} {
+		if err := s.CreateAuditEvent(ctx, store.CreateAuditEventParams{ActorID: &admin.ID, Action: action, TargetID: &target}); err != nil {
+			t.Fatalf("CreateAuditEvent() error = %v", err)
+		}
+	}
+
+	_ = s.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		_ = q.CreateAuditEvent(ctx, store.CreateAuditEventParams{Action: "user.create"})
+		return errors.New("abort")
+	})
+
+	all, _ := s.ListAuditEvents(ctx, store.ListAuditEventsParams{MaxRows: 10})
+	if len(all) != 3 || all[0].Action != "user.delete" {
+		t.Fatalf("ListAuditEvents() = %+v, want 3 events newest first without the rolled back one", all)
+	}
+	if all[0].ActorEmail == nil || *all[0].ActorEmail != admin.Email {
+		t.Fatalf("ActorEmail = %v, want %q", all[0].ActorEmail, admin.Email)
+	}
+
+	action := "user.update"
+	filtered, _ := s.ListAuditEvents(ctx, store.ListAuditEventsParams{Action: &action, MaxRows: 10})
+	if len(filtered) != 1 || filtered[0].ID != 2 {
+		t.Fatalf("ListAuditEvents(action) = %+v, want event 2", filtered)
+	}
+
+	before := int64(3)
+	page, _ := s.ListAuditEvents(ctx, store.ListAuditEventsParams{BeforeID: &before, MaxRows: 1})
+	if len(page) != 1 || page[0].ID != 2 {
+		t.Fatalf("ListAuditEvents(before 3, limit 1) = %+v, want event 2", page)
+	}
+}
+
+func TestJobsClaimDueJobsAndRetryDeadOnes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	enqueue := func(kind string, runAt time.Time) store.Job {
+		t.Helper()
+		job, err := s.EnqueueJob(ctx, store.EnqueueJobParams{
+			Kind:        kind,
+			MaxAttempts: 1,
+			RunAt:       pgtype.Timestamptz{Time: runAt, Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("EnqueueJob() error = %v", err)
+		}
+		return job
+	}
+
+	later := enqueue("mail", now.Add(time.Minute))
+	due := enqueue("mail", now.Add(-time.Minute))
+	enqueue("other", now.Add(-time.Hour))
+
+	worker := "w1"
+	claimed, err := s.ClaimJobs(ctx, store.ClaimJobsParams{Worker: &worker, Kinds: []string{"mail"}, MaxJobs: 5})
+	if err != nil {
+		t.Fatalf("ClaimJobs() error = %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != due.ID || claimed[0].State != "running" || claimed[0].Attempts != 1 {
+		t.Fatalf("ClaimJobs() = %+v, want only the due mail job, running", claimed)
+	}
+
+	if err := s.FailJob(ctx, store.FailJobParams{LastError: "boom", ID: due.ID}); err != nil {
+		t.Fatalf("FailJob() error = %v", err)
+	}
+	if rows, err := s.CancelJob(ctx, due.ID); err != nil || rows != 0 {
+		t.Fatalf("CancelJob(dead) = %d, %v; want 0 rows", rows, err)
+	}
+	if rows, err := s.RetryJob(ctx, due.ID); err != nil || rows != 1 {
+		t.Fatalf("RetryJob(dead) = %d, %v; want 1 row", rows, err)
+	}
+	if rows, err := s.RetryJob(ctx, later.ID); err != nil || rows != 0 {
+		t.Fatalf("RetryJob(pending) = %d, %v; want 0 rows", rows, err)
+	}
+
+	counts, err := s.CountJobsByState(ctx)
+	if err != nil {
+		t.Fatalf("CountJobsByState() error = %v", err)
+	}
+	if len(counts) != 1 || counts[0].State != "pending" || counts[0].Count != 3 {
+		t.Fatalf("CountJobsByState() = %+v, want 3 pending", counts)
+	}
+}
+
+func TestAuditPurgeNeedsTransactionOptIn(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := New()
+
+	for _, action := range []string{"auth.login", "auth.logout"} {
+		if err := s.CreateAuditEvent(ctx, store.CreateAuditEventParams{Action: action}); err != nil {
+			t.Fatalf("CreateAuditEvent() error = %v", err)
+		}
+	}
+	cutoff := pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}
+
+	var pgErr *pgconn.PgError
+	if _, err := s.PurgeAuditEvents(ctx, cutoff); !errors.As(err, &pgErr) {
+		t.Fatalf("PurgeAuditEvents() without opt-in error = %v, want the append-only error", err)
+	}
+
+	var purged int64
+	err := s.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		if err := q.AllowAuditPurge(ctx); err != nil {
+			return err
+		}
+		var err error
+		purged, err = q.PurgeAuditEvents(ctx, cutoff)
+		return err
+	})
+	if err != nil || purged != 2 {
+		t.Fatalf("PurgeAuditEvents() in opted-in tx = %d, %v, want 2 events", purged, err)
+	}
+
+	// The opt-in ends with its transaction.
+	if _, err := s.PurgeAuditEvents(ctx, cutoff); !errors.As(err, &pgErr) {
+		t.Fatalf("PurgeAuditEvents() after the tx error = %v, want the append-only error", err)
+	}
+}
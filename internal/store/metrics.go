@@ -0,0 +1,100 @@
+package store
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStatsCollector exports pgxpool statistics as Prometheus metrics.
+type PoolStatsCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns *prometheus.Desc
+	idleConns     *prometheus.Desc
+	totalConns    *prometheus.Desc
+	maxConns      *prometheus.Desc
+	acquireCount  *prometheus.Desc
+	acquireWait   *prometheus.Desc
+	emptyAcquire  *prometheus.Desc
+}
+
+// NewPoolStatsCollector creates a collector for the given pool.
+func NewPoolStatsCollector(pool *pgxpool.Pool) *PoolStatsCollector {
+	return &PoolStatsCollector{
+		pool:          pool,
+		acquiredConns: prometheus.NewDesc("db_pool_acquired_connections", "Connections currently checked out of the pool.", nil, nil),
+		idleConns:     prometheus.NewDesc("db_pool_idle_connections", "Idle connections in the pool.", nil, nil),
+		totalConns:    prometheus.NewDesc("db_pool_total_connections", "Open connections in the pool.", nil, nil),
+		maxConns:      prometheus.NewDesc("db_pool_max_connections", "Maximum size of the pool.", nil, nil),
+		acquireCount:  prometheus.NewDesc("db_pool_acquires_total", "Successful connection acquires.", nil, nil),
+		acquireWait:   prometheus.NewDesc("db_pool_acquire_wait_seconds_total", "Time spent waiting to acquire a connection.", nil, nil),
+		emptyAcquire:  prometheus.NewDesc("db_pool_empty_acquires_total", "Acquires that had to wait because the pool was empty.", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *PoolStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquireCount
+	ch <- c.acquireWait
+	ch <- c.emptyAcquire
+}
+
+// Collect implements prometheus.Collector.
+func (c *PoolStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireWait, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquire, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+}
+
+// QueryStatsCollector exports per-query statistics as Prometheus metrics.
+type QueryStatsCollector struct {
+	stats *QueryStats
+
+	duration *prometheus.Desc
+	errors   *prometheus.Desc
+	slow     *prometheus.Desc
+}
+
+// NewQueryStatsCollector creates a collector for the given statistics.
+func NewQueryStatsCollector(stats *QueryStats) *QueryStatsCollector {
+	labels := []string{"query"}
+
+	return &QueryStatsCollector{
+		stats:    stats,
+		duration: prometheus.NewDesc("db_query_duration_seconds", "Query latency by sqlc query name; quantiles cover recent executions.", labels, nil),
+		errors:   prometheus.NewDesc("db_query_errors_total", "Queries that returned an error.", labels, nil),
+		slow:     prometheus.NewDesc("db_query_slow_total", "Queries that exceeded the slow query threshold.", labels, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *QueryStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.duration
+	ch <- c.errors
+	ch <- c.slow
+}
+
+// Collect implements prometheus.Collector.
+func (c *QueryStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stat := range c.stats.Snapshot() {
+		quantiles := map[float64]float64{
+			0.5:  stat.P50.Seconds(),
+			0.95: stat.P95.Seconds(),
+			0.99: stat.P99.Seconds(),
+		}
+
+		ch <- prometheus.MustNewConstSummary(c.duration, stat.Count, stat.Total.Seconds(), quantiles, stat.Name)
+		ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(stat.Errors), stat.Name)
+		ch <- prometheus.MustNewConstMetric(c.slow, prometheus.CounterValue, float64(stat.Slow), stat.Name)
+	}
+}
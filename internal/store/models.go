@@ -8,6 +8,90 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AccountDeletion struct {
+	UserID      int64              `db:"user_id" json:"user_id"`
+	RequestedAt pgtype.Timestamptz `db:"requested_at" json:"requested_at"`
+	DeleteAfter pgtype.Timestamptz `db:"delete_after" json:"delete_after"`
+}
+
+type AuditEvent struct {
+	ID         int64              `db:"id" json:"id"`
+	OccurredAt pgtype.Timestamptz `db:"occurred_at" json:"occurred_at"`
+	ActorID    *int64             `db:"actor_id" json:"actor_id"`
+	Action     string             `db:"action" json:"action"`
+	TargetType *string            `db:"target_type" json:"target_type"`
+	TargetID   *int64             `db:"target_id" json:"target_id"`
+	Ip         *string            `db:"ip" json:"ip"`
+	UserAgent  *string            `db:"user_agent" json:"user_agent"`
+	RequestID  *string            `db:"request_id" json:"request_id"`
+	Before     []byte             `db:"before" json:"before"`
+	After      []byte             `db:"after" json:"after"`
+}
+
+type DataExport struct {
+	ID         int64              `db:"id" json:"id"`
+	UserID     int64              `db:"user_id" json:"user_id"`
+	Format     string             `db:"format" json:"format"`
+	State      string             `db:"state" json:"state"`
+	SizeBytes  int64              `db:"size_bytes" json:"size_bytes"`
+	LastError  *string            `db:"last_error" json:"last_error"`
+	CreatedAt  pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	FinishedAt pgtype.Timestamptz `db:"finished_at" json:"finished_at"`
+	ExpiresAt  pgtype.Timestamptz `db:"expires_at" json:"expires_at"`
+}
+
+type DataExportFile struct {
+	ExportID int64  `db:"export_id" json:"export_id"`
+	Data     []byte `db:"data" json:"data"`
+}
+
+type EventPayload struct {
+	ID        int64              `db:"id" json:"id"`
+	Topic     string             `db:"topic" json:"topic"`
+	Data      []byte             `db:"data" json:"data"`
+	CreatedAt pgtype.Timestamptz `db:"created_at" json:"created_at"`
+}
+
+type Job struct {
+	ID          int64              `db:"id" json:"id"`
+	Kind        string             `db:"kind" json:"kind"`
+	Payload     []byte             `db:"payload" json:"payload"`
+	State       string             `db:"state" json:"state"`
+	Attempts    int32              `db:"attempts" json:"attempts"`
+	MaxAttempts int32              `db:"max_attempts" json:"max_attempts"`
+	UniqueKey   *string            `db:"unique_key" json:"unique_key"`
+	RunAt       pgtype.Timestamptz `db:"run_at" json:"run_at"`
+	LockedAt    pgtype.Timestamptz `db:"locked_at" json:"locked_at"`
+	LockedBy    *string            `db:"locked_by" json:"locked_by"`
+	LastError   *string            `db:"last_error" json:"last_error"`
+	CreatedAt   pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+	FinishedAt  pgtype.Timestamptz `db:"finished_at" json:"finished_at"`
+}
+
+type RateLimitBucket struct {
+	Key       string             `db:"key" json:"key"`
+	Tokens    float64            `db:"tokens" json:"tokens"`
+	Allowed   bool               `db:"allowed" json:"allowed"`
+	UpdatedAt pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+	ExpiresAt pgtype.Timestamptz `db:"expires_at" json:"expires_at"`
+}
+
+type ScheduledTask struct {
+	Name           string             `db:"name" json:"name"`
+	Schedule       string             `db:"schedule" json:"schedule"`
+	LastTick       pgtype.Timestamptz `db:"last_tick" json:"last_tick"`
+	LastStartedAt  pgtype.Timestamptz `db:"last_started_at" json:"last_started_at"`
+	LastFinishedAt pgtype.Timestamptz `db:"last_finished_at" json:"last_finished_at"`
+	LastStatus     *string            `db:"last_status" json:"last_status"`
+	LastError      *string            `db:"last_error" json:"last_error"`
+	LastDurationMs *int64             `db:"last_duration_ms" json:"last_duration_ms"`
+	NextRunAt      pgtype.Timestamptz `db:"next_run_at" json:"next_run_at"`
+	Runs           int64              `db:"runs" json:"runs"`
+	Failures       int64              `db:"failures" json:"failures"`
+	UpdatedAt      pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+}
+
 type Session struct {
 	Token  string             `db:"token" json:"token"`
 	Data   []byte             `db:"data" json:"data"`
@@ -24,4 +108,62 @@ type User struct {
 	IsActive     *bool              `db:"is_active" json:"is_active"`
 	CreatedAt    pgtype.Timestamptz `db:"created_at" json:"created_at"`
 	UpdatedAt    pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+	Version      int64              `db:"version" json:"version"`
+	DeletedAt    pgtype.Timestamptz `db:"deleted_at" json:"deleted_at"`
+	IsAdmin      bool               `db:"is_admin" json:"is_admin"`
+}
+
+type UserImport struct {
+	ID            int64              `db:"id" json:"id"`
+	CreatedBy     *int64             `db:"created_by" json:"created_by"`
+	Filename      string             `db:"filename" json:"filename"`
+	Format        string             `db:"format" json:"format"`
+	Mode          string             `db:"mode" json:"mode"`
+	DryRun        bool               `db:"dry_run" json:"dry_run"`
+	State         string             `db:"state" json:"state"`
+	TotalRows     int32              `db:"total_rows" json:"total_rows"`
+	ProcessedRows int32              `db:"processed_rows" json:"processed_rows"`
+	CreatedRows   int32              `db:"created_rows" json:"created_rows"`
+	FailedRows    int32              `db:"failed_rows" json:"failed_rows"`
+	RowErrors     []byte             `db:"row_errors" json:"row_errors"`
+	LastError     *string            `db:"last_error" json:"last_error"`
+	CreatedAt     pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	StartedAt     pgtype.Timestamptz `db:"started_at" json:"started_at"`
+	FinishedAt    pgtype.Timestamptz `db:"finished_at" json:"finished_at"`
+}
+
+type UserImportFile struct {
+	ImportID int64  `db:"import_id" json:"import_id"`
+	Data     []byte `db:"data" json:"data"`
+}
+
+type WebhookDelivery struct {
+	ID             int64              `db:"id" json:"id"`
+	EndpointID     int64              `db:"endpoint_id" json:"endpoint_id"`
+	Event          string             `db:"event" json:"event"`
+	EventID        string             `db:"event_id" json:"event_id"`
+	Payload        []byte             `db:"payload" json:"payload"`
+	State          string             `db:"state" json:"state"`
+	Attempts       int32              `db:"attempts" json:"attempts"`
+	ResponseStatus *int32             `db:"response_status" json:"response_status"`
+	ResponseBody   *string            `db:"response_body" json:"response_body"`
+	LastError      *string            `db:"last_error" json:"last_error"`
+	DurationMs     *int64             `db:"duration_ms" json:"duration_ms"`
+	RedeliveryOf   *int64             `db:"redelivery_of" json:"redelivery_of"`
+	CreatedAt      pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	LastAttemptAt  pgtype.Timestamptz `db:"last_attempt_at" json:"last_attempt_at"`
+	DeliveredAt    pgtype.Timestamptz `db:"delivered_at" json:"delivered_at"`
+}
+
+type WebhookEndpoint struct {
+	ID                  int64              `db:"id" json:"id"`
+	Url                 string             `db:"url" json:"url"`
+	Description         *string            `db:"description" json:"description"`
+	Secret              string             `db:"secret" json:"secret"`
+	Events              []string           `db:"events" json:"events"`
+	Enabled             bool               `db:"enabled" json:"enabled"`
+	ConsecutiveFailures int32              `db:"consecutive_failures" json:"consecutive_failures"`
+	DisabledReason      *string            `db:"disabled_reason" json:"disabled_reason"`
+	CreatedAt           pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt           pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
 }
@@ -1,16 +1,27 @@
 -- name: GetUser :one
-SELECT * FROM users WHERE id = $1 LIMIT 1;
+SELECT * FROM users WHERE id = $1 AND deleted_at IS NULL LIMIT 1;
 
 -- name: GetUserByEmail :one
-SELECT * FROM users WHERE email = $1 LIMIT 1;
+SELECT * FROM users WHERE email = $1 AND deleted_at IS NULL LIMIT 1;
 
 -- name: ListUsers :many
 SELECT * FROM users 
-WHERE is_active = true 
+WHERE is_active = true AND deleted_at IS NULL
 ORDER BY created_at DESC;
 
 -- name: ListAllUsers :many
-SELECT * FROM users ORDER BY created_at DESC;
+SELECT * FROM users WHERE deleted_at IS NULL ORDER BY created_at DESC;
+
+-- name: SearchUsers :many
+-- Lists users who are not deleted, newest first. A NULL status matches active and inactive users; a NULL query matches every name and email.
+SELECT * FROM users
+WHERE deleted_at IS NULL
+  AND (sqlc.narg(status)::text IS NULL
+       OR (sqlc.narg(status)::text = 'active') = COALESCE(is_active, false))
+  AND (sqlc.narg(query)::text IS NULL
+       OR strpos(lower(email), lower(sqlc.narg(query)::text)) > 0
+       OR strpos(lower(name), lower(sqlc.narg(query)::text)) > 0)
+ORDER BY created_at DESC, id DESC;
 
 -- name: CreateUser :one
 INSERT INTO users (email, name, bio, avatar_url, password_hash) 
@@ -19,23 +30,407 @@ RETURNING *;
 
 -- name: UpdateUser :one
 UPDATE users 
-SET email = $1, name = $2, bio = $3, avatar_url = $4, updated_at = CURRENT_TIMESTAMP
-WHERE id = $5
+SET email = $1, name = $2, bio = $3, avatar_url = $4, version = version + 1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $5 AND version = $6 AND deleted_at IS NULL
 RETURNING *;
 
 -- name: UpdateUserPassword :one
 UPDATE users 
-SET email = $1, name = $2, bio = $3, avatar_url = $4, password_hash = $5, updated_at = CURRENT_TIMESTAMP
-WHERE id = $6
+SET email = $1, name = $2, bio = $3, avatar_url = $4, password_hash = $5, version = version + 1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $6 AND version = $7 AND deleted_at IS NULL
 RETURNING *;
 
 -- name: DeactivateUser :exec
 UPDATE users 
 SET is_active = false, updated_at = CURRENT_TIMESTAMP
-WHERE id = $1;
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: ReactivateUser :execrows
+UPDATE users
+SET is_active = true, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: SoftDeleteUser :execrows
+UPDATE users
+SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: RestoreUser :execrows
+UPDATE users
+SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NOT NULL;
+
+-- name: ListDeletedUsers :many
+SELECT * FROM users
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
+
+-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deleted_at < sqlc.arg(deleted_before)
+RETURNING id, avatar_url;
 
 -- name: DeleteUser :exec
 DELETE FROM users WHERE id = $1;
 
 -- name: CountUsers :one
-SELECT COUNT(*) FROM users WHERE is_active = true;
+SELECT COUNT(*) FROM users WHERE is_active = true AND deleted_at IS NULL;
+
+-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at, expires_at)
+VALUES (
+    sqlc.arg(key),
+    sqlc.arg(capacity)::double precision - 1,
+    true,
+    CURRENT_TIMESTAMP,
+    CURRENT_TIMESTAMP + make_interval(secs => sqlc.arg(ttl_seconds)::double precision)
+)
+ON CONFLICT (key) DO UPDATE
+SET tokens = CASE
+        WHEN LEAST(sqlc.arg(capacity)::double precision, b.tokens + EXTRACT(EPOCH FROM (CURRENT_TIMESTAMP - b.updated_at))::double precision * sqlc.arg(refill_per_second)::double precision) >= 1
+        THEN LEAST(sqlc.arg(capacity)::double precision, b.tokens + EXTRACT(EPOCH FROM (CURRENT_TIMESTAMP - b.updated_at))::double precision * sqlc.arg(refill_per_second)::double precision) - 1
+        ELSE LEAST(sqlc.arg(capacity)::double precision, b.tokens + EXTRACT(EPOCH FROM (CURRENT_TIMESTAMP - b.updated_at))::double precision * sqlc.arg(refill_per_second)::double precision)
+    END,
+    allowed = LEAST(sqlc.arg(capacity)::double precision, b.tokens + EXTRACT(EPOCH FROM (CURRENT_TIMESTAMP - b.updated_at))::double precision * sqlc.arg(refill_per_second)::double precision) >= 1,
+    updated_at = CURRENT_TIMESTAMP,
+    expires_at = EXCLUDED.expires_at
+RETURNING tokens, allowed;
+
+-- name: DeleteExpiredRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets WHERE expires_at < CURRENT_TIMESTAMP;
+
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (actor_id, action, target_type, target_id, ip, user_agent, request_id, before, after)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+
+-- name: ListAuditEvents :many
+SELECT a.id, a.occurred_at, a.actor_id, u.email AS actor_email, a.action, a.target_type, a.target_id,
+    a.ip, a.user_agent, a.request_id, a.before, a.after
+FROM audit_events a
+LEFT JOIN users u ON u.id = a.actor_id
+WHERE (sqlc.narg(action)::text IS NULL OR a.action = sqlc.narg(action))
+  AND (sqlc.narg(actor_email)::text IS NULL OR u.email = sqlc.narg(actor_email))
+  AND (sqlc.narg(target_id)::bigint IS NULL OR a.target_id = sqlc.narg(target_id))
+  AND (sqlc.narg(before_id)::bigint IS NULL OR a.id < sqlc.narg(before_id))
+ORDER BY a.id DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: EnqueueJob :one
+INSERT INTO jobs (kind, payload, max_attempts, unique_key, run_at)
+VALUES (
+    sqlc.arg(kind),
+    sqlc.arg(payload),
+    sqlc.arg(max_attempts),
+    sqlc.narg(unique_key),
+    COALESCE(sqlc.narg(run_at)::timestamptz, CURRENT_TIMESTAMP)
+)
+ON CONFLICT (unique_key) WHERE unique_key IS NOT NULL AND state IN ('pending', 'running') DO NOTHING
+RETURNING *;
+
+-- name: ClaimJobs :many
+UPDATE jobs
+SET state = 'running', attempts = attempts + 1, locked_at = CURRENT_TIMESTAMP,
+    locked_by = sqlc.arg(worker), updated_at = CURRENT_TIMESTAMP
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE state = 'pending' AND run_at <= CURRENT_TIMESTAMP AND kind = ANY(sqlc.arg(kinds)::text[])
+    ORDER BY run_at, id
+    LIMIT sqlc.arg(max_jobs)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET state = 'succeeded', locked_at = NULL, locked_by = NULL, last_error = NULL,
+    finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND state = 'running';
+
+-- name: ScheduleJobRetry :exec
+UPDATE jobs
+SET state = 'pending', run_at = sqlc.arg(run_at), last_error = sqlc.arg(last_error)::text,
+    locked_at = NULL, locked_by = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND state = 'running';
+
+-- name: FailJob :exec
+UPDATE jobs
+SET state = 'dead', last_error = sqlc.arg(last_error)::text, locked_at = NULL, locked_by = NULL,
+    finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND state = 'running';
+
+-- name: ReleaseJob :exec
+UPDATE jobs
+SET state = 'pending', attempts = attempts - 1, locked_at = NULL, locked_by = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND state = 'running';
+
+-- name: RescueStaleJobs :execrows
+UPDATE jobs
+SET state = 'pending', last_error = 'worker stopped before finishing the job', locked_at = NULL,
+    locked_by = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE state = 'running' AND locked_at < sqlc.arg(locked_before);
+
+-- name: RetryJob :execrows
+UPDATE jobs
+SET state = 'pending', attempts = 0, run_at = CURRENT_TIMESTAMP, finished_at = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND state IN ('dead', 'cancelled');
+
+-- name: CancelJob :execrows
+UPDATE jobs
+SET state = 'cancelled', finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND state = 'pending';
+
+-- name: ListJobs :many
+SELECT * FROM jobs
+WHERE (sqlc.narg(state)::text IS NULL OR state = sqlc.narg(state))
+  AND (sqlc.narg(before_id)::bigint IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: CountJobsByState :many
+SELECT state, COUNT(*) AS count FROM jobs GROUP BY state;
+
+-- name: PurgeFinishedJobs :execrows
+DELETE FROM jobs
+WHERE state IN ('succeeded', 'dead', 'cancelled') AND finished_at < sqlc.arg(finished_before);
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expiry < CURRENT_TIMESTAMP;
+
+-- name: AllowAuditPurge :exec
+-- Lets PurgeAuditEvents past the append-only trigger until the transaction ends.
+SELECT set_config('app.audit_purge', 'on', true);
+
+-- name: PurgeAuditEvents :execrows
+DELETE FROM audit_events WHERE occurred_at < sqlc.arg(occurred_before);
+
+-- name: UpsertScheduledTask :exec
+INSERT INTO scheduled_tasks (name, schedule, next_run_at)
+VALUES (sqlc.arg(name), sqlc.arg(schedule), sqlc.arg(next_run_at))
+ON CONFLICT (name) DO UPDATE
+SET schedule = EXCLUDED.schedule, next_run_at = EXCLUDED.next_run_at, updated_at = CURRENT_TIMESTAMP;
+
+-- name: ClaimScheduledTick :execrows
+-- Claims a tick for one replica; a tick another replica already ran affects no rows.
+UPDATE scheduled_tasks
+SET last_tick = sqlc.arg(tick), last_started_at = CURRENT_TIMESTAMP, last_status = 'running',
+    updated_at = CURRENT_TIMESTAMP
+WHERE name = sqlc.arg(name) AND (last_tick IS NULL OR last_tick < sqlc.arg(tick));
+
+-- name: FinishScheduledTask :exec
+UPDATE scheduled_tasks
+SET last_status = sqlc.arg(status)::text, last_error = sqlc.narg(last_error),
+    last_finished_at = CURRENT_TIMESTAMP, last_duration_ms = sqlc.arg(duration_ms),
+    next_run_at = sqlc.arg(next_run_at), runs = runs + 1,
+    failures = failures + CASE WHEN sqlc.arg(status)::text = 'failed' THEN 1 ELSE 0 END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE name = sqlc.arg(name);
+
+-- name: ListScheduledTasks :many
+SELECT * FROM scheduled_tasks ORDER BY name;
+
+-- name: Notify :exec
+-- Sends payload to the channel's listeners once the transaction commits.
+SELECT pg_notify(sqlc.arg(channel)::text, sqlc.arg(payload)::text);
+
+-- name: CreateEventPayload :one
+INSERT INTO event_payloads (topic, data)
+VALUES ($1, $2)
+RETURNING id;
+
+-- name: GetEventPayload :one
+SELECT data FROM event_payloads WHERE id = $1;
+
+-- name: PurgeEventPayloads :execrows
+DELETE FROM event_payloads WHERE created_at < sqlc.arg(created_before);
+
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (url, description, secret, events)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints WHERE id = $1;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints ORDER BY id;
+
+-- name: ListWebhookEndpointsForEvent :many
+-- Lists the enabled endpoints subscribed to event; an empty events list subscribes to all.
+SELECT * FROM webhook_endpoints
+WHERE enabled AND (cardinality(events) = 0 OR sqlc.arg(event)::text = ANY(events))
+ORDER BY id;
+
+-- name: SetWebhookEndpointEnabled :execrows
+-- Enabling an endpoint also clears its failure count.
+UPDATE webhook_endpoints
+SET enabled = sqlc.arg(enabled),
+    consecutive_failures = CASE WHEN sqlc.arg(enabled) THEN 0 ELSE consecutive_failures END,
+    disabled_reason = sqlc.narg(disabled_reason), updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id);
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints WHERE id = $1;
+
+-- name: RecordWebhookEndpointSuccess :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND consecutive_failures > 0;
+
+-- name: RecordWebhookEndpointFailure :one
+-- Counts a failed attempt and disables the endpoint with reason once disable_after attempts in a row have failed; zero never disables it.
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    enabled = enabled AND (sqlc.arg(disable_after)::integer = 0 OR consecutive_failures + 1 < sqlc.arg(disable_after)::integer),
+    disabled_reason = CASE
+        WHEN enabled AND sqlc.arg(disable_after)::integer > 0 AND consecutive_failures + 1 >= sqlc.arg(disable_after)::integer
+        THEN sqlc.arg(reason)::text
+        ELSE disabled_reason
+    END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (endpoint_id, event, event_id, payload, redelivery_of)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg(endpoint_id)
+  AND (sqlc.narg(before_id)::bigint IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: FinishWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET state = sqlc.arg(state)::text, attempts = attempts + 1,
+    response_status = sqlc.narg(response_status), response_body = sqlc.narg(response_body),
+    last_error = sqlc.narg(last_error), duration_ms = sqlc.arg(duration_ms),
+    last_attempt_at = CURRENT_TIMESTAMP,
+    delivered_at = CASE WHEN sqlc.arg(state)::text = 'succeeded' THEN CURRENT_TIMESTAMP END
+WHERE id = sqlc.arg(id);
+
+-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET state = 'failed', last_error = sqlc.arg(last_error)::text
+WHERE id = sqlc.arg(id) AND state = 'pending';
+
+-- name: PurgeWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries WHERE created_at < sqlc.arg(created_before);
+
+-- name: CreateUserImport :one
+INSERT INTO user_imports (created_by, filename, format, mode, dry_run)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: CreateUserImportFile :exec
+INSERT INTO user_import_files (import_id, data) VALUES ($1, $2);
+
+-- name: GetUserImport :one
+SELECT * FROM user_imports WHERE id = $1;
+
+-- name: GetUserImportFile :one
+SELECT data FROM user_import_files WHERE import_id = $1;
+
+-- name: DeleteUserImportFile :exec
+DELETE FROM user_import_files WHERE import_id = $1;
+
+-- name: ListUserImports :many
+SELECT * FROM user_imports ORDER BY id DESC LIMIT sqlc.arg(max_rows);
+
+-- name: StartUserImport :execrows
+-- Moves a pending import to running. A retried job restarts a running import from zero.
+UPDATE user_imports
+SET state = 'running', total_rows = sqlc.arg(total_rows),
+    processed_rows = 0, created_rows = 0, failed_rows = 0,
+    started_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND state IN ('pending', 'running');
+
+-- name: UpdateUserImportProgress :exec
+UPDATE user_imports
+SET processed_rows = $1, created_rows = $2, failed_rows = $3
+WHERE id = $4;
+
+-- name: FinishUserImport :exec
+UPDATE user_imports
+SET state = sqlc.arg(state)::text, processed_rows = sqlc.arg(processed_rows),
+    created_rows = sqlc.arg(created_rows), failed_rows = sqlc.arg(failed_rows),
+    row_errors = sqlc.arg(row_errors), last_error = sqlc.narg(last_error),
+    finished_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id);
+
+-- name: CreateDataExport :one
+INSERT INTO data_exports (user_id, format)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: CreateDataExportFile :exec
+INSERT INTO data_export_files (export_id, data) VALUES ($1, $2);
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports WHERE id = $1;
+
+-- name: GetDataExportFile :one
+SELECT data FROM data_export_files WHERE export_id = $1;
+
+-- name: ListDataExports :many
+SELECT * FROM data_exports
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY id DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: StartDataExport :execrows
+UPDATE data_exports SET state = 'running' WHERE id = $1 AND state IN ('pending', 'running');
+
+-- name: FinishDataExport :exec
+UPDATE data_exports
+SET state = sqlc.arg(state)::text, size_bytes = sqlc.arg(size_bytes), last_error = sqlc.narg(last_error),
+    finished_at = CURRENT_TIMESTAMP, expires_at = sqlc.narg(expires_at)
+WHERE id = sqlc.arg(id);
+
+-- name: PurgeDataExports :execrows
+DELETE FROM data_exports WHERE expires_at < sqlc.arg(expires_before);
+
+-- name: ListUserAuditEvents :many
+-- Lists the events a user performed or that targeted them, oldest first.
+SELECT * FROM audit_events
+WHERE actor_id = sqlc.arg(user_id)::bigint
+   OR (target_type = 'user' AND target_id = sqlc.arg(user_id)::bigint)
+ORDER BY id;
+
+-- name: RequestAccountDeletion :one
+-- Schedules an account for erasure. A user whose deletion is already pending gets no rows.
+INSERT INTO account_deletions (user_id, delete_after)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO NOTHING
+RETURNING *;
+
+-- name: GetAccountDeletion :one
+SELECT * FROM account_deletions WHERE user_id = $1;
+
+-- name: CancelAccountDeletion :execrows
+DELETE FROM account_deletions WHERE user_id = $1;
+
+-- name: ListAccountDeletions :many
+SELECT d.user_id, u.email, u.name, d.requested_at, d.delete_after
+FROM account_deletions d
+JOIN users u ON u.id = d.user_id
+ORDER BY d.delete_after, d.user_id;
+
+-- name: ListDueAccountDeletions :many
+SELECT * FROM account_deletions
+WHERE delete_after <= sqlc.arg(due_at)
+ORDER BY delete_after, user_id;
+
+-- name: EraseUser :one
+-- Permanently deletes a user whose deletion is due, with every row that cascades from them. A cancelled deletion gets no rows.
+DELETE FROM users
+WHERE id = sqlc.arg(id)
+  AND id IN (SELECT user_id FROM account_deletions WHERE delete_after <= sqlc.arg(due_at))
+RETURNING *;
@@ -7,124 +7,1804 @@ package store
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const allowAuditPurge = `-- name: AllowAuditPurge :exec
+SELECT set_config('app.audit_purge', 'on', true)
+`
+
+// Lets PurgeAuditEvents past the append-only trigger until the transaction ends.
+func (q *Queries) AllowAuditPurge(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, allowAuditPurge)
+	return err
+}
+
+const cancelAccountDeletion = `-- name: CancelAccountDeletion :execrows
+DELETE FROM account_deletions WHERE user_id = $1
+`
+
+func (q *Queries) CancelAccountDeletion(ctx context.Context, userID int64) (int64, error) {
+	result, err := q.db.Exec(ctx, cancelAccountDeletion, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const cancelJob = `-- name: CancelJob :execrows
+UPDATE jobs
+SET state = 'cancelled', finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND state = 'pending'
+`
+
+func (q *Queries) CancelJob(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, cancelJob, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const claimJobs = `-- name: ClaimJobs :many
+UPDATE jobs
+SET state = 'running', attempts = attempts + 1, locked_at = CURRENT_TIMESTAMP,
+    locked_by = $1, updated_at = CURRENT_TIMESTAMP
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE state = 'pending' AND run_at <= CURRENT_TIMESTAMP AND kind = ANY($2::text[])
+    ORDER BY run_at, id
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, payload, state, attempts, max_attempts, unique_key, run_at, locked_at, locked_by, last_error, created_at, updated_at, finished_at
+`
+
+type ClaimJobsParams struct {
+	Worker  *string  `db:"worker" json:"worker"`
+	Kinds   []string `db:"kinds" json:"kinds"`
+	MaxJobs int32    `db:"max_jobs" json:"max_jobs"`
+}
+
+func (q *Queries) ClaimJobs(ctx context.Context, arg ClaimJobsParams) ([]Job, error) {
+	rows, err := q.db.Query(ctx, claimJobs, arg.Worker, arg.Kinds, arg.MaxJobs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Payload,
+			&i.State,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.UniqueKey,
+			&i.RunAt,
+			&i.LockedAt,
+			&i.LockedBy,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const claimScheduledTick = `-- name: ClaimScheduledTick :execrows
+UPDATE scheduled_tasks
+SET last_tick = $1, last_started_at = CURRENT_TIMESTAMP, last_status = 'running',
+    updated_at = CURRENT_TIMESTAMP
+WHERE name = $2 AND (last_tick IS NULL OR last_tick < $1)
+`
+
+type ClaimScheduledTickParams struct {
+	Tick pgtype.Timestamptz `db:"tick" json:"tick"`
+	Name string             `db:"name" json:"name"`
+}
+
+// Claims a tick for one replica; a tick another replica already ran affects no rows.
+func (q *Queries) ClaimScheduledTick(ctx context.Context, arg ClaimScheduledTickParams) (int64, error) {
+	result, err := q.db.Exec(ctx, claimScheduledTick, arg.Tick, arg.Name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const completeJob = `-- name: CompleteJob :exec
+UPDATE jobs
+SET state = 'succeeded', locked_at = NULL, locked_by = NULL, last_error = NULL,
+    finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND state = 'running'
+`
+
+func (q *Queries) CompleteJob(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, completeJob, id)
+	return err
+}
+
+const countJobsByState = `-- name: CountJobsByState :many
+SELECT state, COUNT(*) AS count FROM jobs GROUP BY state
+`
+
+type CountJobsByStateRow struct {
+	State string `db:"state" json:"state"`
+	Count int64  `db:"count" json:"count"`
+}
+
+func (q *Queries) CountJobsByState(ctx context.Context) ([]CountJobsByStateRow, error) {
+	rows, err := q.db.Query(ctx, countJobsByState)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountJobsByStateRow
+	for rows.Next() {
+		var i CountJobsByStateRow
+		if err := rows.Scan(&i.State, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*) FROM users WHERE is_active = true AND deleted_at IS NULL
+`
+
+func (q *Queries) CountUsers(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, countUsers)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createAuditEvent = `-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (actor_id, action, target_type, target_id, ip, user_agent, request_id, before, after)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+`
+
+type CreateAuditEventParams struct {
+	ActorID    *int64  `db:"actor_id" json:"actor_id"`
+	Action     string  `db:"action" json:"action"`
+	TargetType *string `db:"target_type" json:"target_type"`
+	TargetID   *int64  `db:"target_id" json:"target_id"`
+	Ip         *string `db:"ip" json:"ip"`
+	UserAgent  *string `db:"user_agent" json:"user_agent"`
+	RequestID  *string `db:"request_id" json:"request_id"`
+	Before     []byte  `db:"before" json:"before"`
+	After      []byte  `db:"after" json:"after"`
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error {
+	_, err := q.db.Exec(ctx, createAuditEvent,
+		arg.ActorID,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.Ip,
+		arg.UserAgent,
+		arg.RequestID,
+		arg.Before,
+		arg.After,
+	)
+	return err
+}
+
+const createDataExport = `-- name: CreateDataExport :one
+INSERT INTO data_exports (user_id, format)
+VALUES ($1, $2)
+RETURNING id, user_id, format, state, size_bytes, last_error, created_at, finished_at, expires_at
+`
+
+type CreateDataExportParams struct {
+	UserID int64  `db:"user_id" json:"user_id"`
+	Format string `db:"format" json:"format"`
+}
+
+func (q *Queries) CreateDataExport(ctx context.Context, arg CreateDataExportParams) (DataExport, error) {
+	row := q.db.QueryRow(ctx, createDataExport, arg.UserID, arg.Format)
+	var i DataExport
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Format,
+		&i.State,
+		&i.SizeBytes,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.FinishedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const createDataExportFile = `-- name: CreateDataExportFile :exec
+INSERT INTO data_export_files (export_id, data) VALUES ($1, $2)
+`
+
+type CreateDataExportFileParams struct {
+	ExportID int64  `db:"export_id" json:"export_id"`
+	Data     []byte `db:"data" json:"data"`
+}
+
+func (q *Queries) CreateDataExportFile(ctx context.Context, arg CreateDataExportFileParams) error {
+	_, err := q.db.Exec(ctx, createDataExportFile, arg.ExportID, arg.Data)
+	return err
+}
+
+const createEventPayload = `-- name: CreateEventPayload :one
+INSERT INTO event_payloads (topic, data)
+VALUES ($1, $2)
+RETURNING id
+`
+
+type CreateEventPayloadParams struct {
+	Topic string `db:"topic" json:"topic"`
+	Data  []byte `db:"data" json:"data"`
+}
+
+func (q *Queries) CreateEventPayload(ctx context.Context, arg CreateEventPayloadParams) (int64, error) {
+	row := q.db.QueryRow(ctx, createEventPayload, arg.Topic, arg.Data)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const createUser = `-- name: CreateUser :one
+INSERT INTO users (email, name, bio, avatar_url, password_hash) 
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at, version, deleted_at, is_admin
+`
+
+type CreateUserParams struct {
+	Email        string  `db:"email" json:"email"`
+	Name         string  `db:"name" json:"name"`
+	Bio          *string `db:"bio" json:"bio"`
+	AvatarUrl    *string `db:"avatar_url" json:"avatar_url"`
+	PasswordHash string  `db:"password_hash" json:"password_hash"`
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, createUser,
+		arg.Email,
+		arg.Name,
+		arg.Bio,
+		arg.AvatarUrl,
+		arg.PasswordHash,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Name,
+		&i.AvatarUrl,
+		&i.Bio,
+		&i.PasswordHash,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.DeletedAt,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const createUserImport = `-- name: CreateUserImport :one
+INSERT INTO user_imports (created_by, filename, format, mode, dry_run)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, created_by, filename, format, mode, dry_run, state, total_rows, processed_rows, created_rows, failed_rows, row_errors, last_error, created_at, started_at, finished_at
+`
+
+type CreateUserImportParams struct {
+	CreatedBy *int64 `db:"created_by" json:"created_by"`
+	Filename  string `db:"filename" json:"filename"`
+	Format    string `db:"format" json:"format"`
+	Mode      string `db:"mode" json:"mode"`
+	DryRun    bool   `db:"dry_run" json:"dry_run"`
+}
+
+func (q *Queries) CreateUserImport(ctx context.Context, arg CreateUserImportParams) (UserImport, error) {
+	row := q.db.QueryRow(ctx, createUserImport,
+		arg.CreatedBy,
+		arg.Filename,
+		arg.Format,
+		arg.Mode,
+		arg.DryRun,
+	)
+	var i UserImport
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedBy,
+		&i.Filename,
+		&i.Format,
+		&i.Mode,
+		&i.DryRun,
+		&i.State,
+		&i.TotalRows,
+		&i.ProcessedRows,
+		&i.CreatedRows,
+		&i.FailedRows,
+		&i.RowErrors,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const createUserImportFile = `-- name: CreateUserImportFile :exec
+INSERT INTO user_import_files (import_id, data) VALUES ($1, $2)
+`
+
+type CreateUserImportFileParams struct {
+	ImportID int64  `db:"import_id" json:"import_id"`
+	Data     []byte `db:"data" json:"data"`
+}
+
+func (q *Queries) CreateUserImportFile(ctx context.Context, arg CreateUserImportFileParams) error {
+	_, err := q.db.Exec(ctx, createUserImportFile, arg.ImportID, arg.Data)
+	return err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (endpoint_id, event, event_id, payload, redelivery_of)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, endpoint_id, event, event_id, payload, state, attempts, response_status, response_body, last_error, duration_ms, redelivery_of, created_at, last_attempt_at, delivered_at
+`
+
+type CreateWebhookDeliveryParams struct {
+	EndpointID   int64  `db:"endpoint_id" json:"endpoint_id"`
+	Event        string `db:"event" json:"event"`
+	EventID      string `db:"event_id" json:"event_id"`
+	Payload      []byte `db:"payload" json:"payload"`
+	RedeliveryOf *int64 `db:"redelivery_of" json:"redelivery_of"`
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, createWebhookDelivery,
+		arg.EndpointID,
+		arg.Event,
+		arg.EventID,
+		arg.Payload,
+		arg.RedeliveryOf,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EndpointID,
+		&i.Event,
+		&i.EventID,
+		&i.Payload,
+		&i.State,
+		&i.Attempts,
+		&i.ResponseStatus,
+		&i.ResponseBody,
+		&i.LastError,
+		&i.DurationMs,
+		&i.RedeliveryOf,
+		&i.CreatedAt,
+		&i.LastAttemptAt,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const createWebhookEndpoint = `-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (url, description, secret, events)
+VALUES ($1, $2, $3, $4)
+RETURNING id, url, description, secret, events, enabled, consecutive_failures, disabled_reason, created_at, updated_at
+`
+
+type CreateWebhookEndpointParams struct {
+	Url         string   `db:"url" json:"url"`
+	Description *string  `db:"description" json:"description"`
+	Secret      string   `db:"secret" json:"secret"`
+	Events      []string `db:"events" json:"events"`
+}
+
+func (q *Queries) CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRow(ctx, createWebhookEndpoint,
+		arg.Url,
+		arg.Description,
+		arg.Secret,
+		arg.Events,
+	)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Description,
+		&i.Secret,
+		&i.Events,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledReason,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deactivateUser = `-- name: DeactivateUser :exec
+UPDATE users 
+SET is_active = false, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) DeactivateUser(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, deactivateUser, id)
+	return err
+}
+
+const deleteExpiredRateLimitBuckets = `-- name: DeleteExpiredRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets WHERE expires_at < CURRENT_TIMESTAMP
+`
+
+func (q *Queries) DeleteExpiredRateLimitBuckets(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredRateLimitBuckets)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expiry < CURRENT_TIMESTAMP
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredSessions)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, deleteUser, id)
+	return err
+}
+
+const deleteUserImportFile = `-- name: DeleteUserImportFile :exec
+DELETE FROM user_import_files WHERE import_id = $1
+`
+
+func (q *Queries) DeleteUserImportFile(ctx context.Context, importID int64) error {
+	_, err := q.db.Exec(ctx, deleteUserImportFile, importID)
+	return err
+}
+
+const deleteWebhookEndpoint = `-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints WHERE id = $1
+`
+
+func (q *Queries) DeleteWebhookEndpoint(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteWebhookEndpoint, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const enqueueJob = `-- name: EnqueueJob :one
+INSERT INTO jobs (kind, payload, max_attempts, unique_key, run_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    COALESCE($5::timestamptz, CURRENT_TIMESTAMP)
 )
+ON CONFLICT (unique_key) WHERE unique_key IS NOT NULL AND state IN ('pending', 'running') DO NOTHING
+RETURNING id, kind, payload, state, attempts, max_attempts, unique_key, run_at, locked_at, locked_by, last_error, created_at, updated_at, finished_at
+`
+
+type EnqueueJobParams struct {
+	Kind        string             `db:"kind" json:"kind"`
+	Payload     []byte             `db:"payload" json:"payload"`
+	MaxAttempts int32              `db:"max_attempts" json:"max_attempts"`
+	UniqueKey   *string            `db:"unique_key" json:"unique_key"`
+	RunAt       pgtype.Timestamptz `db:"run_at" json:"run_at"`
+}
+
+func (q *Queries) EnqueueJob(ctx context.Context, arg EnqueueJobParams) (Job, error) {
+	row := q.db.QueryRow(ctx, enqueueJob,
+		arg.Kind,
+		arg.Payload,
+		arg.MaxAttempts,
+		arg.UniqueKey,
+		arg.RunAt,
+	)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Payload,
+		&i.State,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.UniqueKey,
+		&i.RunAt,
+		&i.LockedAt,
+		&i.LockedBy,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const eraseUser = `-- name: EraseUser :one
+DELETE FROM users
+WHERE id = $1
+  AND id IN (SELECT user_id FROM account_deletions WHERE delete_after <= $2)
+RETURNING id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at, version, deleted_at, is_admin
+`
+
+type EraseUserParams struct {
+	ID    int64              `db:"id" json:"id"`
+	DueAt pgtype.Timestamptz `db:"due_at" json:"due_at"`
+}
+
+// Permanently deletes a user whose deletion is due, with every row that cascades from them. A cancelled deletion gets no rows.
+func (q *Queries) EraseUser(ctx context.Context, arg EraseUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, eraseUser, arg.ID, arg.DueAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Name,
+		&i.AvatarUrl,
+		&i.Bio,
+		&i.PasswordHash,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.DeletedAt,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const failJob = `-- name: FailJob :exec
+UPDATE jobs
+SET state = 'dead', last_error = $1::text, locked_at = NULL, locked_by = NULL,
+    finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $2 AND state = 'running'
+`
+
+type FailJobParams struct {
+	LastError string `db:"last_error" json:"last_error"`
+	ID        int64  `db:"id" json:"id"`
+}
+
+func (q *Queries) FailJob(ctx context.Context, arg FailJobParams) error {
+	_, err := q.db.Exec(ctx, failJob, arg.LastError, arg.ID)
+	return err
+}
+
+const failWebhookDelivery = `-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET state = 'failed', last_error = $1::text
+WHERE id = $2 AND state = 'pending'
+`
+
+type FailWebhookDeliveryParams struct {
+	LastError string `db:"last_error" json:"last_error"`
+	ID        int64  `db:"id" json:"id"`
+}
+
+func (q *Queries) FailWebhookDelivery(ctx context.Context, arg FailWebhookDeliveryParams) error {
+	_, err := q.db.Exec(ctx, failWebhookDelivery, arg.LastError, arg.ID)
+	return err
+}
+
+const finishDataExport = `-- name: FinishDataExport :exec
+UPDATE data_exports
+SET state = $1::text, size_bytes = $2, last_error = $3,
+    finished_at = CURRENT_TIMESTAMP, expires_at = $4
+WHERE id = $5
+`
+
+type FinishDataExportParams struct {
+	State     string             `db:"state" json:"state"`
+	SizeBytes int64              `db:"size_bytes" json:"size_bytes"`
+	LastError *string            `db:"last_error" json:"last_error"`
+	ExpiresAt pgtype.Timestamptz `db:"expires_at" json:"expires_at"`
+	ID        int64              `db:"id" json:"id"`
+}
+
+func (q *Queries) FinishDataExport(ctx context.Context, arg FinishDataExportParams) error {
+	_, err := q.db.Exec(ctx, finishDataExport,
+		arg.State,
+		arg.SizeBytes,
+		arg.LastError,
+		arg.ExpiresAt,
+		arg.ID,
+	)
+	return err
+}
+
+const finishScheduledTask = `-- name: FinishScheduledTask :exec
+UPDATE scheduled_tasks
+SET last_status = $1::text, last_error = $2,
+    last_finished_at = CURRENT_TIMESTAMP, last_duration_ms = $3,
+    next_run_at = $4, runs = runs + 1,
+    failures = failures + CASE WHEN $1::text = 'failed' THEN 1 ELSE 0 END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE name = $5
+`
+
+type FinishScheduledTaskParams struct {
+	Status     string             `db:"status" json:"status"`
+	LastError  *string            `db:"last_error" json:"last_error"`
+	DurationMs *int64             `db:"duration_ms" json:"duration_ms"`
+	NextRunAt  pgtype.Timestamptz `db:"next_run_at" json:"next_run_at"`
+	Name       string             `db:"name" json:"name"`
+}
+
+func (q *Queries) FinishScheduledTask(ctx context.Context, arg FinishScheduledTaskParams) error {
+	_, err := q.db.Exec(ctx, finishScheduledTask,
+		arg.Status,
+		arg.LastError,
+		arg.DurationMs,
+		arg.NextRunAt,
+		arg.Name,
+	)
+	return err
+}
+
+const finishUserImport = `-- name: FinishUserImport :exec
+UPDATE user_imports
+SET state = $1::text, processed_rows = $2,
+    created_rows = $3, failed_rows = $4,
+    row_errors = $5, last_error = $6,
+    finished_at = CURRENT_TIMESTAMP
+WHERE id = $7
+`
+
+type FinishUserImportParams struct {
+	State         string  `db:"state" json:"state"`
+	ProcessedRows int32   `db:"processed_rows" json:"processed_rows"`
+	CreatedRows   int32   `db:"created_rows" json:"created_rows"`
+	FailedRows    int32   `db:"failed_rows" json:"failed_rows"`
+	RowErrors     []byte  `db:"row_errors" json:"row_errors"`
+	LastError     *string `db:"last_error" json:"last_error"`
+	ID            int64   `db:"id" json:"id"`
+}
+
+func (q *Queries) FinishUserImport(ctx context.Context, arg FinishUserImportParams) error {
+	_, err := q.db.Exec(ctx, finishUserImport,
+		arg.State,
+		arg.ProcessedRows,
+		arg.CreatedRows,
+		arg.FailedRows,
+		arg.RowErrors,
+		arg.LastError,
+		arg.ID,
+	)
+	return err
+}
+
+const finishWebhookAttempt = `-- name: FinishWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET state = $1::text, attempts = attempts + 1,
+    response_status = $2, response_body = $3,
+    last_error = $4, duration_ms = $5,
+    last_attempt_at = CURRENT_TIMESTAMP,
+    delivered_at = CASE WHEN $1::text = 'succeeded' THEN CURRENT_TIMESTAMP END
+WHERE id = $6
+`
+
+type FinishWebhookAttemptParams struct {
+	State          string  `db:"state" json:"state"`
+	ResponseStatus *int32  `db:"response_status" json:"response_status"`
+	ResponseBody   *string `db:"response_body" json:"response_body"`
+	LastError      *string `db:"last_error" json:"last_error"`
+	DurationMs     *int64  `db:"duration_ms" json:"duration_ms"`
+	ID             int64   `db:"id" json:"id"`
+}
+
+func (q *Queries) FinishWebhookAttempt(ctx context.Context, arg FinishWebhookAttemptParams) error {
+	_, err := q.db.Exec(ctx, finishWebhookAttempt,
+		arg.State,
+		arg.ResponseStatus,
+		arg.ResponseBody,
+		arg.LastError,
+		arg.DurationMs,
+		arg.ID,
+	)
+	return err
+}
+
+const getAccountDeletion = `-- name: GetAccountDeletion :one
+SELECT user_id, requested_at, delete_after FROM account_deletions WHERE user_id = $1
+`
+
+func (q *Queries) GetAccountDeletion(ctx context.Context, userID int64) (AccountDeletion, error) {
+	row := q.db.QueryRow(ctx, getAccountDeletion, userID)
+	var i AccountDeletion
+	err := row.Scan(&i.UserID, &i.RequestedAt, &i.DeleteAfter)
+	return i, err
+}
+
+const getDataExport = `-- name: GetDataExport :one
+SELECT id, user_id, format, state, size_bytes, last_error, created_at, finished_at, expires_at FROM data_exports WHERE id = $1
+`
+
+func (q *Queries) GetDataExport(ctx context.Context, id int64) (DataExport, error) {
+	row := q.db.QueryRow(ctx, getDataExport, id)
+	var i DataExport
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Format,
+		&i.State,
+		&i.SizeBytes,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.FinishedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const getDataExportFile = `-- name: GetDataExportFile :one
+SELECT data FROM data_export_files WHERE export_id = $1
+`
+
+func (q *Queries) GetDataExportFile(ctx context.Context, exportID int64) ([]byte, error) {
+	row := q.db.QueryRow(ctx, getDataExportFile, exportID)
+	var data []byte
+	err := row.Scan(&data)
+	return data, err
+}
+
+const getEventPayload = `-- name: GetEventPayload :one
+SELECT data FROM event_payloads WHERE id = $1
+`
+
+func (q *Queries) GetEventPayload(ctx context.Context, id int64) ([]byte, error) {
+	row := q.db.QueryRow(ctx, getEventPayload, id)
+	var data []byte
+	err := row.Scan(&data)
+	return data, err
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at, version, deleted_at, is_admin FROM users WHERE id = $1 AND deleted_at IS NULL LIMIT 1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRow(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Name,
+		&i.AvatarUrl,
+		&i.Bio,
+		&i.PasswordHash,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.DeletedAt,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at, version, deleted_at, is_admin FROM users WHERE email = $1 AND deleted_at IS NULL LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Name,
+		&i.AvatarUrl,
+		&i.Bio,
+		&i.PasswordHash,
+		&i.IsActive,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.DeletedAt,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const getUserImport = `-- name: GetUserImport :one
+SELECT id, created_by, filename, format, mode, dry_run, state, total_rows, processed_rows, created_rows, failed_rows, row_errors, last_error, created_at, started_at, finished_at FROM user_imports WHERE id = $1
+`
+
+func (q *Queries) GetUserImport(ctx context.Context, id int64) (UserImport, error) {
+	row := q.db.QueryRow(ctx, getUserImport, id)
+	var i UserImport
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedBy,
+		&i.Filename,
+		&i.Format,
+		&i.Mode,
+		&i.DryRun,
+		&i.State,
+		&i.TotalRows,
+		&i.ProcessedRows,
+		&i.CreatedRows,
+		&i.FailedRows,
+		&i.RowErrors,
+		&i.LastError,
+		&i.CreatedAt,
+		&i.StartedAt,
+		&i.FinishedAt,
+	)
+	return i, err
+}
+
+const getUserImportFile = `-- name: GetUserImportFile :one
+SELECT data FROM user_import_files WHERE import_id = $1
+`
+
+func (q *Queries) GetUserImportFile(ctx context.Context, importID int64) ([]byte, error) {
+	row := q.db.QueryRow(ctx, getUserImportFile, importID)
+	var data []byte
+	err := row.Scan(&data)
+	return data, err
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+SELECT id, endpoint_id, event, event_id, payload, state, attempts, response_status, response_body, last_error, duration_ms, redelivery_of, created_at, last_attempt_at, delivered_at FROM webhook_deliveries WHERE id = $1
+`
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, getWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EndpointID,
+		&i.Event,
+		&i.EventID,
+		&i.Payload,
+		&i.State,
+		&i.Attempts,
+		&i.ResponseStatus,
+		&i.ResponseBody,
+		&i.LastError,
+		&i.DurationMs,
+		&i.RedeliveryOf,
+		&i.CreatedAt,
+		&i.LastAttemptAt,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const getWebhookEndpoint = `-- name: GetWebhookEndpoint :one
+SELECT id, url, description, secret, events, enabled, consecutive_failures, disabled_reason, created_at, updated_at FROM webhook_endpoints WHERE id = $1
+`
+
+func (q *Queries) GetWebhookEndpoint(ctx context.Context, id int64) (WebhookEndpoint, error) {
+	row := q.db.QueryRow(ctx, getWebhookEndpoint, id)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Description,
+		&i.Secret,
+		&i.Events,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledReason,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const listAccountDeletions = `-- name: ListAccountDeletions :many
+SELECT d.user_id, u.email, u.name, d.requested_at, d.delete_after
+FROM account_deletions d
+JOIN users u ON u.id = d.user_id
+ORDER BY d.delete_after, d.user_id
+`
+
+type ListAccountDeletionsRow struct {
+	UserID      int64              `db:"user_id" json:"user_id"`
+	Email       string             `db:"email" json:"email"`
+	Name        string             `db:"name" json:"name"`
+	RequestedAt pgtype.Timestamptz `db:"requested_at" json:"requested_at"`
+	DeleteAfter pgtype.Timestamptz `db:"delete_after" json:"delete_after"`
+}
+
+func (q *Queries) ListAccountDeletions(ctx context.Context) ([]ListAccountDeletionsRow, error) {
+	rows, err := q.db.Query(ctx, listAccountDeletions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAccountDeletionsRow
+	for rows.Next() {
+		var i ListAccountDeletionsRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Email,
+			&i.Name,
+			&i.RequestedAt,
+			&i.DeleteAfter,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllUsers = `-- name: ListAllUsers :many
+SELECT id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at, version, deleted_at, is_admin FROM users WHERE deleted_at IS NULL ORDER BY created_at DESC
+`
+
+func (q *Queries) ListAllUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.Query(ctx, listAllUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.Name,
+			&i.AvatarUrl,
+			&i.Bio,
+			&i.PasswordHash,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.DeletedAt,
+			&i.IsAdmin,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT a.id, a.occurred_at, a.actor_id, u.email AS actor_email, a.action, a.target_type, a.target_id,
+    a.ip, a.user_agent, a.request_id, a.before, a.after
+FROM audit_events a
+LEFT JOIN users u ON u.id = a.actor_id
+WHERE ($1::text IS NULL OR a.action = $1)
+  AND ($2::text IS NULL OR u.email = $2)
+  AND ($3::bigint IS NULL OR a.target_id = $3)
+  AND ($4::bigint IS NULL OR a.id < $4)
+ORDER BY a.id DESC
+LIMIT $5
+`
+
+type ListAuditEventsParams struct {
+	Action     *string `db:"action" json:"action"`
+	ActorEmail *string `db:"actor_email" json:"actor_email"`
+	TargetID   *int64  `db:"target_id" json:"target_id"`
+	BeforeID   *int64  `db:"before_id" json:"before_id"`
+	MaxRows    int32   `db:"max_rows" json:"max_rows"`
+}
+
+type ListAuditEventsRow struct {
+	ID         int64              `db:"id" json:"id"`
+	OccurredAt pgtype.Timestamptz `db:"occurred_at" json:"occurred_at"`
+	ActorID    *int64             `db:"actor_id" json:"actor_id"`
+	ActorEmail *string            `db:"actor_email" json:"actor_email"`
+	Action     string             `db:"action" json:"action"`
+	TargetType *string            `db:"target_type" json:"target_type"`
+	TargetID   *int64             `db:"target_id" json:"target_id"`
+	Ip         *string            `db:"ip" json:"ip"`
+	UserAgent  *string            `db:"user_agent" json:"user_agent"`
+	RequestID  *string            `db:"request_id" json:"request_id"`
+	Before     []byte             `db:"before" json:"before"`
+	After      []byte             `db:"after" json:"after"`
+}
+
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]ListAuditEventsRow, error) {
+	rows, err := q.db.Query(ctx, listAuditEvents,
+		arg.Action,
+		arg.ActorEmail,
+		arg.TargetID,
+		arg.BeforeID,
+		arg.MaxRows,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAuditEventsRow
+	for rows.Next() {
+		var i ListAuditEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OccurredAt,
+			&i.ActorID,
+			&i.ActorEmail,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.Ip,
+			&i.UserAgent,
+			&i.RequestID,
+			&i.Before,
+			&i.After,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDataExports = `-- name: ListDataExports :many
+SELECT id, user_id, format, state, size_bytes, last_error, created_at, finished_at, expires_at FROM data_exports
+WHERE user_id = $1
+ORDER BY id DESC
+LIMIT $2
+`
+
+type ListDataExportsParams struct {
+	UserID  int64 `db:"user_id" json:"user_id"`
+	MaxRows int32 `db:"max_rows" json:"max_rows"`
+}
+
+func (q *Queries) ListDataExports(ctx context.Context, arg ListDataExportsParams) ([]DataExport, error) {
+	rows, err := q.db.Query(ctx, listDataExports, arg.UserID, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DataExport
+	for rows.Next() {
+		var i DataExport
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Format,
+			&i.State,
+			&i.SizeBytes,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.FinishedAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeletedUsers = `-- name: ListDeletedUsers :many
+SELECT id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at, version, deleted_at, is_admin FROM users
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC
+`
+
+func (q *Queries) ListDeletedUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.Query(ctx, listDeletedUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.Name,
+			&i.AvatarUrl,
+			&i.Bio,
+			&i.PasswordHash,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.DeletedAt,
+			&i.IsAdmin,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDueAccountDeletions = `-- name: ListDueAccountDeletions :many
+SELECT user_id, requested_at, delete_after FROM account_deletions
+WHERE delete_after <= $1
+ORDER BY delete_after, user_id
+`
+
+func (q *Queries) ListDueAccountDeletions(ctx context.Context, dueAt pgtype.Timestamptz) ([]AccountDeletion, error) {
+	rows, err := q.db.Query(ctx, listDueAccountDeletions, dueAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AccountDeletion
+	for rows.Next() {
+		var i AccountDeletion
+		if err := rows.Scan(&i.UserID, &i.RequestedAt, &i.DeleteAfter); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listJobs = `-- name: ListJobs :many
+SELECT id, kind, payload, state, attempts, max_attempts, unique_key, run_at, locked_at, locked_by, last_error, created_at, updated_at, finished_at FROM jobs
+WHERE ($1::text IS NULL OR state = $1)
+  AND ($2::bigint IS NULL OR id < $2)
+ORDER BY id DESC
+LIMIT $3
+`
+
+type ListJobsParams struct {
+	State    *string `db:"state" json:"state"`
+	BeforeID *int64  `db:"before_id" json:"before_id"`
+	MaxRows  int32   `db:"max_rows" json:"max_rows"`
+}
+
+func (q *Queries) ListJobs(ctx context.Context, arg ListJobsParams) ([]Job, error) {
+	rows, err := q.db.Query(ctx, listJobs, arg.State, arg.BeforeID, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Payload,
+			&i.State,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.UniqueKey,
+			&i.RunAt,
+			&i.LockedAt,
+			&i.LockedBy,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listScheduledTasks = `-- name: ListScheduledTasks :many
+SELECT name, schedule, last_tick, last_started_at, last_finished_at, last_status, last_error, last_duration_ms, next_run_at, runs, failures, updated_at FROM scheduled_tasks ORDER BY name
+`
+
+func (q *Queries) ListScheduledTasks(ctx context.Context) ([]ScheduledTask, error) {
+	rows, err := q.db.Query(ctx, listScheduledTasks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ScheduledTask
+	for rows.Next() {
+		var i ScheduledTask
+		if err := rows.Scan(
+			&i.Name,
+			&i.Schedule,
+			&i.LastTick,
+			&i.LastStartedAt,
+			&i.LastFinishedAt,
+			&i.LastStatus,
+			&i.LastError,
+			&i.LastDurationMs,
+			&i.NextRunAt,
+			&i.Runs,
+			&i.Failures,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserAuditEvents = `-- name: ListUserAuditEvents :many
+SELECT id, occurred_at, actor_id, action, target_type, target_id, ip, user_agent, request_id, before, after FROM audit_events
+WHERE actor_id = $1::bigint
+   OR (target_type = 'user' AND target_id = $1::bigint)
+ORDER BY id
+`
+
+// Lists the events a user performed or that targeted them, oldest first.
+func (q *Queries) ListUserAuditEvents(ctx context.Context, userID int64) ([]AuditEvent, error) {
+	rows, err := q.db.Query(ctx, listUserAuditEvents, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.OccurredAt,
+			&i.ActorID,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.Ip,
+			&i.UserAgent,
+			&i.RequestID,
+			&i.Before,
+			&i.After,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserImports = `-- name: ListUserImports :many
+SELECT id, created_by, filename, format, mode, dry_run, state, total_rows, processed_rows, created_rows, failed_rows, row_errors, last_error, created_at, started_at, finished_at FROM user_imports ORDER BY id DESC LIMIT $1
+`
+
+func (q *Queries) ListUserImports(ctx context.Context, maxRows int32) ([]UserImport, error) {
+	rows, err := q.db.Query(ctx, listUserImports, maxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserImport
+	for rows.Next() {
+		var i UserImport
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedBy,
+			&i.Filename,
+			&i.Format,
+			&i.Mode,
+			&i.DryRun,
+			&i.State,
+			&i.TotalRows,
+			&i.ProcessedRows,
+			&i.CreatedRows,
+			&i.FailedRows,
+			&i.RowErrors,
+			&i.LastError,
+			&i.CreatedAt,
+			&i.StartedAt,
+			&i.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at, version, deleted_at, is_admin FROM users 
+WHERE is_active = true AND deleted_at IS NULL
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.Name,
+			&i.AvatarUrl,
+			&i.Bio,
+			&i.PasswordHash,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.DeletedAt,
+			&i.IsAdmin,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, endpoint_id, event, event_id, payload, state, attempts, response_status, response_body, last_error, duration_ms, redelivery_of, created_at, last_attempt_at, delivered_at FROM webhook_deliveries
+WHERE endpoint_id = $1
+  AND ($2::bigint IS NULL OR id < $2)
+ORDER BY id DESC
+LIMIT $3
+`
+
+type ListWebhookDeliveriesParams struct {
+	EndpointID int64  `db:"endpoint_id" json:"endpoint_id"`
+	BeforeID   *int64 `db:"before_id" json:"before_id"`
+	MaxRows    int32  `db:"max_rows" json:"max_rows"`
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveries, arg.EndpointID, arg.BeforeID, arg.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.EndpointID,
+			&i.Event,
+			&i.EventID,
+			&i.Payload,
+			&i.State,
+			&i.Attempts,
+			&i.ResponseStatus,
+			&i.ResponseBody,
+			&i.LastError,
+			&i.DurationMs,
+			&i.RedeliveryOf,
+			&i.CreatedAt,
+			&i.LastAttemptAt,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookEndpoints = `-- name: ListWebhookEndpoints :many
+SELECT id, url, description, secret, events, enabled, consecutive_failures, disabled_reason, created_at, updated_at FROM webhook_endpoints ORDER BY id
+`
+
+func (q *Queries) ListWebhookEndpoints(ctx context.Context) ([]WebhookEndpoint, error) {
+	rows, err := q.db.Query(ctx, listWebhookEndpoints)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Description,
+			&i.Secret,
+			&i.Events,
+			&i.Enabled,
+			&i.ConsecutiveFailures,
+			&i.DisabledReason,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
 
-const countUsers = `-- name: CountUsers :one
-SELECT COUNT(*) FROM users WHERE is_active = true
+const listWebhookEndpointsForEvent = `-- name: ListWebhookEndpointsForEvent :many
+SELECT id, url, description, secret, events, enabled, consecutive_failures, disabled_reason, created_at, updated_at FROM webhook_endpoints
+WHERE enabled AND (cardinality(events) = 0 OR $1::text = ANY(events))
+ORDER BY id
 `
 
-func (q *Queries) CountUsers(ctx context.Context) (int64, error) {
-	row := q.db.QueryRow(ctx, countUsers)
-	var count int64
-	err := row.Scan(&count)
-	return count, err
+// Lists the enabled endpoints subscribed to event; an empty events list subscribes to all.
+func (q *Queries) ListWebhookEndpointsForEvent(ctx context.Context, event string) ([]WebhookEndpoint, error) {
+	rows, err := q.db.Query(ctx, listWebhookEndpointsForEvent, event)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Description,
+			&i.Secret,
+			&i.Events,
+			&i.Enabled,
+			&i.ConsecutiveFailures,
+			&i.DisabledReason,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-const createUser = `-- name: CreateUser :one
-INSERT INTO users (email, name, bio, avatar_url, password_hash) 
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at
+const notify = `-- name: Notify :exec
+SELECT pg_notify($1::text, $2::text)
 `
 
-type CreateUserParams struct {
-	Email        string  `db:"email" json:"email"`
-	Name         string  `db:"name" json:"name"`
-	Bio          *string `db:"bio" json:"bio"`
-	AvatarUrl    *string `db:"avatar_url" json:"avatar_url"`
-	PasswordHash string  `db:"password_hash" json:"password_hash"`
+type NotifyParams struct {
+	Channel string `db:"channel" json:"channel"`
+	Payload string `db:"payload" json:"payload"`
 }
 
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRow(ctx, createUser,
-		arg.Email,
-		arg.Name,
-		arg.Bio,
-		arg.AvatarUrl,
-		arg.PasswordHash,
-	)
-	var i User
+// Sends payload to the channel's listeners once the transaction commits.
+func (q *Queries) Notify(ctx context.Context, arg NotifyParams) error {
+	_, err := q.db.Exec(ctx, notify, arg.Channel, arg.Payload)
+	return err
+}
+
+const purgeAuditEvents = `-- name: PurgeAuditEvents :execrows
+DELETE FROM audit_events WHERE occurred_at < $1
+`
+
+func (q *Queries) PurgeAuditEvents(ctx context.Context, occurredBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeAuditEvents, occurredBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const purgeDataExports = `-- name: PurgeDataExports :execrows
+DELETE FROM data_exports WHERE expires_at < $1
+`
+
+func (q *Queries) PurgeDataExports(ctx context.Context, expiresBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDataExports, expiresBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE deleted_at < $1
+RETURNING id, avatar_url
+`
+
+type PurgeDeletedUsersRow struct {
+	ID        int64   `db:"id" json:"id"`
+	AvatarUrl *string `db:"avatar_url" json:"avatar_url"`
+}
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletedBefore pgtype.Timestamptz) ([]PurgeDeletedUsersRow, error) {
+	rows, err := q.db.Query(ctx, purgeDeletedUsers, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PurgeDeletedUsersRow
+	for rows.Next() {
+		var i PurgeDeletedUsersRow
+		if err := rows.Scan(&i.ID, &i.AvatarUrl); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeEventPayloads = `-- name: PurgeEventPayloads :execrows
+DELETE FROM event_payloads WHERE created_at < $1
+`
+
+func (q *Queries) PurgeEventPayloads(ctx context.Context, createdBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeEventPayloads, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const purgeFinishedJobs = `-- name: PurgeFinishedJobs :execrows
+DELETE FROM jobs
+WHERE state IN ('succeeded', 'dead', 'cancelled') AND finished_at < $1
+`
+
+func (q *Queries) PurgeFinishedJobs(ctx context.Context, finishedBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeFinishedJobs, finishedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const purgeWebhookDeliveries = `-- name: PurgeWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries WHERE created_at < $1
+`
+
+func (q *Queries) PurgeWebhookDeliveries(ctx context.Context, createdBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeWebhookDeliveries, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const reactivateUser = `-- name: ReactivateUser :execrows
+UPDATE users
+SET is_active = true, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) ReactivateUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, reactivateUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const recordWebhookEndpointFailure = `-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    enabled = enabled AND ($1::integer = 0 OR consecutive_failures + 1 < $1::integer),
+    disabled_reason = CASE
+        WHEN enabled AND $1::integer > 0 AND consecutive_failures + 1 >= $1::integer
+        THEN $2::text
+        ELSE disabled_reason
+    END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $3
+RETURNING id, url, description, secret, events, enabled, consecutive_failures, disabled_reason, created_at, updated_at
+`
+
+type RecordWebhookEndpointFailureParams struct {
+	DisableAfter int32  `db:"disable_after" json:"disable_after"`
+	Reason       string `db:"reason" json:"reason"`
+	ID           int64  `db:"id" json:"id"`
+}
+
+// Counts a failed attempt and disables the endpoint with reason once disable_after attempts in a row have failed; zero never disables it.
+func (q *Queries) RecordWebhookEndpointFailure(ctx context.Context, arg RecordWebhookEndpointFailureParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRow(ctx, recordWebhookEndpointFailure, arg.DisableAfter, arg.Reason, arg.ID)
+	var i WebhookEndpoint
 	err := row.Scan(
 		&i.ID,
-		&i.Email,
-		&i.Name,
-		&i.AvatarUrl,
-		&i.Bio,
-		&i.PasswordHash,
-		&i.IsActive,
+		&i.Url,
+		&i.Description,
+		&i.Secret,
+		&i.Events,
+		&i.Enabled,
+		&i.ConsecutiveFailures,
+		&i.DisabledReason,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
 	return i, err
 }
 
-const deactivateUser = `-- name: DeactivateUser :exec
-UPDATE users 
-SET is_active = false, updated_at = CURRENT_TIMESTAMP
-WHERE id = $1
+const recordWebhookEndpointSuccess = `-- name: RecordWebhookEndpointSuccess :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND consecutive_failures > 0
 `
 
-func (q *Queries) DeactivateUser(ctx context.Context, id int64) error {
-	_, err := q.db.Exec(ctx, deactivateUser, id)
+func (q *Queries) RecordWebhookEndpointSuccess(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, recordWebhookEndpointSuccess, id)
 	return err
 }
 
-const deleteUser = `-- name: DeleteUser :exec
-DELETE FROM users WHERE id = $1
+const releaseJob = `-- name: ReleaseJob :exec
+UPDATE jobs
+SET state = 'pending', attempts = attempts - 1, locked_at = NULL, locked_by = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND state = 'running'
 `
 
-func (q *Queries) DeleteUser(ctx context.Context, id int64) error {
-	_, err := q.db.Exec(ctx, deleteUser, id)
+func (q *Queries) ReleaseJob(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, releaseJob, id)
 	return err
 }
 
-const getUser = `-- name: GetUser :one
-SELECT id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at FROM users WHERE id = $1 LIMIT 1
+const requestAccountDeletion = `-- name: RequestAccountDeletion :one
+INSERT INTO account_deletions (user_id, delete_after)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO NOTHING
+RETURNING user_id, requested_at, delete_after
 `
 
-func (q *Queries) GetUser(ctx context.Context, id int64) (User, error) {
-	row := q.db.QueryRow(ctx, getUser, id)
-	var i User
-	err := row.Scan(
-		&i.ID,
-		&i.Email,
-		&i.Name,
-		&i.AvatarUrl,
-		&i.Bio,
-		&i.PasswordHash,
-		&i.IsActive,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-	)
+type RequestAccountDeletionParams struct {
+	UserID      int64              `db:"user_id" json:"user_id"`
+	DeleteAfter pgtype.Timestamptz `db:"delete_after" json:"delete_after"`
+}
+
+// Schedules an account for erasure. A user whose deletion is already pending gets no rows.
+func (q *Queries) RequestAccountDeletion(ctx context.Context, arg RequestAccountDeletionParams) (AccountDeletion, error) {
+	row := q.db.QueryRow(ctx, requestAccountDeletion, arg.UserID, arg.DeleteAfter)
+	var i AccountDeletion
+	err := row.Scan(&i.UserID, &i.RequestedAt, &i.DeleteAfter)
 	return i, err
 }
 
-const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at FROM users WHERE email = $1 LIMIT 1
+const rescueStaleJobs = `-- name: RescueStaleJobs :execrows
+UPDATE jobs
+SET state = 'pending', last_error = 'worker stopped before finishing the job', locked_at = NULL,
+    locked_by = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE state = 'running' AND locked_at < $1
 `
 
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
-	row := q.db.QueryRow(ctx, getUserByEmail, email)
-	var i User
-	err := row.Scan(
-		&i.ID,
-		&i.Email,
-		&i.Name,
-		&i.AvatarUrl,
-		&i.Bio,
-		&i.PasswordHash,
-		&i.IsActive,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-	)
-	return i, err
+func (q *Queries) RescueStaleJobs(ctx context.Context, lockedBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, rescueStaleJobs, lockedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
-const listAllUsers = `-- name: ListAllUsers :many
-SELECT id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at FROM users ORDER BY created_at DESC
+const restoreUser = `-- name: RestoreUser :execrows
+UPDATE users
+SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NOT NULL
 `
 
-func (q *Queries) ListAllUsers(ctx context.Context) ([]User, error) {
-	rows, err := q.db.Query(ctx, listAllUsers)
+func (q *Queries) RestoreUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, restoreUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const retryJob = `-- name: RetryJob :execrows
+UPDATE jobs
+SET state = 'pending', attempts = 0, run_at = CURRENT_TIMESTAMP, finished_at = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND state IN ('dead', 'cancelled')
+`
+
+func (q *Queries) RetryJob(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, retryJob, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const scheduleJobRetry = `-- name: ScheduleJobRetry :exec
+UPDATE jobs
+SET state = 'pending', run_at = $1, last_error = $2::text,
+    locked_at = NULL, locked_by = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = $3 AND state = 'running'
+`
+
+type ScheduleJobRetryParams struct {
+	RunAt     pgtype.Timestamptz `db:"run_at" json:"run_at"`
+	LastError string             `db:"last_error" json:"last_error"`
+	ID        int64              `db:"id" json:"id"`
+}
+
+func (q *Queries) ScheduleJobRetry(ctx context.Context, arg ScheduleJobRetryParams) error {
+	_, err := q.db.Exec(ctx, scheduleJobRetry, arg.RunAt, arg.LastError, arg.ID)
+	return err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at, version, deleted_at, is_admin FROM users
+WHERE deleted_at IS NULL
+  AND ($1::text IS NULL
+       OR ($1::text = 'active') = COALESCE(is_active, false))
+  AND ($2::text IS NULL
+       OR strpos(lower(email), lower($2::text)) > 0
+       OR strpos(lower(name), lower($2::text)) > 0)
+ORDER BY created_at DESC, id DESC
+`
+
+type SearchUsersParams struct {
+	Status *string `db:"status" json:"status"`
+	Query  *string `db:"query" json:"query"`
+}
+
+// Lists users who are not deleted, newest first. A NULL status matches active and inactive users; a NULL query matches every name and email.
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, searchUsers, arg.Status, arg.Query)
 	if err != nil {
 		return nil, err
 	}
@@ -142,6 +1822,9 @@ func (q *Queries) ListAllUsers(ctx context.Context) ([]User, error) {
 			&i.IsActive,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Version,
+			&i.DeletedAt,
+			&i.IsAdmin,
 		); err != nil {
 			return nil, err
 		}
@@ -153,47 +1836,127 @@ func (q *Queries) ListAllUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
-const listUsers = `-- name: ListUsers :many
-SELECT id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at FROM users 
-WHERE is_active = true 
-ORDER BY created_at DESC
+const setWebhookEndpointEnabled = `-- name: SetWebhookEndpointEnabled :execrows
+UPDATE webhook_endpoints
+SET enabled = $1,
+    consecutive_failures = CASE WHEN $1 THEN 0 ELSE consecutive_failures END,
+    disabled_reason = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $3
 `
 
-func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
-	rows, err := q.db.Query(ctx, listUsers)
+type SetWebhookEndpointEnabledParams struct {
+	Enabled        bool    `db:"enabled" json:"enabled"`
+	DisabledReason *string `db:"disabled_reason" json:"disabled_reason"`
+	ID             int64   `db:"id" json:"id"`
+}
+
+// Enabling an endpoint also clears its failure count.
+func (q *Queries) SetWebhookEndpointEnabled(ctx context.Context, arg SetWebhookEndpointEnabledParams) (int64, error) {
+	result, err := q.db.Exec(ctx, setWebhookEndpointEnabled, arg.Enabled, arg.DisabledReason, arg.ID)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	defer rows.Close()
-	var items []User
-	for rows.Next() {
-		var i User
-		if err := rows.Scan(
-			&i.ID,
-			&i.Email,
-			&i.Name,
-			&i.AvatarUrl,
-			&i.Bio,
-			&i.PasswordHash,
-			&i.IsActive,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
+	return result.RowsAffected(), nil
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :execrows
+UPDATE users
+SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, softDeleteUser, id)
+	if err != nil {
+		return 0, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+	return result.RowsAffected(), nil
+}
+
+const startDataExport = `-- name: StartDataExport :execrows
+UPDATE data_exports SET state = 'running' WHERE id = $1 AND state IN ('pending', 'running')
+`
+
+func (q *Queries) StartDataExport(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.Exec(ctx, startDataExport, id)
+	if err != nil {
+		return 0, err
 	}
-	return items, nil
+	return result.RowsAffected(), nil
+}
+
+const startUserImport = `-- name: StartUserImport :execrows
+UPDATE user_imports
+SET state = 'running', total_rows = $1,
+    processed_rows = 0, created_rows = 0, failed_rows = 0,
+    started_at = CURRENT_TIMESTAMP
+WHERE id = $2 AND state IN ('pending', 'running')
+`
+
+type StartUserImportParams struct {
+	TotalRows int32 `db:"total_rows" json:"total_rows"`
+	ID        int64 `db:"id" json:"id"`
+}
+
+// Moves a pending import to running. A retried job restarts a running import from zero.
+func (q *Queries) StartUserImport(ctx context.Context, arg StartUserImportParams) (int64, error) {
+	result, err := q.db.Exec(ctx, startUserImport, arg.TotalRows, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const takeRateLimitToken = `-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at, expires_at)
+VALUES (
+    $1,
+    $2::double precision - 1,
+    true,
+    CURRENT_TIMESTAMP,
+    CURRENT_TIMESTAMP + make_interval(secs => $3::double precision)
+)
+ON CONFLICT (key) DO UPDATE
+SET tokens = CASE
+        WHEN LEAST($2::double precision, b.tokens + EXTRACT(EPOCH FROM (CURRENT_TIMESTAMP - b.updated_at))::double precision * $4::double precision) >= 1
+        THEN LEAST($2::double precision, b.tokens + EXTRACT(EPOCH FROM (CURRENT_TIMESTAMP - b.updated_at))::double precision * $4::double precision) - 1
+        ELSE LEAST($2::double precision, b.tokens + EXTRACT(EPOCH FROM (CURRENT_TIMESTAMP - b.updated_at))::double precision * $4::double precision)
+    END,
+    allowed = LEAST($2::double precision, b.tokens + EXTRACT(EPOCH FROM (CURRENT_TIMESTAMP - b.updated_at))::double precision * $4::double precision) >= 1,
+    updated_at = CURRENT_TIMESTAMP,
+    expires_at = EXCLUDED.expires_at
+RETURNING tokens, allowed
+`
+
+type TakeRateLimitTokenParams struct {
+	Key             string  `db:"key" json:"key"`
+	Capacity        float64 `db:"capacity" json:"capacity"`
+	TtlSeconds      float64 `db:"ttl_seconds" json:"ttl_seconds"`
+	RefillPerSecond float64 `db:"refill_per_second" json:"refill_per_second"`
+}
+
+type TakeRateLimitTokenRow struct {
+	Tokens  float64 `db:"tokens" json:"tokens"`
+	Allowed bool    `db:"allowed" json:"allowed"`
+}
+
+func (q *Queries) TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (TakeRateLimitTokenRow, error) {
+	row := q.db.QueryRow(ctx, takeRateLimitToken,
+		arg.Key,
+		arg.Capacity,
+		arg.TtlSeconds,
+		arg.RefillPerSecond,
+	)
+	var i TakeRateLimitTokenRow
+	err := row.Scan(&i.Tokens, &i.Allowed)
+	return i, err
 }
 
 const updateUser = `-- name: UpdateUser :one
 UPDATE users 
-SET email = $1, name = $2, bio = $3, avatar_url = $4, updated_at = CURRENT_TIMESTAMP
-WHERE id = $5
-RETURNING id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at
+SET email = $1, name = $2, bio = $3, avatar_url = $4, version = version + 1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $5 AND version = $6 AND deleted_at IS NULL
+RETURNING id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at, version, deleted_at, is_admin
 `
 
 type UpdateUserParams struct {
@@ -202,6 +1965,7 @@ type UpdateUserParams struct {
 	Bio       *string `db:"bio" json:"bio"`
 	AvatarUrl *string `db:"avatar_url" json:"avatar_url"`
 	ID        int64   `db:"id" json:"id"`
+	Version   int64   `db:"version" json:"version"`
 }
 
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
@@ -211,6 +1975,7 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		arg.Bio,
 		arg.AvatarUrl,
 		arg.ID,
+		arg.Version,
 	)
 	var i User
 	err := row.Scan(
@@ -223,15 +1988,41 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.IsActive,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Version,
+		&i.DeletedAt,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
+const updateUserImportProgress = `-- name: UpdateUserImportProgress :exec
+UPDATE user_imports
+SET processed_rows = $1, created_rows = $2, failed_rows = $3
+WHERE id = $4
+`
+
+type UpdateUserImportProgressParams struct {
+	ProcessedRows int32 `db:"processed_rows" json:"processed_rows"`
+	CreatedRows   int32 `db:"created_rows" json:"created_rows"`
+	FailedRows    int32 `db:"failed_rows" json:"failed_rows"`
+	ID            int64 `db:"id" json:"id"`
+}
+
+func (q *Queries) UpdateUserImportProgress(ctx context.Context, arg UpdateUserImportProgressParams) error {
+	_, err := q.db.Exec(ctx, updateUserImportProgress,
+		arg.ProcessedRows,
+		arg.CreatedRows,
+		arg.FailedRows,
+		arg.ID,
+	)
+	return err
+}
+
 const updateUserPassword = `-- name: UpdateUserPassword :one
 UPDATE users 
-SET email = $1, name = $2, bio = $3, avatar_url = $4, password_hash = $5, updated_at = CURRENT_TIMESTAMP
-WHERE id = $6
-RETURNING id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at
+SET email = $1, name = $2, bio = $3, avatar_url = $4, password_hash = $5, version = version + 1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $6 AND version = $7 AND deleted_at IS NULL
+RETURNING id, email, name, avatar_url, bio, password_hash, is_active, created_at, updated_at, version, deleted_at, is_admin
 `
 
 type UpdateUserPasswordParams struct {
@@ -241,6 +2032,7 @@ type UpdateUserPasswordParams struct {
 	AvatarUrl    *string `db:"avatar_url" json:"avatar_url"`
 	PasswordHash string  `db:"password_hash" json:"password_hash"`
 	ID           int64   `db:"id" json:"id"`
+	Version      int64   `db:"version" json:"version"`
 }
 
 func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
@@ -251,6 +2043,7 @@ func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPassword
 		arg.AvatarUrl,
 		arg.PasswordHash,
 		arg.ID,
+		arg.Version,
 	)
 	var i User
 	err := row.Scan(
@@ -263,6 +2056,27 @@ func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPassword
 		&i.IsActive,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Version,
+		&i.DeletedAt,
+		&i.IsAdmin,
 	)
 	return i, err
 }
+
+const upsertScheduledTask = `-- name: UpsertScheduledTask :exec
+INSERT INTO scheduled_tasks (name, schedule, next_run_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE
+SET schedule = EXCLUDED.schedule, next_run_at = EXCLUDED.next_run_at, updated_at = CURRENT_TIMESTAMP
+`
+
+type UpsertScheduledTaskParams struct {
+	Name      string             `db:"name" json:"name"`
+	Schedule  string             `db:"schedule" json:"schedule"`
+	NextRunAt pgtype.Timestamptz `db:"next_run_at" json:"next_run_at"`
+}
+
+func (q *Queries) UpsertScheduledTask(ctx context.Context, arg UpsertScheduledTaskParams) error {
+	_, err := q.db.Exec(ctx, upsertScheduledTask, arg.Name, arg.Schedule, arg.NextRunAt)
+	return err
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// querySampleSize is how many recent durations each query keeps for percentiles.
+const querySampleSize = 512
+
+// QueryStat summarizes the executions of one named query.
+type QueryStat struct {
+	Name   string        `json:"name"`
+	Count  uint64        `json:"count"`
+	Errors uint64        `json:"errors"`
+	Slow   uint64        `json:"slow"`
+	Total  time.Duration `json:"total_ns"`
+	Mean   time.Duration `json:"mean_ns"`
+	Max    time.Duration `json:"max_ns"`
+	P50    time.Duration `json:"p50_ns"`
+	P95    time.Duration `json:"p95_ns"`
+	P99    time.Duration `json:"p99_ns"`
+}
+
+// QueryStats aggregates per-query counts and latencies. Percentiles are
+// computed over the most recent executions of each query.
+type QueryStats struct {
+	mu      sync.Mutex
+	queries map[string]*queryStat
+}
+
+type queryStat struct {
+	count   uint64
+	errors  uint64
+	slow    uint64
+	total   time.Duration
+	max     time.Duration
+	samples []time.Duration
+	next    int
+}
+
+// NewQueryStats creates an empty QueryStats.
+func NewQueryStats() *QueryStats {
+	return &QueryStats{queries: make(map[string]*queryStat)}
+}
+
+// Record adds one execution of the named query.
+func (s *QueryStats) Record(name string, duration time.Duration, failed, slow bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stat, ok := s.queries[name]
+	if !ok {
+		stat = &queryStat{samples: make([]time.Duration, 0, querySampleSize)}
+		s.queries[name] = stat
+	}
+
+	stat.count++
+	stat.total += duration
+	stat.max = max(stat.max, duration)
+	if failed {
+		stat.errors++
+	}
+	if slow {
+		stat.slow++
+	}
+
+	if len(stat.samples) < querySampleSize {
+		stat.samples = append(stat.samples, duration)
+	} else {
+		stat.samples[stat.next] = duration
+		stat.next = (stat.next + 1) % querySampleSize
+	}
+}
+
+// Snapshot returns the current statistics, slowest cumulative time first.
+func (s *QueryStats) Snapshot() []QueryStat {
+	s.mu.Lock()
+	snapshot := make([]QueryStat, 0, len(s.queries))
+	for name, stat := range s.queries {
+		samples := slices.Clone(stat.samples)
+		slices.Sort(samples)
+
+		snapshot = append(snapshot, QueryStat{
+			Name:   name,
+			Count:  stat.count,
+			Errors: stat.errors,
+			Slow:   stat.slow,
+			Total:  stat.total,
+			Mean:   stat.total / time.Duration(stat.count),
+			Max:    stat.max,
+			P50:    percentile(samples, 0.50),
+			P95:    percentile(samples, 0.95),
+			P99:    percentile(samples, 0.99),
+		})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Total != snapshot[j].Total {
+			return snapshot[i].Total > snapshot[j].Total
+		}
+		return snapshot[i].Name < snapshot[j].Name
+	})
+
+	return snapshot
+}
+
+// percentile returns the nearest-rank percentile of sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(p*float64(len(sorted))+0.5) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+
+	return sorted[rank]
+}
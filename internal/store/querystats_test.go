@@ -0,0 +1,56 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryStatsSnapshot(t *testing.T) {
+	t.Parallel()
+
+	stats := NewQueryStats()
+	for i := 1; i <= 100; i++ {
+		stats.Record("GetUser", time.Duration(i)*time.Millisecond, i == 100, i > 90)
+	}
+	stats.Record("CountUsers", time.Second, false, true)
+
+	snapshot := stats.Snapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("snapshot has %d queries, want 2", len(snapshot))
+	}
+
+	// GetUser spent 5.05s in total, CountUsers 1s.
+	getUser := snapshot[0]
+	if getUser.Name != "GetUser" {
+		t.Fatalf("first query = %q, want GetUser", getUser.Name)
+	}
+	if getUser.Count != 100 || getUser.Errors != 1 || getUser.Slow != 10 {
+		t.Fatalf("GetUser counts = %d/%d/%d, want 100/1/10", getUser.Count, getUser.Errors, getUser.Slow)
+	}
+	if getUser.P50 != 50*time.Millisecond || getUser.P95 != 95*time.Millisecond || getUser.P99 != 99*time.Millisecond {
+		t.Fatalf("GetUser percentiles = %v/%v/%v, want 50ms/95ms/99ms", getUser.P50, getUser.P95, getUser.P99)
+	}
+	if getUser.Max != 100*time.Millisecond {
+		t.Fatalf("GetUser max = %v, want 100ms", getUser.Max)
+	}
+}
+
+func TestQueryStatsKeepsRecentSamples(t *testing.T) {
+	t.Parallel()
+
+	stats := NewQueryStats()
+	for range querySampleSize {
+		stats.Record("ListUsers", time.Second, false, false)
+	}
+	for range querySampleSize {
+		stats.Record("ListUsers", time.Millisecond, false, false)
+	}
+
+	stat := stats.Snapshot()[0]
+	if stat.P99 != time.Millisecond {
+		t.Fatalf("P99 = %v, want old samples to be evicted", stat.P99)
+	}
+	if stat.Max != time.Second {
+		t.Fatalf("Max = %v, want all-time maximum", stat.Max)
+	}
+}
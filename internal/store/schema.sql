@@ -8,7 +8,13 @@ CREATE TABLE IF NOT EXISTS users (
     password_hash TEXT NOT NULL,
     is_active BOOLEAN DEFAULT true,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    -- Incremented on every edit for optimistic concurrency control
+    version BIGINT NOT NULL DEFAULT 1,
+    -- Set when a user is moved to the trash; purged after the retention period
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    -- Grants the /admin pages; only set directly in the database
+    is_admin BOOLEAN NOT NULL DEFAULT false
 );
 
 -- Index for faster email lookups
@@ -17,6 +23,9 @@ CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
 -- Index for active users
 CREATE INDEX IF NOT EXISTS idx_users_active ON users(is_active);
 
+-- Index for the trash listing and retention purge
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+
 -- Sessions table for SCS
 CREATE TABLE IF NOT EXISTS sessions (
     token TEXT PRIMARY KEY,
@@ -26,3 +35,222 @@ CREATE TABLE IF NOT EXISTS sessions (
 
 -- Index for session expiry cleanup
 CREATE INDEX IF NOT EXISTS idx_sessions_expiry ON sessions(expiry);
+
+-- Shared token buckets for rate limiting across replicas
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Index for expired bucket cleanup
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_expires_at ON rate_limit_buckets(expires_at);
+
+-- Append-only log of security-relevant and administrative actions. actor_id
+-- has no foreign key so events outlive purged users.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor_id BIGINT,
+    action TEXT NOT NULL,
+    target_type TEXT,
+    target_id BIGINT,
+    ip TEXT,
+    user_agent TEXT,
+    request_id TEXT,
+    before JSONB,
+    after JSONB
+);
+
+-- Indexes for the audit page filters, newest first
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events(action, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events(actor_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target_type, target_id, id);
+
+-- Index for the retention purge
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON audit_events(occurred_at);
+
+-- Reject updates and deletes so the log cannot be rewritten; only the
+-- retention purge may delete, after SET LOCAL app.audit_purge = 'on'
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('app.audit_purge', true) = 'on' THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- Background job queue; workers claim due rows with FOR UPDATE SKIP LOCKED
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    state TEXT NOT NULL DEFAULT 'pending'
+        CONSTRAINT jobs_state_check CHECK (state IN ('pending', 'running', 'succeeded', 'dead', 'cancelled')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    unique_key TEXT,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_at TIMESTAMPTZ,
+    locked_by TEXT,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMPTZ
+);
+
+-- Index for claiming due jobs
+CREATE INDEX IF NOT EXISTS idx_jobs_pending ON jobs(run_at, id) WHERE state = 'pending';
+
+-- Index for rescuing jobs whose worker died
+CREATE INDEX IF NOT EXISTS idx_jobs_running ON jobs(locked_at) WHERE state = 'running';
+
+-- Index for the admin listing
+CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs(state, id);
+
+-- Index for purging finished jobs
+CREATE INDEX IF NOT EXISTS idx_jobs_finished_at ON jobs(finished_at) WHERE finished_at IS NOT NULL;
+
+-- At most one queued or running job per unique key
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_unique_key ON jobs(unique_key)
+    WHERE unique_key IS NOT NULL AND state IN ('pending', 'running');
+
+-- Last run of each periodic maintenance task, shared by every replica
+CREATE TABLE IF NOT EXISTS scheduled_tasks (
+    name TEXT PRIMARY KEY,
+    schedule TEXT NOT NULL,
+    last_tick TIMESTAMPTZ,
+    last_started_at TIMESTAMPTZ,
+    last_finished_at TIMESTAMPTZ,
+    last_status TEXT,
+    last_error TEXT,
+    last_duration_ms BIGINT,
+    next_run_at TIMESTAMPTZ,
+    runs BIGINT NOT NULL DEFAULT 0,
+    failures BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Event data too large for a NOTIFY payload; listeners load it by ID
+CREATE TABLE IF NOT EXISTS event_payloads (
+    id BIGSERIAL PRIMARY KEY,
+    topic TEXT NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for purging delivered payloads
+CREATE INDEX IF NOT EXISTS idx_event_payloads_created_at ON event_payloads(created_at);
+
+-- Receivers of outbound webhooks; an empty events list subscribes to every event
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    description TEXT,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    disabled_reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One event sent to one endpoint, with the outcome of its latest attempt
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    endpoint_id BIGINT NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    state TEXT NOT NULL DEFAULT 'pending'
+        CONSTRAINT webhook_deliveries_state_check CHECK (state IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,
+    response_body TEXT,
+    last_error TEXT,
+    duration_ms BIGINT,
+    redelivery_of BIGINT REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_attempt_at TIMESTAMPTZ,
+    delivered_at TIMESTAMPTZ
+);
+
+-- Index for an endpoint's delivery log
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, id);
+
+-- Index for purging old deliveries
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries(created_at);
+
+-- Bulk user imports uploaded by admins, with their progress and row errors
+CREATE TABLE IF NOT EXISTS user_imports (
+    id BIGSERIAL PRIMARY KEY,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    filename TEXT NOT NULL,
+    format TEXT NOT NULL
+        CONSTRAINT user_imports_format_check CHECK (format IN ('csv', 'ndjson')),
+    mode TEXT NOT NULL
+        CONSTRAINT user_imports_mode_check CHECK (mode IN ('atomic', 'best_effort')),
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    state TEXT NOT NULL DEFAULT 'pending'
+        CONSTRAINT user_imports_state_check CHECK (state IN ('pending', 'running', 'succeeded', 'failed')),
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    created_rows INTEGER NOT NULL DEFAULT 0,
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    row_errors JSONB NOT NULL DEFAULT '[]',
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+-- Uploaded files waiting to be imported; deleted once the import finishes
+CREATE TABLE IF NOT EXISTS user_import_files (
+    import_id BIGINT PRIMARY KEY REFERENCES user_imports(id) ON DELETE CASCADE,
+    data BYTEA NOT NULL
+);
+
+-- Personal data archives users request from their profile
+CREATE TABLE IF NOT EXISTS data_exports (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format TEXT NOT NULL
+        CONSTRAINT data_exports_format_check CHECK (format IN ('zip', 'json')),
+    state TEXT NOT NULL DEFAULT 'pending'
+        CONSTRAINT data_exports_state_check CHECK (state IN ('pending', 'running', 'succeeded', 'failed')),
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+);
+
+-- Index for listing a user's archives
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id, id);
+
+-- Index for purging expired archives
+CREATE INDEX IF NOT EXISTS idx_data_exports_expires_at ON data_exports(expires_at) WHERE expires_at IS NOT NULL;
+
+-- Generated archives, deleted with their export
+CREATE TABLE IF NOT EXISTS data_export_files (
+    export_id BIGINT PRIMARY KEY REFERENCES data_exports(id) ON DELETE CASCADE,
+    data BYTEA NOT NULL
+);
+
+-- Pending account deletions; the account is erased once delete_after passes
+CREATE TABLE IF NOT EXISTS account_deletions (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    requested_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delete_after TIMESTAMPTZ NOT NULL
+);
+
+-- Index for finding deletions that are due
+CREATE INDEX IF NOT EXISTS idx_account_deletions_delete_after ON account_deletions(delete_after);
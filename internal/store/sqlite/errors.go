@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// translateError maps SQLite errors to the store package's backend-neutral
+// ones, so handlers check store.ErrNotFound and store.AsConstraintError
+// whichever backend is configured.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	var kind store.ConstraintKind
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		kind = store.ConstraintUnique
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		kind = store.ConstraintNotNull
+	default:
+		return err
+	}
+
+	return &store.ConstraintError{
+		Kind:   kind,
+		Column: constraintColumn(sqliteErr.Error()),
+		Err:    err,
+	}
+}
+
+// constraintColumn extracts the column from messages such as
+// "UNIQUE constraint failed: users.email (2067)". Multi-column constraints
+// report their first column.
+func constraintColumn(message string) string {
+	const marker = "constraint failed: "
+	i := strings.LastIndex(message, marker)
+	if i < 0 {
+		return ""
+	}
+
+	columns, _, _ := strings.Cut(message[i+len(marker):], " (")
+	column, _, _ := strings.Cut(columns, ", ")
+	if _, name, found := strings.Cut(column, "."); found {
+		column = name
+	}
+
+	return strings.ToLower(column)
+}
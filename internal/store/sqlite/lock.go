@@ -0,0 +1,23 @@
+package sqlite
+
+import "context"
+
+// WithAdvisoryLock implements store.Locker. SQLite has no advisory locks and
+// its deployments run a single node, so the lock is held in this process.
+func (s *Store) WithAdvisoryLock(ctx context.Context, key int64, fn func(ctx context.Context) error) (bool, error) {
+	s.mu.Lock()
+	if s.locks[key] {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.locks[key] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.locks, key)
+		s.mu.Unlock()
+	}()
+
+	return true, fn(ctx)
+}
@@ -0,0 +1,477 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// querier implements store.Querier on the generated queries, converting
+// their rows to store types and their errors with translateError.
+type querier struct {
+	queries *Queries
+	traced  *tracedDB
+	// inTx is set on queriers handed to RunInTx callbacks.
+	inTx bool
+	// auditPurge records that AllowAuditPurge inserted the purge flag, which
+	// RunInTx clears before committing.
+	auditPurge bool
+}
+
+var _ store.Querier = (*querier)(nil)
+
+func newQuerier(traced *tracedDB, inTx bool) *querier {
+	return &querier{queries: New(traced), traced: traced, inTx: inTx}
+}
+
+// convertRows converts the rows of a :many query, keeping a nil slice nil
+// like the PostgreSQL queries do.
+func convertRows[S, T any](rows []S, err error, convert func(S) T) ([]T, error) {
+	if err != nil {
+		return nil, translateError(err)
+	}
+	if rows == nil {
+		return nil, nil
+	}
+
+	converted := make([]T, len(rows))
+	for i, row := range rows {
+		converted[i] = convert(row)
+	}
+
+	return converted, nil
+}
+
+func (q *querier) CancelAccountDeletion(ctx context.Context, userID int64) (int64, error) {
+	result, err := q.queries.CancelAccountDeletion(ctx, userID)
+	return result, translateError(err)
+}
+
+func (q *querier) CancelJob(ctx context.Context, id int64) (int64, error) {
+	result, err := q.queries.CancelJob(ctx, id)
+	return result, translateError(err)
+}
+
+func (q *querier) ClaimJobs(ctx context.Context, arg store.ClaimJobsParams) ([]store.Job, error) {
+	rows, err := q.queries.ClaimJobs(ctx, ClaimJobsParams{
+		LockedBy: arg.Worker,
+		Kinds:    arg.Kinds,
+		Limit:    int64(arg.MaxJobs),
+	})
+	return convertRows(rows, err, func(row Job) store.Job { return store.Job(row) })
+}
+
+func (q *querier) ClaimScheduledTick(ctx context.Context, arg store.ClaimScheduledTickParams) (int64, error) {
+	result, err := q.queries.ClaimScheduledTick(ctx, ClaimScheduledTickParams(arg))
+	return result, translateError(err)
+}
+
+func (q *querier) CompleteJob(ctx context.Context, id int64) error {
+	return translateError(q.queries.CompleteJob(ctx, id))
+}
+
+func (q *querier) CountJobsByState(ctx context.Context) ([]store.CountJobsByStateRow, error) {
+	rows, err := q.queries.CountJobsByState(ctx)
+	return convertRows(rows, err, func(row CountJobsByStateRow) store.CountJobsByStateRow { return store.CountJobsByStateRow(row) })
+}
+
+func (q *querier) CountUsers(ctx context.Context) (int64, error) {
+	result, err := q.queries.CountUsers(ctx)
+	return result, translateError(err)
+}
+
+func (q *querier) CreateAuditEvent(ctx context.Context, arg store.CreateAuditEventParams) error {
+	return translateError(q.queries.CreateAuditEvent(ctx, CreateAuditEventParams(arg)))
+}
+
+func (q *querier) CreateDataExport(ctx context.Context, arg store.CreateDataExportParams) (store.DataExport, error) {
+	row, err := q.queries.CreateDataExport(ctx, CreateDataExportParams(arg))
+	return store.DataExport(row), translateError(err)
+}
+
+func (q *querier) CreateDataExportFile(ctx context.Context, arg store.CreateDataExportFileParams) error {
+	return translateError(q.queries.CreateDataExportFile(ctx, CreateDataExportFileParams(arg)))
+}
+
+func (q *querier) CreateEventPayload(ctx context.Context, arg store.CreateEventPayloadParams) (int64, error) {
+	result, err := q.queries.CreateEventPayload(ctx, CreateEventPayloadParams(arg))
+	return result, translateError(err)
+}
+
+func (q *querier) CreateUser(ctx context.Context, arg store.CreateUserParams) (store.User, error) {
+	row, err := q.queries.CreateUser(ctx, CreateUserParams(arg))
+	return store.User(row), translateError(err)
+}
+
+func (q *querier) CreateUserImport(ctx context.Context, arg store.CreateUserImportParams) (store.UserImport, error) {
+	row, err := q.queries.CreateUserImport(ctx, CreateUserImportParams(arg))
+	return store.UserImport(row), translateError(err)
+}
+
+func (q *querier) CreateUserImportFile(ctx context.Context, arg store.CreateUserImportFileParams) error {
+	return translateError(q.queries.CreateUserImportFile(ctx, CreateUserImportFileParams(arg)))
+}
+
+func (q *querier) CreateWebhookDelivery(ctx context.Context, arg store.CreateWebhookDeliveryParams) (store.WebhookDelivery, error) {
+	row, err := q.queries.CreateWebhookDelivery(ctx, CreateWebhookDeliveryParams(arg))
+	return store.WebhookDelivery(row), translateError(err)
+}
+
+func (q *querier) CreateWebhookEndpoint(ctx context.Context, arg store.CreateWebhookEndpointParams) (store.WebhookEndpoint, error) {
+	row, err := q.queries.CreateWebhookEndpoint(ctx, CreateWebhookEndpointParams{
+		Url:         arg.Url,
+		Description: arg.Description,
+		Secret:      arg.Secret,
+		Events:      stringList(arg.Events),
+	})
+	return webhookEndpoint(row), translateError(err)
+}
+
+func (q *querier) DeactivateUser(ctx context.Context, id int64) error {
+	return translateError(q.queries.DeactivateUser(ctx, id))
+}
+
+func (q *querier) DeleteExpiredRateLimitBuckets(ctx context.Context) (int64, error) {
+	result, err := q.queries.DeleteExpiredRateLimitBuckets(ctx)
+	return result, translateError(err)
+}
+
+func (q *querier) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	result, err := q.queries.DeleteExpiredSessions(ctx)
+	return result, translateError(err)
+}
+
+func (q *querier) DeleteUser(ctx context.Context, id int64) error {
+	return translateError(q.queries.DeleteUser(ctx, id))
+}
+
+func (q *querier) DeleteUserImportFile(ctx context.Context, importID int64) error {
+	return translateError(q.queries.DeleteUserImportFile(ctx, importID))
+}
+
+func (q *querier) DeleteWebhookEndpoint(ctx context.Context, id int64) (int64, error) {
+	result, err := q.queries.DeleteWebhookEndpoint(ctx, id)
+	return result, translateError(err)
+}
+
+func (q *querier) EnqueueJob(ctx context.Context, arg store.EnqueueJobParams) (store.Job, error) {
+	row, err := q.queries.EnqueueJob(ctx, EnqueueJobParams{
+		Kind:        arg.Kind,
+		Payload:     arg.Payload,
+		MaxAttempts: arg.MaxAttempts,
+		UniqueKey:   arg.UniqueKey,
+		RunAt:       arg.RunAt,
+	})
+	return store.Job(row), translateError(err)
+}
+
+func (q *querier) EraseUser(ctx context.Context, arg store.EraseUserParams) (store.User, error) {
+	row, err := q.queries.EraseUser(ctx, EraseUserParams{ID: arg.ID, DueAt: arg.DueAt})
+	return store.User(row), translateError(err)
+}
+
+func (q *querier) FailJob(ctx context.Context, arg store.FailJobParams) error {
+	return translateError(q.queries.FailJob(ctx, FailJobParams(arg)))
+}
+
+func (q *querier) FailWebhookDelivery(ctx context.Context, arg store.FailWebhookDeliveryParams) error {
+	return translateError(q.queries.FailWebhookDelivery(ctx, FailWebhookDeliveryParams(arg)))
+}
+
+func (q *querier) FinishDataExport(ctx context.Context, arg store.FinishDataExportParams) error {
+	return translateError(q.queries.FinishDataExport(ctx, FinishDataExportParams(arg)))
+}
+
+func (q *querier) FinishScheduledTask(ctx context.Context, arg store.FinishScheduledTaskParams) error {
+	return translateError(q.queries.FinishScheduledTask(ctx, FinishScheduledTaskParams(arg)))
+}
+
+func (q *querier) FinishUserImport(ctx context.Context, arg store.FinishUserImportParams) error {
+	return translateError(q.queries.FinishUserImport(ctx, FinishUserImportParams(arg)))
+}
+
+func (q *querier) FinishWebhookAttempt(ctx context.Context, arg store.FinishWebhookAttemptParams) error {
+	return translateError(q.queries.FinishWebhookAttempt(ctx, FinishWebhookAttemptParams(arg)))
+}
+
+func (q *querier) GetAccountDeletion(ctx context.Context, userID int64) (store.AccountDeletion, error) {
+	row, err := q.queries.GetAccountDeletion(ctx, userID)
+	return store.AccountDeletion(row), translateError(err)
+}
+
+func (q *querier) GetDataExport(ctx context.Context, id int64) (store.DataExport, error) {
+	row, err := q.queries.GetDataExport(ctx, id)
+	return store.DataExport(row), translateError(err)
+}
+
+func (q *querier) GetDataExportFile(ctx context.Context, exportID int64) ([]byte, error) {
+	result, err := q.queries.GetDataExportFile(ctx, exportID)
+	return result, translateError(err)
+}
+
+func (q *querier) GetEventPayload(ctx context.Context, id int64) ([]byte, error) {
+	result, err := q.queries.GetEventPayload(ctx, id)
+	return result, translateError(err)
+}
+
+func (q *querier) GetUser(ctx context.Context, id int64) (store.User, error) {
+	row, err := q.queries.GetUser(ctx, id)
+	return store.User(row), translateError(err)
+}
+
+func (q *querier) GetUserByEmail(ctx context.Context, email string) (store.User, error) {
+	row, err := q.queries.GetUserByEmail(ctx, email)
+	return store.User(row), translateError(err)
+}
+
+func (q *querier) GetUserImport(ctx context.Context, id int64) (store.UserImport, error) {
+	row, err := q.queries.GetUserImport(ctx, id)
+	return store.UserImport(row), translateError(err)
+}
+
+func (q *querier) GetUserImportFile(ctx context.Context, importID int64) ([]byte, error) {
+	result, err := q.queries.GetUserImportFile(ctx, importID)
+	return result, translateError(err)
+}
+
+func (q *querier) GetWebhookDelivery(ctx context.Context, id int64) (store.WebhookDelivery, error) {
+	row, err := q.queries.GetWebhookDelivery(ctx, id)
+	return store.WebhookDelivery(row), translateError(err)
+}
+
+func (q *querier) GetWebhookEndpoint(ctx context.Context, id int64) (store.WebhookEndpoint, error) {
+	row, err := q.queries.GetWebhookEndpoint(ctx, id)
+	return webhookEndpoint(row), translateError(err)
+}
+
+func (q *querier) ListAccountDeletions(ctx context.Context) ([]store.ListAccountDeletionsRow, error) {
+	rows, err := q.queries.ListAccountDeletions(ctx)
+	return convertRows(rows, err, func(row ListAccountDeletionsRow) store.ListAccountDeletionsRow {
+		return store.ListAccountDeletionsRow(row)
+	})
+}
+
+func (q *querier) ListAllUsers(ctx context.Context) ([]store.User, error) {
+	rows, err := q.queries.ListAllUsers(ctx)
+	return convertRows(rows, err, func(row User) store.User { return store.User(row) })
+}
+
+func (q *querier) ListAuditEvents(ctx context.Context, arg store.ListAuditEventsParams) ([]store.ListAuditEventsRow, error) {
+	rows, err := q.queries.ListAuditEvents(ctx, ListAuditEventsParams{
+		Action:     arg.Action,
+		ActorEmail: arg.ActorEmail,
+		TargetID:   arg.TargetID,
+		BeforeID:   arg.BeforeID,
+		MaxRows:    int64(arg.MaxRows),
+	})
+	return convertRows(rows, err, func(row ListAuditEventsRow) store.ListAuditEventsRow { return store.ListAuditEventsRow(row) })
+}
+
+func (q *querier) ListDataExports(ctx context.Context, arg store.ListDataExportsParams) ([]store.DataExport, error) {
+	rows, err := q.queries.ListDataExports(ctx, ListDataExportsParams{UserID: arg.UserID, MaxRows: int64(arg.MaxRows)})
+	return convertRows(rows, err, func(row DataExport) store.DataExport { return store.DataExport(row) })
+}
+
+func (q *querier) ListDeletedUsers(ctx context.Context) ([]store.User, error) {
+	rows, err := q.queries.ListDeletedUsers(ctx)
+	return convertRows(rows, err, func(row User) store.User { return store.User(row) })
+}
+
+func (q *querier) ListDueAccountDeletions(ctx context.Context, dueAt pgtype.Timestamptz) ([]store.AccountDeletion, error) {
+	rows, err := q.queries.ListDueAccountDeletions(ctx, dueAt)
+	return convertRows(rows, err, func(row AccountDeletion) store.AccountDeletion { return store.AccountDeletion(row) })
+}
+
+func (q *querier) ListJobs(ctx context.Context, arg store.ListJobsParams) ([]store.Job, error) {
+	rows, err := q.queries.ListJobs(ctx, ListJobsParams{
+		State:    arg.State,
+		BeforeID: arg.BeforeID,
+		MaxRows:  int64(arg.MaxRows),
+	})
+	return convertRows(rows, err, func(row Job) store.Job { return store.Job(row) })
+}
+
+func (q *querier) ListScheduledTasks(ctx context.Context) ([]store.ScheduledTask, error) {
+	rows, err := q.queries.ListScheduledTasks(ctx)
+	return convertRows(rows, err, func(row ScheduledTask) store.ScheduledTask { return store.ScheduledTask(row) })
+}
+
+func (q *querier) ListUserAuditEvents(ctx context.Context, userID int64) ([]store.AuditEvent, error) {
+	rows, err := q.queries.ListUserAuditEvents(ctx, &userID)
+	return convertRows(rows, err, func(row AuditEvent) store.AuditEvent { return store.AuditEvent(row) })
+}
+
+func (q *querier) ListUserImports(ctx context.Context, maxRows int32) ([]store.UserImport, error) {
+	rows, err := q.queries.ListUserImports(ctx, int64(maxRows))
+	return convertRows(rows, err, func(row UserImport) store.UserImport { return store.UserImport(row) })
+}
+
+func (q *querier) ListUsers(ctx context.Context) ([]store.User, error) {
+	rows, err := q.queries.ListUsers(ctx)
+	return convertRows(rows, err, func(row User) store.User { return store.User(row) })
+}
+
+func (q *querier) ListWebhookDeliveries(ctx context.Context, arg store.ListWebhookDeliveriesParams) ([]store.WebhookDelivery, error) {
+	rows, err := q.queries.ListWebhookDeliveries(ctx, ListWebhookDeliveriesParams{
+		EndpointID: arg.EndpointID,
+		BeforeID:   arg.BeforeID,
+		MaxRows:    int64(arg.MaxRows),
+	})
+	return convertRows(rows, err, func(row WebhookDelivery) store.WebhookDelivery { return store.WebhookDelivery(row) })
+}
+
+func (q *querier) ListWebhookEndpoints(ctx context.Context) ([]store.WebhookEndpoint, error) {
+	rows, err := q.queries.ListWebhookEndpoints(ctx)
+	return convertRows(rows, err, webhookEndpoint)
+}
+
+func (q *querier) ListWebhookEndpointsForEvent(ctx context.Context, event string) ([]store.WebhookEndpoint, error) {
+	rows, err := q.queries.ListWebhookEndpointsForEvent(ctx, event)
+	return convertRows(rows, err, webhookEndpoint)
+}
+
+func (q *querier) PurgeAuditEvents(ctx context.Context, occurredBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.queries.PurgeAuditEvents(ctx, occurredBefore)
+	return result, translateError(err)
+}
+
+func (q *querier) PurgeDataExports(ctx context.Context, expiresBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.queries.PurgeDataExports(ctx, expiresBefore)
+	return result, translateError(err)
+}
+
+func (q *querier) PurgeDeletedUsers(ctx context.Context, deletedBefore pgtype.Timestamptz) ([]store.PurgeDeletedUsersRow, error) {
+	rows, err := q.queries.PurgeDeletedUsers(ctx, deletedBefore)
+	return convertRows(rows, err, func(row PurgeDeletedUsersRow) store.PurgeDeletedUsersRow { return store.PurgeDeletedUsersRow(row) })
+}
+
+func (q *querier) PurgeEventPayloads(ctx context.Context, createdBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.queries.PurgeEventPayloads(ctx, createdBefore)
+	return result, translateError(err)
+}
+
+func (q *querier) PurgeFinishedJobs(ctx context.Context, finishedBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.queries.PurgeFinishedJobs(ctx, finishedBefore)
+	return result, translateError(err)
+}
+
+func (q *querier) PurgeWebhookDeliveries(ctx context.Context, createdBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.queries.PurgeWebhookDeliveries(ctx, createdBefore)
+	return result, translateError(err)
+}
+
+func (q *querier) ReactivateUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.queries.ReactivateUser(ctx, id)
+	return result, translateError(err)
+}
+
+func (q *querier) RecordWebhookEndpointFailure(ctx context.Context, arg store.RecordWebhookEndpointFailureParams) (store.WebhookEndpoint, error) {
+	row, err := q.queries.RecordWebhookEndpointFailure(ctx, RecordWebhookEndpointFailureParams{
+		DisableAfter: int64(arg.DisableAfter),
+		Reason:       arg.Reason,
+		ID:           arg.ID,
+	})
+	return webhookEndpoint(row), translateError(err)
+}
+
+func (q *querier) RecordWebhookEndpointSuccess(ctx context.Context, id int64) error {
+	return translateError(q.queries.RecordWebhookEndpointSuccess(ctx, id))
+}
+
+func (q *querier) ReleaseJob(ctx context.Context, id int64) error {
+	return translateError(q.queries.ReleaseJob(ctx, id))
+}
+
+func (q *querier) RequestAccountDeletion(ctx context.Context, arg store.RequestAccountDeletionParams) (store.AccountDeletion, error) {
+	row, err := q.queries.RequestAccountDeletion(ctx, RequestAccountDeletionParams(arg))
+	return store.AccountDeletion(row), translateError(err)
+}
+
+func (q *querier) RescueStaleJobs(ctx context.Context, lockedBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.queries.RescueStaleJobs(ctx, lockedBefore)
+	return result, translateError(err)
+}
+
+func (q *querier) RestoreUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.queries.RestoreUser(ctx, id)
+	return result, translateError(err)
+}
+
+func (q *querier) RetryJob(ctx context.Context, id int64) (int64, error) {
+	result, err := q.queries.RetryJob(ctx, id)
+	return result, translateError(err)
+}
+
+func (q *querier) ScheduleJobRetry(ctx context.Context, arg store.ScheduleJobRetryParams) error {
+	return translateError(q.queries.ScheduleJobRetry(ctx, ScheduleJobRetryParams(arg)))
+}
+
+func (q *querier) SearchUsers(ctx context.Context, arg store.SearchUsersParams) ([]store.User, error) {
+	rows, err := q.queries.SearchUsers(ctx, SearchUsersParams(arg))
+	return convertRows(rows, err, func(row User) store.User { return store.User(row) })
+}
+
+func (q *querier) SetWebhookEndpointEnabled(ctx context.Context, arg store.SetWebhookEndpointEnabledParams) (int64, error) {
+	result, err := q.queries.SetWebhookEndpointEnabled(ctx, SetWebhookEndpointEnabledParams(arg))
+	return result, translateError(err)
+}
+
+func (q *querier) SoftDeleteUser(ctx context.Context, id int64) (int64, error) {
+	result, err := q.queries.SoftDeleteUser(ctx, id)
+	return result, translateError(err)
+}
+
+func (q *querier) StartDataExport(ctx context.Context, id int64) (int64, error) {
+	result, err := q.queries.StartDataExport(ctx, id)
+	return result, translateError(err)
+}
+
+func (q *querier) StartUserImport(ctx context.Context, arg store.StartUserImportParams) (int64, error) {
+	result, err := q.queries.StartUserImport(ctx, StartUserImportParams(arg))
+	return result, translateError(err)
+}
+
+func (q *querier) TakeRateLimitToken(ctx context.Context, arg store.TakeRateLimitTokenParams) (store.TakeRateLimitTokenRow, error) {
+	row, err := q.queries.TakeRateLimitToken(ctx, TakeRateLimitTokenParams{
+		Key:             arg.Key,
+		Capacity:        arg.Capacity,
+		RefillPerSecond: arg.RefillPerSecond,
+		TtlSeconds:      arg.TtlSeconds,
+	})
+	return store.TakeRateLimitTokenRow(row), translateError(err)
+}
+
+func (q *querier) UpdateUser(ctx context.Context, arg store.UpdateUserParams) (store.User, error) {
+	row, err := q.queries.UpdateUser(ctx, UpdateUserParams(arg))
+	return store.User(row), translateError(err)
+}
+
+func (q *querier) UpdateUserImportProgress(ctx context.Context, arg store.UpdateUserImportProgressParams) error {
+	return translateError(q.queries.UpdateUserImportProgress(ctx, UpdateUserImportProgressParams(arg)))
+}
+
+func (q *querier) UpdateUserPassword(ctx context.Context, arg store.UpdateUserPasswordParams) (store.User, error) {
+	row, err := q.queries.UpdateUserPassword(ctx, UpdateUserPasswordParams(arg))
+	return store.User(row), translateError(err)
+}
+
+func (q *querier) UpsertScheduledTask(ctx context.Context, arg store.UpsertScheduledTaskParams) error {
+	return translateError(q.queries.UpsertScheduledTask(ctx, UpsertScheduledTaskParams(arg)))
+}
+
+// webhookEndpoint converts an endpoint row, whose events are stored as JSON.
+func webhookEndpoint(row WebhookEndpoint) store.WebhookEndpoint {
+	return store.WebhookEndpoint{
+		ID:                  row.ID,
+		Url:                 row.Url,
+		Description:         row.Description,
+		Secret:              row.Secret,
+		Events:              []string(row.Events),
+		Enabled:             row.Enabled,
+		ConsecutiveFailures: row.ConsecutiveFailures,
+		DisabledReason:      row.DisabledReason,
+		CreatedAt:           row.CreatedAt,
+		UpdatedAt:           row.UpdatedAt,
+	}
+}
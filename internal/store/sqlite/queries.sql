@@ -0,0 +1,451 @@
+-- SQLite versions of internal/store/queries.sql. Timestamps written by Go
+-- carry their own UTC offset, so comparisons and orderings on them go through
+-- julianday() rather than comparing the text.
+
+-- name: GetUser :one
+SELECT * FROM users WHERE id = sqlc.arg(id) AND deleted_at IS NULL LIMIT 1;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users WHERE email = sqlc.arg(email) AND deleted_at IS NULL LIMIT 1;
+
+-- name: ListUsers :many
+SELECT * FROM users
+WHERE is_active = TRUE AND deleted_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: ListAllUsers :many
+SELECT * FROM users WHERE deleted_at IS NULL ORDER BY created_at DESC;
+
+-- name: SearchUsers :many
+-- Lists users who are not deleted, newest first. A NULL status matches active and inactive users; a NULL query matches every name and email.
+SELECT * FROM users
+WHERE deleted_at IS NULL
+  AND (CAST(sqlc.narg(status) AS TEXT) IS NULL
+       OR (CAST(sqlc.narg(status) AS TEXT) = 'active') = COALESCE(is_active, FALSE))
+  AND (CAST(sqlc.narg(query) AS TEXT) IS NULL
+       OR instr(lower(email), lower(CAST(sqlc.narg(query) AS TEXT))) > 0
+       OR instr(lower(name), lower(CAST(sqlc.narg(query) AS TEXT))) > 0)
+ORDER BY created_at DESC, id DESC;
+
+-- name: CreateUser :one
+INSERT INTO users (email, name, bio, avatar_url, password_hash)
+VALUES (sqlc.arg(email), sqlc.arg(name), sqlc.narg(bio), sqlc.narg(avatar_url), sqlc.arg(password_hash))
+RETURNING *;
+
+-- name: UpdateUser :one
+UPDATE users
+SET email = sqlc.arg(email), name = sqlc.arg(name), bio = sqlc.narg(bio), avatar_url = sqlc.narg(avatar_url),
+    version = version + 1, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND version = sqlc.arg(version) AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET email = sqlc.arg(email), name = sqlc.arg(name), bio = sqlc.narg(bio), avatar_url = sqlc.narg(avatar_url),
+    password_hash = sqlc.arg(password_hash), version = version + 1, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND version = sqlc.arg(version) AND deleted_at IS NULL
+RETURNING *;
+
+-- name: DeactivateUser :exec
+UPDATE users
+SET is_active = FALSE, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND deleted_at IS NULL;
+
+-- name: ReactivateUser :execrows
+UPDATE users
+SET is_active = TRUE, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND deleted_at IS NULL;
+
+-- name: SoftDeleteUser :execrows
+UPDATE users
+SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND deleted_at IS NULL;
+
+-- name: RestoreUser :execrows
+UPDATE users
+SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND deleted_at IS NOT NULL;
+
+-- name: ListDeletedUsers :many
+SELECT * FROM users
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
+
+-- name: PurgeDeletedUsers :many
+DELETE FROM users
+WHERE julianday(deleted_at) < julianday(sqlc.arg(deleted_before))
+RETURNING id, avatar_url;
+
+-- name: DeleteUser :exec
+DELETE FROM users WHERE id = sqlc.arg(id);
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users WHERE is_active = TRUE AND deleted_at IS NULL;
+
+-- name: TakeRateLimitToken :one
+-- SQLite upserts cannot bind parameters in DO UPDATE, so the policy travels in the inserted row.
+INSERT INTO rate_limit_buckets (key, tokens, allowed, capacity, refill_per_second, updated_at, expires_at)
+VALUES (
+    sqlc.arg(key),
+    CAST(sqlc.arg(capacity) AS REAL) - 1,
+    TRUE,
+    sqlc.arg(capacity),
+    sqlc.arg(refill_per_second),
+    strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    datetime('now', printf('%+f seconds', CAST(sqlc.arg(ttl_seconds) AS REAL)))
+)
+ON CONFLICT (key) DO UPDATE
+SET tokens = CASE
+        WHEN min(excluded.capacity, tokens + (julianday(excluded.updated_at) - julianday(updated_at)) * 86400.0 * excluded.refill_per_second) >= 1
+        THEN min(excluded.capacity, tokens + (julianday(excluded.updated_at) - julianday(updated_at)) * 86400.0 * excluded.refill_per_second) - 1
+        ELSE min(excluded.capacity, tokens + (julianday(excluded.updated_at) - julianday(updated_at)) * 86400.0 * excluded.refill_per_second)
+    END,
+    allowed = min(excluded.capacity, tokens + (julianday(excluded.updated_at) - julianday(updated_at)) * 86400.0 * excluded.refill_per_second) >= 1,
+    capacity = excluded.capacity,
+    refill_per_second = excluded.refill_per_second,
+    updated_at = excluded.updated_at,
+    expires_at = excluded.expires_at
+RETURNING tokens, allowed;
+
+-- name: DeleteExpiredRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets WHERE julianday(expires_at) < julianday('now');
+
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (actor_id, action, target_type, target_id, ip, user_agent, request_id, before, after)
+VALUES (
+    sqlc.narg(actor_id), sqlc.arg(action), sqlc.narg(target_type), sqlc.narg(target_id), sqlc.narg(ip),
+    sqlc.narg(user_agent), sqlc.narg(request_id), sqlc.arg(before), sqlc.arg(after)
+);
+
+-- name: ListAuditEvents :many
+SELECT a.id, a.occurred_at, a.actor_id, u.email AS actor_email, a.action, a.target_type, a.target_id,
+    a.ip, a.user_agent, a.request_id, a.before, a.after
+FROM audit_events a
+LEFT JOIN users u ON u.id = a.actor_id
+WHERE (CAST(sqlc.narg(action) AS TEXT) IS NULL OR a.action = sqlc.narg(action))
+  AND (CAST(sqlc.narg(actor_email) AS TEXT) IS NULL OR u.email = sqlc.narg(actor_email))
+  AND (CAST(sqlc.narg(target_id) AS INTEGER) IS NULL OR a.target_id = sqlc.narg(target_id))
+  AND (CAST(sqlc.narg(before_id) AS INTEGER) IS NULL OR a.id < sqlc.narg(before_id))
+ORDER BY a.id DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: EnqueueJob :one
+INSERT INTO jobs (kind, payload, max_attempts, unique_key, run_at)
+VALUES (
+    sqlc.arg(kind),
+    sqlc.arg(payload),
+    sqlc.arg(max_attempts),
+    sqlc.narg(unique_key),
+    COALESCE(sqlc.narg(run_at), CURRENT_TIMESTAMP)
+)
+ON CONFLICT (unique_key) WHERE unique_key IS NOT NULL AND state IN ('pending', 'running') DO NOTHING
+RETURNING *;
+
+-- name: ClaimJobs :many
+-- Placeholders are positional because sqlc's numbered arguments shift once the kinds slice expands.
+UPDATE jobs
+SET state = 'running', attempts = attempts + 1, locked_at = CURRENT_TIMESTAMP,
+    locked_by = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id IN (
+    SELECT j.id FROM jobs j
+    WHERE j.state = 'pending' AND julianday(j.run_at) <= julianday('now') AND j.kind IN (sqlc.slice(kinds))
+    ORDER BY julianday(j.run_at), j.id
+    LIMIT ?
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET state = 'succeeded', locked_at = NULL, locked_by = NULL, last_error = NULL,
+    finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND state = 'running';
+
+-- name: ScheduleJobRetry :exec
+UPDATE jobs
+SET state = 'pending', run_at = sqlc.arg(run_at), last_error = CAST(sqlc.arg(last_error) AS TEXT),
+    locked_at = NULL, locked_by = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND state = 'running';
+
+-- name: FailJob :exec
+UPDATE jobs
+SET state = 'dead', last_error = CAST(sqlc.arg(last_error) AS TEXT), locked_at = NULL, locked_by = NULL,
+    finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND state = 'running';
+
+-- name: ReleaseJob :exec
+UPDATE jobs
+SET state = 'pending', attempts = attempts - 1, locked_at = NULL, locked_by = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND state = 'running';
+
+-- name: RescueStaleJobs :execrows
+UPDATE jobs
+SET state = 'pending', last_error = 'worker stopped before finishing the job', locked_at = NULL,
+    locked_by = NULL, updated_at = CURRENT_TIMESTAMP
+WHERE state = 'running' AND julianday(locked_at) < julianday(sqlc.arg(locked_before));
+
+-- name: RetryJob :execrows
+UPDATE jobs
+SET state = 'pending', attempts = 0, run_at = CURRENT_TIMESTAMP, finished_at = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND state IN ('dead', 'cancelled');
+
+-- name: CancelJob :execrows
+UPDATE jobs
+SET state = 'cancelled', finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND state = 'pending';
+
+-- name: ListJobs :many
+SELECT * FROM jobs
+WHERE (CAST(sqlc.narg(state) AS TEXT) IS NULL OR state = sqlc.narg(state))
+  AND (CAST(sqlc.narg(before_id) AS INTEGER) IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: CountJobsByState :many
+SELECT state, COUNT(*) AS count FROM jobs GROUP BY state;
+
+-- name: PurgeFinishedJobs :execrows
+DELETE FROM jobs
+WHERE state IN ('succeeded', 'dead', 'cancelled') AND julianday(finished_at) < julianday(sqlc.arg(finished_before));
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expiry < julianday('now');
+
+-- name: AllowAuditPurge :exec
+-- Lets PurgeAuditEvents past the append-only trigger until ClearAuditPurge runs.
+INSERT INTO audit_purge (allowed) VALUES (TRUE);
+
+-- name: ClearAuditPurge :exec
+DELETE FROM audit_purge;
+
+-- name: PurgeAuditEvents :execrows
+DELETE FROM audit_events WHERE julianday(occurred_at) < julianday(sqlc.arg(occurred_before));
+
+-- name: UpsertScheduledTask :exec
+INSERT INTO scheduled_tasks (name, schedule, next_run_at)
+VALUES (sqlc.arg(name), sqlc.arg(schedule), sqlc.arg(next_run_at))
+ON CONFLICT (name) DO UPDATE
+SET schedule = excluded.schedule, next_run_at = excluded.next_run_at, updated_at = CURRENT_TIMESTAMP;
+
+-- name: ClaimScheduledTick :execrows
+-- Claims a tick once; a tick that already ran affects no rows.
+UPDATE scheduled_tasks
+SET last_tick = sqlc.arg(tick), last_started_at = CURRENT_TIMESTAMP, last_status = 'running',
+    updated_at = CURRENT_TIMESTAMP
+WHERE name = sqlc.arg(name) AND (last_tick IS NULL OR julianday(last_tick) < julianday(sqlc.arg(tick)));
+
+-- name: FinishScheduledTask :exec
+UPDATE scheduled_tasks
+SET last_status = CAST(sqlc.arg(status) AS TEXT), last_error = sqlc.narg(last_error),
+    last_finished_at = CURRENT_TIMESTAMP, last_duration_ms = sqlc.narg(duration_ms),
+    next_run_at = sqlc.arg(next_run_at), runs = runs + 1,
+    failures = failures + CASE WHEN CAST(sqlc.arg(status) AS TEXT) = 'failed' THEN 1 ELSE 0 END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE name = sqlc.arg(name);
+
+-- name: ListScheduledTasks :many
+SELECT * FROM scheduled_tasks ORDER BY name;
+
+-- name: CreateEventPayload :one
+INSERT INTO event_payloads (topic, data)
+VALUES (sqlc.arg(topic), sqlc.arg(data))
+RETURNING id;
+
+-- name: GetEventPayload :one
+SELECT data FROM event_payloads WHERE id = sqlc.arg(id);
+
+-- name: PurgeEventPayloads :execrows
+DELETE FROM event_payloads WHERE julianday(created_at) < julianday(sqlc.arg(created_before));
+
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (url, description, secret, events)
+VALUES (sqlc.arg(url), sqlc.narg(description), sqlc.arg(secret), sqlc.arg(events))
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints WHERE id = sqlc.arg(id);
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints ORDER BY id;
+
+-- name: ListWebhookEndpointsForEvent :many
+-- Lists the enabled endpoints subscribed to event; an empty events list subscribes to all.
+SELECT * FROM webhook_endpoints
+WHERE enabled
+  AND (json_array_length(events) = 0
+       OR EXISTS (SELECT 1 FROM json_each(webhook_endpoints.events) WHERE json_each.value = CAST(sqlc.arg(event) AS TEXT)))
+ORDER BY id;
+
+-- name: SetWebhookEndpointEnabled :execrows
+-- Enabling an endpoint also clears its failure count.
+UPDATE webhook_endpoints
+SET enabled = sqlc.arg(enabled),
+    consecutive_failures = CASE WHEN sqlc.arg(enabled) THEN 0 ELSE consecutive_failures END,
+    disabled_reason = sqlc.narg(disabled_reason), updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id);
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints WHERE id = sqlc.arg(id);
+
+-- name: RecordWebhookEndpointSuccess :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0, updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND consecutive_failures > 0;
+
+-- name: RecordWebhookEndpointFailure :one
+-- Counts a failed attempt and disables the endpoint with reason once disable_after attempts in a row have failed; zero never disables it.
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    enabled = enabled AND (CAST(sqlc.arg(disable_after) AS INTEGER) = 0 OR consecutive_failures + 1 < CAST(sqlc.arg(disable_after) AS INTEGER)),
+    disabled_reason = CASE
+        WHEN enabled AND CAST(sqlc.arg(disable_after) AS INTEGER) > 0 AND consecutive_failures + 1 >= CAST(sqlc.arg(disable_after) AS INTEGER)
+        THEN CAST(sqlc.arg(reason) AS TEXT)
+        ELSE disabled_reason
+    END,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (endpoint_id, event, event_id, payload, redelivery_of)
+VALUES (sqlc.arg(endpoint_id), sqlc.arg(event), sqlc.arg(event_id), sqlc.arg(payload), sqlc.narg(redelivery_of))
+RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries WHERE id = sqlc.arg(id);
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg(endpoint_id)
+  AND (CAST(sqlc.narg(before_id) AS INTEGER) IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: FinishWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET state = sqlc.arg(state), attempts = attempts + 1,
+    response_status = sqlc.narg(response_status), response_body = sqlc.narg(response_body),
+    last_error = sqlc.narg(last_error), duration_ms = sqlc.narg(duration_ms),
+    last_attempt_at = CURRENT_TIMESTAMP,
+    delivered_at = CASE WHEN sqlc.arg(state) = 'succeeded' THEN CURRENT_TIMESTAMP END
+WHERE id = sqlc.arg(id);
+
+-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET state = 'failed', last_error = CAST(sqlc.arg(last_error) AS TEXT)
+WHERE id = sqlc.arg(id) AND state = 'pending';
+
+-- name: PurgeWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries WHERE julianday(created_at) < julianday(sqlc.arg(created_before));
+
+-- name: CreateUserImport :one
+INSERT INTO user_imports (created_by, filename, format, mode, dry_run)
+VALUES (sqlc.narg(created_by), sqlc.arg(filename), sqlc.arg(format), sqlc.arg(mode), sqlc.arg(dry_run))
+RETURNING *;
+
+-- name: CreateUserImportFile :exec
+INSERT INTO user_import_files (import_id, data) VALUES (sqlc.arg(import_id), sqlc.arg(data));
+
+-- name: GetUserImport :one
+SELECT * FROM user_imports WHERE id = sqlc.arg(id);
+
+-- name: GetUserImportFile :one
+SELECT data FROM user_import_files WHERE import_id = sqlc.arg(import_id);
+
+-- name: DeleteUserImportFile :exec
+DELETE FROM user_import_files WHERE import_id = sqlc.arg(import_id);
+
+-- name: ListUserImports :many
+SELECT * FROM user_imports ORDER BY id DESC LIMIT sqlc.arg(max_rows);
+
+-- name: StartUserImport :execrows
+-- Moves a pending import to running. A retried job restarts a running import from zero.
+UPDATE user_imports
+SET state = 'running', total_rows = sqlc.arg(total_rows),
+    processed_rows = 0, created_rows = 0, failed_rows = 0,
+    started_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND state IN ('pending', 'running');
+
+-- name: UpdateUserImportProgress :exec
+UPDATE user_imports
+SET processed_rows = sqlc.arg(processed_rows), created_rows = sqlc.arg(created_rows), failed_rows = sqlc.arg(failed_rows)
+WHERE id = sqlc.arg(id);
+
+-- name: FinishUserImport :exec
+UPDATE user_imports
+SET state = sqlc.arg(state), processed_rows = sqlc.arg(processed_rows),
+    created_rows = sqlc.arg(created_rows), failed_rows = sqlc.arg(failed_rows),
+    row_errors = sqlc.arg(row_errors), last_error = sqlc.narg(last_error),
+    finished_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id);
+
+-- name: CreateDataExport :one
+INSERT INTO data_exports (user_id, format)
+VALUES (sqlc.arg(user_id), sqlc.arg(format))
+RETURNING *;
+
+-- name: CreateDataExportFile :exec
+INSERT INTO data_export_files (export_id, data) VALUES (sqlc.arg(export_id), sqlc.arg(data));
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports WHERE id = sqlc.arg(id);
+
+-- name: GetDataExportFile :one
+SELECT data FROM data_export_files WHERE export_id = sqlc.arg(export_id);
+
+-- name: ListDataExports :many
+SELECT * FROM data_exports
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY id DESC
+LIMIT sqlc.arg(max_rows);
+
+-- name: StartDataExport :execrows
+UPDATE data_exports SET state = 'running' WHERE id = sqlc.arg(id) AND state IN ('pending', 'running');
+
+-- name: FinishDataExport :exec
+UPDATE data_exports
+SET state = sqlc.arg(state), size_bytes = sqlc.arg(size_bytes), last_error = sqlc.narg(last_error),
+    finished_at = CURRENT_TIMESTAMP, expires_at = sqlc.narg(expires_at)
+WHERE id = sqlc.arg(id);
+
+-- name: PurgeDataExports :execrows
+DELETE FROM data_exports WHERE julianday(expires_at) < julianday(sqlc.arg(expires_before));
+
+-- name: ListUserAuditEvents :many
+-- Lists the events a user performed or that targeted them, oldest first.
+SELECT * FROM audit_events
+WHERE actor_id = sqlc.arg(user_id)
+   OR (target_type = 'user' AND target_id = sqlc.arg(user_id))
+ORDER BY id;
+
+-- name: RequestAccountDeletion :one
+-- Schedules an account for erasure. A user whose deletion is already pending gets no rows.
+INSERT INTO account_deletions (user_id, delete_after)
+VALUES (sqlc.arg(user_id), sqlc.arg(delete_after))
+ON CONFLICT (user_id) DO NOTHING
+RETURNING *;
+
+-- name: GetAccountDeletion :one
+SELECT * FROM account_deletions WHERE user_id = sqlc.arg(user_id);
+
+-- name: CancelAccountDeletion :execrows
+DELETE FROM account_deletions WHERE user_id = sqlc.arg(user_id);
+
+-- name: ListAccountDeletions :many
+SELECT d.user_id, u.email, u.name, d.requested_at, d.delete_after
+FROM account_deletions d
+JOIN users u ON u.id = d.user_id
+ORDER BY julianday(d.delete_after), d.user_id;
+
+-- name: ListDueAccountDeletions :many
+SELECT * FROM account_deletions
+WHERE julianday(delete_after) <= julianday(sqlc.arg(due_at))
+ORDER BY julianday(delete_after), user_id;
+
+-- name: EraseUser :one
+-- Permanently deletes a user whose deletion is due, with every row that cascades from them. A cancelled deletion gets no rows.
+DELETE FROM users
+WHERE id = sqlc.arg(id)
+  AND id IN (SELECT user_id FROM account_deletions WHERE julianday(delete_after) <= julianday(sqlc.arg(due_at)))
+RETURNING *;
@@ -0,0 +1,264 @@
+-- SQLite schema for single-node and development deployments. It mirrors
+-- internal/store/schema.sql; timestamps are DATETIME text compared through
+-- julianday(), JSON is stored as BLOB and arrays as JSON text.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    avatar_url TEXT,
+    bio TEXT,
+    password_hash TEXT NOT NULL,
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    -- Incremented on every edit for optimistic concurrency control
+    version INTEGER NOT NULL DEFAULT 1,
+    -- Set when a user is moved to the trash; purged after the retention period
+    deleted_at DATETIME,
+    -- Grants the /admin pages; only set directly in the database
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+-- Index for active users
+CREATE INDEX IF NOT EXISTS idx_users_active ON users(is_active);
+
+-- Index for the trash listing and retention purge
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Sessions table in the layout sqlite3store expects; expiry is a Julian day
+CREATE TABLE IF NOT EXISTS sessions (
+    token TEXT NOT NULL PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry REAL NOT NULL
+);
+
+-- Index for session expiry cleanup
+CREATE INDEX IF NOT EXISTS idx_sessions_expiry ON sessions(expiry);
+
+-- Token buckets for the rate limiter. capacity and refill_per_second hold the
+-- policy of the latest request; updated_at keeps milliseconds for refills.
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key TEXT NOT NULL PRIMARY KEY,
+    tokens REAL NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    capacity REAL NOT NULL,
+    refill_per_second REAL NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL
+);
+
+-- Index for expired bucket cleanup
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_expires_at ON rate_limit_buckets(expires_at);
+
+-- Append-only log of security-relevant and administrative actions. actor_id
+-- has no foreign key so events outlive purged users.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    occurred_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor_id INTEGER,
+    action TEXT NOT NULL,
+    target_type TEXT,
+    target_id INTEGER,
+    ip TEXT,
+    user_agent TEXT,
+    request_id TEXT,
+    before BLOB,
+    after BLOB
+);
+
+-- Indexes for the audit page filters, newest first
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events(action, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events(actor_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target_type, target_id, id);
+
+-- Index for the retention purge
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON audit_events(occurred_at);
+
+-- SQLite has no transaction-scoped settings, so the retention purge inserts
+-- a row here before deleting and removes it again before committing
+CREATE TABLE IF NOT EXISTS audit_purge (
+    allowed BOOLEAN NOT NULL
+);
+
+-- Reject updates and deletes so the log cannot be rewritten; only the
+-- retention purge may delete
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+WHEN NOT EXISTS (SELECT 1 FROM audit_purge)
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+-- Background job queue; SQLite has a single writer, so claims need no row locks
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    payload BLOB NOT NULL DEFAULT '{}',
+    state TEXT NOT NULL DEFAULT 'pending'
+        CONSTRAINT jobs_state_check CHECK (state IN ('pending', 'running', 'succeeded', 'dead', 'cancelled')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    unique_key TEXT,
+    run_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_at DATETIME,
+    locked_by TEXT,
+    last_error TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME
+);
+
+-- Index for claiming due jobs
+CREATE INDEX IF NOT EXISTS idx_jobs_pending ON jobs(run_at, id) WHERE state = 'pending';
+
+-- Index for rescuing jobs whose worker died
+CREATE INDEX IF NOT EXISTS idx_jobs_running ON jobs(locked_at) WHERE state = 'running';
+
+-- Index for the admin listing
+CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs(state, id);
+
+-- Index for purging finished jobs
+CREATE INDEX IF NOT EXISTS idx_jobs_finished_at ON jobs(finished_at) WHERE finished_at IS NOT NULL;
+
+-- At most one queued or running job per unique key
+CREATE UNIQUE INDEX IF NOT EXISTS jobs_unique_key ON jobs(unique_key)
+    WHERE unique_key IS NOT NULL AND state IN ('pending', 'running');
+
+-- Last run of each periodic maintenance task
+CREATE TABLE IF NOT EXISTS scheduled_tasks (
+    name TEXT NOT NULL PRIMARY KEY,
+    schedule TEXT NOT NULL,
+    last_tick DATETIME,
+    last_started_at DATETIME,
+    last_finished_at DATETIME,
+    last_status TEXT,
+    last_error TEXT,
+    last_duration_ms INTEGER,
+    next_run_at DATETIME,
+    runs INTEGER NOT NULL DEFAULT 0,
+    failures INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Event data kept for parity with PostgreSQL; the in-process bus never spills
+CREATE TABLE IF NOT EXISTS event_payloads (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    topic TEXT NOT NULL,
+    data BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for purging delivered payloads
+CREATE INDEX IF NOT EXISTS idx_event_payloads_created_at ON event_payloads(created_at);
+
+-- Receivers of outbound webhooks; events is a JSON array, and an empty one
+-- subscribes to every event
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    description TEXT,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL DEFAULT '[]',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    disabled_reason TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One event sent to one endpoint, with the outcome of its latest attempt
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    endpoint_id INTEGER NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    payload BLOB NOT NULL,
+    state TEXT NOT NULL DEFAULT 'pending'
+        CONSTRAINT webhook_deliveries_state_check CHECK (state IN ('pending', 'succeeded', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,
+    response_body TEXT,
+    last_error TEXT,
+    duration_ms INTEGER,
+    redelivery_of INTEGER REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_attempt_at DATETIME,
+    delivered_at DATETIME
+);
+
+-- Index for an endpoint's delivery log
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, id);
+
+-- Index for purging old deliveries
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries(created_at);
+
+-- Bulk user imports uploaded by admins, with their progress and row errors
+CREATE TABLE IF NOT EXISTS user_imports (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    filename TEXT NOT NULL,
+    format TEXT NOT NULL
+        CONSTRAINT user_imports_format_check CHECK (format IN ('csv', 'ndjson')),
+    mode TEXT NOT NULL
+        CONSTRAINT user_imports_mode_check CHECK (mode IN ('atomic', 'best_effort')),
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    state TEXT NOT NULL DEFAULT 'pending'
+        CONSTRAINT user_imports_state_check CHECK (state IN ('pending', 'running', 'succeeded', 'failed')),
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    created_rows INTEGER NOT NULL DEFAULT 0,
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    row_errors BLOB NOT NULL DEFAULT '[]',
+    last_error TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME,
+    finished_at DATETIME
+);
+
+-- Uploaded files waiting to be imported; deleted once the import finishes
+CREATE TABLE IF NOT EXISTS user_import_files (
+    import_id INTEGER NOT NULL PRIMARY KEY REFERENCES user_imports(id) ON DELETE CASCADE,
+    data BLOB NOT NULL
+);
+
+-- Personal data archives users request from their profile
+CREATE TABLE IF NOT EXISTS data_exports (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format TEXT NOT NULL
+        CONSTRAINT data_exports_format_check CHECK (format IN ('zip', 'json')),
+    state TEXT NOT NULL DEFAULT 'pending'
+        CONSTRAINT data_exports_state_check CHECK (state IN ('pending', 'running', 'succeeded', 'failed')),
+    size_bytes INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    expires_at DATETIME
+);
+
+-- Index for listing a user's archives
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id, id);
+
+-- Index for purging expired archives
+CREATE INDEX IF NOT EXISTS idx_data_exports_expires_at ON data_exports(expires_at) WHERE expires_at IS NOT NULL;
+
+-- Generated archives, deleted with their export
+CREATE TABLE IF NOT EXISTS data_export_files (
+    export_id INTEGER NOT NULL PRIMARY KEY REFERENCES data_exports(id) ON DELETE CASCADE,
+    data BLOB NOT NULL
+);
+
+-- Pending account deletions; the account is erased once delete_after passes
+CREATE TABLE IF NOT EXISTS account_deletions (
+    user_id INTEGER NOT NULL PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    requested_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delete_after DATETIME NOT NULL
+);
+
+-- Index for finding deletions that are due
+CREATE INDEX IF NOT EXISTS idx_account_deletions_delete_after ON account_deletions(delete_after);
@@ -0,0 +1,295 @@
+// Package sqlite implements the store API on SQLite for single-node and
+// development deployments. Queries are generated by sqlc from queries.sql and
+// Store converts their rows to the store package's types, so handlers work
+// the same against either backend.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	_ "modernc.org/sqlite" // Registers the "sqlite" database/sql driver.
+)
+
+//go:embed schema.sql
+var schema string
+
+// defaultPragmas are applied to every connection unless the URL sets the
+// same pragma. Foreign keys are off by default in SQLite, and WAL lets
+// readers proceed while a write transaction is open.
+var defaultPragmas = []string{
+	"busy_timeout(5000)",
+	"foreign_keys(1)",
+	"journal_mode(WAL)",
+}
+
+// Store provides all functions to execute queries against SQLite.
+type Store struct {
+	*querier
+
+	db    *sql.DB
+	stats *store.QueryStats
+
+	// locks holds the advisory locks taken by this process.
+	mu    sync.Mutex
+	locks map[int64]bool
+}
+
+var (
+	_ store.TxQuerier = (*Store)(nil)
+	_ store.Locker    = (*Store)(nil)
+)
+
+// Open opens the database named by a sqlite:, sqlite3: or file: URL, such as
+// sqlite:///var/lib/app/app.db. config bounds the connection pool;
+// MinConns and QueryTimeout have no database/sql equivalent and are ignored.
+func Open(ctx context.Context, databaseURL string, config store.PoolConfig) (*Store, error) {
+	dsn, err := DSN(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if config.MaxConns > 0 {
+		db.SetMaxOpenConns(int(config.MaxConns))
+		db.SetMaxIdleConns(int(config.MaxConns))
+	}
+	db.SetConnMaxLifetime(config.MaxConnLifetime)
+	db.SetConnMaxIdleTime(config.MaxConnIdleTime)
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	stats := store.NewQueryStats()
+
+	return &Store{
+		querier: newQuerier(&tracedDB{DBTX: db, stats: stats, slowThreshold: config.SlowQueryThreshold}, false),
+		db:      db,
+		stats:   stats,
+		locks:   make(map[int64]bool),
+	}, nil
+}
+
+// DSN converts a database URL to a driver data source name. The query string
+// is kept, so callers can pass SQLite URI parameters and _pragma settings.
+// Timestamps are always written in SQLite's own format, which the schema's
+// julianday comparisons depend on.
+func DSN(databaseURL string) (string, error) {
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse database URL: %w", err)
+	}
+
+	var path string
+	switch strings.ToLower(u.Scheme) {
+	case "sqlite", "sqlite3", "file":
+		path = u.Opaque
+		if path == "" {
+			path = u.Host + u.Path
+		}
+	default:
+		return "", fmt.Errorf("unsupported SQLite URL scheme %q", u.Scheme)
+	}
+	if path == "" {
+		return "", errors.New("database URL names no SQLite file")
+	}
+
+	query := u.Query()
+	for _, pragma := range defaultPragmas {
+		name, _, _ := strings.Cut(pragma, "(")
+		if !hasPragma(query["_pragma"], name) {
+			query.Add("_pragma", pragma)
+		}
+	}
+	if query.Get("_txlock") == "" {
+		// Write transactions take the lock up front, so they wait on
+		// busy_timeout instead of failing when they first write.
+		query.Set("_txlock", "immediate")
+	}
+	query.Set("_time_format", "sqlite")
+
+	return "file:" + path + "?" + query.Encode(), nil
+}
+
+func hasPragma(pragmas []string, name string) bool {
+	for _, pragma := range pragmas {
+		if pragmaName, _, _ := strings.Cut(pragma, "("); strings.EqualFold(strings.TrimSpace(pragmaName), name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Close closes the database.
+func (s *Store) Close() {
+	_ = s.db.Close()
+}
+
+// DB returns the underlying database handle for advanced operations.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// QueryStats returns per-query execution statistics.
+func (s *Store) QueryStats() *store.QueryStats {
+	return s.stats
+}
+
+// PoolStats returns the connection pool's current usage.
+func (s *Store) PoolStats() store.PoolStats {
+	stats := s.db.Stats()
+
+	return store.PoolStats{
+		AcquiredConns: int32(stats.InUse),
+		IdleConns:     int32(stats.Idle),
+		TotalConns:    int32(stats.OpenConnections),
+		MaxConns:      int32(stats.MaxOpenConnections),
+	}
+}
+
+// ConsumeRateLimitToken takes one token from the shared bucket identified by key.
+// It returns the tokens left and whether the request was admitted.
+func (s *Store) ConsumeRateLimitToken(ctx context.Context, key string, capacity, refillPerSecond float64, ttl time.Duration) (float64, bool, error) {
+	row, err := s.queries.TakeRateLimitToken(ctx, TakeRateLimitTokenParams{
+		Key:             key,
+		Capacity:        capacity,
+		RefillPerSecond: refillPerSecond,
+		TtlSeconds:      ttl.Seconds(),
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+
+	return row.Tokens, row.Allowed, nil
+}
+
+// Ping verifies the database answers.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// PoolSaturation returns the fraction of the pool's connections that are in use.
+func (s *Store) PoolSaturation() float64 {
+	stats := s.db.Stats()
+	if stats.MaxOpenConnections == 0 {
+		return 0
+	}
+
+	return float64(stats.InUse) / float64(stats.MaxOpenConnections)
+}
+
+// AppliedMigrationVersion returns the newest fully applied Atlas migration
+// version. On SQLite Atlas keeps revisions in the database's own
+// atlas_schema_revisions table.
+func (s *Store) AppliedMigrationVersion(ctx context.Context) (string, error) {
+	var tables int
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'atlas_schema_revisions'",
+	).Scan(&tables); err != nil {
+		return "", fmt.Errorf("failed to locate atlas revisions table: %w", err)
+	}
+	if tables == 0 {
+		return "", store.ErrNoMigrationHistory
+	}
+
+	var version *string
+	if err := s.db.QueryRowContext(ctx,
+		"SELECT max(version) FROM atlas_schema_revisions WHERE applied = total",
+	).Scan(&version); err != nil {
+		return "", fmt.Errorf("failed to read applied migration version: %w", err)
+	}
+	if version == nil {
+		return "", store.ErrNoMigrationHistory
+	}
+
+	return *version, nil
+}
+
+// InitSchema creates any missing tables, indexes and triggers from schema.sql.
+// Migrations are preferred outside development.
+func (s *Store) InitSchema(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	return nil
+}
+
+// tracedDB records per-query statistics and logs slow queries, like the
+// PostgreSQL store's query tracer.
+type tracedDB struct {
+	DBTX
+
+	stats *store.QueryStats
+	// slowThreshold logs queries that take at least this long; zero disables it.
+	slowThreshold time.Duration
+}
+
+func (t *tracedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := t.DBTX.ExecContext(ctx, query, args...)
+	t.record(ctx, query, start, err)
+
+	return result, err
+}
+
+func (t *tracedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := t.DBTX.QueryContext(ctx, query, args...)
+	t.record(ctx, query, start, err)
+
+	return rows, err
+}
+
+func (t *tracedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := t.DBTX.QueryRowContext(ctx, query, args...)
+	err := row.Err()
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	t.record(ctx, query, start, err)
+
+	return row
+}
+
+func (t *tracedDB) record(ctx context.Context, query string, start time.Time, err error) {
+	name := store.QueryName(query)
+	duration := time.Since(start)
+	slow := t.slowThreshold > 0 && duration >= t.slowThreshold
+
+	t.stats.Record(name, duration, err != nil, slow)
+
+	if slow {
+		attrs := []any{
+			"query", name,
+			"duration", duration,
+			"threshold", t.slowThreshold,
+		}
+		if err != nil {
+			attrs = append(attrs, "error", err)
+		}
+		slog.WarnContext(ctx, "slow query", attrs...)
+	}
+}
+
+// withTx returns a tracedDB that runs queries in tx.
+func (t *tracedDB) withTx(tx *sql.Tx) *tracedDB {
+	return &tracedDB{DBTX: tx, stats: t.stats, slowThreshold: t.slowThreshold}
+}
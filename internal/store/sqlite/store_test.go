@@ -0,0 +1,376 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/migrations"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	ctx := context.Background()
+	s, err := Open(ctx, "sqlite://"+filepath.Join(t.TempDir(), "app.db"), store.PoolConfig{MaxConns: 4})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(s.Close)
+
+	if err := s.InitSchema(ctx); err != nil {
+		t.Fatalf("InitSchema() error = %v", err)
+	}
+
+	return s
+}
+
+func TestDSN(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{
+			url:  "sqlite:///var/lib/app/app.db",
+			want: "file:/var/lib/app/app.db?_pragma=busy_timeout%285000%29&_pragma=foreign_keys%281%29&_pragma=journal_mode%28WAL%29&_time_format=sqlite&_txlock=immediate",
+		},
+		{
+			url:  "sqlite3:app.db?_pragma=journal_mode(DELETE)&_txlock=deferred",
+			want: "file:app.db?_pragma=journal_mode%28DELETE%29&_pragma=busy_timeout%285000%29&_pragma=foreign_keys%281%29&_time_format=sqlite&_txlock=deferred",
+		},
+		{
+			url:  "file:data/app.db?mode=rwc",
+			want: "file:data/app.db?_pragma=busy_timeout%285000%29&_pragma=foreign_keys%281%29&_pragma=journal_mode%28WAL%29&_time_format=sqlite&_txlock=immediate&mode=rwc",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := DSN(tt.url)
+		if err != nil || got != tt.want {
+			t.Errorf("DSN(%q) = %q, %v; want %q", tt.url, got, err, tt.want)
+		}
+	}
+
+	for _, url := range []string{"postgres://localhost/app", "sqlite://"} {
+		if _, err := DSN(url); err == nil {
+			t.Errorf("DSN(%q) error = nil, want an error", url)
+		}
+	}
+}
+
+func TestStoreUsers(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	created, err := s.CreateUser(ctx, store.CreateUserParams{Email: "ada@example.com", Name: "Ada", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if created.ID == 0 || !created.CreatedAt.Valid || created.Version != 1 {
+		t.Fatalf("CreateUser() = %+v, want an ID, a creation time and version 1", created)
+	}
+
+	got, err := s.GetUserByEmail(ctx, "ada@example.com")
+	if err != nil || got.ID != created.ID {
+		t.Fatalf("GetUserByEmail() = %+v, %v; want user %d", got, err, created.ID)
+	}
+
+	if _, err := s.GetUser(ctx, created.ID+1); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("GetUser(missing) error = %v, want store.ErrNotFound", err)
+	}
+
+	_, err = s.CreateUser(ctx, store.CreateUserParams{Email: "ada@example.com", Name: "Ada again", PasswordHash: "hash"})
+	constraintErr, ok := store.AsConstraintError(err)
+	if !ok || constraintErr.Kind != store.ConstraintUnique || constraintErr.Column != "email" {
+		t.Fatalf("CreateUser(duplicate) error = %v, want a unique violation on email", err)
+	}
+
+	var streamed []string
+	if err := s.StreamUsers(ctx, store.SearchUsersParams{}, func(user store.User) error {
+		streamed = append(streamed, user.Email)
+		return nil
+	}); err != nil {
+		t.Fatalf("StreamUsers() error = %v", err)
+	}
+	if len(streamed) != 1 || streamed[0] != "ada@example.com" {
+		t.Fatalf("StreamUsers() = %v, want [ada@example.com]", streamed)
+	}
+}
+
+func TestStorePurgeDeletedUsers(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	created, err := s.CreateUser(ctx, store.CreateUserParams{Email: "ada@example.com", Name: "Ada", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if n, err := s.SoftDeleteUser(ctx, created.ID); err != nil || n != 1 {
+		t.Fatalf("SoftDeleteUser() = %d, %v; want 1 row", n, err)
+	}
+
+	// deleted_at is written by SQLite in UTC while the cutoff carries a
+	// local offset, so this also checks the julianday() comparison.
+	for _, tc := range []struct {
+		cutoff time.Time
+		want   int
+	}{
+		{time.Now().Add(-time.Hour).In(time.FixedZone("UTC+2", 2*60*60)), 0},
+		{time.Now().Add(time.Hour).In(time.FixedZone("UTC-5", -5*60*60)), 1},
+	} {
+		purged, err := s.PurgeDeletedUsers(ctx, pgtype.Timestamptz{Time: tc.cutoff, Valid: true})
+		if err != nil || len(purged) != tc.want {
+			t.Fatalf("PurgeDeletedUsers(%v) = %v, %v; want %d users", tc.cutoff, purged, err, tc.want)
+		}
+	}
+}
+
+func TestStoreRunInTxRollsBack(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := openTestStore(t)
+	errAbort := errors.New("abort")
+
+	err := s.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		if _, err := q.CreateUser(ctx, store.CreateUserParams{Email: "ada@example.com", Name: "Ada", PasswordHash: "hash"}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("RunInTx() error = %v, want %v", err, errAbort)
+	}
+
+	if _, err := s.GetUserByEmail(ctx, "ada@example.com"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("GetUserByEmail() after rollback error = %v, want store.ErrNotFound", err)
+	}
+}
+
+func TestStoreAuditPurge(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	if err := s.CreateAuditEvent(ctx, store.CreateAuditEventParams{Action: "user.created"}); err != nil {
+		t.Fatalf("CreateAuditEvent() error = %v", err)
+	}
+	cutoff := pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}
+
+	if _, err := s.PurgeAuditEvents(ctx, cutoff); err == nil || !strings.Contains(err.Error(), "append-only") {
+		t.Fatalf("PurgeAuditEvents() without AllowAuditPurge error = %v, want append-only", err)
+	}
+
+	var purged int64
+	if err := s.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		if err := q.AllowAuditPurge(ctx); err != nil {
+			return err
+		}
+		var err error
+		purged, err = q.PurgeAuditEvents(ctx, cutoff)
+		return err
+	}); err != nil {
+		t.Fatalf("RunInTx(purge) error = %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("PurgeAuditEvents() = %d, want 1", purged)
+	}
+
+	// The flag must not outlive the transaction that set it.
+	if err := s.CreateAuditEvent(ctx, store.CreateAuditEventParams{Action: "user.updated"}); err != nil {
+		t.Fatalf("CreateAuditEvent() error = %v", err)
+	}
+	if _, err := s.PurgeAuditEvents(ctx, cutoff); err == nil {
+		t.Fatal("PurgeAuditEvents() after the purge transaction error = nil, want append-only")
+	}
+}
+
+func TestStoreConsumeRateLimitToken(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	for i, wantAllowed := range []bool{true, true, false} {
+		_, allowed, err := s.ConsumeRateLimitToken(ctx, "login:203.0.113.7", 2, 0.001, time.Minute)
+		if err != nil {
+			t.Fatalf("ConsumeRateLimitToken() #%d error = %v", i, err)
+		}
+		if allowed != wantAllowed {
+			t.Fatalf("ConsumeRateLimitToken() #%d allowed = %v, want %v", i, allowed, wantAllowed)
+		}
+	}
+}
+
+func TestStoreClaimJobs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	// Go times keep their zone when written, so comparisons must not depend
+	// on it.
+	zone := time.FixedZone("UTC+10", 10*60*60)
+	due, err := s.EnqueueJob(ctx, store.EnqueueJobParams{
+		Kind:        "email",
+		Payload:     []byte(`{}`),
+		MaxAttempts: 3,
+		RunAt:       pgtype.Timestamptz{Time: time.Now().Add(-time.Minute).In(zone), Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("EnqueueJob(due) error = %v", err)
+	}
+	if _, err := s.EnqueueJob(ctx, store.EnqueueJobParams{
+		Kind:        "email",
+		Payload:     []byte(`{}`),
+		MaxAttempts: 3,
+		RunAt:       pgtype.Timestamptz{Time: time.Now().Add(time.Hour).UTC(), Valid: true},
+	}); err != nil {
+		t.Fatalf("EnqueueJob(later) error = %v", err)
+	}
+
+	worker := "worker-1"
+	claimed, err := s.ClaimJobs(ctx, store.ClaimJobsParams{Worker: &worker, Kinds: []string{"email", "export"}, MaxJobs: 10})
+	if err != nil {
+		t.Fatalf("ClaimJobs() error = %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != due.ID || claimed[0].State != "running" || claimed[0].Attempts != 1 {
+		t.Fatalf("ClaimJobs() = %+v, want only job %d running", claimed, due.ID)
+	}
+}
+
+func TestStoreWebhookEndpointsForEvent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	all, err := s.CreateWebhookEndpoint(ctx, store.CreateWebhookEndpointParams{Url: "https://example.com/all", Secret: "s"})
+	if err != nil {
+		t.Fatalf("CreateWebhookEndpoint(all) error = %v", err)
+	}
+	users, err := s.CreateWebhookEndpoint(ctx, store.CreateWebhookEndpointParams{
+		Url:    "https://example.com/users",
+		Secret: "s",
+		Events: []string{"user.created", "user.deleted"},
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhookEndpoint(users) error = %v", err)
+	}
+	if len(users.Events) != 2 || users.Events[1] != "user.deleted" {
+		t.Fatalf("CreateWebhookEndpoint() events = %v, want [user.created user.deleted]", users.Events)
+	}
+
+	tests := []struct {
+		event string
+		want  []int64
+	}{
+		{event: "user.created", want: []int64{all.ID, users.ID}},
+		{event: "job.failed", want: []int64{all.ID}},
+	}
+	for _, tt := range tests {
+		endpoints, err := s.ListWebhookEndpointsForEvent(ctx, tt.event)
+		if err != nil {
+			t.Fatalf("ListWebhookEndpointsForEvent(%q) error = %v", tt.event, err)
+		}
+
+		var got []int64
+		for _, endpoint := range endpoints {
+			got = append(got, endpoint.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Fatalf("ListWebhookEndpointsForEvent(%q) = %v, want %v", tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestStoreWithAdvisoryLock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := openTestStore(t)
+	key := store.AdvisoryLockKey("purge")
+
+	ran, err := s.WithAdvisoryLock(ctx, key, func(ctx context.Context) error {
+		nested, err := s.WithAdvisoryLock(ctx, key, func(context.Context) error { return nil })
+		if nested || err != nil {
+			t.Errorf("WithAdvisoryLock(held) = %v, %v; want false, nil", nested, err)
+		}
+		return nil
+	})
+	if !ran || err != nil {
+		t.Fatalf("WithAdvisoryLock() = %v, %v; want true, nil", ran, err)
+	}
+}
+
+func TestMigrationsMatchSchema(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	bootstrapped := openTestStore(t)
+
+	migrated, err := Open(ctx, "sqlite://"+filepath.Join(t.TempDir(), "migrated.db"), store.PoolConfig{MaxConns: 1})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(migrated.Close)
+
+	names, err := fs.Glob(migrations.SQLiteFiles, "sqlite/*.sql")
+	if err != nil {
+		t.Fatalf("fs.Glob() error = %v", err)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		migration, err := fs.ReadFile(migrations.SQLiteFiles, name)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", name, err)
+		}
+		if _, err := migrated.DB().ExecContext(ctx, string(migration)); err != nil {
+			t.Fatalf("apply %s: %v", name, err)
+		}
+	}
+
+	if got, want := schemaObjects(t, migrated), schemaObjects(t, bootstrapped); !slices.Equal(got, want) {
+		t.Fatalf("migrated schema objects = %v, want the schema.sql objects %v", got, want)
+	}
+}
+
+// schemaObjects lists the tables, indexes and triggers in s's database.
+func schemaObjects(t *testing.T, s *Store) []string {
+	t.Helper()
+
+	rows, err := s.DB().QueryContext(context.Background(),
+		"SELECT type || ' ' || name FROM sqlite_master WHERE name NOT LIKE 'sqlite_%' ORDER BY type, name")
+	if err != nil {
+		t.Fatalf("list schema objects: %v", err)
+	}
+	defer rows.Close()
+
+	var objects []string
+	for rows.Next() {
+		var object string
+		if err := rows.Scan(&object); err != nil {
+			t.Fatalf("scan schema object: %v", err)
+		}
+		objects = append(objects, object)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("list schema objects: %v", err)
+	}
+
+	return objects
+}
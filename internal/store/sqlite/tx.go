@@ -0,0 +1,117 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/dunamismax/go-web-server/internal/store"
+)
+
+// RunInTx implements store.TxQuerier. SQLite transactions are serializable
+// and write transactions take the database lock when they begin, so the
+// isolation level is ignored and there are no serialization failures to
+// retry.
+func (s *Store) RunInTx(ctx context.Context, opts store.TxOptions, fn func(q store.Querier) error) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	q := newQuerier(s.traced.withTx(tx), true)
+	if err := fn(q); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && !errors.Is(rollbackErr, sql.ErrTxDone) {
+			return errors.Join(err, fmt.Errorf("rollback transaction: %w", rollbackErr))
+		}
+		return err
+	}
+
+	if q.auditPurge {
+		if err := q.queries.ClearAuditPurge(ctx); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("clear audit purge flag: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// StreamUsers implements store.UserStreamer. SQLite has no cursors, but rows
+// are read from the database as fn consumes them, and the read transaction
+// keeps every row in one snapshot.
+func (s *Store) StreamUsers(ctx context.Context, arg store.SearchUsersParams, fn func(store.User) error) error {
+	return s.RunInTx(ctx, store.TxOptions{ReadOnly: true, MaxAttempts: 1}, func(q store.Querier) error {
+		return q.(*querier).streamUsers(ctx, arg, fn)
+	})
+}
+
+func (q *querier) streamUsers(ctx context.Context, arg store.SearchUsersParams, fn func(store.User) error) error {
+	rows, err := q.traced.QueryContext(ctx, searchUsers, arg.Status, arg.Query)
+	if err != nil {
+		return fmt.Errorf("query users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.Name,
+			&i.AvatarUrl,
+			&i.Bio,
+			&i.PasswordHash,
+			&i.IsActive,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.DeletedAt,
+			&i.IsAdmin,
+		); err != nil {
+			return fmt.Errorf("scan user: %w", err)
+		}
+
+		if err := fn(store.User(i)); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("read users: %w", err)
+	}
+
+	return nil
+}
+
+// AllowAuditPurge lets PurgeAuditEvents delete until the surrounding RunInTx
+// ends. Like SET LOCAL on PostgreSQL, it has no effect outside a transaction.
+func (q *querier) AllowAuditPurge(ctx context.Context) error {
+	if !q.inTx || q.auditPurge {
+		return nil
+	}
+
+	if err := q.queries.AllowAuditPurge(ctx); err != nil {
+		return translateError(err)
+	}
+	q.auditPurge = true
+
+	return nil
+}
+
+// Notify is a no-op: SQLite deployments run a single node, whose event bus
+// delivers in process.
+func (q *querier) Notify(_ context.Context, _ store.NotifyParams) error {
+	return nil
+}
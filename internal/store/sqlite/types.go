@@ -0,0 +1,56 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// timestamptz is the Go type of DATETIME columns. It is an alias so generated
+// models share the store package's field types.
+type timestamptz = pgtype.Timestamptz
+
+// stringList stores a TEXT[] column as a JSON array, so queries can match
+// its elements with json_each.
+type stringList []string
+
+// Scan implements sql.Scanner.
+func (l *stringList) Scan(src any) error {
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(src)
+	case []byte:
+		data = src
+	default:
+		return fmt.Errorf("cannot scan %T into a string list", src)
+	}
+
+	var items []string
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("decode string list: %w", err)
+	}
+	*l = items
+
+	return nil
+}
+
+// Value implements driver.Valuer. A nil list is stored as an empty array,
+// matching the column's default.
+func (l stringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+
+	data, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, fmt.Errorf("encode string list: %w", err)
+	}
+
+	return string(data), nil
+}
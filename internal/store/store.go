@@ -3,6 +3,7 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -14,7 +15,10 @@ import (
 type Store struct {
 	*Queries // Embed sqlc-generated queries
 
-	db *pgxpool.Pool
+	db    *pgxpool.Pool
+	stats *QueryStats
+	// tx is set on stores returned by WithTx.
+	tx pgx.Tx
 }
 
 // PoolConfig holds database connection pool configuration.
@@ -23,6 +27,10 @@ type PoolConfig struct {
 	MinConns        int32
 	MaxConnLifetime time.Duration
 	MaxConnIdleTime time.Duration
+	// QueryTimeout is the deadline for queries whose context has none.
+	QueryTimeout time.Duration
+	// SlowQueryThreshold logs queries that take at least this long; zero disables it.
+	SlowQueryThreshold time.Duration
 }
 
 // NewStore creates a new store instance with database connection pool.
@@ -51,6 +59,14 @@ func NewStoreWithConfig(ctx context.Context, databaseURL string, poolConfig Pool
 	config.MaxConnLifetime = poolConfig.MaxConnLifetime
 	config.MaxConnIdleTime = poolConfig.MaxConnIdleTime
 
+	// Queries join the caller's trace; spans are no-ops unless tracing is enabled.
+	stats := NewQueryStats()
+	config.ConnConfig.Tracer = &queryTracer{
+		timeout:       poolConfig.QueryTimeout,
+		slowThreshold: poolConfig.SlowQueryThreshold,
+		stats:         stats,
+	}
+
 	db, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
@@ -62,14 +78,17 @@ func NewStoreWithConfig(ctx context.Context, databaseURL string, poolConfig Pool
 
 	return &Store{
 		db:      db,
+		stats:   stats,
 		Queries: New(db),
 	}, nil
 }
 
 // NewStoreWithDB creates a new store instance with an existing database pool.
+// Query statistics are only collected for pools created by NewStoreWithConfig.
 func NewStoreWithDB(db *pgxpool.Pool) *Store {
 	return &Store{
 		db:      db,
+		stats:   NewQueryStats(),
 		Queries: New(db),
 	}
 }
@@ -84,6 +103,31 @@ func (s *Store) DB() *pgxpool.Pool {
 	return s.db
 }
 
+// QueryStats returns per-query execution statistics.
+func (s *Store) QueryStats() *QueryStats {
+	return s.stats
+}
+
+// PoolStats describes connection pool usage independently of the backend.
+type PoolStats struct {
+	AcquiredConns int32
+	IdleConns     int32
+	TotalConns    int32
+	MaxConns      int32
+}
+
+// PoolStats returns the connection pool's current usage.
+func (s *Store) PoolStats() PoolStats {
+	stat := s.db.Stat()
+
+	return PoolStats{
+		AcquiredConns: stat.AcquiredConns(),
+		IdleConns:     stat.IdleConns(),
+		TotalConns:    stat.TotalConns(),
+		MaxConns:      stat.MaxConns(),
+	}
+}
+
 // BeginTx starts a new transaction.
 func (s *Store) BeginTx(ctx context.Context) (pgx.Tx, error) {
 	return s.db.Begin(ctx)
@@ -93,10 +137,77 @@ func (s *Store) BeginTx(ctx context.Context) (pgx.Tx, error) {
 func (s *Store) WithTx(tx pgx.Tx) *Store {
 	return &Store{
 		db:      s.db,
+		stats:   s.stats,
+		tx:      tx,
 		Queries: s.Queries.WithTx(tx),
 	}
 }
 
+// ConsumeRateLimitToken takes one token from the shared bucket identified by key.
+// It returns the tokens left and whether the request was admitted.
+func (s *Store) ConsumeRateLimitToken(ctx context.Context, key string, capacity, refillPerSecond float64, ttl time.Duration) (float64, bool, error) {
+	row, err := s.TakeRateLimitToken(ctx, TakeRateLimitTokenParams{
+		Key:             key,
+		Capacity:        capacity,
+		TtlSeconds:      ttl.Seconds(),
+		RefillPerSecond: refillPerSecond,
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+
+	return row.Tokens, row.Allowed, nil
+}
+
+// Ping verifies the database answers.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.Ping(ctx)
+}
+
+// PoolSaturation returns the fraction of the pool's connections that are checked out.
+func (s *Store) PoolSaturation() float64 {
+	stat := s.db.Stat()
+	if stat.MaxConns() == 0 {
+		return 0
+	}
+
+	return float64(stat.AcquiredConns()) / float64(stat.MaxConns())
+}
+
+// ErrNoMigrationHistory is returned when Atlas has not recorded any revisions.
+var ErrNoMigrationHistory = errors.New("no atlas migration history found")
+
+// AppliedMigrationVersion returns the newest fully applied Atlas migration
+// version. Atlas keeps revisions in the atlas_schema_revisions schema, or in
+// the connection's schema when the URL is bound to one.
+func (s *Store) AppliedMigrationVersion(ctx context.Context) (string, error) {
+	var table *string
+	if err := s.db.QueryRow(ctx, `
+		SELECT coalesce(
+			to_regclass('atlas_schema_revisions.atlas_schema_revisions'),
+			to_regclass('atlas_schema_revisions')
+		)::text
+	`).Scan(&table); err != nil {
+		return "", fmt.Errorf("failed to locate atlas revisions table: %w", err)
+	}
+	if table == nil {
+		return "", ErrNoMigrationHistory
+	}
+
+	var version *string
+	// The table name comes from to_regclass, so it is already a quoted identifier.
+	if err := s.db.QueryRow(ctx,
+		"SELECT max(version) FROM "+*table+" WHERE applied = total",
+	).Scan(&version); err != nil {
+		return "", fmt.Errorf("failed to read applied migration version: %w", err)
+	}
+	if version == nil {
+		return "", ErrNoMigrationHistory
+	}
+
+	return *version, nil
+}
+
 // InitSchema initializes the database schema using the schema.sql file.
 // This is kept here for compatibility, but migrations are preferred.
 func (s *Store) InitSchema(ctx context.Context) error {
@@ -121,6 +232,9 @@ func (s *Store) InitSchema(ctx context.Context) error {
 			updated_at = CURRENT_TIMESTAMP
 		WHERE password_hash IS NULL;
 		ALTER TABLE users ALTER COLUMN password_hash SET NOT NULL;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT false;
 
 		-- Index for faster email lookups
 		CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
@@ -128,6 +242,9 @@ func (s *Store) InitSchema(ctx context.Context) error {
 		-- Index for active users
 		CREATE INDEX IF NOT EXISTS idx_users_active ON users(is_active);
 
+		-- Index for the trash listing and retention purge
+		CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
+
 		-- Sessions table for SCS
 		CREATE TABLE IF NOT EXISTS sessions (
 			token TEXT PRIMARY KEY,
@@ -137,6 +254,221 @@ func (s *Store) InitSchema(ctx context.Context) error {
 
 		-- Index for session expiry cleanup
 		CREATE INDEX IF NOT EXISTS idx_sessions_expiry ON sessions(expiry);
+
+		-- Shared token buckets for rate limiting across replicas
+		CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+			key TEXT PRIMARY KEY,
+			tokens DOUBLE PRECISION NOT NULL,
+			allowed BOOLEAN NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMPTZ NOT NULL
+		);
+
+		-- Index for expired bucket cleanup
+		CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_expires_at ON rate_limit_buckets(expires_at);
+
+		-- Append-only log of security-relevant and administrative actions. actor_id
+		-- has no foreign key so events outlive purged users.
+		CREATE TABLE IF NOT EXISTS audit_events (
+			id BIGSERIAL PRIMARY KEY,
+			occurred_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			actor_id BIGINT,
+			action TEXT NOT NULL,
+			target_type TEXT,
+			target_id BIGINT,
+			ip TEXT,
+			user_agent TEXT,
+			request_id TEXT,
+			before JSONB,
+			after JSONB
+		);
+
+		-- Indexes for the audit page filters, newest first
+		CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events(action, id);
+		CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events(actor_id, id);
+		CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target_type, target_id, id);
+		
+		-- Index for the retention purge
+		CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON audit_events(occurred_at);
+
+		-- Reject updates and deletes so the log cannot be rewritten; only the
+		-- retention purge may delete, after SET LOCAL app.audit_purge = 'on'
+		CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger LANGUAGE plpgsql AS $$
+		BEGIN
+			IF TG_OP = 'DELETE' AND current_setting('app.audit_purge', true) = 'on' THEN
+				RETURN OLD;
+			END IF;
+			RAISE EXCEPTION 'audit_events is append-only';
+		END;
+		$$;
+
+		DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+		CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+			FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+		-- Background job queue; workers claim due rows with FOR UPDATE SKIP LOCKED
+		CREATE TABLE IF NOT EXISTS jobs (
+			id BIGSERIAL PRIMARY KEY,
+			kind TEXT NOT NULL,
+			payload JSONB NOT NULL DEFAULT '{}',
+			state TEXT NOT NULL DEFAULT 'pending'
+				CONSTRAINT jobs_state_check CHECK (state IN ('pending', 'running', 'succeeded', 'dead', 'cancelled')),
+			attempts INTEGER NOT NULL DEFAULT 0,
+			max_attempts INTEGER NOT NULL DEFAULT 5,
+			unique_key TEXT,
+			run_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			locked_at TIMESTAMPTZ,
+			locked_by TEXT,
+			last_error TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			finished_at TIMESTAMPTZ
+		);
+
+		-- Index for claiming due jobs
+		CREATE INDEX IF NOT EXISTS idx_jobs_pending ON jobs(run_at, id) WHERE state = 'pending';
+
+		-- Index for rescuing jobs whose worker died
+		CREATE INDEX IF NOT EXISTS idx_jobs_running ON jobs(locked_at) WHERE state = 'running';
+
+		-- Index for the admin listing
+		CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs(state, id);
+		
+		-- Index for purging finished jobs
+		CREATE INDEX IF NOT EXISTS idx_jobs_finished_at ON jobs(finished_at) WHERE finished_at IS NOT NULL;
+
+		-- At most one queued or running job per unique key
+		CREATE UNIQUE INDEX IF NOT EXISTS jobs_unique_key ON jobs(unique_key)
+			WHERE unique_key IS NOT NULL AND state IN ('pending', 'running');
+
+		-- Last run of each periodic maintenance task, shared by every replica
+		CREATE TABLE IF NOT EXISTS scheduled_tasks (
+			name TEXT PRIMARY KEY,
+			schedule TEXT NOT NULL,
+			last_tick TIMESTAMPTZ,
+			last_started_at TIMESTAMPTZ,
+			last_finished_at TIMESTAMPTZ,
+			last_status TEXT,
+			last_error TEXT,
+			last_duration_ms BIGINT,
+			next_run_at TIMESTAMPTZ,
+			runs BIGINT NOT NULL DEFAULT 0,
+			failures BIGINT NOT NULL DEFAULT 0,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		-- Event data too large for a NOTIFY payload; listeners load it by ID
+		CREATE TABLE IF NOT EXISTS event_payloads (
+			id BIGSERIAL PRIMARY KEY,
+			topic TEXT NOT NULL,
+			data JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		-- Index for purging delivered payloads
+		CREATE INDEX IF NOT EXISTS idx_event_payloads_created_at ON event_payloads(created_at);
+
+		-- Receivers of outbound webhooks; an empty events list subscribes to every event
+		CREATE TABLE IF NOT EXISTS webhook_endpoints (
+			id BIGSERIAL PRIMARY KEY,
+			url TEXT NOT NULL,
+			description TEXT,
+			secret TEXT NOT NULL,
+			events TEXT[] NOT NULL DEFAULT '{}',
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			consecutive_failures INTEGER NOT NULL DEFAULT 0,
+			disabled_reason TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		-- One event sent to one endpoint, with the outcome of its latest attempt
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id BIGSERIAL PRIMARY KEY,
+			endpoint_id BIGINT NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+			event TEXT NOT NULL,
+			event_id TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			state TEXT NOT NULL DEFAULT 'pending'
+				CONSTRAINT webhook_deliveries_state_check CHECK (state IN ('pending', 'succeeded', 'failed')),
+			attempts INTEGER NOT NULL DEFAULT 0,
+			response_status INTEGER,
+			response_body TEXT,
+			last_error TEXT,
+			duration_ms BIGINT,
+			redelivery_of BIGINT REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_attempt_at TIMESTAMPTZ,
+			delivered_at TIMESTAMPTZ
+		);
+
+		-- Index for an endpoint's delivery log
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, id);
+
+		-- Index for purging old deliveries
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries(created_at);
+
+		-- Bulk user imports uploaded by admins, with their progress and row errors
+		CREATE TABLE IF NOT EXISTS user_imports (
+			id BIGSERIAL PRIMARY KEY,
+			created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+			filename TEXT NOT NULL,
+			format TEXT NOT NULL
+				CONSTRAINT user_imports_format_check CHECK (format IN ('csv', 'ndjson')),
+			mode TEXT NOT NULL
+				CONSTRAINT user_imports_mode_check CHECK (mode IN ('atomic', 'best_effort')),
+			dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+			state TEXT NOT NULL DEFAULT 'pending'
+				CONSTRAINT user_imports_state_check CHECK (state IN ('pending', 'running', 'succeeded', 'failed')),
+			total_rows INTEGER NOT NULL DEFAULT 0,
+			processed_rows INTEGER NOT NULL DEFAULT 0,
+			created_rows INTEGER NOT NULL DEFAULT 0,
+			failed_rows INTEGER NOT NULL DEFAULT 0,
+			row_errors JSONB NOT NULL DEFAULT '[]',
+			last_error TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			started_at TIMESTAMPTZ,
+			finished_at TIMESTAMPTZ
+		);
+
+		-- Uploaded files waiting to be imported; deleted once the import finishes
+		CREATE TABLE IF NOT EXISTS user_import_files (
+			import_id BIGINT PRIMARY KEY REFERENCES user_imports(id) ON DELETE CASCADE,
+			data BYTEA NOT NULL
+		);
+
+		-- Personal data archives users asked for, built by a background job
+		CREATE TABLE IF NOT EXISTS data_exports (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			format TEXT NOT NULL
+				CONSTRAINT data_exports_format_check CHECK (format IN ('zip', 'json')),
+			state TEXT NOT NULL DEFAULT 'pending'
+				CONSTRAINT data_exports_state_check CHECK (state IN ('pending', 'running', 'succeeded', 'failed')),
+			size_bytes BIGINT NOT NULL DEFAULT 0,
+			last_error TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			finished_at TIMESTAMPTZ,
+			expires_at TIMESTAMPTZ
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id, id);
+		CREATE INDEX IF NOT EXISTS idx_data_exports_expires_at ON data_exports(expires_at) WHERE expires_at IS NOT NULL;
+
+		-- Finished archives, deleted when they expire
+		CREATE TABLE IF NOT EXISTS data_export_files (
+			export_id BIGINT PRIMARY KEY REFERENCES data_exports(id) ON DELETE CASCADE,
+			data BYTEA NOT NULL
+		);
+
+		-- Accounts their owners asked to delete, erased after delete_after
+		CREATE TABLE IF NOT EXISTS account_deletions (
+			user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			requested_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			delete_after TIMESTAMPTZ NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_account_deletions_delete_after ON account_deletions(delete_after);
 	`
 
 	_, err := s.db.Exec(ctx, schema)
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/telemetry"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryTracer times every query run through the pool. It applies the default
+// per-query deadline, records per-query statistics, logs slow queries and
+// creates a client span for each query.
+type queryTracer struct {
+	// timeout bounds queries whose context has no deadline of its own.
+	timeout time.Duration
+	// slowThreshold logs queries that take at least this long; zero disables it.
+	slowThreshold time.Duration
+	stats         *QueryStats
+}
+
+type queryTraceKey struct{}
+
+type queryTrace struct {
+	name   string
+	args   []any
+	start  time.Time
+	cancel context.CancelFunc
+}
+
+// TraceQueryStart implements pgx.QueryTracer.
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	name := QueryName(data.SQL)
+
+	ctx, _ = telemetry.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBQuerySummary(name),
+			semconv.DBQueryText(data.SQL),
+		),
+	)
+
+	cancel := context.CancelFunc(func() {})
+	if _, ok := ctx.Deadline(); !ok && t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+	}
+
+	return context.WithValue(ctx, queryTraceKey{}, &queryTrace{
+		name:   name,
+		args:   data.Args,
+		start:  time.Now(),
+		cancel: cancel,
+	})
+}
+
+// TraceQueryEnd implements pgx.QueryTracer.
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetAttributes(attribute.Int64("db.response.rows_affected", data.CommandTag.RowsAffected()))
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+
+	query, ok := ctx.Value(queryTraceKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+	query.cancel()
+
+	duration := time.Since(query.start)
+	slow := t.slowThreshold > 0 && duration >= t.slowThreshold
+
+	if t.stats != nil {
+		t.stats.Record(query.name, duration, data.Err != nil, slow)
+	}
+
+	if slow {
+		attrs := []any{
+			"query", query.name,
+			"duration", duration,
+			"threshold", t.slowThreshold,
+			"args", sanitizeArgs(query.args),
+		}
+		if data.Err != nil {
+			attrs = append(attrs, "error", data.Err)
+		}
+		slog.WarnContext(ctx, "slow query", attrs...)
+	}
+}
+
+// QueryName returns the sqlc query name from its "-- name: X :kind" header,
+// falling back to the SQL verb for hand-written statements.
+func QueryName(sql string) string {
+	sql = strings.TrimSpace(sql)
+
+	if rest, ok := strings.CutPrefix(sql, "-- name:"); ok {
+		if fields := strings.Fields(rest); len(fields) > 0 {
+			return fields[0]
+		}
+	}
+
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		return strings.ToUpper(fields[0])
+	}
+
+	return "query"
+}
+
+// sanitizeArgs renders query arguments for logs. Numbers, booleans and times
+// are kept so the plan can be reproduced; text and binary values may hold
+// emails or password hashes, so only their size is logged.
+func sanitizeArgs(args []any) []string {
+	sanitized := make([]string, len(args))
+	for i, arg := range args {
+		sanitized[i] = sanitizeArg(arg)
+	}
+
+	return sanitized
+}
+
+func sanitizeArg(arg any) string {
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return fmt.Sprintf("<redacted string len=%d>", len(v))
+	case *string:
+		if v == nil {
+			return "NULL"
+		}
+		return fmt.Sprintf("<redacted string len=%d>", len(*v))
+	case []byte:
+		return fmt.Sprintf("<redacted bytes len=%d>", len(v))
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	default:
+		return fmt.Sprintf("<redacted %T>", v)
+	}
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestQueryName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{sql: "-- name: GetUser :one\nSELECT id FROM users WHERE id = $1", want: "GetUser"},
+		{sql: "\n  -- name: ListUsers :many\nSELECT id FROM users", want: "ListUsers"},
+		{sql: "select 1", want: "SELECT"},
+		{sql: "  ", want: "query"},
+	}
+
+	for _, tt := range tests {
+		if got := QueryName(tt.sql); got != tt.want {
+			t.Fatalf("QueryName(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestQueryTracerAppliesDefaultTimeoutAndRecordsStats(t *testing.T) {
+	t.Parallel()
+
+	stats := NewQueryStats()
+	tracer := &queryTracer{timeout: time.Minute, stats: stats}
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{
+		SQL: "-- name: GetUser :one\nSELECT id FROM users WHERE id = $1",
+	})
+	if _, ok := ctx.Deadline(); !ok {
+		t.Fatal("expected the default query deadline to be applied")
+	}
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	if ctx.Err() == nil {
+		t.Fatal("expected the query context to be released when the query ends")
+	}
+	if snapshot := stats.Snapshot(); len(snapshot) != 1 || snapshot[0].Name != "GetUser" || snapshot[0].Count != 1 {
+		t.Fatalf("snapshot = %+v, want one GetUser execution", snapshot)
+	}
+
+	parent, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	parentDeadline, _ := parent.Deadline()
+
+	ctx = tracer.TraceQueryStart(parent, nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	if deadline, _ := ctx.Deadline(); !deadline.Equal(parentDeadline) {
+		t.Fatalf("deadline = %v, want caller deadline %v", deadline, parentDeadline)
+	}
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+}
+
+func TestSanitizeArgsRedactsText(t *testing.T) {
+	t.Parallel()
+
+	got := sanitizeArgs([]any{int64(42), "user@example.com", []byte("secret"), true, nil})
+	want := []string{"42", "<redacted string len=16>", "<redacted bytes len=6>", "true", "NULL"}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sanitizeArgs()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
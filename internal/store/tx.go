@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DefaultTxAttempts is how many times InTx runs a transaction that keeps
+// hitting serialization failures or deadlocks.
+const DefaultTxAttempts = 3
+
+// txRetryBaseDelay is the backoff before the first retry; it doubles per attempt.
+const txRetryBaseDelay = 10 * time.Millisecond
+
+// TxOptions configures a transaction started by InTx.
+type TxOptions struct {
+	// IsoLevel defaults to the server's default, normally read committed.
+	IsoLevel pgx.TxIsoLevel
+	ReadOnly bool
+	// MaxAttempts bounds retries on SQLSTATE 40001 and 40P01; zero means
+	// DefaultTxAttempts and one disables retries.
+	MaxAttempts int
+}
+
+// TxQuerier is a Querier that can also run several queries atomically and
+// stream large result sets. Handlers depend on it so they work with both
+// Store and in-memory fakes.
+type TxQuerier interface {
+	Querier
+	UserStreamer
+	RunInTx(ctx context.Context, opts TxOptions, fn func(q Querier) error) error
+}
+
+var _ TxQuerier = (*Store)(nil)
+
+// InTx runs fn inside a transaction, committing when fn returns nil and
+// rolling back when it returns an error or panics. Serialization failures and
+// deadlocks restart the whole transaction with jittered backoff, so fn must
+// be safe to run more than once. Called on a Store returned by WithTx, InTx
+// uses a savepoint in the existing transaction instead and does not retry.
+func (s *Store) InTx(ctx context.Context, opts TxOptions, fn func(q *Queries) error) error {
+	if s.tx != nil {
+		return runInTx(ctx, s.tx, fn)
+	}
+
+	attempts := opts.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultTxAttempts
+	}
+
+	txOptions := pgx.TxOptions{IsoLevel: opts.IsoLevel}
+	if opts.ReadOnly {
+		txOptions.AccessMode = pgx.ReadOnly
+	}
+
+	return retryTx(ctx, attempts, func() error {
+		tx, err := s.db.BeginTx(ctx, txOptions)
+		if err != nil {
+			return fmt.Errorf("begin transaction: %w", err)
+		}
+
+		return finishTx(ctx, tx, fn)
+	})
+}
+
+// RunInTx implements TxQuerier on top of InTx.
+func (s *Store) RunInTx(ctx context.Context, opts TxOptions, fn func(q Querier) error) error {
+	return s.InTx(ctx, opts, func(q *Queries) error {
+		return fn(q)
+	})
+}
+
+// InTx runs fn in a nested transaction. Inside a transaction this is a
+// savepoint, so fn's writes can be rolled back without aborting the caller's
+// transaction; on a pool it starts a regular transaction without retries.
+func (q *Queries) InTx(ctx context.Context, fn func(q *Queries) error) error {
+	beginner, ok := q.db.(txBeginner)
+	if !ok {
+		return errors.New("queries are not bound to a connection that supports transactions")
+	}
+
+	return runInTx(ctx, beginner, fn)
+}
+
+// txBeginner is implemented by pools, connections and transactions; on a
+// transaction Begin creates a savepoint.
+type txBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+func runInTx(ctx context.Context, beginner txBeginner, fn func(q *Queries) error) error {
+	tx, err := beginner.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	return finishTx(ctx, tx, fn)
+}
+
+// finishTx runs fn against tx and commits or rolls back. A panic in fn rolls
+// back before it is re-raised.
+func finishTx(ctx context.Context, tx pgx.Tx, fn func(q *Queries) error) error {
+	// Roll back even if the request context was cancelled.
+	rollbackCtx := context.WithoutCancel(ctx)
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(rollbackCtx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(New(tx)); err != nil {
+		if rollbackErr := tx.Rollback(rollbackCtx); rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+			return errors.Join(err, fmt.Errorf("rollback transaction: %w", rollbackErr))
+		}
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// retryTx calls run until it succeeds, fails with a non-retryable error, or
+// attempts are exhausted.
+func retryTx(ctx context.Context, attempts int, run func() error) error {
+	var err error
+	for attempt := range attempts {
+		if err = run(); err == nil || !isRetryableTxError(err) {
+			return err
+		}
+
+		if attempt == attempts-1 {
+			break
+		}
+
+		backoff := txRetryBaseDelay << attempt
+		delay := backoff/2 + rand.N(backoff)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+
+	return fmt.Errorf("transaction failed after %d attempts: %w", attempts, err)
+}
+
+// isRetryableTxError reports serialization failures (40001) and deadlocks (40P01).
+func isRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type fakeTx struct {
+	pgx.Tx
+
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	tx.rolledBack = true
+	return nil
+}
+
+func TestFinishTx(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	tx := &fakeTx{}
+	if err := finishTx(ctx, tx, func(*Queries) error { return nil }); err != nil || !tx.committed || tx.rolledBack {
+		t.Fatalf("success: err = %v, committed = %v, rolled back = %v", err, tx.committed, tx.rolledBack)
+	}
+
+	errBoom := errors.New("boom")
+	tx = &fakeTx{}
+	if err := finishTx(ctx, tx, func(*Queries) error { return errBoom }); !errors.Is(err, errBoom) || tx.committed || !tx.rolledBack {
+		t.Fatalf("error: err = %v, committed = %v, rolled back = %v", err, tx.committed, tx.rolledBack)
+	}
+
+	tx = &fakeTx{}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected the panic to be re-raised")
+			}
+		}()
+		_ = finishTx(ctx, tx, func(*Queries) error { panic("boom") })
+	}()
+	if tx.committed || !tx.rolledBack {
+		t.Fatalf("panic: committed = %v, rolled back = %v", tx.committed, tx.rolledBack)
+	}
+}
+
+func TestRetryTx(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	serializationFailure := &pgconn.PgError{Code: "40001"}
+
+	calls := 0
+	err := retryTx(ctx, 3, func() error {
+		calls++
+		if calls < 3 {
+			return serializationFailure
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("retryTx() = %v after %d calls, want success after 3", err, calls)
+	}
+
+	calls = 0
+	err = retryTx(ctx, 2, func() error {
+		calls++
+		return &pgconn.PgError{Code: "40P01"}
+	})
+	if !isRetryableTxError(err) || calls != 2 {
+		t.Fatalf("retryTx() = %v after %d calls, want deadlock error after 2", err, calls)
+	}
+
+	calls = 0
+	errConstraint := &pgconn.PgError{Code: "23505"}
+	err = retryTx(ctx, 3, func() error {
+		calls++
+		return errConstraint
+	})
+	if !errors.Is(err, errConstraint) || calls != 1 {
+		t.Fatalf("retryTx() = %v after %d calls, want no retry for constraint errors", err, calls)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = retryTx(cancelled, 3, func() error { return serializationFailure })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("retryTx() with cancelled context = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,50 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLogHandlerAddsTraceCorrelation(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil))).With("component", "test")
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	logger.InfoContext(ctx, "traced")
+	logger.Info("untraced")
+
+	decoder := json.NewDecoder(&buf)
+
+	var traced map[string]any
+	if err := decoder.Decode(&traced); err != nil {
+		t.Fatalf("decode traced record: %v", err)
+	}
+	if traced["trace_id"] != traceID.String() || traced["span_id"] != spanID.String() {
+		t.Fatalf("traced record = %v, want trace_id and span_id", traced)
+	}
+	if traced["component"] != "test" {
+		t.Fatalf("traced record lost handler attributes: %v", traced)
+	}
+
+	var untraced map[string]any
+	if err := decoder.Decode(&untraced); err != nil {
+		t.Fatalf("decode untraced record: %v", err)
+	}
+	if _, ok := untraced["trace_id"]; ok {
+		t.Fatalf("untraced record = %v, want no trace_id", untraced)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Server-sent events extension for htmx 2, compatible with the attributes of
+ * the official htmx-ext-sse:
+ *
+ *   hx-ext="sse" sse-connect="/url"  opens an EventSource for the element
+ *   sse-swap="name[,name...]"         swaps each named event's data into the
+ *                                     element, honouring hx-target and hx-swap
+ *   hx-trigger="sse:name"             triggers the element on a named event
+ *
+ * Elements inside the connected element use its EventSource. The source is
+ * closed when the connected element is removed, and reopened with backoff if
+ * the browser gives up reconnecting.
+ */
+(function () {
+	'use strict';
+
+	var api;
+
+	htmx.defineExtension('sse', {
+		init: function (apiRef) {
+			api = apiRef;
+		},
+
+		onEvent: function (name, evt) {
+			var elt = evt.target || (evt.detail && evt.detail.elt);
+			if (!(elt instanceof Element)) {
+				return;
+			}
+
+			switch (name) {
+				case 'htmx:beforeCleanupElement':
+					var data = api.getInternalData(elt);
+					if (data.sseEventSource) {
+						data.sseEventSource.close();
+						data.sseEventSource = null;
+					}
+					return;
+
+				case 'htmx:afterProcessNode':
+					if (elt.hasAttribute('sse-connect') && !api.getInternalData(elt).sseEventSource) {
+						connect(elt, 0);
+					}
+					register(elt);
+			}
+		}
+	});
+
+	function connect(elt, attempt) {
+		var source = new EventSource(elt.getAttribute('sse-connect'), { withCredentials: true });
+		api.getInternalData(elt).sseEventSource = source;
+
+		source.onopen = function () {
+			attempt = 0;
+			api.triggerEvent(elt, 'htmx:sseOpen', { source: source });
+		};
+
+		source.onerror = function (err) {
+			api.triggerErrorEvent(elt, 'htmx:sseError', { error: err, source: source });
+
+			// The browser retries on its own unless the server refused the
+			// stream outright.
+			if (source.readyState !== EventSource.CLOSED) {
+				return;
+			}
+
+			var delay = Math.min(1000 * Math.pow(2, attempt), 30000);
+			setTimeout(function () {
+				if (!api.bodyContains(elt) || api.getInternalData(elt).sseEventSource !== source) {
+					return;
+				}
+				connect(elt, attempt + 1);
+				reregister(elt);
+			}, delay);
+		};
+	}
+
+	// reregister moves the listeners of elt and its descendants onto a new
+	// EventSource.
+	function reregister(elt) {
+		var elts = [elt].concat(Array.prototype.slice.call(elt.querySelectorAll('[sse-swap], [hx-trigger*="sse:"]')));
+		elts.forEach(function (child) {
+			api.getInternalData(child).sseSource = null;
+			register(child);
+		});
+	}
+
+	function sourceElement(elt) {
+		return api.getClosestMatch(elt, function (candidate) {
+			return !!api.getInternalData(candidate).sseEventSource;
+		});
+	}
+
+	function register(elt) {
+		var sourceElt = sourceElement(elt);
+		if (!sourceElt) {
+			return;
+		}
+
+		var source = api.getInternalData(sourceElt).sseEventSource;
+		var data = api.getInternalData(elt);
+		if (data.sseSource === source) {
+			return;
+		}
+		data.sseSource = source;
+
+		var swapNames = api.getAttributeValue(elt, 'sse-swap');
+		if (swapNames) {
+			swapNames.split(',').forEach(function (swapName) {
+				listen(source, elt, swapName.trim(), function (event) {
+					if (!api.triggerEvent(elt, 'htmx:sseBeforeMessage', event)) {
+						return;
+					}
+					htmx.swap(api.getTarget(elt), event.data, api.getSwapSpecification(elt));
+					api.triggerEvent(elt, 'htmx:sseMessage', event);
+				});
+			});
+		}
+
+		api.getTriggerSpecs(elt).forEach(function (spec) {
+			if (spec.trigger.indexOf('sse:') !== 0) {
+				return;
+			}
+			listen(source, elt, spec.trigger.slice(4), function (event) {
+				htmx.trigger(elt, spec.trigger, event);
+			});
+		});
+	}
+
+	// listen calls handler for every eventName message until elt leaves the
+	// page or is registered with another source.
+	function listen(source, elt, eventName, handler) {
+		var listener = function (event) {
+			if (!api.bodyContains(elt) || api.getInternalData(elt).sseSource !== source) {
+				source.removeEventListener(eventName, listener);
+				return;
+			}
+			handler(event);
+		};
+		source.addEventListener(eventName, listener);
+	}
+})();
@@ -0,0 +1,237 @@
+package userimport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/jobs"
+	"github.com/dunamismax/go-web-server/internal/store"
+)
+
+// Import states, as stored in user_imports.state.
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+)
+
+const (
+	// MaxStoredErrors caps the row errors kept on an import.
+	MaxStoredErrors = 1000
+	// progressInterval throttles progress writes for large files.
+	progressInterval = 500 * time.Millisecond
+	// maxAttempts is how many times a queued import is tried. A best-effort
+	// import retried after a crash reports the users it already created as
+	// existing.
+	maxAttempts = 3
+)
+
+// ImportArgs is the job that runs a queued import.
+type ImportArgs struct {
+	ImportID int64 `json:"import_id"`
+}
+
+// Kind implements jobs.Args.
+func (ImportArgs) Kind() string { return "user.import" }
+
+// InviteArgs is the job that invites an imported user.
+type InviteArgs struct {
+	UserID int64 `json:"user_id"`
+}
+
+// Kind implements jobs.Args.
+func (InviteArgs) Kind() string { return "user.invite" }
+
+// QueueParams describes an uploaded import.
+type QueueParams struct {
+	CreatedBy *int64
+	Filename  string
+	Format    Format
+	Mode      Mode
+	DryRun    bool
+}
+
+// Queue stores data and queues the job that imports it. Pass the
+// transaction's Querier so the upload's audit entry commits with it.
+func Queue(ctx context.Context, q store.Querier, params QueueParams, data []byte) (store.UserImport, error) {
+	imp, err := q.CreateUserImport(ctx, store.CreateUserImportParams{
+		CreatedBy: params.CreatedBy,
+		Filename:  params.Filename,
+		Format:    string(params.Format),
+		Mode:      string(params.Mode),
+		DryRun:    params.DryRun,
+	})
+	if err != nil {
+		return store.UserImport{}, fmt.Errorf("create user import: %w", err)
+	}
+
+	if err := q.CreateUserImportFile(ctx, store.CreateUserImportFileParams{ImportID: imp.ID, Data: data}); err != nil {
+		return store.UserImport{}, fmt.Errorf("store user import file: %w", err)
+	}
+
+	if _, err := jobs.Enqueue(ctx, q, ImportArgs{ImportID: imp.ID}, jobs.EnqueueOptions{MaxAttempts: maxAttempts}); err != nil {
+		return store.UserImport{}, fmt.Errorf("queue user import: %w", err)
+	}
+
+	return imp, nil
+}
+
+// Runner runs queued imports and invitations.
+type Runner struct {
+	store    store.TxQuerier
+	importer *Importer
+}
+
+// NewRunner creates a runner that imports with importer.
+func NewRunner(s store.TxQuerier, importer *Importer) *Runner {
+	return &Runner{store: s, importer: importer}
+}
+
+// Register adds the import and invitation job handlers to registry.
+func (r *Runner) Register(registry *jobs.Registry) {
+	jobs.Register(registry, r.runImport)
+	jobs.Register(registry, r.invite)
+}
+
+// runImport imports one uploaded file, recording progress as it goes, and
+// deletes the file once the import has finished.
+func (r *Runner) runImport(ctx context.Context, _ store.Job, args ImportArgs) error {
+	imp, err := r.store.GetUserImport(ctx, args.ImportID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load user import: %w", err)
+	}
+	if imp.State != StatePending && imp.State != StateRunning {
+		return nil
+	}
+
+	data, err := r.store.GetUserImportFile(ctx, imp.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		return r.finish(ctx, imp.ID, StateFailed, Result{}, "the uploaded file is missing")
+	}
+	if err != nil {
+		return fmt.Errorf("load user import file: %w", err)
+	}
+
+	rows, err := Decode(bytes.NewReader(data), Format(imp.Format))
+	if err != nil {
+		return r.finish(ctx, imp.ID, StateFailed, Result{}, err.Error())
+	}
+
+	if _, err := r.store.StartUserImport(ctx, store.StartUserImportParams{TotalRows: int32(len(rows)), ID: imp.ID}); err != nil {
+		return fmt.Errorf("start user import: %w", err)
+	}
+
+	var (
+		mu           sync.Mutex
+		lastProgress time.Time
+	)
+	result, err := r.importer.Run(ctx, rows, Options{
+		Mode:    Mode(imp.Mode),
+		DryRun:  imp.DryRun,
+		ActorID: imp.CreatedBy,
+		Progress: func(result Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			if time.Since(lastProgress) < progressInterval && result.Processed < result.Total {
+				return
+			}
+			lastProgress = time.Now()
+
+			err := r.store.UpdateUserImportProgress(ctx, store.UpdateUserImportProgressParams{
+				ProcessedRows: int32(result.Processed),
+				CreatedRows:   int32(result.Created),
+				FailedRows:    int32(result.Failed),
+				ID:            imp.ID,
+			})
+			if err != nil {
+				slog.WarnContext(ctx, "Failed to record user import progress", "import_id", imp.ID, "error", err)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("run user import: %w", err)
+	}
+
+	state, lastError := StateSucceeded, ""
+	if Mode(imp.Mode) == ModeAtomic && !imp.DryRun && result.Failed > 0 {
+		state = StateFailed
+		lastError = fmt.Sprintf("%d rows have errors; no users were created", result.Failed)
+	}
+
+	return r.finish(ctx, imp.ID, state, result, lastError)
+}
+
+// finish records the outcome and deletes the uploaded file, which may hold
+// initial passwords.
+func (r *Runner) finish(ctx context.Context, id int64, state string, result Result, lastError string) error {
+	rowErrors := result.Errors
+	if len(rowErrors) > MaxStoredErrors {
+		rowErrors = rowErrors[:MaxStoredErrors]
+	}
+	if rowErrors == nil {
+		rowErrors = []RowError{}
+	}
+	encoded, err := json.Marshal(rowErrors)
+	if err != nil {
+		return fmt.Errorf("encode user import errors: %w", err)
+	}
+
+	var lastErrorPtr *string
+	if lastError != "" {
+		lastErrorPtr = &lastError
+	}
+
+	return r.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		err := q.FinishUserImport(ctx, store.FinishUserImportParams{
+			State:         state,
+			ProcessedRows: int32(result.Processed),
+			CreatedRows:   int32(result.Created),
+			FailedRows:    int32(result.Failed),
+			RowErrors:     encoded,
+			LastError:     lastErrorPtr,
+			ID:            id,
+		})
+		if err != nil {
+			return fmt.Errorf("finish user import: %w", err)
+		}
+
+		return q.DeleteUserImportFile(ctx, id)
+	})
+}
+
+// invite records that an imported user should be invited. The application
+// does not send email yet, so this is where a mailer would send the link
+// that lets the user choose a password.
+func (r *Runner) invite(ctx context.Context, _ store.Job, args InviteArgs) error {
+	user, err := r.store.GetUser(ctx, args.UserID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load invited user: %w", err)
+	}
+
+	slog.InfoContext(ctx, "User invited", "user_id", user.ID, "email", user.Email)
+
+	return nil
+}
+
+// DecodeErrors decodes the row errors stored on an import.
+func DecodeErrors(imp store.UserImport) []RowError {
+	var rowErrors []RowError
+	if err := json.Unmarshal(imp.RowErrors, &rowErrors); err != nil {
+		return nil
+	}
+
+	return rowErrors
+}
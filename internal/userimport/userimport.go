@@ -0,0 +1,568 @@
+// Package userimport creates users in bulk from CSV or NDJSON files. Every
+// row is checked with the user form's validation rules before anything is
+// written, and a dry run stops there. An atomic import creates every user in
+// one transaction or none of them; a best-effort import creates each valid
+// row in its own transaction and reports the rest.
+package userimport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dunamismax/go-web-server/internal/jobs"
+	"github.com/dunamismax/go-web-server/internal/middleware"
+	"github.com/dunamismax/go-web-server/internal/store"
+)
+
+// Format is the encoding of an import file.
+type Format string
+
+// Supported formats.
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+// Mode decides what happens to valid rows when others fail.
+type Mode string
+
+// Import modes.
+const (
+	// ModeAtomic creates every user or, if any row fails, none of them.
+	ModeAtomic Mode = "atomic"
+	// ModeBestEffort creates every valid row and reports the others.
+	ModeBestEffort Mode = "best_effort"
+)
+
+const (
+	// MaxFileSize is the largest file an import accepts.
+	MaxFileSize = 10 << 20
+	// MaxRows is the most rows one import may contain.
+	MaxRows = 10000
+	// maxLineSize bounds one NDJSON line.
+	maxLineSize = 64 << 10
+)
+
+// ErrTooManyRows is returned by Decode for a file with more than MaxRows rows.
+var ErrTooManyRows = fmt.Errorf("import files are limited to %d rows", MaxRows)
+
+// columns lists the CSV columns an import understands. Avatars are uploaded
+// by their owners, so there is no avatar column.
+var columns = []string{"email", "name", "bio", "password", "invite"}
+
+// Row is one user to import.
+type Row struct {
+	// Line is where the row starts in its file.
+	Line  int    `json:"-"`
+	Email string `json:"email" validate:"required,email"`
+	Name  string `json:"name" validate:"required,min=2,max=100" sanitize:"strict"`
+	Bio   string `json:"bio,omitempty" validate:"max=500" sanitize:"ugc"`
+	// Password is the initial password; leave it empty to invite the user.
+	Password string `json:"password,omitempty" validate:"omitempty,password"`
+	// Invite creates the user with a random password and queues an
+	// invitation.
+	Invite bool `json:"invite,omitempty"`
+
+	// decodeErr explains why the row could not be read.
+	decodeErr string
+}
+
+// Validate implements custom validation for Row.
+func (r Row) Validate() error {
+	switch {
+	case r.Password == "" && !r.Invite:
+		return middleware.ValidationErrors{{Field: "password", Message: "set a password or invite the user"}}
+	case r.Password != "" && r.Invite:
+		return middleware.ValidationErrors{{Field: "invite", Message: "set a password or invite the user, not both"}}
+	}
+
+	return nil
+}
+
+// RowError is a problem with one row. Field is empty when the problem is
+// not tied to a column.
+type RowError struct {
+	Line    int    `json:"line"`
+	Email   string `json:"email,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e RowError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Field, e.Message)
+}
+
+// FormatForFilename picks the format from a file name's extension.
+func FormatForFilename(name string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV, true
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, true
+	default:
+		return "", false
+	}
+}
+
+// Decode reads every row of r. It fails only when the file as a whole cannot
+// be read, such as a CSV without an email column; a row that cannot be read
+// is returned and reported when the import runs.
+func Decode(r io.Reader, format Format) ([]Row, error) {
+	var (
+		rows []Row
+		err  error
+	)
+	switch format {
+	case FormatCSV:
+		rows, err = decodeCSV(r)
+	case FormatNDJSON:
+		rows, err = decodeNDJSON(r)
+	default:
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rows {
+		sanitizeRow(&rows[i])
+	}
+
+	return rows, nil
+}
+
+func decodeCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !isColumn(name) {
+			return nil, fmt.Errorf("unknown column %q; expected %s", name, strings.Join(columns, ", "))
+		}
+		if _, ok := index[name]; ok {
+			return nil, fmt.Errorf("column %q appears twice", name)
+		}
+		index[name] = i
+	}
+	for _, required := range []string{"email", "name"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("the header has no %s column", required)
+		}
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read CSV: %w", err)
+		}
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := Row{Line: line}
+		if len(record) != len(header) {
+			row.decodeErr = fmt.Sprintf("expected %d fields, found %d", len(header), len(record))
+			rows = append(rows, row)
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok {
+				return record[i]
+			}
+			return ""
+		}
+		row.Email = field("email")
+		row.Name = field("name")
+		row.Bio = field("bio")
+		row.Password = field("password")
+		row.Invite, err = parseBool(field("invite"))
+		if err != nil {
+			row.decodeErr = "invite must be true or false"
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func decodeNDJSON(r io.Reader) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+
+	var rows []Row
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+
+		row := Row{Line: line}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row); err != nil {
+			row = Row{Line: line, decodeErr: "invalid JSON: " + err.Error()}
+		} else if decoder.More() {
+			row = Row{Line: line, decodeErr: "invalid JSON: more than one value on the line"}
+		}
+		row.Line = line
+
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("a line is longer than %d bytes", maxLineSize)
+		}
+		return nil, fmt.Errorf("read NDJSON: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the file is empty")
+	}
+
+	return rows, nil
+}
+
+func isColumn(name string) bool {
+	for _, column := range columns {
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "false", "no", "n":
+		return false, nil
+	case "1", "true", "yes", "y":
+		return true, nil
+	default:
+		return strconv.ParseBool(value)
+	}
+}
+
+// sanitizeRow normalizes fields the way the request middleware normalizes
+// form values, then applies the HTML policies handlers apply. Passwords are
+// left as written, as they are in forms.
+func sanitizeRow(row *Row) {
+	for _, field := range []*string{&row.Email, &row.Name, &row.Bio} {
+		*field = middleware.SanitizeString(*field, middleware.DefaultSanitizeConfig)
+	}
+	// Row's tags name policies that exist, so this cannot fail.
+	_ = middleware.SanitizeStruct(row)
+}
+
+// PasswordHasher hashes initial passwords. *middleware.SessionAuthService
+// implements it.
+type PasswordHasher interface {
+	HashPasswordArgon2(ctx context.Context, password string) (string, error)
+}
+
+// CreatedFunc runs in the transaction that creates each user, so side
+// effects such as audit events commit or roll back with the user.
+type CreatedFunc func(ctx context.Context, q store.Querier, user store.User, actorID *int64) error
+
+// Options configures one import run.
+type Options struct {
+	Mode Mode
+	// DryRun validates every row without creating anything.
+	DryRun bool
+	// ActorID is the admin the import is recorded against, if any.
+	ActorID *int64
+	// Progress, if set, is called after each row with the totals so far.
+	Progress func(Result)
+}
+
+// Result summarizes an import run.
+type Result struct {
+	Total     int
+	Processed int
+	Created   int
+	// Failed counts rows with at least one error.
+	Failed int
+	Errors []RowError
+}
+
+// Importer creates users from decoded rows.
+type Importer struct {
+	store   store.TxQuerier
+	hasher  PasswordHasher
+	created CreatedFunc
+}
+
+// New creates an Importer. created may be nil.
+func New(s store.TxQuerier, hasher PasswordHasher, created CreatedFunc) *Importer {
+	return &Importer{store: s, hasher: hasher, created: created}
+}
+
+// Run validates rows and, unless opts.DryRun is set, creates their users as
+// opts.Mode says. Problems with rows are reported in the Result; the error is
+// for failures that stop the whole import, such as a lost database.
+func (imp *Importer) Run(ctx context.Context, rows []Row, opts Options) (Result, error) {
+	result := Result{Total: len(rows)}
+
+	progress := func() {
+		if opts.Progress != nil {
+			opts.Progress(result)
+		}
+	}
+
+	invalid := make(map[int][]RowError, 0)
+	seen := make(map[string]int, len(rows))
+	for i, row := range rows {
+		errs, err := imp.validate(ctx, row, seen)
+		if err != nil {
+			return result, err
+		}
+		if len(errs) > 0 {
+			invalid[i] = errs
+		}
+
+		if opts.DryRun {
+			result.Processed++
+			if len(errs) > 0 {
+				result.Failed++
+				result.Errors = append(result.Errors, errs...)
+			}
+			progress()
+		}
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	if opts.Mode == ModeAtomic {
+		return imp.runAtomic(ctx, rows, invalid, opts, result, progress)
+	}
+
+	for i, row := range rows {
+		if errs, ok := invalid[i]; ok {
+			result.Failed++
+			result.Errors = append(result.Errors, errs...)
+		} else {
+			hash, err := imp.hash(ctx, row)
+			if err != nil {
+				return result, err
+			}
+
+			err = imp.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+				return imp.create(ctx, q, row, hash, opts.ActorID)
+			})
+			if rowErr, ok := asRowError(row, err); ok {
+				result.Failed++
+				result.Errors = append(result.Errors, rowErr)
+			} else if err != nil {
+				return result, err
+			} else {
+				result.Created++
+			}
+		}
+
+		result.Processed++
+		progress()
+	}
+
+	return result, nil
+}
+
+func (imp *Importer) runAtomic(ctx context.Context, rows []Row, invalid map[int][]RowError, opts Options, result Result, progress func()) (Result, error) {
+	if len(invalid) > 0 {
+		for i := range rows {
+			if errs, ok := invalid[i]; ok {
+				result.Failed++
+				result.Errors = append(result.Errors, errs...)
+			}
+		}
+		result.Processed = result.Total
+		progress()
+
+		return result, nil
+	}
+
+	// Hash first so the transaction stays short and retries do not repeat
+	// the expensive work.
+	hashes := make([]string, len(rows))
+	for i, row := range rows {
+		hash, err := imp.hash(ctx, row)
+		if err != nil {
+			return result, err
+		}
+		hashes[i] = hash
+
+		result.Processed++
+		progress()
+	}
+
+	var failed *RowError
+	err := imp.store.RunInTx(ctx, store.TxOptions{}, func(q store.Querier) error {
+		failed = nil
+		for i, row := range rows {
+			err := imp.create(ctx, q, row, hashes[i], opts.ActorID)
+			if rowErr, ok := asRowError(row, err); ok {
+				failed = &rowErr
+				return err
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if failed != nil {
+		result.Failed = 1
+		result.Errors = append(result.Errors, *failed)
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	result.Created = result.Total
+	return result, nil
+}
+
+// validate returns the row's problems. seen maps emails earlier in the file
+// to their lines.
+func (imp *Importer) validate(ctx context.Context, row Row, seen map[string]int) ([]RowError, error) {
+	if row.decodeErr != "" {
+		return []RowError{{Line: row.Line, Message: row.decodeErr}}, nil
+	}
+
+	var errs []RowError
+	fieldErrors := middleware.ValidateStruct(row)
+	var custom middleware.ValidationErrors
+	if errors.As(row.Validate(), &custom) {
+		fieldErrors = append(fieldErrors, custom...)
+	}
+	for _, fieldErr := range fieldErrors {
+		errs = append(errs, RowError{Line: row.Line, Email: row.Email, Field: fieldErr.Field, Message: fieldErr.Message})
+	}
+
+	if row.Email == "" {
+		return errs, nil
+	}
+
+	key := strings.ToLower(row.Email)
+	if line, ok := seen[key]; ok {
+		return append(errs, RowError{
+			Line:    row.Line,
+			Email:   row.Email,
+			Field:   "email",
+			Message: fmt.Sprintf("email also appears on line %d", line),
+		}), nil
+	}
+	seen[key] = row.Line
+
+	_, err := imp.store.GetUserByEmail(ctx, row.Email)
+	switch {
+	case err == nil:
+		errs = append(errs, RowError{Line: row.Line, Email: row.Email, Field: "email", Message: "email already exists"})
+	case !errors.Is(err, store.ErrNotFound):
+		return nil, fmt.Errorf("look up %s: %w", row.Email, err)
+	}
+
+	return errs, nil
+}
+
+// hash returns the password hash for row, generating a random password for
+// invited users.
+func (imp *Importer) hash(ctx context.Context, row Row) (string, error) {
+	password := row.Password
+	if row.Invite {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return "", fmt.Errorf("generate password: %w", err)
+		}
+		password = hex.EncodeToString(random)
+	}
+
+	hash, err := imp.hasher.HashPasswordArgon2(ctx, password)
+	if err != nil {
+		return "", fmt.Errorf("hash password for line %d: %w", row.Line, err)
+	}
+
+	return hash, nil
+}
+
+func (imp *Importer) create(ctx context.Context, q store.Querier, row Row, hash string, actorID *int64) error {
+	user, err := q.CreateUser(ctx, store.CreateUserParams{
+		Email:        row.Email,
+		Name:         row.Name,
+		Bio:          optional(row.Bio),
+		PasswordHash: hash,
+	})
+	if err != nil {
+		return err
+	}
+
+	if row.Invite {
+		if _, err := jobs.Enqueue(ctx, q, InviteArgs{UserID: user.ID}, jobs.EnqueueOptions{}); err != nil {
+			return err
+		}
+	}
+
+	if imp.created != nil {
+		return imp.created(ctx, q, user, actorID)
+	}
+
+	return nil
+}
+
+// asRowError reports a constraint violation while creating row as a problem
+// with the row rather than with the import.
+func asRowError(row Row, err error) (RowError, bool) {
+	constraintErr, ok := store.AsConstraintError(err)
+	if !ok {
+		return RowError{}, false
+	}
+
+	rowErr := RowError{Line: row.Line, Email: row.Email, Field: constraintErr.Column, Message: "violates " + constraintErr.Constraint}
+	if constraintErr.Kind == store.ConstraintUnique && constraintErr.Column == "email" {
+		rowErr.Message = "email already exists"
+	}
+
+	return rowErr, true
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
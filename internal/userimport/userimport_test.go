@@ -0,0 +1,250 @@
+package userimport
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dunamismax/go-web-server/internal/jobs"
+	"github.com/dunamismax/go-web-server/internal/store"
+	"github.com/dunamismax/go-web-server/internal/store/memstore"
+)
+
+const testPassword = "Import-Pass-123"
+
+// fakeHasher skips Argon2 so tests stay fast.
+type fakeHasher struct{}
+
+func (fakeHasher) HashPasswordArgon2(_ context.Context, password string) (string, error) {
+	return "hashed:" + password, nil
+}
+
+func decode(t *testing.T, format Format, data string) []Row {
+	t.Helper()
+
+	rows, err := Decode(strings.NewReader(data), format)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	return rows
+}
+
+func TestDecodeCSV(t *testing.T) {
+	t.Parallel()
+
+	rows := decode(t, FormatCSV, "\ufeffEmail,Name,Invite\n  ada@example.com ,Ada Lovelace,yes\nbad,row\n")
+	if len(rows) != 2 {
+		t.Fatalf("len(rows) = %d, want 2", len(rows))
+	}
+	if got := rows[0]; got.Email != "ada@example.com" || got.Name != "Ada Lovelace" || !got.Invite || got.Line != 2 {
+		t.Fatalf("rows[0] = %+v, want trimmed invited Ada on line 2", got)
+	}
+	if rows[1].decodeErr == "" {
+		t.Fatal("rows[1] decoded a short record without an error")
+	}
+
+	for name, data := range map[string]string{
+		"empty":          "",
+		"unknown column": "email,name,role\n",
+		"missing name":   "email,password\n",
+	} {
+		if _, err := Decode(strings.NewReader(data), FormatCSV); err == nil {
+			t.Errorf("Decode(%s) error = nil, want an error", name)
+		}
+	}
+}
+
+func TestDecodeNDJSON(t *testing.T) {
+	t.Parallel()
+
+	rows := decode(t, FormatNDJSON, `{"email":"ada@example.com","name":"Ada Lovelace","password":"`+testPassword+`"}
+
+{"email":"grace@example.com","role":"admin"}
+not json
+`)
+	if len(rows) != 3 {
+		t.Fatalf("len(rows) = %d, want 3", len(rows))
+	}
+	if rows[0].Password != testPassword || rows[0].Line != 1 {
+		t.Fatalf("rows[0] = %+v, want the password on line 1", rows[0])
+	}
+	if rows[1].Line != 3 || rows[1].decodeErr == "" {
+		t.Fatalf("rows[1] = %+v, want an unknown field error on line 3", rows[1])
+	}
+	if rows[2].Line != 4 || rows[2].decodeErr == "" {
+		t.Fatalf("rows[2] = %+v, want a JSON error on line 4", rows[2])
+	}
+}
+
+func TestRunDryRunReportsEveryProblem(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := memstore.New()
+	if _, err := s.CreateUser(ctx, store.CreateUserParams{Email: "taken@example.com", Name: "Taken", PasswordHash: "x"}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	rows := decode(t, FormatCSV, strings.Join([]string{
+		"email,name,password,invite",
+		"ada@example.com,Ada Lovelace," + testPassword + ",",
+		"not-an-email,A,,",
+		"taken@example.com,Taken Again,,true",
+		"ADA@example.com,Ada Again,,true",
+		"both@example.com,Both Set," + testPassword + ",true",
+	}, "\n"))
+
+	var calls int
+	result, err := New(s, fakeHasher{}, nil).Run(ctx, rows, Options{
+		Mode:     ModeAtomic,
+		DryRun:   true,
+		Progress: func(Result) { calls++ },
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if result.Total != 5 || result.Processed != 5 || result.Failed != 4 || result.Created != 0 {
+		t.Fatalf("Run() = %+v, want 5 processed, 4 failed, none created", result)
+	}
+	if calls != 5 {
+		t.Fatalf("progress calls = %d, want 5", calls)
+	}
+
+	fields := make(map[int][]string)
+	for _, rowErr := range result.Errors {
+		fields[rowErr.Line] = append(fields[rowErr.Line], rowErr.Field)
+	}
+	for line, want := range map[int][]string{
+		3: {"email", "name", "password"},
+		4: {"email"},
+		5: {"email"},
+		6: {"invite"},
+	} {
+		if strings.Join(fields[line], ",") != strings.Join(want, ",") {
+			t.Errorf("line %d error fields = %v, want %v", line, fields[line], want)
+		}
+	}
+
+	if _, err := s.GetUserByEmail(ctx, "ada@example.com"); err == nil {
+		t.Fatal("dry run created a user")
+	}
+}
+
+func TestRunAtomicCreatesAllOrNothing(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := memstore.New()
+
+	var created []int64
+	importer := New(s, fakeHasher{}, func(_ context.Context, _ store.Querier, user store.User, _ *int64) error {
+		created = append(created, user.ID)
+		return nil
+	})
+
+	bad := decode(t, FormatNDJSON, `{"email":"ada@example.com","name":"Ada Lovelace","password":"`+testPassword+`"}
+{"email":"grace@example.com","name":"G","invite":true}
+`)
+	result, err := importer.Run(ctx, bad, Options{Mode: ModeAtomic})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Created != 0 || result.Failed != 1 {
+		t.Fatalf("Run() = %+v, want nothing created and one failure", result)
+	}
+	if _, err := s.GetUserByEmail(ctx, "ada@example.com"); err == nil {
+		t.Fatal("atomic import created a user despite an invalid row")
+	}
+
+	good := decode(t, FormatNDJSON, `{"email":"ada@example.com","name":"Ada Lovelace","password":"`+testPassword+`"}
+{"email":"grace@example.com","name":"Grace Hopper","invite":true}
+`)
+	result, err = importer.Run(ctx, good, Options{Mode: ModeAtomic})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Created != 2 || result.Failed != 0 || len(created) != 2 {
+		t.Fatalf("Run() = %+v with %d callbacks, want both created", result, len(created))
+	}
+
+	ada, err := s.GetUserByEmail(ctx, "ada@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail() error = %v", err)
+	}
+	if ada.PasswordHash != "hashed:"+testPassword {
+		t.Fatalf("PasswordHash = %q, want the hashed initial password", ada.PasswordHash)
+	}
+
+	queued, err := s.ListJobs(ctx, store.ListJobsParams{MaxRows: 10})
+	if err != nil {
+		t.Fatalf("ListJobs() error = %v", err)
+	}
+	if len(queued) != 1 || queued[0].Kind != (InviteArgs{}).Kind() {
+		t.Fatalf("queued jobs = %+v, want one invitation", queued)
+	}
+}
+
+func TestRunBestEffortCreatesValidRows(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := memstore.New()
+
+	rows := decode(t, FormatCSV, strings.Join([]string{
+		"email,name,invite",
+		"ada@example.com,Ada Lovelace,true",
+		"grace@example.com,G,true",
+		"linus@example.com,Linus Torvalds,true",
+	}, "\n"))
+
+	result, err := New(s, fakeHasher{}, nil).Run(ctx, rows, Options{Mode: ModeBestEffort})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Created != 2 || result.Failed != 1 || result.Processed != 3 {
+		t.Fatalf("Run() = %+v, want 2 created and 1 failed", result)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Line != 3 {
+		t.Fatalf("Errors = %+v, want one error on line 3", result.Errors)
+	}
+}
+
+func TestRunnerImportsQueuedFile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := memstore.New()
+
+	data := []byte("email,name,password\nada@example.com,Ada Lovelace," + testPassword + "\nbad,B,\n")
+	imp, err := Queue(ctx, s, QueueParams{Filename: "users.csv", Format: FormatCSV, Mode: ModeBestEffort}, data)
+	if err != nil {
+		t.Fatalf("Queue() error = %v", err)
+	}
+
+	runner := NewRunner(s, New(s, fakeHasher{}, nil))
+	if err := runner.runImport(ctx, store.Job{}, ImportArgs{ImportID: imp.ID}); err != nil {
+		t.Fatalf("runImport() error = %v", err)
+	}
+
+	imp, err = s.GetUserImport(ctx, imp.ID)
+	if err != nil {
+		t.Fatalf("GetUserImport() error = %v", err)
+	}
+	if imp.State != StateSucceeded || imp.TotalRows != 2 || imp.CreatedRows != 1 || imp.FailedRows != 1 {
+		t.Fatalf("import = %+v, want succeeded with 1 created and 1 failed", imp)
+	}
+	if rowErrors := DecodeErrors(imp); len(rowErrors) == 0 || rowErrors[0].Line != 3 {
+		t.Fatalf("DecodeErrors() = %+v, want errors on line 3", rowErrors)
+	}
+	if _, err := s.GetUserImportFile(ctx, imp.ID); err == nil {
+		t.Fatal("the uploaded file was kept after the import finished")
+	}
+
+	registry := jobs.NewRegistry()
+	runner.Register(registry)
+	if kinds := registry.Kinds(); strings.Join(kinds, ",") != "user.import,user.invite" {
+		t.Fatalf("Kinds() = %v, want user.import and user.invite", kinds)
+	}
+}
@@ -128,7 +128,7 @@ func HomeContent() templ.Component {
 			templ_7745c5c3_Var5 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<section><hgroup><h1>go-web-server</h1><p>A small Echo + Templ + HTMX starter with PostgreSQL-backed sessions and a protected user CRUD demo.</p></hgroup><div class=\"grid\"><div><p><strong>What it is:</strong> a basic server-rendered Go app with session auth, CSRF protection, SQLC queries, and just enough structure to start changing it.</p></div><div style=\"text-align: center;\"><button onclick=\"document.getElementById('demo-area').scrollIntoView({ behavior: 'smooth', block: 'center' }); setTimeout(() => { document.querySelector('#demo-area button[hx-get=\\'/demo\\']').click(); }, 500);\" class=\"contrast\" style=\"font-size: 18px; padding: 16px 32px; font-weight: 600;\">Try Live Demo</button><br><br><small>Scroll down to load server-rendered partials.</small></div></div></section><section><h2>What&apos;s Here</h2><div class=\"grid\"><article><header><h4>Go Web Basics</h4></header><p><strong>Echo + Templ + HTMX:</strong> routes, handlers, and partial page updates without adding a frontend framework.</p><details><summary role=\"button\" class=\"secondary outline\">Learn More</summary><ul><li>Thin handlers with store-backed persistence</li><li>Server-rendered HTML fragments for HTMX swaps</li><li>Health and demo endpoints for quick smoke tests</li></ul></details></article><article><header><h4>Auth + Data</h4></header><p><strong>PostgreSQL + SQLC + SCS:</strong> one users table, session cookies stored in Postgres, and Argon2id hashing for newly registered accounts.</p><details><summary role=\"button\" class=\"secondary outline\">Learn More</summary><ul><li>User management routes now require login</li><li>CSRF protection covers state-changing requests</li><li>Parameterized SQLC queries handle database writes</li></ul></details></article><article><header><h4>Sharp Edges Left Intact</h4></header><p><strong>This is not a framework:</strong> there are no roles, background jobs, password reset flow, metrics endpoint, or polished component system.</p><details><summary role=\"button\" class=\"secondary outline\">Learn More</summary><ul><li>Use it as a starter, not as an architecture promise</li><li>Expect to replace copy, routes, and templates quickly</li><li>Current styling is functional, not precious</li></ul></details></article></div></section><section><h2>Quick Actions</h2><div class=\"grid\"><div role=\"group\" style=\"display: flex; flex-wrap: wrap; gap: 1rem; justify-content: center;\"><button hx-get=\"/demo\" hx-target=\"#demo-area\" hx-swap=\"innerHTML swap:0s settle:0s\" hx-trigger=\"click\" hx-indicator=\".demo-indicator\" style=\"min-width: 140px;\">Show Demo <span class=\"demo-indicator htmx-indicator css-spinner\" style=\"margin-left: 0.5rem;\" aria-hidden=\"true\"></span></button> <button hx-get=\"/auth/login\" hx-target=\"main\" hx-swap=\"innerHTML swap:0s settle:0s\" hx-push-url=\"true\" hx-indicator=\".login-indicator\" class=\"secondary\" style=\"min-width: 140px;\">Sign In <span class=\"login-indicator htmx-indicator css-spinner\" style=\"margin-left: 0.5rem;\" aria-hidden=\"true\"></span></button> <button hx-get=\"/health\" hx-target=\"#demo-area\" hx-swap=\"innerHTML swap:0s settle:0s\" class=\"outline\" hx-indicator=\".health-indicator\" style=\"min-width: 140px;\">Health Check <span class=\"health-indicator htmx-indicator css-spinner\" style=\"margin-left: 0.5rem;\" aria-hidden=\"true\"></span></button></div></div></section><section><div id=\"demo-area\" style=\"scroll-margin-top: 2rem;\"><article style=\"border: 2px dashed #0ea5e9; background: rgba(14, 165, 233, 0.08);\"><header><h4>Interactive Demo Area</h4></header><p>Click the buttons above to load server-rendered responses. The users screen is behind login on purpose.</p><div class=\"grid\"><div><p><small><strong>Developer Tip:</strong> Open your browser&apos;s Network tab to see the HTMX request/response cycle.</small></p></div><div style=\"text-align: center;\"><small style=\"color: #2563eb; font-weight: 600;\">Try the buttons above</small></div></div></article></div></section><section><h2>Included</h2><div class=\"grid\"><div><h5>Core Stack</h5><ul><li>Go 1.26 + Echo v4</li><li>Templ views + HTMX partials</li><li>PostgreSQL + pgx/v5 + SQLC</li><li>SCS-backed session auth</li><li>Mage + Atlas + Tailwind tooling</li></ul></div><div><h5>Security Posture</h5><ul><li>Session cookie auth for protected pages</li><li>CSRF protection on writes</li><li>Rate limiting and security headers</li><li>Templ autoescaping</li><li>Request normalization for form input</li></ul></div><div><h5>Current Limits</h5><ul><li>No roles or per-user authorization</li><li>No password reset or email flow</li><li>No metrics or pprof endpoints</li><li>No API versioning or OpenAPI spec</li><li>UI is a starter, not a design system</li></ul></div></div></section><section><article><header><h3>Good Fit</h3></header><div class=\"grid\"><div><p><strong>Small Internal Tools:</strong> Start with basic CRUD, auth, and templates instead of scaffolding a larger stack.</p></div><div><p><strong>Learning Projects:</strong> Trace a request from route to handler to store without a lot of framework ceremony.</p></div><div><p><strong>Starter Repos:</strong> Clone it, delete what you don&apos;t need, and build from a smaller honest base.</p></div></div></article></section>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<section><hgroup><h1>go-web-server</h1><p>A small Echo + Templ + HTMX starter with PostgreSQL-backed sessions and a protected user CRUD demo.</p></hgroup><div class=\"grid\"><div><p><strong>What it is:</strong> a basic server-rendered Go app with session auth, CSRF protection, SQLC queries, and just enough structure to start changing it.</p></div><div style=\"text-align: center;\"><button hx-get=\"/demo\" hx-target=\"#demo-area\" hx-swap=\"innerHTML show:#demo-area:center\" class=\"contrast\" style=\"font-size: 18px; padding: 16px 32px; font-weight: 600;\">Try Live Demo</button><br><br><small>Scroll down to load server-rendered partials.</small></div></div></section><section><h2>What&apos;s Here</h2><div class=\"grid\"><article><header><h4>Go Web Basics</h4></header><p><strong>Echo + Templ + HTMX:</strong> routes, handlers, and partial page updates without adding a frontend framework.</p><details><summary role=\"button\" class=\"secondary outline\">Learn More</summary><ul><li>Thin handlers with store-backed persistence</li><li>Server-rendered HTML fragments for HTMX swaps</li><li>Health and demo endpoints for quick smoke tests</li></ul></details></article><article><header><h4>Auth + Data</h4></header><p><strong>PostgreSQL + SQLC + SCS:</strong> one users table, session cookies stored in Postgres, and Argon2id hashing for newly registered accounts.</p><details><summary role=\"button\" class=\"secondary outline\">Learn More</summary><ul><li>User management routes now require login</li><li>CSRF protection covers state-changing requests</li><li>Parameterized SQLC queries handle database writes</li></ul></details></article><article><header><h4>Sharp Edges Left Intact</h4></header><p><strong>This is not a framework:</strong> there are no roles, background jobs, password reset flow, metrics endpoint, or polished component system.</p><details><summary role=\"button\" class=\"secondary outline\">Learn More</summary><ul><li>Use it as a starter, not as an architecture promise</li><li>Expect to replace copy, routes, and templates quickly</li><li>Current styling is functional, not precious</li></ul></details></article></div></section><section><h2>Quick Actions</h2><div class=\"grid\"><div role=\"group\" style=\"display: flex; flex-wrap: wrap; gap: 1rem; justify-content: center;\"><button hx-get=\"/demo\" hx-target=\"#demo-area\" hx-swap=\"innerHTML swap:0s settle:0s\" hx-trigger=\"click\" hx-indicator=\".demo-indicator\" style=\"min-width: 140px;\">Show Demo <span class=\"demo-indicator htmx-indicator css-spinner\" style=\"margin-left: 0.5rem;\" aria-hidden=\"true\"></span></button> <button hx-get=\"/auth/login\" hx-target=\"main\" hx-swap=\"innerHTML swap:0s settle:0s\" hx-push-url=\"true\" hx-indicator=\".login-indicator\" class=\"secondary\" style=\"min-width: 140px;\">Sign In <span class=\"login-indicator htmx-indicator css-spinner\" style=\"margin-left: 0.5rem;\" aria-hidden=\"true\"></span></button> <button hx-get=\"/health\" hx-target=\"#demo-area\" hx-swap=\"innerHTML swap:0s settle:0s\" class=\"outline\" hx-indicator=\".health-indicator\" style=\"min-width: 140px;\">Health Check <span class=\"health-indicator htmx-indicator css-spinner\" style=\"margin-left: 0.5rem;\" aria-hidden=\"true\"></span></button></div></div></section><section><div id=\"demo-area\" style=\"scroll-margin-top: 2rem;\"><article style=\"border: 2px dashed #0ea5e9; background: rgba(14, 165, 233, 0.08);\"><header><h4>Interactive Demo Area</h4></header><p>Click the buttons above to load server-rendered responses. The users screen is behind login on purpose.</p><div class=\"grid\"><div><p><small><strong>Developer Tip:</strong> Open your browser&apos;s Network tab to see the HTMX request/response cycle.</small></p></div><div style=\"text-align: center;\"><small style=\"color: #2563eb; font-weight: 600;\">Try the buttons above</small></div></div></article></div></section><section><h2>Included</h2><div class=\"grid\"><div><h5>Core Stack</h5><ul><li>Go 1.26 + Echo v4</li><li>Templ views + HTMX partials</li><li>PostgreSQL + pgx/v5 + SQLC</li><li>SCS-backed session auth</li><li>Mage + Atlas + Tailwind tooling</li></ul></div><div><h5>Security Posture</h5><ul><li>Session cookie auth for protected pages</li><li>CSRF protection on writes</li><li>Rate limiting and security headers</li><li>Templ autoescaping</li><li>Request normalization for form input</li></ul></div><div><h5>Current Limits</h5><ul><li>No roles or per-user authorization</li><li>No password reset or email flow</li><li>No metrics or pprof endpoints</li><li>No API versioning or OpenAPI spec</li><li>UI is a starter, not a design system</li></ul></div></div></section><section><article><header><h3>Good Fit</h3></header><div class=\"grid\"><div><p><strong>Small Internal Tools:</strong> Start with basic CRUD, auth, and templates instead of scaffolding a larger stack.</p></div><div><p><strong>Learning Projects:</strong> Trace a request from route to handler to store without a lot of framework ceremony.</p></div><div><p><strong>Starter Repos:</strong> Clone it, delete what you don&apos;t need, and build from a smaller honest base.</p></div></div></article></section>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -164,7 +164,7 @@ func DemoContent(message string, features []string, serverTime, requestID string
 		var templ_7745c5c3_Var7 string
 		templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(message)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 227, Col: 22}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 229, Col: 22}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
 		if templ_7745c5c3_Err != nil {
@@ -182,7 +182,7 @@ func DemoContent(message string, features []string, serverTime, requestID string
 			var templ_7745c5c3_Var8 string
 			templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(feature)
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 233, Col: 19}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 235, Col: 19}
 			}
 			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
 			if templ_7745c5c3_Err != nil {
@@ -200,7 +200,7 @@ func DemoContent(message string, features []string, serverTime, requestID string
 		var templ_7745c5c3_Var9 string
 		templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinStringErrs(serverTime)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 239, Col: 56}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 241, Col: 56}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
 		if templ_7745c5c3_Err != nil {
@@ -218,7 +218,7 @@ func DemoContent(message string, features []string, serverTime, requestID string
 			var templ_7745c5c3_Var10 string
 			templ_7745c5c3_Var10, templ_7745c5c3_Err = templ.JoinStringErrs(requestID)
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 241, Col: 55}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 243, Col: 55}
 			}
 			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var10))
 			if templ_7745c5c3_Err != nil {
@@ -237,7 +237,9 @@ func DemoContent(message string, features []string, serverTime, requestID string
 	})
 }
 
-func HealthCheck(status, service, version, uptime, timestamp string, checks map[string]string) templ.Component {
+// HealthStatus is the public health fragment; the checks behind it are only
+// reported on the admin/ops listener.
+func HealthStatus(status, timestamp string) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -258,7 +260,7 @@ func HealthCheck(status, service, version, uptime, timestamp string, checks map[
 			templ_7745c5c3_Var11 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "<article><header><h4>System Health Check</h4></header><div class=\"grid\"><div><h6>Service Status</h6><p><strong>Status:</strong> ")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "<article><header><h4>System Health Check</h4></header><p><strong>Status:</strong> ")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -270,7 +272,7 @@ func HealthCheck(status, service, version, uptime, timestamp string, checks map[
 			var templ_7745c5c3_Var12 string
 			templ_7745c5c3_Var12, templ_7745c5c3_Err = templ.JoinStringErrs(status)
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 281, Col: 47}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 282, Col: 45}
 			}
 			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var12))
 			if templ_7745c5c3_Err != nil {
@@ -288,7 +290,7 @@ func HealthCheck(status, service, version, uptime, timestamp string, checks map[
 			var templ_7745c5c3_Var13 string
 			templ_7745c5c3_Var13, templ_7745c5c3_Err = templ.JoinStringErrs(status)
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 283, Col: 47}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 284, Col: 45}
 			}
 			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var13))
 			if templ_7745c5c3_Err != nil {
@@ -299,123 +301,206 @@ func HealthCheck(status, service, version, uptime, timestamp string, checks map[
 				return templ_7745c5c3_Err
 			}
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, "</p><p><strong>Service:</strong> ")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, "</p><footer><small><strong>Last checked:</strong> ")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		var templ_7745c5c3_Var14 string
-		templ_7745c5c3_Var14, templ_7745c5c3_Err = templ.JoinStringErrs(service)
+		templ_7745c5c3_Var14, templ_7745c5c3_Err = templ.JoinStringErrs(timestamp)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 286, Col: 42}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 288, Col: 52}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var14))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 17, "</p><p><strong>Version:</strong> ")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 17, "</small></footer></article>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+func HealthCheck(status, service, version, uptime, timestamp string, checks map[string]string) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var15 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var15 == nil {
+			templ_7745c5c3_Var15 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 18, "<article><header><h4>System Health Check</h4></header><div class=\"grid\"><div><h6>Service Status</h6><p><strong>Status:</strong> ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if status == "ok" {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 19, "<span style=\"color: #16a34a\">✓ ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var16 string
+			templ_7745c5c3_Var16, templ_7745c5c3_Err = templ.JoinStringErrs(status)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 304, Col: 47}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var16))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 20, "</span>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		} else {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 21, "<span style=\"color: #dc2626\">✗ ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var17 string
+			templ_7745c5c3_Var17, templ_7745c5c3_Err = templ.JoinStringErrs(status)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 306, Col: 47}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var17))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 22, "</span>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 23, "</p><p><strong>Service:</strong> ")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var18 string
+		templ_7745c5c3_Var18, templ_7745c5c3_Err = templ.JoinStringErrs(service)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 309, Col: 42}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var18))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var15 string
-		templ_7745c5c3_Var15, templ_7745c5c3_Err = templ.JoinStringErrs(version)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 24, "</p><p><strong>Version:</strong> ")
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 287, Col: 42}
+			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var15))
+		var templ_7745c5c3_Var19 string
+		templ_7745c5c3_Var19, templ_7745c5c3_Err = templ.JoinStringErrs(version)
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 310, Col: 42}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var19))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 18, "</p><p><strong>Uptime:</strong> ")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 25, "</p><p><strong>Uptime:</strong> ")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var16 string
-		templ_7745c5c3_Var16, templ_7745c5c3_Err = templ.JoinStringErrs(uptime)
+		var templ_7745c5c3_Var20 string
+		templ_7745c5c3_Var20, templ_7745c5c3_Err = templ.JoinStringErrs(uptime)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 288, Col: 40}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 311, Col: 40}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var16))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var20))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 19, "</p></div><div><h6>Component Checks</h6>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 26, "</p></div><div><h6>Component Checks</h6>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		for component, checkStatus := range checks {
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 20, "<p><strong>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 27, "<p><strong>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			var templ_7745c5c3_Var17 string
-			templ_7745c5c3_Var17, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("%s:", component))
+			var templ_7745c5c3_Var21 string
+			templ_7745c5c3_Var21, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("%s:", component))
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 294, Col: 45}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 317, Col: 45}
 			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var17))
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var21))
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 21, "</strong> ")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 28, "</strong> ")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 			if checkStatus == "ok" {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 22, "<span style=\"color: #16a34a\">✓ ")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 29, "<span style=\"color: #16a34a\">✓ ")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var18 string
-				templ_7745c5c3_Var18, templ_7745c5c3_Err = templ.JoinStringErrs(checkStatus)
+				var templ_7745c5c3_Var22 string
+				templ_7745c5c3_Var22, templ_7745c5c3_Err = templ.JoinStringErrs(checkStatus)
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 296, Col: 53}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 319, Col: 53}
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var18))
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var22))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 23, "</span>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 30, "</span>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			} else {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 24, "<span style=\"color: #dc2626\">✗ ")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 31, "<span style=\"color: #dc2626\">✗ ")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var19 string
-				templ_7745c5c3_Var19, templ_7745c5c3_Err = templ.JoinStringErrs(checkStatus)
+				var templ_7745c5c3_Var23 string
+				templ_7745c5c3_Var23, templ_7745c5c3_Err = templ.JoinStringErrs(checkStatus)
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 298, Col: 53}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 321, Col: 53}
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var19))
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var23))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 25, "</span>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 32, "</span>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 26, "</p>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 33, "</p>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 27, "</div></div><footer><small><strong>Last checked:</strong> ")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 34, "</div></div><footer><small><strong>Last checked:</strong> ")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var20 string
-		templ_7745c5c3_Var20, templ_7745c5c3_Err = templ.JoinStringErrs(timestamp)
+		var templ_7745c5c3_Var24 string
+		templ_7745c5c3_Var24, templ_7745c5c3_Err = templ.JoinStringErrs(timestamp)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 305, Col: 52}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/view/home.templ`, Line: 328, Col: 52}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var20))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var24))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 28, "</small></footer></article>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 35, "</small></footer></article>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
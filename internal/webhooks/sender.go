@@ -0,0 +1,243 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/jobs"
+	"github.com/dunamismax/go-web-server/internal/store"
+)
+
+const (
+	// maxResponseBody is how much of each response the delivery log keeps.
+	maxResponseBody = 4 << 10
+	userAgent       = "go-web-server-webhooks/1"
+)
+
+// errPrivateAddress is returned when an endpoint resolves to an address
+// deliveries may not reach.
+var errPrivateAddress = errors.New("webhook endpoint resolves to a private or loopback address")
+
+// Config configures a Sender.
+type Config struct {
+	// Timeout bounds each delivery request, including reading the response.
+	Timeout time.Duration
+	// DisableAfter disables an endpoint once this many attempts in a row
+	// have failed; zero never disables it.
+	DisableAfter int32
+	// AllowPrivateNetworks lets endpoints resolve to loopback, private and
+	// link-local addresses. Leave it off unless every admin is trusted not
+	// to point webhooks at internal services.
+	AllowPrivateNetworks bool
+}
+
+// DefaultConfig provides the sender defaults.
+var DefaultConfig = Config{
+	Timeout:      10 * time.Second,
+	DisableAfter: 20,
+}
+
+// Sender runs the jobs that send deliveries.
+type Sender struct {
+	store  store.Querier
+	client *http.Client
+	config Config
+}
+
+// NewSender creates a sender with the default configuration.
+func NewSender(q store.Querier) *Sender {
+	return NewSenderWithConfig(q, DefaultConfig)
+}
+
+// NewSenderWithConfig creates a sender with a custom configuration.
+func NewSenderWithConfig(q store.Querier, config Config) *Sender {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultConfig.Timeout
+	}
+	if config.DisableAfter < 0 {
+		config.DisableAfter = 0
+	}
+
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	if !config.AllowPrivateNetworks {
+		// Checked after DNS resolution, so a public name pointing at an
+		// internal address is refused too.
+		dialer.Control = denyPrivateAddress
+	}
+
+	client := &http.Client{
+		Timeout: config.Timeout,
+		Transport: &http.Transport{
+			// No proxy: it would dial internal addresses on our behalf.
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: config.Timeout,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		// A redirect is reported as the endpoint's response rather than
+		// followed to wherever it points.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &Sender{store: q, client: client, config: config}
+}
+
+// Register adds the delivery job handler to registry.
+func (s *Sender) Register(registry *jobs.Registry) {
+	jobs.Register(registry, s.deliver)
+}
+
+// deliver sends one delivery and records the attempt. It returns an error to
+// have the job queue retry the delivery.
+func (s *Sender) deliver(ctx context.Context, job store.Job, args DeliverArgs) error {
+	delivery, err := s.store.GetWebhookDelivery(ctx, args.DeliveryID)
+	if errors.Is(err, store.ErrNotFound) {
+		// Deleted with its endpoint or purged; nothing is left to send.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load webhook delivery: %w", err)
+	}
+	if delivery.State != StatePending {
+		return nil
+	}
+
+	endpoint, err := s.store.GetWebhookEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		return fmt.Errorf("load webhook endpoint: %w", err)
+	}
+	if !endpoint.Enabled {
+		return s.store.FailWebhookDelivery(ctx, store.FailWebhookDeliveryParams{
+			LastError: ErrEndpointDisabled.Error(),
+			ID:        delivery.ID,
+		})
+	}
+
+	started := time.Now()
+	status, body, sendErr := s.send(ctx, endpoint, delivery)
+	duration := time.Since(started).Milliseconds()
+
+	if ctx.Err() != nil {
+		// Shutdown gave up waiting; the job is released and the attempt
+		// does not count.
+		return ctx.Err()
+	}
+
+	finish := store.FinishWebhookAttemptParams{
+		State:        StateSucceeded,
+		ResponseBody: body,
+		DurationMs:   &duration,
+		ID:           delivery.ID,
+	}
+	if status != 0 {
+		code := int32(status) //nolint:gosec // HTTP status codes fit in int32.
+		finish.ResponseStatus = &code
+	}
+
+	if sendErr == nil {
+		if err := s.store.FinishWebhookAttempt(ctx, finish); err != nil {
+			return jobs.Permanent(fmt.Errorf("record webhook delivery: %w", err))
+		}
+		if err := s.store.RecordWebhookEndpointSuccess(ctx, endpoint.ID); err != nil {
+			slog.WarnContext(ctx, "Failed to reset webhook failure count", "endpoint_id", endpoint.ID, "error", err)
+		}
+		return nil
+	}
+
+	updated, err := s.store.RecordWebhookEndpointFailure(ctx, store.RecordWebhookEndpointFailureParams{
+		DisableAfter: s.config.DisableAfter,
+		Reason:       fmt.Sprintf("%d delivery attempts in a row failed; last error: %v", s.config.DisableAfter, sendErr),
+		ID:           endpoint.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("record webhook failure: %w", err)
+	}
+	disabled := !updated.Enabled
+
+	message := sendErr.Error()
+	finish.LastError = &message
+	finish.State = StatePending
+	if disabled || job.Attempts >= job.MaxAttempts {
+		finish.State = StateFailed
+	}
+	if err := s.store.FinishWebhookAttempt(ctx, finish); err != nil {
+		return fmt.Errorf("record webhook delivery: %w", err)
+	}
+
+	if disabled {
+		if endpoint.Enabled && updated.ConsecutiveFailures == s.config.DisableAfter {
+			slog.WarnContext(ctx, "Disabled failing webhook endpoint",
+				"endpoint_id", endpoint.ID,
+				"url", endpoint.Url,
+				"failures", updated.ConsecutiveFailures)
+		}
+		return jobs.Permanent(sendErr)
+	}
+
+	return sendErr
+}
+
+// send POSTs the delivery's payload and returns the response status and the
+// start of its body. Any status outside 2xx is an error.
+func (s *Sender) send(ctx context.Context, endpoint store.WebhookEndpoint, delivery store.WebhookDelivery) (int, *string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, nil, jobs.Permanent(fmt.Errorf("build request: %w", err))
+	}
+
+	timestamp := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(endpoint.Secret, timestamp, delivery.Payload))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(res.Body, maxResponseBody))
+	if err != nil {
+		return res.StatusCode, nil, fmt.Errorf("read response: %w", err)
+	}
+	body := string(bytes.ToValidUTF8(raw, []byte("�")))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, &body, fmt.Errorf("endpoint responded %s", res.Status)
+	}
+
+	return res.StatusCode, &body, nil
+}
+
+// denyPrivateAddress refuses connections to addresses that are not on the
+// public internet.
+func denyPrivateAddress(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return errPrivateAddress
+	}
+
+	addr := addrPort.Addr().Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return errPrivateAddress
+	}
+
+	return nil
+}
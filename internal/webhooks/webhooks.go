@@ -0,0 +1,244 @@
+// Package webhooks notifies endpoints registered by admins when application
+// events happen. Dispatch records one delivery per subscribed endpoint and
+// queues a background job for each inside the caller's transaction, so a
+// webhook goes out exactly when the change it describes commits. The job
+// POSTs the signed payload, logs the response on the delivery and is retried
+// with the job queue's backoff; an endpoint that keeps failing is disabled.
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dunamismax/go-web-server/internal/jobs"
+	"github.com/dunamismax/go-web-server/internal/store"
+)
+
+// Request headers sent with every delivery.
+const (
+	// HeaderEvent names the event, e.g. user.created.
+	HeaderEvent = "X-Webhook-Event"
+	// HeaderDelivery is the delivery ID; a redelivery gets a new one.
+	HeaderDelivery = "X-Webhook-Delivery"
+	// HeaderTimestamp is the Unix time the request was signed.
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature is "sha256=" and the hex HMAC-SHA256 of the timestamp,
+	// a dot and the body, keyed with the endpoint's secret.
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Delivery states, as stored in webhook_deliveries.state.
+const (
+	StatePending   = "pending"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+)
+
+// MaxAttempts is how many times a delivery is tried before it fails. With
+// the job queue's backoff the last attempt comes about 20 minutes after the
+// first.
+const MaxAttempts = 8
+
+const (
+	signaturePrefix = "sha256="
+	secretPrefix    = "whsec_"
+	secretBytes     = 32
+)
+
+var (
+	// ErrInvalidSignature is returned by Verify when the signature does not
+	// match the body.
+	ErrInvalidSignature = errors.New("webhook signature does not match")
+	// ErrStaleTimestamp is returned by Verify when the request was signed
+	// too long ago, which suggests a replay.
+	ErrStaleTimestamp = errors.New("webhook timestamp is outside the tolerance")
+	// ErrEndpointDisabled is returned by Redeliver for a disabled endpoint.
+	ErrEndpointDisabled = errors.New("webhook endpoint is disabled")
+)
+
+// Payload is the JSON body of every delivery.
+type Payload struct {
+	// ID identifies the event; redeliveries repeat it so receivers can
+	// drop duplicates.
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// DeliverArgs is the job that sends one delivery.
+type DeliverArgs struct {
+	DeliveryID int64 `json:"delivery_id"`
+}
+
+// Kind implements jobs.Args.
+func (DeliverArgs) Kind() string { return "webhook.deliver" }
+
+// Dispatch queues event with data for every enabled endpoint subscribed to
+// it and returns how many deliveries it queued. Pass the transaction's
+// Querier so nothing is sent for a change that rolls back.
+func Dispatch(ctx context.Context, q store.Querier, event string, data any) (int, error) {
+	endpoints, err := q.ListWebhookEndpointsForEvent(ctx, event)
+	if err != nil {
+		return 0, fmt.Errorf("list webhook endpoints for %s: %w", event, err)
+	}
+	if len(endpoints) == 0 {
+		return 0, nil
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return 0, fmt.Errorf("encode %s webhook data: %w", event, err)
+	}
+
+	eventID, err := randomHex(16)
+	if err != nil {
+		return 0, err
+	}
+
+	payload, err := json.Marshal(Payload{
+		ID:        eventID,
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      encoded,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("encode %s webhook payload: %w", event, err)
+	}
+
+	for _, endpoint := range endpoints {
+		delivery, err := q.CreateWebhookDelivery(ctx, store.CreateWebhookDeliveryParams{
+			EndpointID: endpoint.ID,
+			Event:      event,
+			EventID:    eventID,
+			Payload:    payload,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("record %s webhook delivery: %w", event, err)
+		}
+
+		if err := enqueue(ctx, q, delivery.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(endpoints), nil
+}
+
+// Redeliver queues a new delivery of the same event and payload as
+// deliveryID, to the same endpoint.
+func Redeliver(ctx context.Context, q store.Querier, deliveryID int64) (store.WebhookDelivery, error) {
+	original, err := q.GetWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		return store.WebhookDelivery{}, err
+	}
+
+	endpoint, err := q.GetWebhookEndpoint(ctx, original.EndpointID)
+	if err != nil {
+		return store.WebhookDelivery{}, err
+	}
+	if !endpoint.Enabled {
+		return store.WebhookDelivery{}, ErrEndpointDisabled
+	}
+
+	delivery, err := q.CreateWebhookDelivery(ctx, store.CreateWebhookDeliveryParams{
+		EndpointID:   original.EndpointID,
+		Event:        original.Event,
+		EventID:      original.EventID,
+		Payload:      original.Payload,
+		RedeliveryOf: &original.ID,
+	})
+	if err != nil {
+		return store.WebhookDelivery{}, fmt.Errorf("record webhook redelivery: %w", err)
+	}
+
+	if err := enqueue(ctx, q, delivery.ID); err != nil {
+		return store.WebhookDelivery{}, err
+	}
+
+	return delivery, nil
+}
+
+func enqueue(ctx context.Context, q store.Querier, deliveryID int64) error {
+	_, err := jobs.Enqueue(ctx, q, DeliverArgs{DeliveryID: deliveryID}, jobs.EnqueueOptions{MaxAttempts: MaxAttempts})
+	return err
+}
+
+// NewSecret returns a random signing secret for a new endpoint.
+func NewSecret() (string, error) {
+	random, err := randomHex(secretBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return secretPrefix + random, nil
+}
+
+// Sign returns the HeaderSignature value for body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a delivery the way a receiver should: the signature must
+// match body and the timestamp header value must be within tolerance of now.
+func Verify(secret, timestamp, signature string, body []byte, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStaleTimestamp
+	}
+	signedAt := time.Unix(unix, 0)
+
+	if age := time.Since(signedAt); age > tolerance || age < -tolerance {
+		return ErrStaleTimestamp
+	}
+
+	if !hmac.Equal([]byte(Sign(secret, signedAt, body)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// ValidateURL reports whether raw can be registered as an endpoint: an
+// absolute http or https URL without credentials.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("URL is not valid")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("URL must use http or https")
+	}
+	if u.Host == "" || strings.HasPrefix(u.Host, ":") {
+		return errors.New("URL must include a host")
+	}
+	if u.User != nil {
+		return errors.New("URL must not include credentials")
+	}
+
+	return nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random bytes: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,159 @@
+// Package xlsx writes single-sheet Excel workbooks as a stream. Rows go
+// straight into the zip archive as they are written, so a workbook of any
+// size needs no more memory than one row. Every cell is an inline string,
+// which spreadsheet applications never evaluate as a formula.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ContentType is the media type of the workbooks Writer produces.
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// maxSheetName is the longest sheet name Excel accepts.
+const maxSheetName = 31
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// staticParts are the package parts that do not depend on the data.
+var staticParts = []struct {
+	name, body string
+}{
+	{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// ErrClosed is returned when writing to a closed Writer.
+var ErrClosed = errors.New("xlsx: writer is closed")
+
+// Writer writes rows to the only sheet of a workbook. Like csv.Writer it is
+// not safe for concurrent use, and the workbook is incomplete until Close.
+type Writer struct {
+	zip    *zip.Writer
+	sheet  io.Writer
+	closed bool
+}
+
+// NewWriter starts a workbook on w with one sheet called sheetName.
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	archive := zip.NewWriter(w)
+
+	for _, part := range staticParts {
+		if err := writePart(archive, part.name, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	workbook := `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + escape(sheetTitle(sheetName)) + `" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`
+	if err := writePart(archive, "xl/workbook.xml", workbook); err != nil {
+		return nil, err
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xmlHeader+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+
+	return &Writer{zip: archive, sheet: sheet}, nil
+}
+
+// Write appends one row.
+func (w *Writer) Write(record []string) error {
+	if w.closed {
+		return ErrClosed
+	}
+
+	var row strings.Builder
+	row.WriteString("<row>")
+	for _, value := range record {
+		row.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		row.WriteString(escape(value))
+		row.WriteString(`</t></is></c>`)
+	}
+	row.WriteString("</row>")
+
+	_, err := io.WriteString(w.sheet, row.String())
+	return err
+}
+
+// Flush writes buffered data to the underlying writer. Compressed data still
+// held by the archive is written by later rows or Close.
+func (w *Writer) Flush() error {
+	if w.closed {
+		return ErrClosed
+	}
+
+	return w.zip.Flush()
+}
+
+// Close finishes the sheet and the archive. It does not close the underlying
+// writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return ErrClosed
+	}
+	w.closed = true
+
+	if _, err := io.WriteString(w.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+
+	return w.zip.Close()
+}
+
+func writePart(archive *zip.Writer, name, body string) error {
+	part, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(part, xmlHeader+body)
+	return err
+}
+
+// escape escapes text for XML, replacing characters XML cannot hold.
+func escape(text string) string {
+	var escaped strings.Builder
+	_ = xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
+
+// sheetTitle makes name a valid sheet name.
+func sheetTitle(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		return "Sheet1"
+	}
+	if runes := []rune(name); len(runes) > maxSheetName {
+		name = string(runes[:maxSheetName])
+	}
+
+	return name
+}
@@ -0,0 +1,75 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriterProducesWorkbook(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Users: active/all")
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	for _, record := range [][]string{
+		{"id", "name"},
+		{"1", `Ada <"Lovelace"> & co`},
+		{"2", "=HYPERLINK(\"http://example.com\")\x00"},
+	} {
+		if err := w.Write(record); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := w.Write([]string{"late"}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Write() after Close error = %v, want %v", err, ErrClosed)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+
+	parts := make(map[string]string)
+	for _, file := range archive.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", file.Name, err)
+		}
+		parts[file.Name] = string(data)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Fatalf("workbook has no %s part", name)
+		}
+	}
+
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Users_ active_all"`) {
+		t.Fatalf("workbook.xml = %s, want the sanitized sheet name", parts["xl/workbook.xml"])
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	if got := strings.Count(sheet, "<row>"); got != 3 {
+		t.Fatalf("sheet has %d rows, want 3", got)
+	}
+	if !strings.Contains(sheet, "Ada &lt;&#34;Lovelace&#34;&gt; &amp; co") {
+		t.Fatalf("sheet = %s, want escaped text", sheet)
+	}
+	if strings.Contains(sheet, "\x00") || strings.Contains(sheet, "<f>") {
+		t.Fatalf("sheet = %q, want no NUL bytes or formulas", sheet)
+	}
+}
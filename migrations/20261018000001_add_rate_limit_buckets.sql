@@ -0,0 +1,11 @@
+-- Create "rate_limit_buckets" table
+CREATE TABLE "rate_limit_buckets" (
+  "key" text NOT NULL,
+  "tokens" double precision NOT NULL,
+  "allowed" boolean NOT NULL,
+  "updated_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "expires_at" timestamptz NOT NULL,
+  PRIMARY KEY ("key")
+);
+-- Create index "idx_rate_limit_buckets_expires_at" to table: "rate_limit_buckets"
+CREATE INDEX "idx_rate_limit_buckets_expires_at" ON "rate_limit_buckets" ("expires_at");
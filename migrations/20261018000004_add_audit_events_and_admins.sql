@@ -0,0 +1,31 @@
+-- Modify "users" table
+ALTER TABLE "users" ADD COLUMN "is_admin" boolean NOT NULL DEFAULT false;
+-- Create "audit_events" table
+CREATE TABLE "audit_events" (
+  "id" bigserial NOT NULL,
+  "occurred_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "actor_id" bigint NULL,
+  "action" text NOT NULL,
+  "target_type" text NULL,
+  "target_id" bigint NULL,
+  "ip" text NULL,
+  "user_agent" text NULL,
+  "request_id" text NULL,
+  "before" jsonb NULL,
+  "after" jsonb NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_audit_events_action" to table: "audit_events"
+CREATE INDEX "idx_audit_events_action" ON "audit_events" ("action", "id");
+-- Create index "idx_audit_events_actor_id" to table: "audit_events"
+CREATE INDEX "idx_audit_events_actor_id" ON "audit_events" ("actor_id", "id");
+-- Create index "idx_audit_events_target" to table: "audit_events"
+CREATE INDEX "idx_audit_events_target" ON "audit_events" ("target_type", "target_id", "id");
+-- Create "audit_events_append_only" function
+CREATE FUNCTION "audit_events_append_only" () RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$;
+-- Create trigger "audit_events_append_only"
+CREATE TRIGGER "audit_events_append_only" BEFORE UPDATE OR DELETE ON "audit_events" FOR EACH ROW EXECUTE FUNCTION "audit_events_append_only"();
@@ -0,0 +1,27 @@
+-- Create "jobs" table
+CREATE TABLE "jobs" (
+  "id" bigserial NOT NULL,
+  "kind" text NOT NULL,
+  "payload" jsonb NOT NULL DEFAULT '{}',
+  "state" text NOT NULL DEFAULT 'pending',
+  "attempts" integer NOT NULL DEFAULT 0,
+  "max_attempts" integer NOT NULL DEFAULT 5,
+  "unique_key" text NULL,
+  "run_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "locked_at" timestamptz NULL,
+  "locked_by" text NULL,
+  "last_error" text NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "finished_at" timestamptz NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "jobs_state_check" CHECK (state = ANY (ARRAY['pending'::text, 'running'::text, 'succeeded'::text, 'dead'::text, 'cancelled'::text]))
+);
+-- Create index "idx_jobs_pending" to table: "jobs"
+CREATE INDEX "idx_jobs_pending" ON "jobs" ("run_at", "id") WHERE (state = 'pending'::text);
+-- Create index "idx_jobs_running" to table: "jobs"
+CREATE INDEX "idx_jobs_running" ON "jobs" ("locked_at") WHERE (state = 'running'::text);
+-- Create index "idx_jobs_state" to table: "jobs"
+CREATE INDEX "idx_jobs_state" ON "jobs" ("state", "id");
+-- Create index "jobs_unique_key" to table: "jobs"
+CREATE UNIQUE INDEX "jobs_unique_key" ON "jobs" ("unique_key") WHERE ((unique_key IS NOT NULL) AND (state = ANY (ARRAY['pending'::text, 'running'::text])));
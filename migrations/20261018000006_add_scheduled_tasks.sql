@@ -0,0 +1,29 @@
+-- Modify "audit_events_append_only" function
+CREATE OR REPLACE FUNCTION "audit_events_append_only" () RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+  IF TG_OP = 'DELETE' AND current_setting('app.audit_purge', true) = 'on' THEN
+    RETURN OLD;
+  END IF;
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$;
+-- Create index "idx_audit_events_occurred_at" to table: "audit_events"
+CREATE INDEX "idx_audit_events_occurred_at" ON "audit_events" ("occurred_at");
+-- Create index "idx_jobs_finished_at" to table: "jobs"
+CREATE INDEX "idx_jobs_finished_at" ON "jobs" ("finished_at") WHERE (finished_at IS NOT NULL);
+-- Create "scheduled_tasks" table
+CREATE TABLE "scheduled_tasks" (
+  "name" text NOT NULL,
+  "schedule" text NOT NULL,
+  "last_tick" timestamptz NULL,
+  "last_started_at" timestamptz NULL,
+  "last_finished_at" timestamptz NULL,
+  "last_status" text NULL,
+  "last_error" text NULL,
+  "last_duration_ms" bigint NULL,
+  "next_run_at" timestamptz NULL,
+  "runs" bigint NOT NULL DEFAULT 0,
+  "failures" bigint NOT NULL DEFAULT 0,
+  "updated_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("name")
+);
@@ -0,0 +1,10 @@
+-- Create "event_payloads" table
+CREATE TABLE "event_payloads" (
+  "id" bigserial NOT NULL,
+  "topic" text NOT NULL,
+  "data" jsonb NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id")
+);
+-- Create index "idx_event_payloads_created_at" to table: "event_payloads"
+CREATE INDEX "idx_event_payloads_created_at" ON "event_payloads" ("created_at");
@@ -0,0 +1,40 @@
+-- Create "webhook_endpoints" table
+CREATE TABLE "webhook_endpoints" (
+  "id" bigserial NOT NULL,
+  "url" text NOT NULL,
+  "description" text NULL,
+  "secret" text NOT NULL,
+  "events" text[] NOT NULL DEFAULT '{}',
+  "enabled" boolean NOT NULL DEFAULT true,
+  "consecutive_failures" integer NOT NULL DEFAULT 0,
+  "disabled_reason" text NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("id")
+);
+-- Create "webhook_deliveries" table
+CREATE TABLE "webhook_deliveries" (
+  "id" bigserial NOT NULL,
+  "endpoint_id" bigint NOT NULL,
+  "event" text NOT NULL,
+  "event_id" text NOT NULL,
+  "payload" jsonb NOT NULL,
+  "state" text NOT NULL DEFAULT 'pending',
+  "attempts" integer NOT NULL DEFAULT 0,
+  "response_status" integer NULL,
+  "response_body" text NULL,
+  "last_error" text NULL,
+  "duration_ms" bigint NULL,
+  "redelivery_of" bigint NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "last_attempt_at" timestamptz NULL,
+  "delivered_at" timestamptz NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "webhook_deliveries_endpoint_id_fkey" FOREIGN KEY ("endpoint_id") REFERENCES "webhook_endpoints" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "webhook_deliveries_redelivery_of_fkey" FOREIGN KEY ("redelivery_of") REFERENCES "webhook_deliveries" ("id") ON UPDATE NO ACTION ON DELETE SET NULL,
+  CONSTRAINT "webhook_deliveries_state_check" CHECK (state = ANY (ARRAY['pending'::text, 'succeeded'::text, 'failed'::text]))
+);
+-- Create index "idx_webhook_deliveries_endpoint" to table: "webhook_deliveries"
+CREATE INDEX "idx_webhook_deliveries_endpoint" ON "webhook_deliveries" ("endpoint_id", "id");
+-- Create index "idx_webhook_deliveries_created_at" to table: "webhook_deliveries"
+CREATE INDEX "idx_webhook_deliveries_created_at" ON "webhook_deliveries" ("created_at");
@@ -0,0 +1,31 @@
+-- Create "user_imports" table
+CREATE TABLE "user_imports" (
+  "id" bigserial NOT NULL,
+  "created_by" bigint NULL,
+  "filename" text NOT NULL,
+  "format" text NOT NULL,
+  "mode" text NOT NULL,
+  "dry_run" boolean NOT NULL DEFAULT false,
+  "state" text NOT NULL DEFAULT 'pending',
+  "total_rows" integer NOT NULL DEFAULT 0,
+  "processed_rows" integer NOT NULL DEFAULT 0,
+  "created_rows" integer NOT NULL DEFAULT 0,
+  "failed_rows" integer NOT NULL DEFAULT 0,
+  "row_errors" jsonb NOT NULL DEFAULT '[]',
+  "last_error" text NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "started_at" timestamptz NULL,
+  "finished_at" timestamptz NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "user_imports_created_by_fkey" FOREIGN KEY ("created_by") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE SET NULL,
+  CONSTRAINT "user_imports_format_check" CHECK (format = ANY (ARRAY['csv'::text, 'ndjson'::text])),
+  CONSTRAINT "user_imports_mode_check" CHECK (mode = ANY (ARRAY['atomic'::text, 'best_effort'::text])),
+  CONSTRAINT "user_imports_state_check" CHECK (state = ANY (ARRAY['pending'::text, 'running'::text, 'succeeded'::text, 'failed'::text]))
+);
+-- Create "user_import_files" table
+CREATE TABLE "user_import_files" (
+  "import_id" bigint NOT NULL,
+  "data" bytea NOT NULL,
+  PRIMARY KEY ("import_id"),
+  CONSTRAINT "user_import_files_import_id_fkey" FOREIGN KEY ("import_id") REFERENCES "user_imports" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
@@ -0,0 +1,37 @@
+-- Create "data_exports" table
+CREATE TABLE "data_exports" (
+  "id" bigserial NOT NULL,
+  "user_id" bigint NOT NULL,
+  "format" text NOT NULL,
+  "state" text NOT NULL DEFAULT 'pending',
+  "size_bytes" bigint NOT NULL DEFAULT 0,
+  "last_error" text NULL,
+  "created_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "finished_at" timestamptz NULL,
+  "expires_at" timestamptz NULL,
+  PRIMARY KEY ("id"),
+  CONSTRAINT "data_exports_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE,
+  CONSTRAINT "data_exports_format_check" CHECK (format = ANY (ARRAY['zip'::text, 'json'::text])),
+  CONSTRAINT "data_exports_state_check" CHECK (state = ANY (ARRAY['pending'::text, 'running'::text, 'succeeded'::text, 'failed'::text]))
+);
+-- Create index "idx_data_exports_user_id" to table: "data_exports"
+CREATE INDEX "idx_data_exports_user_id" ON "data_exports" ("user_id", "id");
+-- Create index "idx_data_exports_expires_at" to table: "data_exports"
+CREATE INDEX "idx_data_exports_expires_at" ON "data_exports" ("expires_at") WHERE (expires_at IS NOT NULL);
+-- Create "data_export_files" table
+CREATE TABLE "data_export_files" (
+  "export_id" bigint NOT NULL,
+  "data" bytea NOT NULL,
+  PRIMARY KEY ("export_id"),
+  CONSTRAINT "data_export_files_export_id_fkey" FOREIGN KEY ("export_id") REFERENCES "data_exports" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create "account_deletions" table
+CREATE TABLE "account_deletions" (
+  "user_id" bigint NOT NULL,
+  "requested_at" timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "delete_after" timestamptz NOT NULL,
+  PRIMARY KEY ("user_id"),
+  CONSTRAINT "account_deletions_user_id_fkey" FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON UPDATE NO ACTION ON DELETE CASCADE
+);
+-- Create index "idx_account_deletions_delete_after" to table: "account_deletions"
+CREATE INDEX "idx_account_deletions_delete_after" ON "account_deletions" ("delete_after");
@@ -0,0 +1,48 @@
+// Package migrations embeds the Atlas migration files so the running binary
+// knows which schema version it expects.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Files holds the versioned PostgreSQL migration files.
+//
+//go:embed *.sql
+var Files embed.FS
+
+// SQLiteFiles holds the versioned SQLite migration files under sqlite/.
+//
+//go:embed sqlite/*.sql
+var SQLiteFiles embed.FS
+
+// LatestVersion returns the version of the newest PostgreSQL migration, which
+// is the file name prefix before the first underscore, as Atlas records it.
+func LatestVersion() (string, error) {
+	return latestVersion(Files, "*.sql")
+}
+
+// LatestSQLiteVersion returns the version of the newest SQLite migration.
+func LatestSQLiteVersion() (string, error) {
+	return latestVersion(SQLiteFiles, "sqlite/*.sql")
+}
+
+func latestVersion(files fs.FS, pattern string) (string, error) {
+	names, err := fs.Glob(files, pattern)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "", errors.New("no migration files embedded")
+	}
+
+	sort.Strings(names)
+	version, _, _ := strings.Cut(path.Base(names[len(names)-1]), "_")
+
+	return version, nil
+}
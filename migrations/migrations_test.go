@@ -0,0 +1,27 @@
+package migrations
+
+import "testing"
+
+func TestLatestVersionUsesNewestMigration(t *testing.T) {
+	t.Parallel()
+
+	version, err := LatestVersion()
+	if err != nil {
+		t.Fatalf("LatestVersion() error = %v", err)
+	}
+	if len(version) != 14 {
+		t.Fatalf("LatestVersion() = %q, want a 14-digit Atlas version", version)
+	}
+}
+
+func TestLatestSQLiteVersionUsesNewestMigration(t *testing.T) {
+	t.Parallel()
+
+	version, err := LatestSQLiteVersion()
+	if err != nil {
+		t.Fatalf("LatestSQLiteVersion() error = %v", err)
+	}
+	if len(version) != 14 {
+		t.Fatalf("LatestSQLiteVersion() = %q, want a 14-digit Atlas version", version)
+	}
+}
@@ -0,0 +1,34 @@
+-- Create "users" table
+CREATE TABLE users (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    avatar_url TEXT,
+    bio TEXT,
+    password_hash TEXT NOT NULL,
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+-- Create index "idx_users_active" to table: "users"
+CREATE INDEX idx_users_active ON users(is_active);
+-- Create "sessions" table
+CREATE TABLE sessions (
+    token TEXT NOT NULL PRIMARY KEY,
+    data BLOB NOT NULL,
+    expiry REAL NOT NULL
+);
+-- Create index "idx_sessions_expiry" to table: "sessions"
+CREATE INDEX idx_sessions_expiry ON sessions(expiry);
+-- Create "rate_limit_buckets" table
+CREATE TABLE rate_limit_buckets (
+    key TEXT NOT NULL PRIMARY KEY,
+    tokens REAL NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    capacity REAL NOT NULL,
+    refill_per_second REAL NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL
+);
+-- Create index "idx_rate_limit_buckets_expires_at" to table: "rate_limit_buckets"
+CREATE INDEX idx_rate_limit_buckets_expires_at ON rate_limit_buckets(expires_at);
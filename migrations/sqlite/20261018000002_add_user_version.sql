@@ -0,0 +1,2 @@
+-- Add column "version" to table: "users"
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
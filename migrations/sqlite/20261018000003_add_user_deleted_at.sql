@@ -0,0 +1,4 @@
+-- Add column "deleted_at" to table: "users"
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+-- Create index "idx_users_deleted_at" to table: "users"
+CREATE INDEX idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,32 @@
+-- Add column "is_admin" to table: "users"
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+-- Create "audit_events" table
+CREATE TABLE audit_events (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    occurred_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor_id INTEGER,
+    action TEXT NOT NULL,
+    target_type TEXT,
+    target_id INTEGER,
+    ip TEXT,
+    user_agent TEXT,
+    request_id TEXT,
+    before BLOB,
+    after BLOB
+);
+-- Create index "idx_audit_events_action" to table: "audit_events"
+CREATE INDEX idx_audit_events_action ON audit_events(action, id);
+-- Create index "idx_audit_events_actor_id" to table: "audit_events"
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id, id);
+-- Create index "idx_audit_events_target" to table: "audit_events"
+CREATE INDEX idx_audit_events_target ON audit_events(target_type, target_id, id);
+-- Create trigger "audit_events_no_update"
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+-- Create trigger "audit_events_no_delete"
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
@@ -0,0 +1,26 @@
+-- Create "jobs" table
+CREATE TABLE jobs (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    payload BLOB NOT NULL DEFAULT '{}',
+    state TEXT NOT NULL DEFAULT 'pending'
+        CONSTRAINT jobs_state_check CHECK (state IN ('pending', 'running', 'succeeded', 'dead', 'cancelled')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    unique_key TEXT,
+    run_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_at DATETIME,
+    locked_by TEXT,
+    last_error TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME
+);
+-- Create index "idx_jobs_pending" to table: "jobs"
+CREATE INDEX idx_jobs_pending ON jobs(run_at, id) WHERE state = 'pending';
+-- Create index "idx_jobs_running" to table: "jobs"
+CREATE INDEX idx_jobs_running ON jobs(locked_at) WHERE state = 'running';
+-- Create index "idx_jobs_state" to table: "jobs"
+CREATE INDEX idx_jobs_state ON jobs(state, id);
+-- Create index "jobs_unique_key" to table: "jobs"
+CREATE UNIQUE INDEX jobs_unique_key ON jobs(unique_key) WHERE unique_key IS NOT NULL AND state IN ('pending', 'running');
@@ -0,0 +1,31 @@
+-- Create index "idx_audit_events_occurred_at" to table: "audit_events"
+CREATE INDEX idx_audit_events_occurred_at ON audit_events(occurred_at);
+-- Create "audit_purge" table
+CREATE TABLE audit_purge (
+    allowed BOOLEAN NOT NULL
+);
+-- Drop trigger "audit_events_no_delete"
+DROP TRIGGER audit_events_no_delete;
+-- Create trigger "audit_events_no_delete"
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+WHEN NOT EXISTS (SELECT 1 FROM audit_purge)
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+-- Create index "idx_jobs_finished_at" to table: "jobs"
+CREATE INDEX idx_jobs_finished_at ON jobs(finished_at) WHERE finished_at IS NOT NULL;
+-- Create "scheduled_tasks" table
+CREATE TABLE scheduled_tasks (
+    name TEXT NOT NULL PRIMARY KEY,
+    schedule TEXT NOT NULL,
+    last_tick DATETIME,
+    last_started_at DATETIME,
+    last_finished_at DATETIME,
+    last_status TEXT,
+    last_error TEXT,
+    last_duration_ms INTEGER,
+    next_run_at DATETIME,
+    runs INTEGER NOT NULL DEFAULT 0,
+    failures INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,9 @@
+-- Create "event_payloads" table
+CREATE TABLE event_payloads (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    topic TEXT NOT NULL,
+    data BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- Create index "idx_event_payloads_created_at" to table: "event_payloads"
+CREATE INDEX idx_event_payloads_created_at ON event_payloads(created_at);
@@ -0,0 +1,27 @@
+-- Create "user_imports" table
+CREATE TABLE user_imports (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    filename TEXT NOT NULL,
+    format TEXT NOT NULL
+        CONSTRAINT user_imports_format_check CHECK (format IN ('csv', 'ndjson')),
+    mode TEXT NOT NULL
+        CONSTRAINT user_imports_mode_check CHECK (mode IN ('atomic', 'best_effort')),
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    state TEXT NOT NULL DEFAULT 'pending'
+        CONSTRAINT user_imports_state_check CHECK (state IN ('pending', 'running', 'succeeded', 'failed')),
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    created_rows INTEGER NOT NULL DEFAULT 0,
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    row_errors BLOB NOT NULL DEFAULT '[]',
+    last_error TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME,
+    finished_at DATETIME
+);
+-- Create "user_import_files" table
+CREATE TABLE user_import_files (
+    import_id INTEGER NOT NULL PRIMARY KEY REFERENCES user_imports(id) ON DELETE CASCADE,
+    data BLOB NOT NULL
+);
@@ -0,0 +1,31 @@
+-- Create "data_exports" table
+CREATE TABLE data_exports (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format TEXT NOT NULL
+        CONSTRAINT data_exports_format_check CHECK (format IN ('zip', 'json')),
+    state TEXT NOT NULL DEFAULT 'pending'
+        CONSTRAINT data_exports_state_check CHECK (state IN ('pending', 'running', 'succeeded', 'failed')),
+    size_bytes INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    expires_at DATETIME
+);
+-- Create index "idx_data_exports_user_id" to table: "data_exports"
+CREATE INDEX idx_data_exports_user_id ON data_exports(user_id, id);
+-- Create index "idx_data_exports_expires_at" to table: "data_exports"
+CREATE INDEX idx_data_exports_expires_at ON data_exports(expires_at) WHERE expires_at IS NOT NULL;
+-- Create "data_export_files" table
+CREATE TABLE data_export_files (
+    export_id INTEGER NOT NULL PRIMARY KEY REFERENCES data_exports(id) ON DELETE CASCADE,
+    data BLOB NOT NULL
+);
+-- Create "account_deletions" table
+CREATE TABLE account_deletions (
+    user_id INTEGER NOT NULL PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    requested_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delete_after DATETIME NOT NULL
+);
+-- Create index "idx_account_deletions_delete_after" to table: "account_deletions"
+CREATE INDEX idx_account_deletions_delete_after ON account_deletions(delete_after);